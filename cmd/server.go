@@ -2,22 +2,36 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	firebase "firebase.google.com/go"
 	"github.com/demirbey05/auth-demo/controllers/core"
 	"github.com/demirbey05/auth-demo/db"
+	pipeline "github.com/demirbey05/auth-demo/internal/core"
+	"github.com/demirbey05/auth-demo/internal/llm"
+	"github.com/demirbey05/auth-demo/internal/store"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"google.golang.org/api/option"
 )
 
 type Server struct {
-	url     string
-	conn    *pgxpool.Pool
-	queries *db.Queries
-	routers *gin.Engine
-	app     *firebase.App
+	url      string
+	conn     *pgxpool.Pool
+	queries  *db.Queries
+	routers  *gin.Engine
+	app      *firebase.App
+	workers  *pipeline.WorkerPool
+	webhooks *pipeline.WebhookDispatcher
+	events   *pipeline.EventBroker
+	media    *pipeline.MediaStore
 }
 
 func NewServer() *Server {
@@ -33,12 +47,107 @@ func NewServer() *Server {
 	if err != nil {
 		panic(err)
 	}
-	return &Server{url: url, routers: r, app: fireApp, conn: conn, queries: queries}
+	provider, err := llm.New(context.Background(), llm.ConfigFromEnv())
+	if err != nil {
+		panic(err)
+	}
+	videoTranscripts, err := pipeline.NewTranscriptProvider(pipeline.TranscriptConfigFromEnv())
+	if err != nil {
+		panic(err)
+	}
+	transcripts := pipeline.SourceTranscripts{Video: videoTranscripts}
+	// Uploads are only accepted when there is a speech-to-text server
+	var media *pipeline.MediaStore
+	if speechConfig := pipeline.SpeechToTextConfigFromEnv(); speechConfig.URL != "" {
+		speech, err := pipeline.NewWhisperTranscriber(speechConfig)
+		if err != nil {
+			panic(err)
+		}
+		media, err = pipeline.NewMediaStore(os.Getenv("MEDIA_DIR"), int64(envInt("MEDIA_MAX_MB", 500))<<20)
+		if err != nil {
+			panic(err)
+		}
+		transcripts.Media = pipeline.NewMediaTranscriber(media, speech)
+	}
+	prompts := pipeline.NewPromptRegistry(store.NewDBPromptStore(queries), envDuration("PROMPT_REFRESH_INTERVAL", time.Minute))
+	events := pipeline.NewEventBroker()
+	workers := pipeline.NewWorkerPool(
+		pipeline.NewPipeline(store.NewDBPodStore(queries), store.NewDBUsageStore(queries), store.NewDBWebhookStore(queries), store.NewDBLLMCallStore(queries), provider, transcripts, prompts, events, pipeline.RetryPolicy{
+			MaxAttempts: envInt("JOB_MAX_ATTEMPTS", 3),
+			BaseDelay:   envDuration("JOB_RETRY_BASE_DELAY", 2*time.Second),
+			MaxDelay:    envDuration("JOB_RETRY_MAX_DELAY", time.Minute),
+		}),
+		store.NewDBJobStore(queries),
+		envInt("WORKER_COUNT", 4),
+		envDuration("WORKER_POLL_INTERVAL", 2*time.Second),
+		// The stale timeout must exceed the job timeout so jobs still
+		// running on other instances are not touched.
+		pipeline.StalePolicy{
+			After:     envDuration("JOB_STALE_TIMEOUT", 20*time.Minute),
+			MaxClaims: envInt("JOB_MAX_CLAIMS", 3),
+			Interval:  envDuration("JOB_STALE_SWEEP_INTERVAL", 5*time.Minute),
+		},
+	)
+	webhooks := pipeline.NewWebhookDispatcher(store.NewDBWebhookStore(queries), pipeline.RetryPolicy{
+		MaxAttempts: envInt("WEBHOOK_MAX_ATTEMPTS", 6),
+		BaseDelay:   envDuration("WEBHOOK_RETRY_BASE_DELAY", 30*time.Second),
+		MaxDelay:    envDuration("WEBHOOK_RETRY_MAX_DELAY", time.Hour),
+	}, envDuration("WEBHOOK_POLL_INTERVAL", 5*time.Second))
+	return &Server{url: url, routers: r, app: fireApp, conn: conn, queries: queries, workers: workers, webhooks: webhooks, events: events, media: media}
 }
 func (s *Server) Run() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	// Add routers
 	s.addRoutes()
-	s.routers.Run(s.url)
+
+	// Jobs left running by a previous process would otherwise stay stuck
+	// until the first periodic sweep.
+	recoverCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	if err := s.workers.Recover(recoverCtx); err != nil {
+		fmt.Println(err)
+	}
+	cancel()
+	s.workers.Start()
+	s.webhooks.Start()
+
+	srv := &http.Server{Addr: s.address(), Handler: s.routers}
+	srv.RegisterOnShutdown(s.events.Close)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			panic(err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	fmt.Println("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), envDuration("SHUTDOWN_TIMEOUT", 30*time.Second))
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		fmt.Println(err)
+	}
+	if err := s.workers.Shutdown(shutdownCtx); err != nil {
+		fmt.Println(err)
+	}
+	// Deliveries still pending are sent by the next process
+	if err := s.webhooks.Shutdown(shutdownCtx); err != nil {
+		fmt.Println(err)
+	}
+	s.conn.Close()
+}
+
+// address mirrors gin's default when SERVICE_URL is unset.
+func (s *Server) address() string {
+	if s.url != "" {
+		return s.url
+	}
+	if port := os.Getenv("PORT"); port != "" {
+		return ":" + port
+	}
+	return ":8080"
 }
 func initStores(postgresUrl string) (*pgxpool.Pool, *db.Queries, error) {
 
@@ -52,5 +161,21 @@ func initStores(postgresUrl string) (*pgxpool.Pool, *db.Queries, error) {
 }
 
 func (s *Server) addRoutes() {
-	core.InitCore(s.routers, s.conn, s.queries, s.app)
+	core.InitCore(s.routers, s.conn, s.queries, s.app, s.events, s.media)
+}
+
+func envInt(key string, fallback int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || v < 0 {
+		return fallback
+	}
+	return v
 }
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/demirbey05/auth-demo/db"
+	"github.com/demirbey05/auth-demo/internal/core"
+	"github.com/demirbey05/auth-demo/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func listDeadLetterJobs(c *gin.Context, queries *db.Queries) {
+	limit, offset, ok := pagination(c)
+	if !ok {
+		c.JSON(400, gin.H{"error": "invalid pagination"})
+		return
+	}
+
+	jobStore := store.NewDBJobStore(queries)
+	jobs, err := jobStore.ListJobs(c.Request.Context(), core.StageDeadLetter, limit, offset)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(200, gin.H{"jobs": jobs})
+}
+
+func requeueJob(c *gin.Context, conn *pgxpool.Pool, queries *db.Queries) {
+	var jobID int
+	if _, err := fmt.Sscan(c.Param("job_id"), &jobID); err != nil {
+		c.JSON(400, gin.H{"error": "invalid job_id"})
+		return
+	}
+
+	tx, err := conn.Begin(c)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+	defer tx.Rollback(c)
+
+	jobStore := store.NewDBJobStore(queries.WithTx(tx))
+	err = jobStore.RequeueJob(c.Request.Context(), jobID, []string{core.StageDeadLetter, core.StageFailed}, core.StageQueued)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNoJob):
+			c.JSON(409, gin.H{"error": "job is not in a failed state"})
+		case errors.Is(err, store.ErrJobSuperseded):
+			c.JSON(409, gin.H{"error": err.Error()})
+		default:
+			fmt.Println(err)
+			c.JSON(500, gin.H{"error": "internal error"})
+		}
+		return
+	}
+
+	if err := tx.Commit(c); err != nil {
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "job requeued"})
+}
+
+func addUserCredit(c *gin.Context, queries *db.Queries) {
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+		Amount int    `json:"amount" binding:"required"`
+		Reason string `json:"reason" binding:"required"`
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "bind error"})
+		return
+	}
+	switch req.Reason {
+	case store.CreditAdminAdjustment:
+	case store.CreditPurchase:
+		if req.Amount < 0 {
+			c.JSON(400, gin.H{"error": "purchase amount must be positive"})
+			return
+		}
+	default:
+		c.JSON(400, gin.H{"error": "invalid reason"})
+		return
+	}
+
+	usageStore := store.NewDBUsageStore(queries)
+	remaining, err := usageStore.AddCredit(c.Request.Context(), req.UserID, req.Amount, req.Reason, req.Note)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(200, gin.H{"remaining_credit": remaining})
+}
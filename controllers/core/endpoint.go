@@ -2,16 +2,20 @@ package core
 
 import (
 	"os"
+	"strconv"
 
 	firebase "firebase.google.com/go"
 	"github.com/demirbey05/auth-demo/controllers/middleware"
 	"github.com/demirbey05/auth-demo/db"
+	"github.com/demirbey05/auth-demo/internal/core"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func InitCore(g *gin.Engine, conn *pgxpool.Pool, queries *db.Queries, app *firebase.App) {
+// InitCore registers the API routes. media is nil when uploads are disabled,
+// which leaves out the upload route.
+func InitCore(g *gin.Engine, conn *pgxpool.Pool, queries *db.Queries, app *firebase.App, events *core.EventBroker, media *core.MediaStore) {
 	// Configure CORS with FRONTEND_URL
 	frontendURL := os.Getenv("FRONTEND_URL")
 	config := cors.Config{
@@ -30,15 +34,35 @@ func InitCore(g *gin.Engine, conn *pgxpool.Pool, queries *db.Queries, app *fireb
 	protected.POST("/create-pod", func(ctx *gin.Context) {
 		createNewPod(ctx, conn, queries)
 	})
+	if media != nil {
+		protected.POST("/create-pod/upload", func(ctx *gin.Context) {
+			uploadPod(ctx, conn, queries, media)
+		})
+	}
 	protected.POST("/pods/share/:pod_id", func(ctx *gin.Context) {
 		sharePod(ctx, conn, queries)
 	})
+	protected.POST("/pods/:pod_id/retry", func(ctx *gin.Context) {
+		retryPod(ctx, conn, queries, media)
+	})
 	protected.GET("/pods/:pod_id/article", func(ctx *gin.Context) {
 		getArticle(ctx, conn, queries)
 	})
+	protected.GET("/pods/:pod_id/article/export", func(ctx *gin.Context) {
+		exportArticle(ctx, queries)
+	})
+	protected.GET("/pods/:pod_id/transcript", func(ctx *gin.Context) {
+		getTranscript(ctx, queries)
+	})
 	protected.GET("/credits", func(c *gin.Context) {
 		getRemainingCredits(c, queries)
 	})
+	protected.GET("/credits/history", func(c *gin.Context) {
+		getCreditHistory(c, queries)
+	})
+	protected.GET("/usage/llm", func(c *gin.Context) {
+		getLLMUsage(c, queries)
+	})
 	protected.POST("/feedback", func(ctx *gin.Context) {
 		insertFeedback(ctx, conn, queries)
 	})
@@ -49,4 +73,70 @@ func InitCore(g *gin.Engine, conn *pgxpool.Pool, queries *db.Queries, app *fireb
 	protected.GET("/pods/:pod_id/quiz", func(ctx *gin.Context) {
 		getQuiz(ctx, conn, queries)
 	})
+	protected.GET("/pods/:pod_id/status", func(ctx *gin.Context) {
+		getPodStatus(ctx, conn, queries)
+	})
+	protected.GET("/pods/:pod_id/events", func(ctx *gin.Context) {
+		streamPodEvents(ctx, queries, events)
+	})
+	protected.GET("/jobs/:job_id", func(ctx *gin.Context) {
+		getJobStatus(ctx, conn, queries)
+	})
+	protected.POST("/webhooks", func(ctx *gin.Context) {
+		createWebhook(ctx, queries)
+	})
+	protected.GET("/webhooks", func(ctx *gin.Context) {
+		listWebhooks(ctx, queries)
+	})
+	protected.DELETE("/webhooks/:webhook_id", func(ctx *gin.Context) {
+		deleteWebhook(ctx, queries)
+	})
+	protected.GET("/webhooks/:webhook_id/deliveries", func(ctx *gin.Context) {
+		listWebhookDeliveries(ctx, queries)
+	})
+
+	admin := v1.Group("/admin")
+	admin.Use(middleware.FirebaseAuthMiddleware(app), middleware.AdminMiddleware())
+
+	admin.GET("/jobs/dead-letter", func(ctx *gin.Context) {
+		listDeadLetterJobs(ctx, queries)
+	})
+	admin.POST("/jobs/:job_id/requeue", func(ctx *gin.Context) {
+		requeueJob(ctx, conn, queries)
+	})
+	admin.POST("/credits", func(ctx *gin.Context) {
+		addUserCredit(ctx, queries)
+	})
+	admin.GET("/usage/llm", func(ctx *gin.Context) {
+		getAdminLLMUsage(ctx, queries)
+	})
+	admin.GET("/prompts", func(ctx *gin.Context) {
+		listPrompts(ctx, queries)
+	})
+	admin.POST("/prompts", func(ctx *gin.Context) {
+		createPrompt(ctx, queries)
+	})
+	admin.PATCH("/prompts/:prompt_id", func(ctx *gin.Context) {
+		setPromptWeight(ctx, queries)
+	})
+}
+
+// pagination reads limit and offset query parameters, defaulting to the first 50 rows.
+func pagination(c *gin.Context) (int, int, bool) {
+	limit, offset := 50, 0
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 200 {
+			return 0, 0, false
+		}
+		limit = n
+	}
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, false
+		}
+		offset = n
+	}
+	return limit, offset, true
 }
@@ -0,0 +1,182 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/demirbey05/auth-demo/db"
+	"github.com/demirbey05/auth-demo/internal/core"
+	"github.com/demirbey05/auth-demo/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// streamPodEvents pushes pipeline progress for a pod as Server-Sent Events.
+// The current state is sent first, then live transitions until the job ends.
+// The job is re-read periodically so progress made by workers on other
+// instances still reaches the client.
+func streamPodEvents(c *gin.Context, queries *db.Queries, events *core.EventBroker) {
+	var podID int
+	if _, err := fmt.Sscan(c.Param("pod_id"), &podID); err != nil {
+		c.JSON(400, gin.H{"error": "invalid pod_id"})
+		return
+	}
+	userID := c.GetString("uuid")
+	if userID == "" {
+		c.JSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	podStore := store.NewDBPodStore(queries)
+	isOwner, err := podStore.IsPodOwner(c.Request.Context(), podID, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(404, gin.H{"error": "pod not found"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+	if !isOwner {
+		c.JSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	// Subscribe before reading the snapshot so no transition falls in between
+	sub, unsubscribe := events.Subscribe(podID)
+	defer unsubscribe()
+
+	snapshot, err := podSnapshot(c, podStore, podID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(404, gin.H{"error": "job not found"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	// send writes an event unless the client has already seen it and reports
+	// whether the stream is finished.
+	var lastStage string
+	lastChunks := -1
+	var articleSent bool
+	send := func(event core.PodEvent) bool {
+		switch event.Type {
+		case core.EventStage:
+			if event.Stage == lastStage {
+				return false
+			}
+			lastStage = event.Stage
+		case core.EventProgress:
+			if event.ChunksDone <= lastChunks {
+				return false
+			}
+			lastChunks = event.ChunksDone
+		case core.EventArticle:
+			if articleSent {
+				return false
+			}
+			articleSent = true
+		}
+		c.SSEvent(event.Type, event)
+		c.Writer.Flush()
+		return isFinalEvent(event)
+	}
+	sendAll := func(events []core.PodEvent) bool {
+		for _, event := range events {
+			if send(event) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if sendAll(snapshot) {
+		return
+	}
+
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-sub:
+			if !ok {
+				// The server is shutting down
+				return
+			}
+			if send(event) {
+				return
+			}
+		case <-ticker.C:
+			current, err := podSnapshot(c, podStore, podID)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if sendAll(current) {
+				return
+			}
+			// Keep idle proxies from closing the stream
+			c.SSEvent("ping", "")
+			c.Writer.Flush()
+		}
+	}
+}
+
+// podSnapshot describes the stored state of a pod as the events a subscriber
+// would have seen so far: the current stage, chunk progress of a long
+// transcript, the article once it exists, and the final outcome if the job is
+// finished.
+func podSnapshot(c *gin.Context, podStore store.PodStore, podID int) ([]core.PodEvent, error) {
+	status, err := podStore.GetJobStatusByPodID(c.Request.Context(), podID)
+	if err != nil {
+		return nil, err
+	}
+	articleID, quizID, err := podStore.GetPodArtifactIDs(c.Request.Context(), podID)
+	if err != nil {
+		return nil, err
+	}
+
+	base := core.PodEvent{PodID: podID, JobID: status.ID, Stage: status.Stage, ArticleID: articleID}
+	switch status.Stage {
+	case core.StageDone:
+		base.Type = core.EventDone
+		base.QuizID = quizID
+		return []core.PodEvent{base}, nil
+	case core.StageFailed, core.StageDeadLetter:
+		base.Type = core.EventFailed
+		base.Error = status.Error
+		return []core.PodEvent{base}, nil
+	}
+
+	stage := base
+	stage.Type = core.EventStage
+	stage.ArticleID = 0
+	if articleID == 0 {
+		if status.ChunksTotal == 0 {
+			return []core.PodEvent{stage}, nil
+		}
+		progress := stage
+		progress.Type = core.EventProgress
+		progress.ChunksDone, progress.ChunksTotal = status.ChunksDone, status.ChunksTotal
+		return []core.PodEvent{stage, progress}, nil
+	}
+	article := base
+	article.Type = core.EventArticle
+	return []core.PodEvent{stage, article}, nil
+}
+
+func isFinalEvent(event core.PodEvent) bool {
+	return event.Type == core.EventDone || event.Type == core.EventFailed
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/demirbey05/auth-demo/db"
+	"github.com/demirbey05/auth-demo/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func getJobStatus(c *gin.Context, conn *pgxpool.Pool, queries *db.Queries) {
+	var jobID int
+	if _, err := fmt.Sscan(c.Param("job_id"), &jobID); err != nil {
+		c.JSON(400, gin.H{"error": "invalid job_id"})
+		return
+	}
+	userID := c.GetString("uuid")
+	if userID == "" {
+		c.JSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	podStore := store.NewDBPodStore(queries)
+	status, err := podStore.GetJobStatus(c.Request.Context(), jobID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(404, gin.H{"error": "job not found"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+
+	isOwner, err := podStore.IsPodOwner(c.Request.Context(), status.PodID, userID)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+	if !isOwner {
+		c.JSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	c.JSON(200, status)
+}
+
+func getPodStatus(c *gin.Context, conn *pgxpool.Pool, queries *db.Queries) {
+	var podID int
+	if _, err := fmt.Sscan(c.Param("pod_id"), &podID); err != nil {
+		c.JSON(400, gin.H{"error": "invalid pod_id"})
+		return
+	}
+	userID := c.GetString("uuid")
+	if userID == "" {
+		c.JSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	podStore := store.NewDBPodStore(queries)
+	isOwner, err := podStore.IsPodOwner(c.Request.Context(), podID, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(404, gin.H{"error": "pod not found"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+	if !isOwner {
+		c.JSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	status, err := podStore.GetJobStatusByPodID(c.Request.Context(), podID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(404, gin.H{"error": "job not found"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(200, status)
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/demirbey05/auth-demo/db"
+	"github.com/demirbey05/auth-demo/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// getLLMUsage aggregates the caller's LLM spend by pod or by day.
+func getLLMUsage(c *gin.Context, queries *db.Queries) {
+	userID := c.GetString("uuid")
+	if userID == "" {
+		c.JSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+	groupBy := c.DefaultQuery("group_by", store.LLMUsageByPod)
+	if groupBy != store.LLMUsageByPod && groupBy != store.LLMUsageByDay {
+		c.JSON(400, gin.H{"error": "group_by must be pod or day"})
+		return
+	}
+	filter, ok := llmUsageFilter(c)
+	if !ok {
+		return
+	}
+	filter.UserID = userID
+
+	writeLLMUsage(c, queries, groupBy, filter)
+}
+
+// getAdminLLMUsage aggregates LLM spend by pod, user or day, optionally for a single user.
+func getAdminLLMUsage(c *gin.Context, queries *db.Queries) {
+	groupBy := c.DefaultQuery("group_by", store.LLMUsageByDay)
+	switch groupBy {
+	case store.LLMUsageByPod, store.LLMUsageByUser, store.LLMUsageByDay:
+	default:
+		c.JSON(400, gin.H{"error": "group_by must be pod, user or day"})
+		return
+	}
+	filter, ok := llmUsageFilter(c)
+	if !ok {
+		return
+	}
+	filter.UserID = c.Query("user_id")
+
+	writeLLMUsage(c, queries, groupBy, filter)
+}
+
+func writeLLMUsage(c *gin.Context, queries *db.Queries, groupBy string, filter store.LLMUsageFilter) {
+	callStore := store.NewDBLLMCallStore(queries)
+	usage, err := callStore.AggregateLLMUsage(c.Request.Context(), groupBy, filter)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"usage":    usage,
+		"group_by": groupBy,
+		"from":     filter.From.Format(time.DateOnly),
+		"to":       filter.To.AddDate(0, 0, -1).Format(time.DateOnly),
+		"limit":    filter.Limit,
+		"offset":   filter.Offset,
+	})
+}
+
+// llmUsageFilter reads pagination and the inclusive from and to dates
+// (YYYY-MM-DD, UTC), defaulting to the last 30 days. It writes the error
+// response itself when the query is invalid.
+func llmUsageFilter(c *gin.Context) (store.LLMUsageFilter, bool) {
+	limit, offset, ok := pagination(c)
+	if !ok {
+		c.JSON(400, gin.H{"error": "invalid pagination"})
+		return store.LLMUsageFilter{}, false
+	}
+
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "invalid to date"})
+			return store.LLMUsageFilter{}, false
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, -29)
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "invalid from date"})
+			return store.LLMUsageFilter{}, false
+		}
+		from = t
+	}
+	if from.After(to) {
+		c.JSON(400, gin.H{"error": "from must not be after to"})
+		return store.LLMUsageFilter{}, false
+	}
+
+	// The range includes the whole to day
+	return store.LLMUsageFilter{From: from, To: to.AddDate(0, 0, 1), Limit: limit, Offset: offset}, true
+}
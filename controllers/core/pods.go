@@ -1,42 +1,104 @@
 package core
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/demirbey05/auth-demo/db"
 	"github.com/demirbey05/auth-demo/internal/core"
 	"github.com/demirbey05/auth-demo/internal/store"
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 func createNewPod(c *gin.Context, conn *pgxpool.Pool, queries *db.Queries) {
 	/* This endpoint will get youtube link and title from the request body and create a new pod in the database. */
-	/* Then it schedules two job  : fetch the video transcription and send it to LLM to generate article  */
-	/* After article is generated, it will be saved in the database */
+	/* Then it queues a job and returns immediately with the pod and job ids */
+	/* The worker pool picks the job up, fetches the video transcription and sends it to LLM to generate article */
 	/* Then article will be sent to llm to generate quiz */
+	/* source_type also accepts text, pdf (a multipart upload of file) and url, whose text is extracted here */
 
 	var req struct {
-		Link     string `json:"link" binding:"required"`
-		Language string `json:"language" binding:"required"`
+		// SourceType defaults to youtube.
+		SourceType string `json:"source_type" form:"source_type"`
+		Link       string `json:"link" form:"link"`
+		// Text is the pasted text of text pods.
+		Text            string `json:"text" form:"text"`
+		Title           string `json:"title" form:"title"`
+		Language        string `json:"language" form:"language" binding:"required"`
+		ForceRegenerate bool   `json:"force_regenerate" form:"force_regenerate"`
+		// QuestionCount and Difficulty shape the quiz; both are optional.
+		QuestionCount int    `json:"question_count" form:"question_count"`
+		Difficulty    string `json:"difficulty" form:"difficulty"`
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
+	// Leave room for the other form fields of PDF uploads
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, core.MaxDocumentBytes+1<<20)
+	if err := c.ShouldBind(&req); err != nil {
 		fmt.Println(err)
 		c.JSON(400, gin.H{"error": "bind error"})
 		return
 	}
-	type resp struct {
-		PodID           int `json:"pod_id"`
-		JobId           int `json:"job_id"`
-		RemainingCredit int `json:"remaining_credit"`
-	}
 	userID := c.GetString("uuid")
 	if userID == "" {
 		c.JSON(401, gin.H{"error": "unauthorized"})
 		return
 	}
 
+	// Documents are read before the transaction, as fetching a page is slow
+	var doc *core.Document
+	switch req.SourceType {
+	case "", core.SourceYouTube:
+		if req.Link == "" {
+			c.JSON(400, gin.H{"error": "link is required"})
+			return
+		}
+	case core.SourceText:
+		extracted, err := core.ExtractText(req.Title, req.Text)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		doc = &extracted
+	case core.SourcePDF:
+		extracted, ok := readPDFUpload(c)
+		if !ok {
+			return
+		}
+		if title := strings.TrimSpace(req.Title); title != "" {
+			extracted.Title = title
+		}
+		doc = &extracted
+	case core.SourceURL:
+		if req.Link == "" {
+			c.JSON(400, gin.H{"error": "link is required"})
+			return
+		}
+		extracted, err := core.FetchWebPage(c.Request.Context(), req.Link)
+		if err != nil {
+			fmt.Println(err)
+			if errors.Is(err, core.ErrEmptyDocument) {
+				c.JSON(400, gin.H{"error": "no text found on the web page"})
+				return
+			}
+			c.JSON(400, gin.H{"error": "could not read the web page"})
+			return
+		}
+		if title := strings.TrimSpace(req.Title); title != "" {
+			extracted.Title = title
+		}
+		doc = &extracted
+	default:
+		c.JSON(400, gin.H{"error": "unsupported source_type", "supported": core.SourceTypes})
+		return
+	}
+
 	tx, err := conn.Begin(c)
 	if err != nil {
 		fmt.Println(err)
@@ -48,36 +110,223 @@ func createNewPod(c *gin.Context, conn *pgxpool.Pool, queries *db.Queries) {
 	qtx := queries.WithTx(tx)
 	podStore := store.NewDBPodStore(qtx)
 	usageStore := store.NewDBUsageStore(qtx)
-	podID, jobID, remainingCredit, err := core.CreateNewPod(req.Link, userID, req.Language, podStore, usageStore)
+	created, err := core.CreateNewPod(core.PodRequest{
+		SourceType:      req.SourceType,
+		Link:            req.Link,
+		UserID:          userID,
+		Language:        req.Language,
+		ForceRegenerate: req.ForceRegenerate,
+		Quiz:            store.QuizSettings{QuestionCount: req.QuestionCount, Difficulty: req.Difficulty},
+		Document:        doc,
+	}, podStore, usageStore, store.NewDBWebhookStore(qtx))
+	if err != nil {
+		podCreationError(c, err)
+		return
+	}
+
+	if err := tx.Commit(c); err != nil {
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(200, createdPodResponse(created))
+
+}
+
+// readPDFUpload extracts the text of the uploaded file of a pdf pod, answering
+// the request itself when it cannot.
+func readPDFUpload(c *gin.Context) (core.Document, bool) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(400, gin.H{"error": "file is required"})
+		return core.Document{}, false
+	}
+	if file.Size > core.MaxDocumentBytes {
+		c.JSON(413, gin.H{"error": "file is too large"})
+		return core.Document{}, false
+	}
+	src, err := file.Open()
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return core.Document{}, false
+	}
+	defer src.Close()
+	data, err := io.ReadAll(src)
 	if err != nil {
-		if err.Error() == "invalid link" {
-			c.JSON(400, gin.H{"error": "invalid link"})
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return core.Document{}, false
+	}
+
+	doc, err := core.ExtractPDF(data)
+	switch {
+	case errors.Is(err, core.ErrEncryptedPDF):
+		c.JSON(400, gin.H{"error": "the pdf is encrypted"})
+		return core.Document{}, false
+	case errors.Is(err, core.ErrEmptyDocument):
+		c.JSON(400, gin.H{"error": "no text found in the pdf"})
+		return core.Document{}, false
+	case err != nil:
+		fmt.Println(err)
+		c.JSON(400, gin.H{"error": "could not read the pdf"})
+		return core.Document{}, false
+	}
+	return doc, true
+}
+
+type createdPod struct {
+	PodID           int  `json:"pod_id"`
+	JobId           int  `json:"job_id"`
+	RemainingCredit int  `json:"remaining_credit"`
+	Cost            int  `json:"cost"`
+	Reused          bool `json:"reused"`
+}
+
+func createdPodResponse(created core.CreatedPod) createdPod {
+	return createdPod{
+		PodID:           created.PodID,
+		JobId:           created.JobID,
+		RemainingCredit: created.RemainingCredit,
+		Cost:            created.Cost,
+		Reused:          created.Reused,
+	}
+}
+
+// podCreationError answers a failed core.CreateNewPod.
+func podCreationError(c *gin.Context, err error) {
+	if err.Error() == "invalid link" {
+		c.JSON(400, gin.H{"error": "invalid link"})
+		return
+	}
+	if err.Error() == "insufficient credits" {
+		c.JSON(400, gin.H{"error": "insufficient credits"})
+		return
+	}
+	if errors.Is(err, core.ErrInvalidQuizSettings) {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.HasPrefix(err.Error(), "error canonicalizing link:") {
+		c.JSON(400, gin.H{"error": "invalid youtube link"})
+		return
+	}
+	fmt.Println(err)
+	c.JSON(500, gin.H{"error": "internal error"})
+}
+
+// uploadPod creates a pod from an uploaded audio or video file. The multipart
+// form carries the file along with language, an optional title and the quiz
+// settings of create-pod. The file is priced by its duration and transcribed
+// by the worker that picks the job up.
+func uploadPod(c *gin.Context, conn *pgxpool.Pool, queries *db.Queries, media *core.MediaStore) {
+	userID := c.GetString("uuid")
+	if userID == "" {
+		c.JSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	// Leave room for the other form fields
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, media.MaxBytes+1<<20)
+	file, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(413, gin.H{"error": "file is too large"})
 			return
 		}
-		if err.Error() == "insufficient credits" {
-			c.JSON(400, gin.H{"error": "insufficient credits"})
+		c.JSON(400, gin.H{"error": "file is required"})
+		return
+	}
+	language := c.PostForm("language")
+	if language == "" {
+		c.JSON(400, gin.H{"error": "language is required"})
+		return
+	}
+	questionCount := 0
+	if v := c.PostForm("question_count"); v != "" {
+		if _, err := fmt.Sscan(v, &questionCount); err != nil {
+			c.JSON(400, gin.H{"error": "invalid question_count"})
 			return
 		}
-		if strings.HasPrefix(err.Error(), "error canonicalizing link:") {
-			c.JSON(400, gin.H{"error": "invalid youtube link"})
+	}
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if !slices.Contains(core.MediaExtensions, ext) {
+		c.JSON(400, gin.H{"error": "unsupported file type", "supported": core.MediaExtensions})
+		return
+	}
+	title := strings.TrimSpace(c.PostForm("title"))
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(file.Filename), filepath.Ext(file.Filename))
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+	defer src.Close()
+	name, err := media.Save(src, ext)
+	if err != nil {
+		if errors.Is(err, core.ErrMediaTooLarge) {
+			c.JSON(413, gin.H{"error": "file is too large"})
 			return
 		}
-		if err.Error() == "content is not educational" {
-			c.JSON(400, gin.H{"error": "content is not educational"})
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+	// The file is only kept once it belongs to a pod
+	keep := false
+	defer func() {
+		if !keep {
+			media.Remove(name)
+		}
+	}()
+
+	duration, err := media.Duration(name)
+	if err != nil {
+		if errors.Is(err, core.ErrUnknownMediaFormat) {
+			c.JSON(400, gin.H{"error": "could not read the duration of the file"})
 			return
 		}
+		fmt.Println(err)
 		c.JSON(500, gin.H{"error": "internal error"})
 		return
 	}
 
-	if err := tx.Commit(c); err != nil {
+	tx, err := conn.Begin(c)
+	if err != nil {
 		fmt.Println(err)
 		c.JSON(500, gin.H{"error": "internal error"})
 		return
 	}
+	defer tx.Rollback(c)
 
-	c.JSON(200, resp{PodID: podID, JobId: jobID, RemainingCredit: remainingCredit})
+	qtx := queries.WithTx(tx)
+	created, err := core.CreateNewPod(core.PodRequest{
+		SourceType: core.SourceMedia,
+		Link:       core.MediaLink(name),
+		UserID:     userID,
+		Language:   language,
+		Quiz:       store.QuizSettings{QuestionCount: questionCount, Difficulty: c.PostForm("difficulty")},
+		Media:      &core.MediaInfo{Title: title, Duration: duration},
+	}, store.NewDBPodStore(qtx), store.NewDBUsageStore(qtx), store.NewDBWebhookStore(qtx))
+	if err != nil {
+		podCreationError(c, err)
+		return
+	}
 
+	if err := tx.Commit(c); err != nil {
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+	keep = true
+
+	c.JSON(200, createdPodResponse(created))
 }
 
 func getPodsByUserID(c *gin.Context, conn *pgxpool.Pool, queries *db.Queries) {
@@ -125,14 +374,136 @@ func getArticle(c *gin.Context, conn *pgxpool.Pool, queries *db.Queries) {
 		c.JSON(401, gin.H{"error": "unauthorized"})
 		return
 	}
+	pod, err := podStore.GetPod(c.Request.Context(), podIDInt)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
 	article, err := podStore.GetArticleByPodID(c.Request.Context(), podIDInt)
 	if err != nil {
 		fmt.Println(err)
 		c.JSON(500, gin.H{"error": "internal error"})
 		return
 	}
+	for i, citation := range article.Citations {
+		article.Citations[i].URL = core.CitationURL(pod.Link, citation.StartMs)
+	}
+
+	c.JSON(200, article)
+}
+
+// getTranscript returns the timestamped transcript the pod was generated from.
+func getTranscript(c *gin.Context, queries *db.Queries) {
+	var podID int
+	if _, err := fmt.Sscan(c.Param("pod_id"), &podID); err != nil {
+		c.JSON(400, gin.H{"error": "invalid pod_id"})
+		return
+	}
+	userID := c.GetString("uuid")
+	if userID == "" {
+		c.JSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	podStore := store.NewDBPodStore(queries)
+	isOwner, err := podStore.IsPodOwner(c.Request.Context(), podID, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(404, gin.H{"error": "pod not found"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+	if !isOwner {
+		c.JSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	transcript, err := podStore.GetTranscriptByPodID(c.Request.Context(), podID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(404, gin.H{"error": "transcript not found"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
 
-	c.JSON(200, gin.H{"article": article})
+	c.JSON(200, gin.H{"transcript": transcript})
+}
+
+// exportArticle serves the article as a downloadable document in the format
+// given by ?format=html|pdf|epub|md.
+func exportArticle(c *gin.Context, queries *db.Queries) {
+	var podID int
+	if _, err := fmt.Sscan(c.Param("pod_id"), &podID); err != nil {
+		c.JSON(400, gin.H{"error": "invalid pod_id"})
+		return
+	}
+	userID := c.GetString("uuid")
+	if userID == "" {
+		c.JSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+	format := c.DefaultQuery("format", core.ExportHTML)
+	if !slices.Contains(core.ExportFormats, format) {
+		c.JSON(400, gin.H{"error": "format must be one of " + strings.Join(core.ExportFormats, ", ")})
+		return
+	}
+
+	podStore := store.NewDBPodStore(queries)
+	isOwner, err := podStore.IsPodOwner(c.Request.Context(), podID, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(404, gin.H{"error": "pod not found"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+	if !isOwner {
+		c.JSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	pod, err := podStore.GetPod(c.Request.Context(), podID)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+	article, err := podStore.GetArticleByPodID(c.Request.Context(), podID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(404, gin.H{"error": "article not found"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+
+	exported, err := core.ExportArticle(core.ArticleDocument{
+		PodID:     pod.ID,
+		Title:     pod.Title,
+		Source:    pod.Link,
+		Language:  pod.Language,
+		CreatedAt: pod.CreatedAt,
+		Markdown:  article.Text,
+	}, format)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": exported.FileName}))
+	c.Data(200, exported.ContentType, exported.Body)
 }
 
 func getQuiz(c *gin.Context, conn *pgxpool.Pool, queries *db.Queries) {
@@ -205,3 +576,72 @@ func sharePod(c *gin.Context, conn *pgxpool.Pool, queries *db.Queries) {
 
 	c.JSON(200, gin.H{"message": "Pod is now public"})
 }
+
+func retryPod(c *gin.Context, conn *pgxpool.Pool, queries *db.Queries, media *core.MediaStore) {
+	var podID int
+	if _, err := fmt.Sscan(c.Param("pod_id"), &podID); err != nil {
+		c.JSON(400, gin.H{"error": "invalid pod_id"})
+		return
+	}
+	userID := c.GetString("uuid")
+	if userID == "" {
+		c.JSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	tx, err := conn.Begin(c)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+	defer tx.Rollback(c)
+
+	qtx := queries.WithTx(tx)
+	podStore := store.NewDBPodStore(qtx)
+	usageStore := store.NewDBUsageStore(qtx)
+
+	// Only the creator can retry, shared viewers cannot
+	isCreator, err := podStore.IsPodCreator(c.Request.Context(), podID, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(404, gin.H{"error": "pod not found"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+	if !isCreator {
+		c.JSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	retried, err := core.RetryPod(podID, userID, podStore, usageStore, media)
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrPodInProgress), errors.Is(err, core.ErrNothingToRetry), errors.Is(err, core.ErrNotRetriable):
+			c.JSON(409, gin.H{"error": err.Error()})
+		case errors.Is(err, core.ErrInsufficientCredits):
+			c.JSON(400, gin.H{"error": "insufficient credits"})
+		default:
+			fmt.Println(err)
+			c.JSON(500, gin.H{"error": "internal error"})
+		}
+		return
+	}
+
+	if err := tx.Commit(c); err != nil {
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"pod_id":           podID,
+		"job_id":           retried.JobID,
+		"remaining_credit": retried.RemainingCredit,
+		"cost":             retried.Cost,
+		"missing":          retried.Missing,
+	})
+}
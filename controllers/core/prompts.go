@@ -0,0 +1,117 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/demirbey05/auth-demo/db"
+	"github.com/demirbey05/auth-demo/internal/core"
+	"github.com/demirbey05/auth-demo/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// listPrompts returns the stored prompt versions and the built-in default of
+// each kind, which is used while a kind has no weighted stored version.
+func listPrompts(c *gin.Context, queries *db.Queries) {
+	promptStore := store.NewDBPromptStore(queries)
+	templates, err := promptStore.ListPromptTemplates(c.Request.Context())
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+
+	defaults := make(map[string]string)
+	for _, kind := range core.PromptKinds {
+		defaults[kind] = core.DefaultPrompt(kind).Version
+	}
+
+	c.JSON(200, gin.H{"prompts": templates, "defaults": defaults})
+}
+
+// createPrompt stores a new prompt version. Without a body the version must
+// name a built-in template, which lets built-in versions join an A/B split;
+// with one it must not.
+func createPrompt(c *gin.Context, queries *db.Queries) {
+	var req struct {
+		Kind    string `json:"kind" binding:"required"`
+		Version string `json:"version" binding:"required"`
+		Body    string `json:"body"`
+		Weight  int    `json:"weight"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "bind error"})
+		return
+	}
+	if !slices.Contains(core.PromptKinds, req.Kind) {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("unknown prompt kind %q", req.Kind)})
+		return
+	}
+	if req.Weight < 0 {
+		c.JSON(400, gin.H{"error": "weight must not be negative"})
+		return
+	}
+	if req.Body == "" {
+		if core.BuiltinPrompt(req.Kind, req.Version) == nil {
+			c.JSON(400, gin.H{"error": "body is required for versions that are not built in"})
+			return
+		}
+	} else if core.BuiltinPrompt(req.Kind, req.Version) != nil {
+		c.JSON(409, gin.H{"error": "version is built in; leave the body empty to use it or pick another version"})
+		return
+	} else if err := core.ValidatePrompt(req.Kind, req.Body); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	promptStore := store.NewDBPromptStore(queries)
+	template, err := promptStore.CreatePromptTemplate(c.Request.Context(), req.Kind, req.Version, req.Body, req.Weight)
+	if err != nil {
+		if errors.Is(err, store.ErrPromptVersionExists) {
+			c.JSON(409, gin.H{"error": "prompt version already exists"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(201, template)
+}
+
+// setPromptWeight changes how often a prompt version is picked. A weight of 0
+// takes the version out of rotation.
+func setPromptWeight(c *gin.Context, queries *db.Queries) {
+	var promptID int
+	if _, err := fmt.Sscan(c.Param("prompt_id"), &promptID); err != nil {
+		c.JSON(400, gin.H{"error": "invalid prompt_id"})
+		return
+	}
+	var req struct {
+		Weight *int `json:"weight" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "bind error"})
+		return
+	}
+	if *req.Weight < 0 {
+		c.JSON(400, gin.H{"error": "weight must not be negative"})
+		return
+	}
+
+	promptStore := store.NewDBPromptStore(queries)
+	template, err := promptStore.SetPromptTemplateWeight(c.Request.Context(), promptID, *req.Weight)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(404, gin.H{"error": "prompt not found"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(200, template)
+}
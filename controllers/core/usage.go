@@ -1,6 +1,8 @@
 package core
 
 import (
+	"fmt"
+
 	"github.com/demirbey05/auth-demo/db"
 	"github.com/demirbey05/auth-demo/internal/store"
 	"github.com/gin-gonic/gin"
@@ -27,3 +29,31 @@ func getRemainingCredits(c *gin.Context, queries *db.Queries) {
 	c.JSON(200, resp{RemainingCredit: remainingCredit})
 
 }
+
+func getCreditHistory(c *gin.Context, queries *db.Queries) {
+	userID := c.GetString("uuid")
+	if userID == "" {
+		c.JSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+	limit, offset, ok := pagination(c)
+	if !ok {
+		c.JSON(400, gin.H{"error": "invalid pagination"})
+		return
+	}
+
+	usageStore := store.NewDBUsageStore(queries)
+	history, total, err := usageStore.GetCreditHistory(c.Request.Context(), userID, limit, offset)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"transactions": history,
+		"total":        total,
+		"limit":        limit,
+		"offset":       offset,
+	})
+}
@@ -0,0 +1,159 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/demirbey05/auth-demo/db"
+	"github.com/demirbey05/auth-demo/internal/core"
+	"github.com/demirbey05/auth-demo/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// createWebhook registers a URL for the caller's pod events. Without an
+// explicit secret one is generated; either way it is only returned here.
+func createWebhook(c *gin.Context, queries *db.Queries) {
+	var req struct {
+		URL    string   `json:"url" binding:"required"`
+		Secret string   `json:"secret"`
+		Events []string `json:"events"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "bind error"})
+		return
+	}
+	userID := c.GetString("uuid")
+	if userID == "" {
+		c.JSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	if err := core.ValidateWebhookURL(req.URL); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Events) == 0 {
+		req.Events = core.WebhookEvents
+	}
+	for _, event := range req.Events {
+		if !slices.Contains(core.WebhookEvents, event) {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("unknown event %q", event)})
+			return
+		}
+	}
+	if req.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			fmt.Println(err)
+			c.JSON(500, gin.H{"error": "internal error"})
+			return
+		}
+		req.Secret = hex.EncodeToString(secret)
+	}
+
+	webhookStore := store.NewDBWebhookStore(queries)
+	webhook, err := webhookStore.CreateWebhook(c.Request.Context(), userID, req.URL, req.Secret, req.Events)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(201, webhook)
+}
+
+func listWebhooks(c *gin.Context, queries *db.Queries) {
+	userID := c.GetString("uuid")
+	if userID == "" {
+		c.JSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	webhookStore := store.NewDBWebhookStore(queries)
+	webhooks, err := webhookStore.ListWebhooks(c.Request.Context(), userID)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(200, gin.H{"webhooks": webhooks})
+}
+
+func deleteWebhook(c *gin.Context, queries *db.Queries) {
+	var webhookID int
+	if _, err := fmt.Sscan(c.Param("webhook_id"), &webhookID); err != nil {
+		c.JSON(400, gin.H{"error": "invalid webhook_id"})
+		return
+	}
+	userID := c.GetString("uuid")
+	if userID == "" {
+		c.JSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	webhookStore := store.NewDBWebhookStore(queries)
+	if err := webhookStore.DeleteWebhook(c.Request.Context(), webhookID, userID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(404, gin.H{"error": "webhook not found"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "webhook deleted"})
+}
+
+// listWebhookDeliveries returns the delivery log of a webhook, newest first.
+func listWebhookDeliveries(c *gin.Context, queries *db.Queries) {
+	var webhookID int
+	if _, err := fmt.Sscan(c.Param("webhook_id"), &webhookID); err != nil {
+		c.JSON(400, gin.H{"error": "invalid webhook_id"})
+		return
+	}
+	userID := c.GetString("uuid")
+	if userID == "" {
+		c.JSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+	limit, offset, ok := pagination(c)
+	if !ok {
+		c.JSON(400, gin.H{"error": "invalid pagination"})
+		return
+	}
+
+	webhookStore := store.NewDBWebhookStore(queries)
+	webhook, err := webhookStore.GetWebhook(c.Request.Context(), webhookID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(404, gin.H{"error": "webhook not found"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+	if webhook.UserID != userID {
+		c.JSON(404, gin.H{"error": "webhook not found"})
+		return
+	}
+
+	deliveries, err := webhookStore.ListWebhookDeliveries(c.Request.Context(), webhookID, limit, offset)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"deliveries": deliveries,
+		"limit":      limit,
+		"offset":     offset,
+	})
+}
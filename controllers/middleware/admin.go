@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminMiddleware only lets through users whose Firebase UID is listed in ADMIN_UIDS.
+// It must run after FirebaseAuthMiddleware.
+func AdminMiddleware() gin.HandlerFunc {
+	admins := make(map[string]bool)
+	for _, uid := range strings.Split(os.Getenv("ADMIN_UIDS"), ",") {
+		if uid = strings.TrimSpace(uid); uid != "" {
+			admins[uid] = true
+		}
+	}
+
+	return func(c *gin.Context) {
+		if !admins[c.GetString("uuid")] {
+			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
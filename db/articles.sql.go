@@ -11,15 +11,43 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const cloneArticle = `-- name: CloneArticle :one
+INSERT INTO articles (pod_id, article_text, prompt_version, notes_prompt_version, citations)
+SELECT $1, a.article_text, a.prompt_version, a.notes_prompt_version, a.citations
+FROM articles a
+WHERE a.pod_id = $2
+ORDER BY a.id DESC
+LIMIT 1
+RETURNING id
+`
+
+type CloneArticleParams struct {
+	PodID       pgtype.Int4
+	SourcePodID pgtype.Int4
+}
+
+func (q *Queries) CloneArticle(ctx context.Context, arg CloneArticleParams) (int32, error) {
+	row := q.db.QueryRow(ctx, cloneArticle, arg.PodID, arg.SourcePodID)
+	var id int32
+	err := row.Scan(&id)
+	return id, err
+}
+
 const getArticleByPodId = `-- name: GetArticleByPodId :one
-SELECT article_text FROM articles WHERE pod_id = $1 LIMIT 1
+SELECT article_text, citations FROM articles WHERE pod_id = $1 ORDER BY id DESC LIMIT 1
 `
 
-func (q *Queries) GetArticleByPodId(ctx context.Context, podID pgtype.Int4) (string, error) {
+type GetArticleByPodIdRow struct {
+	ArticleText string
+	Citations   []byte
+}
+
+// Retries and reuse can store more than one article for a pod; the newest wins.
+func (q *Queries) GetArticleByPodId(ctx context.Context, podID pgtype.Int4) (GetArticleByPodIdRow, error) {
 	row := q.db.QueryRow(ctx, getArticleByPodId, podID)
-	var article_text string
-	err := row.Scan(&article_text)
-	return article_text, err
+	var i GetArticleByPodIdRow
+	err := row.Scan(&i.ArticleText, &i.Citations)
+	return i, err
 }
 
 const getArticlePodInfo = `-- name: GetArticlePodInfo :one
@@ -38,17 +66,29 @@ func (q *Queries) GetArticlePodInfo(ctx context.Context, podID pgtype.Int4) (Get
 	return i, err
 }
 
-const insertArticle = `-- name: InsertArticle :exec
-INSERT INTO articles (pod_id, article_text)
-VALUES ($1, $2)
+const insertArticle = `-- name: InsertArticle :one
+INSERT INTO articles (pod_id, article_text, prompt_version, notes_prompt_version, citations)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id
 `
 
 type InsertArticleParams struct {
-	PodID       pgtype.Int4
-	ArticleText string
+	PodID              pgtype.Int4
+	ArticleText        string
+	PromptVersion      string
+	NotesPromptVersion string
+	Citations          []byte
 }
 
-func (q *Queries) InsertArticle(ctx context.Context, arg InsertArticleParams) error {
-	_, err := q.db.Exec(ctx, insertArticle, arg.PodID, arg.ArticleText)
-	return err
+func (q *Queries) InsertArticle(ctx context.Context, arg InsertArticleParams) (int32, error) {
+	row := q.db.QueryRow(ctx, insertArticle,
+		arg.PodID,
+		arg.ArticleText,
+		arg.PromptVersion,
+		arg.NotesPromptVersion,
+		arg.Citations,
+	)
+	var id int32
+	err := row.Scan(&id)
+	return id, err
 }
@@ -7,59 +7,474 @@ package db
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimJob = `-- name: ClaimJob :one
+UPDATE jobs j
+SET stage = $1,
+    claims = j.claims + 1,
+    started_at = CURRENT_TIMESTAMP,
+    updated_at = CURRENT_TIMESTAMP
+FROM pods p
+WHERE p.id = j.pod_id AND j.id = (
+    SELECT q.id FROM jobs q
+    WHERE q.stage = $2
+    ORDER BY q.id
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
 )
+RETURNING j.id, j.pod_id, j.language, j.cost, j.charged_credits, p.link, p.created_by, p.question_count, p.quiz_difficulty
+`
+
+type ClaimJobParams struct {
+	NewStage string
+	Stage    string
+}
+
+type ClaimJobRow struct {
+	ID             int32
+	PodID          int32
+	Language       string
+	Cost           int32
+	ChargedCredits int32
+	Link           string
+	CreatedBy      string
+	QuestionCount  pgtype.Int4
+	QuizDifficulty string
+}
+
+func (q *Queries) ClaimJob(ctx context.Context, arg ClaimJobParams) (ClaimJobRow, error) {
+	row := q.db.QueryRow(ctx, claimJob, arg.NewStage, arg.Stage)
+	var i ClaimJobRow
+	err := row.Scan(
+		&i.ID,
+		&i.PodID,
+		&i.Language,
+		&i.Cost,
+		&i.ChargedCredits,
+		&i.Link,
+		&i.CreatedBy,
+		&i.QuestionCount,
+		&i.QuizDifficulty,
+	)
+	return i, err
+}
+
+const failStaleJobs = `-- name: FailStaleJobs :many
+UPDATE jobs j
+SET stage = $1,
+    error_reason = $2,
+    last_error = $3,
+    finished_at = CURRENT_TIMESTAMP,
+    updated_at = CURRENT_TIMESTAMP
+FROM pods p
+WHERE p.id = j.pod_id
+  AND j.stage = ANY($4::text[])
+  AND j.updated_at < CURRENT_TIMESTAMP - ($5::int * INTERVAL '1 second')
+  AND j.claims >= $6
+RETURNING j.id, j.pod_id, j.language, j.cost, j.charged_credits, p.link, p.created_by, p.question_count, p.quiz_difficulty
+`
+
+type FailStaleJobsParams struct {
+	FailedStage  string
+	ErrorReason  pgtype.Text
+	LastError    pgtype.Text
+	Stages       []string
+	StaleSeconds int32
+	MaxClaims    int32
+}
+
+type FailStaleJobsRow struct {
+	ID             int32
+	PodID          int32
+	Language       string
+	Cost           int32
+	ChargedCredits int32
+	Link           string
+	CreatedBy      string
+	QuestionCount  pgtype.Int4
+	QuizDifficulty string
+}
+
+func (q *Queries) FailStaleJobs(ctx context.Context, arg FailStaleJobsParams) ([]FailStaleJobsRow, error) {
+	rows, err := q.db.Query(ctx, failStaleJobs,
+		arg.FailedStage,
+		arg.ErrorReason,
+		arg.LastError,
+		arg.Stages,
+		arg.StaleSeconds,
+		arg.MaxClaims,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FailStaleJobsRow
+	for rows.Next() {
+		var i FailStaleJobsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.PodID,
+			&i.Language,
+			&i.Cost,
+			&i.ChargedCredits,
+			&i.Link,
+			&i.CreatedBy,
+			&i.QuestionCount,
+			&i.QuizDifficulty,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const finishJob = `-- name: FinishJob :exec
+UPDATE jobs
+SET stage = $2,
+    error_reason = $3,
+    last_error = COALESCE($4, last_error),
+    finished_at = CURRENT_TIMESTAMP,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+type FinishJobParams struct {
+	ID          int32
+	Stage       string
+	ErrorReason pgtype.Text
+	LastError   pgtype.Text
+}
 
-const getJobStatusByID = `-- name: GetJobStatusByID :one
-SELECT job_status 
-FROM jobs 
+func (q *Queries) FinishJob(ctx context.Context, arg FinishJobParams) error {
+	_, err := q.db.Exec(ctx, finishJob,
+		arg.ID,
+		arg.Stage,
+		arg.ErrorReason,
+		arg.LastError,
+	)
+	return err
+}
+
+const getJobByID = `-- name: GetJobByID :one
+SELECT id, pod_id, language, stage, error_reason, attempts, created_at, started_at, finished_at, updated_at, last_error, charged_credits, chunks_done, chunks_total, cost, claims
+FROM jobs
 WHERE id = $1
 `
 
-func (q *Queries) GetJobStatusByID(ctx context.Context, id int32) (int32, error) {
-	row := q.db.QueryRow(ctx, getJobStatusByID, id)
-	var job_status int32
-	err := row.Scan(&job_status)
-	return job_status, err
+func (q *Queries) GetJobByID(ctx context.Context, id int32) (Job, error) {
+	row := q.db.QueryRow(ctx, getJobByID, id)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.PodID,
+		&i.Language,
+		&i.Stage,
+		&i.ErrorReason,
+		&i.Attempts,
+		&i.CreatedAt,
+		&i.StartedAt,
+		&i.FinishedAt,
+		&i.UpdatedAt,
+		&i.LastError,
+		&i.ChargedCredits,
+		&i.ChunksDone,
+		&i.ChunksTotal,
+		&i.Cost,
+		&i.Claims,
+	)
+	return i, err
 }
 
-const getJobStatusByPodID = `-- name: GetJobStatusByPodID :one
-SELECT job_status 
-FROM jobs 
+const getLatestJobByPodID = `-- name: GetLatestJobByPodID :one
+SELECT id, pod_id, language, stage, error_reason, attempts, created_at, started_at, finished_at, updated_at, last_error, charged_credits, chunks_done, chunks_total, cost, claims
+FROM jobs
 WHERE pod_id = $1
+ORDER BY id DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLatestJobByPodID(ctx context.Context, podID int32) (Job, error) {
+	row := q.db.QueryRow(ctx, getLatestJobByPodID, podID)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.PodID,
+		&i.Language,
+		&i.Stage,
+		&i.ErrorReason,
+		&i.Attempts,
+		&i.CreatedAt,
+		&i.StartedAt,
+		&i.FinishedAt,
+		&i.UpdatedAt,
+		&i.LastError,
+		&i.ChargedCredits,
+		&i.ChunksDone,
+		&i.ChunksTotal,
+		&i.Cost,
+		&i.Claims,
+	)
+	return i, err
+}
+
+const hasNewerPodJob = `-- name: HasNewerPodJob :one
+SELECT EXISTS (
+    SELECT 1 FROM jobs j INNER JOIN jobs n ON n.pod_id = j.pod_id AND n.id > j.id WHERE j.id = $1
+)
 `
 
-func (q *Queries) GetJobStatusByPodID(ctx context.Context, podID int32) (int32, error) {
-	row := q.db.QueryRow(ctx, getJobStatusByPodID, podID)
-	var job_status int32
-	err := row.Scan(&job_status)
-	return job_status, err
+func (q *Queries) HasNewerPodJob(ctx context.Context, id int32) (bool, error) {
+	row := q.db.QueryRow(ctx, hasNewerPodJob, id)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
 }
 
 const insertJob = `-- name: InsertJob :one
-INSERT INTO jobs(pod_id)
-VALUES($1)
+INSERT INTO jobs(pod_id, language, cost)
+VALUES($1, $2, $3)
 RETURNING id
 `
 
-func (q *Queries) InsertJob(ctx context.Context, podID int32) (int32, error) {
-	row := q.db.QueryRow(ctx, insertJob, podID)
+type InsertJobParams struct {
+	PodID    int32
+	Language string
+	Cost     int32
+}
+
+func (q *Queries) InsertJob(ctx context.Context, arg InsertJobParams) (int32, error) {
+	row := q.db.QueryRow(ctx, insertJob, arg.PodID, arg.Language, arg.Cost)
 	var id int32
 	err := row.Scan(&id)
 	return id, err
 }
 
-const updateJobStatusByID = `-- name: UpdateJobStatusByID :exec
+const listJobsByStage = `-- name: ListJobsByStage :many
+SELECT j.id, j.pod_id, j.language, j.stage, j.error_reason, j.last_error, j.attempts, j.claims,
+       j.created_at, j.started_at, j.finished_at, p.link, p.created_by
+FROM jobs j
+INNER JOIN pods p ON p.id = j.pod_id
+WHERE j.stage = $1
+ORDER BY j.id DESC
+LIMIT $2 OFFSET $3
+`
+
+type ListJobsByStageParams struct {
+	Stage  string
+	Limit  int32
+	Offset int32
+}
+
+type ListJobsByStageRow struct {
+	ID          int32
+	PodID       int32
+	Language    string
+	Stage       string
+	ErrorReason pgtype.Text
+	LastError   pgtype.Text
+	Attempts    int32
+	Claims      int32
+	CreatedAt   pgtype.Timestamp
+	StartedAt   pgtype.Timestamp
+	FinishedAt  pgtype.Timestamp
+	Link        string
+	CreatedBy   string
+}
+
+func (q *Queries) ListJobsByStage(ctx context.Context, arg ListJobsByStageParams) ([]ListJobsByStageRow, error) {
+	rows, err := q.db.Query(ctx, listJobsByStage, arg.Stage, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListJobsByStageRow
+	for rows.Next() {
+		var i ListJobsByStageRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.PodID,
+			&i.Language,
+			&i.Stage,
+			&i.ErrorReason,
+			&i.LastError,
+			&i.Attempts,
+			&i.Claims,
+			&i.CreatedAt,
+			&i.StartedAt,
+			&i.FinishedAt,
+			&i.Link,
+			&i.CreatedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockJobPod = `-- name: LockJobPod :one
+SELECT p.id FROM pods p INNER JOIN jobs j ON j.pod_id = p.id WHERE j.id = $1 FOR UPDATE OF p
+`
+
+func (q *Queries) LockJobPod(ctx context.Context, id int32) (int32, error) {
+	row := q.db.QueryRow(ctx, lockJobPod, id)
+	err := row.Scan(&id)
+	return id, err
+}
+
+const recordJobRetry = `-- name: RecordJobRetry :exec
 UPDATE jobs
-SET job_status = $2
+SET attempts = attempts + 1, last_error = $2, updated_at = CURRENT_TIMESTAMP
 WHERE id = $1
 `
 
-type UpdateJobStatusByIDParams struct {
+type RecordJobRetryParams struct {
 	ID        int32
-	JobStatus int32
+	LastError pgtype.Text
+}
+
+func (q *Queries) RecordJobRetry(ctx context.Context, arg RecordJobRetryParams) error {
+	_, err := q.db.Exec(ctx, recordJobRetry, arg.ID, arg.LastError)
+	return err
+}
+
+const releaseJob = `-- name: ReleaseJob :exec
+UPDATE jobs
+SET stage = $2,
+    claims = GREATEST(claims - 1, 0),
+    started_at = NULL,
+    last_error = $3,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+type ReleaseJobParams struct {
+	ID        int32
+	Stage     string
+	LastError pgtype.Text
+}
+
+func (q *Queries) ReleaseJob(ctx context.Context, arg ReleaseJobParams) error {
+	_, err := q.db.Exec(ctx, releaseJob, arg.ID, arg.Stage, arg.LastError)
+	return err
+}
+
+const requeueJob = `-- name: RequeueJob :one
+UPDATE jobs
+SET stage = $1,
+    error_reason = NULL,
+    last_error = NULL,
+    attempts = 0,
+    claims = 0,
+    started_at = NULL,
+    finished_at = NULL,
+    updated_at = CURRENT_TIMESTAMP
+WHERE jobs.id = $2 AND jobs.stage = ANY($3::text[])
+  AND NOT EXISTS (SELECT 1 FROM jobs n WHERE n.pod_id = jobs.pod_id AND n.id > jobs.id)
+RETURNING id
+`
+
+type RequeueJobParams struct {
+	QueuedStage string
+	ID          int32
+	FromStages  []string
+}
+
+// charged_credits is kept: failed jobs were refunded, which zeroed it, so
+// the rerun is charged again unless the refund never happened. Only the
+// latest job of a pod is requeued, as a newer one already reruns the pod.
+func (q *Queries) RequeueJob(ctx context.Context, arg RequeueJobParams) (int32, error) {
+	row := q.db.QueryRow(ctx, requeueJob, arg.QueuedStage, arg.ID, arg.FromStages)
+	var id int32
+	err := row.Scan(&id)
+	return id, err
+}
+
+const requeueStaleJobs = `-- name: RequeueStaleJobs :many
+UPDATE jobs
+SET stage = $1,
+    started_at = NULL,
+    last_error = $2,
+    updated_at = CURRENT_TIMESTAMP
+WHERE stage = ANY($3::text[])
+  AND updated_at < CURRENT_TIMESTAMP - ($4::int * INTERVAL '1 second')
+  AND claims < $5
+RETURNING id
+`
+
+type RequeueStaleJobsParams struct {
+	QueuedStage  string
+	LastError    pgtype.Text
+	Stages       []string
+	StaleSeconds int32
+	MaxClaims    int32
+}
+
+func (q *Queries) RequeueStaleJobs(ctx context.Context, arg RequeueStaleJobsParams) ([]int32, error) {
+	rows, err := q.db.Query(ctx, requeueStaleJobs,
+		arg.QueuedStage,
+		arg.LastError,
+		arg.Stages,
+		arg.StaleSeconds,
+		arg.MaxClaims,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateJobChunkProgress = `-- name: UpdateJobChunkProgress :exec
+UPDATE jobs
+SET chunks_done = $2, chunks_total = $3, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+type UpdateJobChunkProgressParams struct {
+	ID          int32
+	ChunksDone  int32
+	ChunksTotal int32
+}
+
+func (q *Queries) UpdateJobChunkProgress(ctx context.Context, arg UpdateJobChunkProgressParams) error {
+	_, err := q.db.Exec(ctx, updateJobChunkProgress, arg.ID, arg.ChunksDone, arg.ChunksTotal)
+	return err
+}
+
+const updateJobStage = `-- name: UpdateJobStage :exec
+UPDATE jobs
+SET stage = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+type UpdateJobStageParams struct {
+	ID    int32
+	Stage string
 }
 
-func (q *Queries) UpdateJobStatusByID(ctx context.Context, arg UpdateJobStatusByIDParams) error {
-	_, err := q.db.Exec(ctx, updateJobStatusByID, arg.ID, arg.JobStatus)
+func (q *Queries) UpdateJobStage(ctx context.Context, arg UpdateJobStageParams) error {
+	_, err := q.db.Exec(ctx, updateJobStage, arg.ID, arg.Stage)
 	return err
 }
@@ -0,0 +1,248 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: llm_calls.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const insertLLMCall = `-- name: InsertLLMCall :exec
+INSERT INTO llm_calls(pod_id, job_id, user_id, model, purpose, input_tokens, output_tokens, total_tokens, latency_ms, error)
+VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+`
+
+type InsertLLMCallParams struct {
+	PodID        pgtype.Int4
+	JobID        pgtype.Int4
+	UserID       string
+	Model        string
+	Purpose      string
+	InputTokens  int32
+	OutputTokens int32
+	TotalTokens  int32
+	LatencyMs    int32
+	Error        pgtype.Text
+}
+
+func (q *Queries) InsertLLMCall(ctx context.Context, arg InsertLLMCallParams) error {
+	_, err := q.db.Exec(ctx, insertLLMCall,
+		arg.PodID,
+		arg.JobID,
+		arg.UserID,
+		arg.Model,
+		arg.Purpose,
+		arg.InputTokens,
+		arg.OutputTokens,
+		arg.TotalTokens,
+		arg.LatencyMs,
+		arg.Error,
+	)
+	return err
+}
+
+const lLMUsageByDay = `-- name: LLMUsageByDay :many
+SELECT date_trunc('day', l.created_at)::date AS day,
+       COUNT(*)::int AS calls,
+       SUM(l.input_tokens)::bigint AS input_tokens,
+       SUM(l.output_tokens)::bigint AS output_tokens,
+       SUM(l.total_tokens)::bigint AS total_tokens,
+       AVG(l.latency_ms)::int AS avg_latency_ms
+FROM llm_calls l
+WHERE ($1::text IS NULL OR l.user_id = $1)
+  AND l.created_at >= $2 AND l.created_at < $3
+GROUP BY day
+ORDER BY day DESC
+LIMIT $5 OFFSET $4
+`
+
+type LLMUsageByDayParams struct {
+	UserID    pgtype.Text
+	FromTime  pgtype.Timestamp
+	ToTime    pgtype.Timestamp
+	RowOffset int32
+	RowLimit  int32
+}
+
+type LLMUsageByDayRow struct {
+	Day          pgtype.Date
+	Calls        int32
+	InputTokens  int64
+	OutputTokens int64
+	TotalTokens  int64
+	AvgLatencyMs int32
+}
+
+func (q *Queries) LLMUsageByDay(ctx context.Context, arg LLMUsageByDayParams) ([]LLMUsageByDayRow, error) {
+	rows, err := q.db.Query(ctx, lLMUsageByDay,
+		arg.UserID,
+		arg.FromTime,
+		arg.ToTime,
+		arg.RowOffset,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []LLMUsageByDayRow
+	for rows.Next() {
+		var i LLMUsageByDayRow
+		if err := rows.Scan(
+			&i.Day,
+			&i.Calls,
+			&i.InputTokens,
+			&i.OutputTokens,
+			&i.TotalTokens,
+			&i.AvgLatencyMs,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lLMUsageByPod = `-- name: LLMUsageByPod :many
+SELECT l.pod_id,
+       COUNT(*)::int AS calls,
+       SUM(l.input_tokens)::bigint AS input_tokens,
+       SUM(l.output_tokens)::bigint AS output_tokens,
+       SUM(l.total_tokens)::bigint AS total_tokens,
+       AVG(l.latency_ms)::int AS avg_latency_ms,
+       (SELECT COALESCE(SUM(j.charged_credits), 0) FROM jobs j WHERE j.pod_id = l.pod_id)::bigint AS charged_credits
+FROM llm_calls l
+WHERE l.pod_id IS NOT NULL
+  AND ($1::text IS NULL OR l.user_id = $1)
+  AND ($2::int IS NULL OR l.pod_id = $2)
+  AND l.created_at >= $3 AND l.created_at < $4
+GROUP BY l.pod_id
+ORDER BY l.pod_id DESC
+LIMIT $6 OFFSET $5
+`
+
+type LLMUsageByPodParams struct {
+	UserID    pgtype.Text
+	PodID     pgtype.Int4
+	FromTime  pgtype.Timestamp
+	ToTime    pgtype.Timestamp
+	RowOffset int32
+	RowLimit  int32
+}
+
+type LLMUsageByPodRow struct {
+	PodID          pgtype.Int4
+	Calls          int32
+	InputTokens    int64
+	OutputTokens   int64
+	TotalTokens    int64
+	AvgLatencyMs   int32
+	ChargedCredits int64
+}
+
+func (q *Queries) LLMUsageByPod(ctx context.Context, arg LLMUsageByPodParams) ([]LLMUsageByPodRow, error) {
+	rows, err := q.db.Query(ctx, lLMUsageByPod,
+		arg.UserID,
+		arg.PodID,
+		arg.FromTime,
+		arg.ToTime,
+		arg.RowOffset,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []LLMUsageByPodRow
+	for rows.Next() {
+		var i LLMUsageByPodRow
+		if err := rows.Scan(
+			&i.PodID,
+			&i.Calls,
+			&i.InputTokens,
+			&i.OutputTokens,
+			&i.TotalTokens,
+			&i.AvgLatencyMs,
+			&i.ChargedCredits,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lLMUsageByUser = `-- name: LLMUsageByUser :many
+SELECT l.user_id,
+       COUNT(*)::int AS calls,
+       SUM(l.input_tokens)::bigint AS input_tokens,
+       SUM(l.output_tokens)::bigint AS output_tokens,
+       SUM(l.total_tokens)::bigint AS total_tokens,
+       AVG(l.latency_ms)::int AS avg_latency_ms
+FROM llm_calls l
+WHERE ($1::text IS NULL OR l.user_id = $1)
+  AND l.created_at >= $2 AND l.created_at < $3
+GROUP BY l.user_id
+ORDER BY SUM(l.total_tokens) DESC
+LIMIT $5 OFFSET $4
+`
+
+type LLMUsageByUserParams struct {
+	UserID    pgtype.Text
+	FromTime  pgtype.Timestamp
+	ToTime    pgtype.Timestamp
+	RowOffset int32
+	RowLimit  int32
+}
+
+type LLMUsageByUserRow struct {
+	UserID       string
+	Calls        int32
+	InputTokens  int64
+	OutputTokens int64
+	TotalTokens  int64
+	AvgLatencyMs int32
+}
+
+func (q *Queries) LLMUsageByUser(ctx context.Context, arg LLMUsageByUserParams) ([]LLMUsageByUserRow, error) {
+	rows, err := q.db.Query(ctx, lLMUsageByUser,
+		arg.UserID,
+		arg.FromTime,
+		arg.ToTime,
+		arg.RowOffset,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []LLMUsageByUserRow
+	for rows.Next() {
+		var i LLMUsageByUserRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Calls,
+			&i.InputTokens,
+			&i.OutputTokens,
+			&i.TotalTokens,
+			&i.AvgLatencyMs,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -9,10 +9,24 @@ import (
 )
 
 type Article struct {
-	ID          int32
-	PodID       pgtype.Int4
-	ArticleText string
-	CreatedAt   pgtype.Timestamp
+	ID                 int32
+	PodID              pgtype.Int4
+	ArticleText        string
+	CreatedAt          pgtype.Timestamp
+	PromptVersion      string
+	Citations          []byte
+	NotesPromptVersion string
+}
+
+type CreditLedger struct {
+	ID        int32
+	UserID    string
+	Amount    int32
+	Reason    string
+	PodID     pgtype.Int4
+	JobID     pgtype.Int4
+	Note      pgtype.Text
+	CreatedAt pgtype.Timestamp
 }
 
 type Feedback struct {
@@ -22,18 +36,67 @@ type Feedback struct {
 }
 
 type Job struct {
-	ID        int32
-	PodID     int32
-	JobStatus int32
+	ID             int32
+	PodID          int32
+	Language       string
+	Stage          string
+	ErrorReason    pgtype.Text
+	Attempts       int32
+	CreatedAt      pgtype.Timestamp
+	StartedAt      pgtype.Timestamp
+	FinishedAt     pgtype.Timestamp
+	UpdatedAt      pgtype.Timestamp
+	LastError      pgtype.Text
+	ChargedCredits int32
+	ChunksDone     int32
+	ChunksTotal    int32
+	Cost           int32
+	Claims         int32
+}
+
+type LlmCall struct {
+	ID           int32
+	PodID        pgtype.Int4
+	JobID        pgtype.Int4
+	UserID       string
+	Model        string
+	Purpose      string
+	InputTokens  int32
+	OutputTokens int32
+	TotalTokens  int32
+	LatencyMs    int32
+	Error        pgtype.Text
+	CreatedAt    pgtype.Timestamp
 }
 
 type Pod struct {
+	ID                 int32
+	Title              string
+	Link               string
+	CreatedAt          pgtype.Timestamp
+	CreatedBy          string
+	IsPublic           pgtype.Bool
+	Language           string
+	SourcePodID        pgtype.Int4
+	YoutubeCategory    string
+	VerdictEducational pgtype.Bool
+	VerdictCategory    pgtype.Text
+	VerdictConfidence  pgtype.Float4
+	VerdictReason      pgtype.Text
+	VerdictSource      pgtype.Text
+	ClassifiedAt       pgtype.Timestamp
+	QuestionCount      pgtype.Int4
+	QuizDifficulty     string
+	SourceType         string
+}
+
+type PromptTemplate struct {
 	ID        int32
-	Title     string
-	Link      string
+	Kind      string
+	Version   string
+	Body      string
+	Weight    int32
 	CreatedAt pgtype.Timestamp
-	CreatedBy string
-	IsPublic  pgtype.Bool
 }
 
 type Question struct {
@@ -41,12 +104,27 @@ type Question struct {
 	QuizzesID     pgtype.Int4
 	QuestionText  string
 	Options       []string
-	CorrectOption int32
+	CorrectOption pgtype.Int4
+	Explanation   string
+	QuestionType  string
+	Answer        []byte
 }
 
 type Quiz struct {
+	ID            int32
+	PodID         pgtype.Int4
+	CreatedAt     pgtype.Timestamp
+	PromptVersion string
+	QuestionCount pgtype.Int4
+	Difficulty    string
+}
+
+type Transcript struct {
 	ID        int32
-	PodID     pgtype.Int4
+	PodID     int32
+	Provider  string
+	Language  string
+	Segments  []byte
 	CreatedAt pgtype.Timestamp
 }
 
@@ -55,3 +133,26 @@ type Usage struct {
 	UserID  string
 	Credits int32
 }
+
+type Webhook struct {
+	ID        int32
+	UserID    string
+	Url       string
+	Secret    string
+	Events    []string
+	CreatedAt pgtype.Timestamp
+}
+
+type WebhookDelivery struct {
+	ID             int32
+	WebhookID      int32
+	Event          string
+	Payload        []byte
+	Status         string
+	Attempts       int32
+	ResponseStatus pgtype.Int4
+	LastError      pgtype.Text
+	NextAttemptAt  pgtype.Timestamp
+	CreatedAt      pgtype.Timestamp
+	DeliveredAt    pgtype.Timestamp
+}
@@ -11,8 +11,78 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const copyPodVerdict = `-- name: CopyPodVerdict :exec
+UPDATE pods p
+SET verdict_educational = s.verdict_educational,
+    verdict_category = s.verdict_category,
+    verdict_confidence = s.verdict_confidence,
+    verdict_reason = s.verdict_reason,
+    verdict_source = s.verdict_source,
+    classified_at = s.classified_at
+FROM pods s
+WHERE s.id = $1 AND p.id = $2
+`
+
+type CopyPodVerdictParams struct {
+	SourcePodID int32
+	PodID       int32
+}
+
+func (q *Queries) CopyPodVerdict(ctx context.Context, arg CopyPodVerdictParams) error {
+	_, err := q.db.Exec(ctx, copyPodVerdict, arg.SourcePodID, arg.PodID)
+	return err
+}
+
+const getPodArtifactIDs = `-- name: GetPodArtifactIDs :one
+SELECT
+    COALESCE((SELECT a.id FROM articles a WHERE a.pod_id = $1 ORDER BY a.id DESC LIMIT 1), 0)::int AS article_id,
+    COALESCE((SELECT q.id FROM quizzes q WHERE q.pod_id = $1 ORDER BY q.id DESC LIMIT 1), 0)::int AS quiz_id
+`
+
+type GetPodArtifactIDsRow struct {
+	ArticleID int32
+	QuizID    int32
+}
+
+func (q *Queries) GetPodArtifactIDs(ctx context.Context, podID pgtype.Int4) (GetPodArtifactIDsRow, error) {
+	row := q.db.QueryRow(ctx, getPodArtifactIDs, podID)
+	var i GetPodArtifactIDsRow
+	err := row.Scan(&i.ArticleID, &i.QuizID)
+	return i, err
+}
+
+const getPodByID = `-- name: GetPodByID :one
+SELECT id, title, link, created_at, created_by, is_public, language, source_pod_id, youtube_category, verdict_educational, verdict_category, verdict_confidence, verdict_reason, verdict_source, classified_at, question_count, quiz_difficulty, source_type FROM pods WHERE id = $1
+`
+
+func (q *Queries) GetPodByID(ctx context.Context, id int32) (Pod, error) {
+	row := q.db.QueryRow(ctx, getPodByID, id)
+	var i Pod
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Link,
+		&i.CreatedAt,
+		&i.CreatedBy,
+		&i.IsPublic,
+		&i.Language,
+		&i.SourcePodID,
+		&i.YoutubeCategory,
+		&i.VerdictEducational,
+		&i.VerdictCategory,
+		&i.VerdictConfidence,
+		&i.VerdictReason,
+		&i.VerdictSource,
+		&i.ClassifiedAt,
+		&i.QuestionCount,
+		&i.QuizDifficulty,
+		&i.SourceType,
+	)
+	return i, err
+}
+
 const getPodByLink = `-- name: GetPodByLink :many
-select id, title, link, created_at, created_by, is_public from pods where link = $1
+select id, title, link, created_at, created_by, is_public, language, source_pod_id, youtube_category, verdict_educational, verdict_category, verdict_confidence, verdict_reason, verdict_source, classified_at, question_count, quiz_difficulty, source_type from pods where link = $1
 `
 
 func (q *Queries) GetPodByLink(ctx context.Context, link string) ([]Pod, error) {
@@ -31,6 +101,18 @@ func (q *Queries) GetPodByLink(ctx context.Context, link string) ([]Pod, error)
 			&i.CreatedAt,
 			&i.CreatedBy,
 			&i.IsPublic,
+			&i.Language,
+			&i.SourcePodID,
+			&i.YoutubeCategory,
+			&i.VerdictEducational,
+			&i.VerdictCategory,
+			&i.VerdictConfidence,
+			&i.VerdictReason,
+			&i.VerdictSource,
+			&i.ClassifiedAt,
+			&i.QuestionCount,
+			&i.QuizDifficulty,
+			&i.SourceType,
 		); err != nil {
 			return nil, err
 		}
@@ -59,7 +141,7 @@ func (q *Queries) GetPodOwner(ctx context.Context, id int32) (GetPodOwnerRow, er
 }
 
 const getPodsByUserID = `-- name: GetPodsByUserID :many
-SELECT id, title, link, created_at, created_by, is_public FROM pods WHERE created_by = $1
+SELECT id, title, link, created_at, created_by, is_public, language, source_pod_id, youtube_category, verdict_educational, verdict_category, verdict_confidence, verdict_reason, verdict_source, classified_at, question_count, quiz_difficulty, source_type FROM pods WHERE created_by = $1
 `
 
 func (q *Queries) GetPodsByUserID(ctx context.Context, createdBy string) ([]Pod, error) {
@@ -78,6 +160,18 @@ func (q *Queries) GetPodsByUserID(ctx context.Context, createdBy string) ([]Pod,
 			&i.CreatedAt,
 			&i.CreatedBy,
 			&i.IsPublic,
+			&i.Language,
+			&i.SourcePodID,
+			&i.YoutubeCategory,
+			&i.VerdictEducational,
+			&i.VerdictCategory,
+			&i.VerdictConfidence,
+			&i.VerdictReason,
+			&i.VerdictSource,
+			&i.ClassifiedAt,
+			&i.QuestionCount,
+			&i.QuizDifficulty,
+			&i.SourceType,
 		); err != nil {
 			return nil, err
 		}
@@ -89,25 +183,118 @@ func (q *Queries) GetPodsByUserID(ctx context.Context, createdBy string) ([]Pod,
 	return items, nil
 }
 
+const getReusablePod = `-- name: GetReusablePod :one
+SELECT p.id
+FROM pods p
+INNER JOIN jobs j ON j.pod_id = p.id
+WHERE p.link = $1
+  AND p.language = $2
+  AND p.question_count IS NOT DISTINCT FROM $3
+  AND p.quiz_difficulty = $4
+  AND j.stage = $5
+  AND EXISTS (SELECT 1 FROM articles a WHERE a.pod_id = p.id)
+  AND EXISTS (SELECT 1 FROM quizzes q WHERE q.pod_id = p.id)
+ORDER BY p.id DESC
+LIMIT 1
+`
+
+type GetReusablePodParams struct {
+	Link           string
+	Language       string
+	QuestionCount  pgtype.Int4
+	QuizDifficulty string
+	DoneStage      string
+}
+
+func (q *Queries) GetReusablePod(ctx context.Context, arg GetReusablePodParams) (int32, error) {
+	row := q.db.QueryRow(ctx, getReusablePod,
+		arg.Link,
+		arg.Language,
+		arg.QuestionCount,
+		arg.QuizDifficulty,
+		arg.DoneStage,
+	)
+	var id int32
+	err := row.Scan(&id)
+	return id, err
+}
+
 const insertPod = `-- name: InsertPod :one
-INSERT INTO pods (link,title,created_by)
-VALUES ($1,$2,$3)
+INSERT INTO pods (link,title,created_by,language,source_pod_id,youtube_category,question_count,quiz_difficulty,source_type)
+VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
 RETURNING id
 `
 
 type InsertPodParams struct {
-	Link      string
-	Title     string
-	CreatedBy string
+	Link            string
+	Title           string
+	CreatedBy       string
+	Language        string
+	SourcePodID     pgtype.Int4
+	YoutubeCategory string
+	QuestionCount   pgtype.Int4
+	QuizDifficulty  string
+	SourceType      string
 }
 
 func (q *Queries) InsertPod(ctx context.Context, arg InsertPodParams) (int32, error) {
-	row := q.db.QueryRow(ctx, insertPod, arg.Link, arg.Title, arg.CreatedBy)
+	row := q.db.QueryRow(ctx, insertPod,
+		arg.Link,
+		arg.Title,
+		arg.CreatedBy,
+		arg.Language,
+		arg.SourcePodID,
+		arg.YoutubeCategory,
+		arg.QuestionCount,
+		arg.QuizDifficulty,
+		arg.SourceType,
+	)
 	var id int32
 	err := row.Scan(&id)
 	return id, err
 }
 
+const lockPod = `-- name: LockPod :exec
+SELECT id FROM pods WHERE id = $1 FOR UPDATE
+`
+
+func (q *Queries) LockPod(ctx context.Context, id int32) error {
+	_, err := q.db.Exec(ctx, lockPod, id)
+	return err
+}
+
+const setPodVerdict = `-- name: SetPodVerdict :exec
+UPDATE pods
+SET verdict_educational = $2,
+    verdict_category = $3,
+    verdict_confidence = $4,
+    verdict_reason = $5,
+    verdict_source = $6,
+    classified_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+type SetPodVerdictParams struct {
+	ID                 int32
+	VerdictEducational pgtype.Bool
+	VerdictCategory    pgtype.Text
+	VerdictConfidence  pgtype.Float4
+	VerdictReason      pgtype.Text
+	VerdictSource      pgtype.Text
+}
+
+func (q *Queries) SetPodVerdict(ctx context.Context, arg SetPodVerdictParams) error {
+	_, err := q.db.Exec(ctx, setPodVerdict,
+		arg.ID,
+		arg.VerdictEducational,
+		arg.VerdictCategory,
+		arg.VerdictConfidence,
+		arg.VerdictReason,
+		arg.VerdictSource,
+	)
+	return err
+}
+
 const updatePodIsPublic = `-- name: UpdatePodIsPublic :exec
 UPDATE pods SET is_public = $1 WHERE id = $2
 `
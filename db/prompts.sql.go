@@ -0,0 +1,101 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: prompts.sql
+
+package db
+
+import (
+	"context"
+)
+
+const insertPromptTemplate = `-- name: InsertPromptTemplate :one
+INSERT INTO prompt_templates(kind, version, body, weight)
+VALUES($1, $2, $3, $4)
+RETURNING id, kind, version, body, weight, created_at
+`
+
+type InsertPromptTemplateParams struct {
+	Kind    string
+	Version string
+	Body    string
+	Weight  int32
+}
+
+func (q *Queries) InsertPromptTemplate(ctx context.Context, arg InsertPromptTemplateParams) (PromptTemplate, error) {
+	row := q.db.QueryRow(ctx, insertPromptTemplate,
+		arg.Kind,
+		arg.Version,
+		arg.Body,
+		arg.Weight,
+	)
+	var i PromptTemplate
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.Version,
+		&i.Body,
+		&i.Weight,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listPromptTemplates = `-- name: ListPromptTemplates :many
+SELECT id, kind, version, body, weight, created_at
+FROM prompt_templates
+ORDER BY kind, id
+`
+
+func (q *Queries) ListPromptTemplates(ctx context.Context) ([]PromptTemplate, error) {
+	rows, err := q.db.Query(ctx, listPromptTemplates)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PromptTemplate
+	for rows.Next() {
+		var i PromptTemplate
+		if err := rows.Scan(
+			&i.ID,
+			&i.Kind,
+			&i.Version,
+			&i.Body,
+			&i.Weight,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updatePromptTemplateWeight = `-- name: UpdatePromptTemplateWeight :one
+UPDATE prompt_templates
+SET weight = $2
+WHERE id = $1
+RETURNING id, kind, version, body, weight, created_at
+`
+
+type UpdatePromptTemplateWeightParams struct {
+	ID     int32
+	Weight int32
+}
+
+func (q *Queries) UpdatePromptTemplateWeight(ctx context.Context, arg UpdatePromptTemplateWeightParams) (PromptTemplate, error) {
+	row := q.db.QueryRow(ctx, updatePromptTemplateWeight, arg.ID, arg.Weight)
+	var i PromptTemplate
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.Version,
+		&i.Body,
+		&i.Weight,
+		&i.CreatedAt,
+	)
+	return i, err
+}
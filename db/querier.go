@@ -11,30 +11,84 @@ import (
 )
 
 type Querier interface {
-	DecrementCredit(ctx context.Context, arg DecrementCreditParams) (int32, error)
-	DeleteCredit(ctx context.Context, userID string) error
-	GetArticleByPodId(ctx context.Context, podID pgtype.Int4) (string, error)
+	AddCreditTransaction(ctx context.Context, arg AddCreditTransactionParams) (int32, error)
+	// The balance is only debited when it covers the cost; the job is locked so
+	// concurrent charges of the same job wait for each other.
+	ChargeJob(ctx context.Context, arg ChargeJobParams) (int32, error)
+	ClaimJob(ctx context.Context, arg ClaimJobParams) (ClaimJobRow, error)
+	ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]ClaimWebhookDeliveriesRow, error)
+	CloneArticle(ctx context.Context, arg CloneArticleParams) (int32, error)
+	CloneQuestions(ctx context.Context, arg CloneQuestionsParams) error
+	CloneTranscript(ctx context.Context, arg CloneTranscriptParams) error
+	CopyPodVerdict(ctx context.Context, arg CopyPodVerdictParams) error
+	CountCreditHistory(ctx context.Context, userID string) (int64, error)
+	DeleteWebhook(ctx context.Context, arg DeleteWebhookParams) (int64, error)
+	EnqueueWebhookDeliveries(ctx context.Context, arg EnqueueWebhookDeliveriesParams) (int64, error)
+	FailStaleJobs(ctx context.Context, arg FailStaleJobsParams) ([]FailStaleJobsRow, error)
+	FailWebhookDelivery(ctx context.Context, arg FailWebhookDeliveryParams) error
+	FinishJob(ctx context.Context, arg FinishJobParams) error
+	// Retries and reuse can store more than one article for a pod; the newest wins.
+	GetArticleByPodId(ctx context.Context, podID pgtype.Int4) (GetArticleByPodIdRow, error)
 	GetArticlePodInfo(ctx context.Context, podID pgtype.Int4) (GetArticlePodInfoRow, error)
-	GetJobStatusByID(ctx context.Context, id int32) (int32, error)
-	GetJobStatusByPodID(ctx context.Context, podID int32) (int32, error)
+	GetCreditHistory(ctx context.Context, arg GetCreditHistoryParams) ([]GetCreditHistoryRow, error)
+	GetJobByID(ctx context.Context, id int32) (Job, error)
+	GetJobCharge(ctx context.Context, id int32) (GetJobChargeRow, error)
+	GetLatestJobByPodID(ctx context.Context, podID int32) (Job, error)
+	GetPodArtifactIDs(ctx context.Context, podID pgtype.Int4) (GetPodArtifactIDsRow, error)
+	GetPodByID(ctx context.Context, id int32) (Pod, error)
 	GetPodByLink(ctx context.Context, link string) ([]Pod, error)
+	GetPodKeptCredits(ctx context.Context, podID pgtype.Int4) (int32, error)
 	GetPodOwner(ctx context.Context, id int32) (GetPodOwnerRow, error)
 	GetPodsByUserID(ctx context.Context, createdBy string) ([]Pod, error)
 	GetQuestionByQuizId(ctx context.Context, quizzesID pgtype.Int4) ([]GetQuestionByQuizIdRow, error)
+	// Retries and reuse can store more than one quiz for a pod; the newest wins.
 	GetQuizByPodId(ctx context.Context, podID pgtype.Int4) (GetQuizByPodIdRow, error)
 	GetQuizPodInfo(ctx context.Context, podID pgtype.Int4) (GetQuizPodInfoRow, error)
 	GetRemainingCredits(ctx context.Context, userID string) (int32, error)
-	InsertArticle(ctx context.Context, arg InsertArticleParams) error
-	InsertCredit(ctx context.Context, arg InsertCreditParams) error
+	GetReusablePod(ctx context.Context, arg GetReusablePodParams) (int32, error)
+	GetTranscriptByPodId(ctx context.Context, podID int32) (GetTranscriptByPodIdRow, error)
+	GetWebhookByID(ctx context.Context, id int32) (Webhook, error)
+	GrantInitialCredit(ctx context.Context, arg GrantInitialCreditParams) error
+	HasNewerPodJob(ctx context.Context, id int32) (bool, error)
+	InsertArticle(ctx context.Context, arg InsertArticleParams) (int32, error)
 	InsertFeedback(ctx context.Context, arg InsertFeedbackParams) error
-	InsertJob(ctx context.Context, podID int32) (int32, error)
+	InsertJob(ctx context.Context, arg InsertJobParams) (int32, error)
+	InsertLLMCall(ctx context.Context, arg InsertLLMCallParams) error
 	InsertPod(ctx context.Context, arg InsertPodParams) (int32, error)
-	InsertQuestion(ctx context.Context, arg InsertQuestionParams) (int32, error)
-	InsertQuiz(ctx context.Context, podID pgtype.Int4) (int32, error)
+	InsertPromptTemplate(ctx context.Context, arg InsertPromptTemplateParams) (PromptTemplate, error)
+	InsertQuiz(ctx context.Context, arg InsertQuizParams) (int32, error)
+	// questions is a JSON array of store.Question; the quiz only exists once all
+	// of its questions are stored.
+	InsertQuizWithQuestions(ctx context.Context, arg InsertQuizWithQuestionsParams) (int32, error)
+	InsertTranscript(ctx context.Context, arg InsertTranscriptParams) error
+	InsertWebhook(ctx context.Context, arg InsertWebhookParams) (Webhook, error)
 	IsCreditExist(ctx context.Context, userID string) (bool, error)
-	UpdateCredit(ctx context.Context, arg UpdateCreditParams) (int32, error)
-	UpdateJobStatusByID(ctx context.Context, arg UpdateJobStatusByIDParams) error
+	LLMUsageByDay(ctx context.Context, arg LLMUsageByDayParams) ([]LLMUsageByDayRow, error)
+	LLMUsageByPod(ctx context.Context, arg LLMUsageByPodParams) ([]LLMUsageByPodRow, error)
+	LLMUsageByUser(ctx context.Context, arg LLMUsageByUserParams) ([]LLMUsageByUserRow, error)
+	ListJobsByStage(ctx context.Context, arg ListJobsByStageParams) ([]ListJobsByStageRow, error)
+	ListPromptTemplates(ctx context.Context) ([]PromptTemplate, error)
+	ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	ListWebhooksByUser(ctx context.Context, userID string) ([]Webhook, error)
+	LockJobPod(ctx context.Context, id int32) (int32, error)
+	LockPod(ctx context.Context, id int32) error
+	MarkWebhookDelivered(ctx context.Context, arg MarkWebhookDeliveredParams) error
+	RecordJobRetry(ctx context.Context, arg RecordJobRetryParams) error
+	// Refunding zeroes the job's charged_credits, so a job is refunded at most
+	// once per charge and is charged again if it is requeued.
+	RefundJob(ctx context.Context, arg RefundJobParams) (int32, error)
+	ReleaseJob(ctx context.Context, arg ReleaseJobParams) error
+	// charged_credits is kept: failed jobs were refunded, which zeroed it, so
+	// the rerun is charged again unless the refund never happened. Only the
+	// latest job of a pod is requeued, as a newer one already reruns the pod.
+	RequeueJob(ctx context.Context, arg RequeueJobParams) (int32, error)
+	RequeueStaleJobs(ctx context.Context, arg RequeueStaleJobsParams) ([]int32, error)
+	RetryWebhookDelivery(ctx context.Context, arg RetryWebhookDeliveryParams) error
+	SetPodVerdict(ctx context.Context, arg SetPodVerdictParams) error
+	UpdateJobChunkProgress(ctx context.Context, arg UpdateJobChunkProgressParams) error
+	UpdateJobStage(ctx context.Context, arg UpdateJobStageParams) error
 	UpdatePodIsPublic(ctx context.Context, arg UpdatePodIsPublicParams) error
+	UpdatePromptTemplateWeight(ctx context.Context, arg UpdatePromptTemplateWeightParams) (PromptTemplate, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -11,15 +11,36 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const cloneQuestions = `-- name: CloneQuestions :exec
+INSERT INTO questions (quizzes_id, question_type, question_text, options, correct_option, answer, explanation)
+SELECT $1, q.question_type, q.question_text, q.options, q.correct_option, q.answer, q.explanation
+FROM questions q
+WHERE q.quizzes_id = $2
+ORDER BY q.id
+`
+
+type CloneQuestionsParams struct {
+	QuizID       pgtype.Int4
+	SourceQuizID pgtype.Int4
+}
+
+func (q *Queries) CloneQuestions(ctx context.Context, arg CloneQuestionsParams) error {
+	_, err := q.db.Exec(ctx, cloneQuestions, arg.QuizID, arg.SourceQuizID)
+	return err
+}
+
 const getQuestionByQuizId = `-- name: GetQuestionByQuizId :many
-SELECT id,question_text,options,correct_option FROM questions WHERE quizzes_id = $1
+SELECT id,question_type,question_text,options,correct_option,answer,explanation FROM questions WHERE quizzes_id = $1
 `
 
 type GetQuestionByQuizIdRow struct {
 	ID            int32
+	QuestionType  string
 	QuestionText  string
 	Options       []string
-	CorrectOption int32
+	CorrectOption pgtype.Int4
+	Answer        []byte
+	Explanation   string
 }
 
 func (q *Queries) GetQuestionByQuizId(ctx context.Context, quizzesID pgtype.Int4) ([]GetQuestionByQuizIdRow, error) {
@@ -33,9 +54,12 @@ func (q *Queries) GetQuestionByQuizId(ctx context.Context, quizzesID pgtype.Int4
 		var i GetQuestionByQuizIdRow
 		if err := rows.Scan(
 			&i.ID,
+			&i.QuestionType,
 			&i.QuestionText,
 			&i.Options,
 			&i.CorrectOption,
+			&i.Answer,
+			&i.Explanation,
 		); err != nil {
 			return nil, err
 		}
@@ -46,28 +70,3 @@ func (q *Queries) GetQuestionByQuizId(ctx context.Context, quizzesID pgtype.Int4
 	}
 	return items, nil
 }
-
-const insertQuestion = `-- name: InsertQuestion :one
-INSERT INTO questions (quizzes_id, question_text, options, correct_option)
-VALUES ($1, $2, $3, $4)
-RETURNING id
-`
-
-type InsertQuestionParams struct {
-	QuizzesID     pgtype.Int4
-	QuestionText  string
-	Options       []string
-	CorrectOption int32
-}
-
-func (q *Queries) InsertQuestion(ctx context.Context, arg InsertQuestionParams) (int32, error) {
-	row := q.db.QueryRow(ctx, insertQuestion,
-		arg.QuizzesID,
-		arg.QuestionText,
-		arg.Options,
-		arg.CorrectOption,
-	)
-	var id int32
-	err := row.Scan(&id)
-	return id, err
-}
@@ -12,18 +12,28 @@ import (
 )
 
 const getQuizByPodId = `-- name: GetQuizByPodId :one
-SELECT id,pod_id FROM quizzes WHERE pod_id = $1 LIMIT 1
+SELECT id,pod_id,prompt_version,question_count,difficulty FROM quizzes WHERE pod_id = $1 ORDER BY id DESC LIMIT 1
 `
 
 type GetQuizByPodIdRow struct {
-	ID    int32
-	PodID pgtype.Int4
+	ID            int32
+	PodID         pgtype.Int4
+	PromptVersion string
+	QuestionCount pgtype.Int4
+	Difficulty    string
 }
 
+// Retries and reuse can store more than one quiz for a pod; the newest wins.
 func (q *Queries) GetQuizByPodId(ctx context.Context, podID pgtype.Int4) (GetQuizByPodIdRow, error) {
 	row := q.db.QueryRow(ctx, getQuizByPodId, podID)
 	var i GetQuizByPodIdRow
-	err := row.Scan(&i.ID, &i.PodID)
+	err := row.Scan(
+		&i.ID,
+		&i.PodID,
+		&i.PromptVersion,
+		&i.QuestionCount,
+		&i.Difficulty,
+	)
 	return i, err
 }
 
@@ -44,13 +54,68 @@ func (q *Queries) GetQuizPodInfo(ctx context.Context, podID pgtype.Int4) (GetQui
 }
 
 const insertQuiz = `-- name: InsertQuiz :one
-INSERT INTO quizzes (pod_id)
-VALUES ($1)
+INSERT INTO quizzes (pod_id, prompt_version, question_count, difficulty)
+VALUES ($1, $2, $3, $4)
 RETURNING id
 `
 
-func (q *Queries) InsertQuiz(ctx context.Context, podID pgtype.Int4) (int32, error) {
-	row := q.db.QueryRow(ctx, insertQuiz, podID)
+type InsertQuizParams struct {
+	PodID         pgtype.Int4
+	PromptVersion string
+	QuestionCount pgtype.Int4
+	Difficulty    string
+}
+
+func (q *Queries) InsertQuiz(ctx context.Context, arg InsertQuizParams) (int32, error) {
+	row := q.db.QueryRow(ctx, insertQuiz,
+		arg.PodID,
+		arg.PromptVersion,
+		arg.QuestionCount,
+		arg.Difficulty,
+	)
+	var id int32
+	err := row.Scan(&id)
+	return id, err
+}
+
+const insertQuizWithQuestions = `-- name: InsertQuizWithQuestions :one
+WITH quiz AS (
+    INSERT INTO quizzes (pod_id, prompt_version, question_count, difficulty)
+    VALUES ($1, $2, $3, $4)
+    RETURNING id
+), inserted AS (
+    INSERT INTO questions (quizzes_id, question_type, question_text, options, correct_option, answer, explanation)
+    SELECT quiz.id,
+           q.question->>'type',
+           q.question->>'question',
+           ARRAY(SELECT jsonb_array_elements_text(q.question->'options')),
+           (q.question->>'correct_answer_index')::int,
+           NULLIF(q.question->'answer', 'null'::jsonb),
+           q.question->>'explanation'
+    FROM quiz, jsonb_array_elements($5::jsonb) WITH ORDINALITY AS q(question, position)
+    ORDER BY q.position
+)
+SELECT id FROM quiz
+`
+
+type InsertQuizWithQuestionsParams struct {
+	PodID         pgtype.Int4
+	PromptVersion string
+	QuestionCount pgtype.Int4
+	Difficulty    string
+	Questions     []byte
+}
+
+// questions is a JSON array of store.Question; the quiz only exists once all
+// of its questions are stored.
+func (q *Queries) InsertQuizWithQuestions(ctx context.Context, arg InsertQuizWithQuestionsParams) (int32, error) {
+	row := q.db.QueryRow(ctx, insertQuizWithQuestions,
+		arg.PodID,
+		arg.PromptVersion,
+		arg.QuestionCount,
+		arg.Difficulty,
+		arg.Questions,
+	)
 	var id int32
 	err := row.Scan(&id)
 	return id, err
@@ -0,0 +1,76 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: transcripts.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const cloneTranscript = `-- name: CloneTranscript :exec
+INSERT INTO transcripts (pod_id, provider, language, segments)
+SELECT $1, t.provider, t.language, t.segments
+FROM transcripts t
+WHERE t.pod_id = $2
+ON CONFLICT (pod_id) DO NOTHING
+`
+
+type CloneTranscriptParams struct {
+	PodID       int32
+	SourcePodID int32
+}
+
+func (q *Queries) CloneTranscript(ctx context.Context, arg CloneTranscriptParams) error {
+	_, err := q.db.Exec(ctx, cloneTranscript, arg.PodID, arg.SourcePodID)
+	return err
+}
+
+const getTranscriptByPodId = `-- name: GetTranscriptByPodId :one
+SELECT provider, language, segments, created_at FROM transcripts WHERE pod_id = $1
+`
+
+type GetTranscriptByPodIdRow struct {
+	Provider  string
+	Language  string
+	Segments  []byte
+	CreatedAt pgtype.Timestamp
+}
+
+func (q *Queries) GetTranscriptByPodId(ctx context.Context, podID int32) (GetTranscriptByPodIdRow, error) {
+	row := q.db.QueryRow(ctx, getTranscriptByPodId, podID)
+	var i GetTranscriptByPodIdRow
+	err := row.Scan(
+		&i.Provider,
+		&i.Language,
+		&i.Segments,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const insertTranscript = `-- name: InsertTranscript :exec
+INSERT INTO transcripts (pod_id, provider, language, segments)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (pod_id) DO NOTHING
+`
+
+type InsertTranscriptParams struct {
+	PodID    int32
+	Provider string
+	Language string
+	Segments []byte
+}
+
+func (q *Queries) InsertTranscript(ctx context.Context, arg InsertTranscriptParams) error {
+	_, err := q.db.Exec(ctx, insertTranscript,
+		arg.PodID,
+		arg.Provider,
+		arg.Language,
+		arg.Segments,
+	)
+	return err
+}
@@ -7,31 +7,175 @@ package db
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
-const decrementCredit = `-- name: DecrementCredit :one
-UPDATE usage SET credits = credits - $1 WHERE user_id = $2 RETURNING credits
+const addCreditTransaction = `-- name: AddCreditTransaction :one
+WITH entry AS (
+    INSERT INTO credit_ledger (user_id, amount, reason, pod_id, job_id, note)
+    VALUES ($1, $2, $3, $4, $5, $6)
+    RETURNING user_id, amount
+)
+UPDATE usage u
+SET credits = u.credits + entry.amount
+FROM entry
+WHERE u.user_id = entry.user_id
+RETURNING u.credits
 `
 
-type DecrementCreditParams struct {
-	Credits int32
-	UserID  string
+type AddCreditTransactionParams struct {
+	UserID string
+	Amount int32
+	Reason string
+	PodID  pgtype.Int4
+	JobID  pgtype.Int4
+	Note   pgtype.Text
 }
 
-func (q *Queries) DecrementCredit(ctx context.Context, arg DecrementCreditParams) (int32, error) {
-	row := q.db.QueryRow(ctx, decrementCredit, arg.Credits, arg.UserID)
+func (q *Queries) AddCreditTransaction(ctx context.Context, arg AddCreditTransactionParams) (int32, error) {
+	row := q.db.QueryRow(ctx, addCreditTransaction,
+		arg.UserID,
+		arg.Amount,
+		arg.Reason,
+		arg.PodID,
+		arg.JobID,
+		arg.Note,
+	)
 	var credits int32
 	err := row.Scan(&credits)
 	return credits, err
 }
 
-const deleteCredit = `-- name: DeleteCredit :exec
-DELETE FROM usage WHERE user_id = $1
+const chargeJob = `-- name: ChargeJob :one
+WITH job AS (
+    SELECT j.id, j.pod_id, j.cost
+    FROM jobs j
+    WHERE j.id = $1 AND j.charged_credits = 0 AND j.cost > 0
+    FOR UPDATE
+), debit AS (
+    UPDATE usage u
+    SET credits = u.credits - job.cost
+    FROM job
+    WHERE u.user_id = $2 AND u.credits >= job.cost
+    RETURNING u.credits, job.id, job.pod_id, job.cost
+), charged AS (
+    UPDATE jobs j
+    SET charged_credits = d.cost, updated_at = CURRENT_TIMESTAMP
+    FROM debit d
+    WHERE j.id = d.id
+), entry AS (
+    INSERT INTO credit_ledger (user_id, amount, reason, pod_id, job_id)
+    SELECT $2, -d.cost, 'pod_debit', d.pod_id, d.id
+    FROM debit d
+)
+SELECT credits FROM debit
 `
 
-func (q *Queries) DeleteCredit(ctx context.Context, userID string) error {
-	_, err := q.db.Exec(ctx, deleteCredit, userID)
-	return err
+type ChargeJobParams struct {
+	JobID  int32
+	UserID string
+}
+
+// The balance is only debited when it covers the cost; the job is locked so
+// concurrent charges of the same job wait for each other.
+func (q *Queries) ChargeJob(ctx context.Context, arg ChargeJobParams) (int32, error) {
+	row := q.db.QueryRow(ctx, chargeJob, arg.JobID, arg.UserID)
+	var credits int32
+	err := row.Scan(&credits)
+	return credits, err
+}
+
+const countCreditHistory = `-- name: CountCreditHistory :one
+SELECT COUNT(*) FROM credit_ledger WHERE user_id = $1
+`
+
+func (q *Queries) CountCreditHistory(ctx context.Context, userID string) (int64, error) {
+	row := q.db.QueryRow(ctx, countCreditHistory, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const getCreditHistory = `-- name: GetCreditHistory :many
+SELECT id, amount, reason, pod_id, job_id, note, created_at
+FROM credit_ledger
+WHERE user_id = $1
+ORDER BY id DESC
+LIMIT $2 OFFSET $3
+`
+
+type GetCreditHistoryParams struct {
+	UserID string
+	Limit  int32
+	Offset int32
+}
+
+type GetCreditHistoryRow struct {
+	ID        int32
+	Amount    int32
+	Reason    string
+	PodID     pgtype.Int4
+	JobID     pgtype.Int4
+	Note      pgtype.Text
+	CreatedAt pgtype.Timestamp
+}
+
+func (q *Queries) GetCreditHistory(ctx context.Context, arg GetCreditHistoryParams) ([]GetCreditHistoryRow, error) {
+	rows, err := q.db.Query(ctx, getCreditHistory, arg.UserID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetCreditHistoryRow
+	for rows.Next() {
+		var i GetCreditHistoryRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Amount,
+			&i.Reason,
+			&i.PodID,
+			&i.JobID,
+			&i.Note,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getJobCharge = `-- name: GetJobCharge :one
+SELECT cost, charged_credits FROM jobs WHERE id = $1
+`
+
+type GetJobChargeRow struct {
+	Cost           int32
+	ChargedCredits int32
+}
+
+func (q *Queries) GetJobCharge(ctx context.Context, id int32) (GetJobChargeRow, error) {
+	row := q.db.QueryRow(ctx, getJobCharge, id)
+	var i GetJobChargeRow
+	err := row.Scan(&i.Cost, &i.ChargedCredits)
+	return i, err
+}
+
+const getPodKeptCredits = `-- name: GetPodKeptCredits :one
+SELECT (-COALESCE(SUM(amount), 0))::int AS kept
+FROM credit_ledger
+WHERE pod_id = $1 AND reason IN ('pod_debit', 'refund')
+`
+
+func (q *Queries) GetPodKeptCredits(ctx context.Context, podID pgtype.Int4) (int32, error) {
+	row := q.db.QueryRow(ctx, getPodKeptCredits, podID)
+	var kept int32
+	err := row.Scan(&kept)
+	return kept, err
 }
 
 const getRemainingCredits = `-- name: GetRemainingCredits :one
@@ -45,17 +189,24 @@ func (q *Queries) GetRemainingCredits(ctx context.Context, userID string) (int32
 	return credits, err
 }
 
-const insertCredit = `-- name: InsertCredit :exec
-INSERT INTO usage (user_id, credits) VALUES ($1, $2)
+const grantInitialCredit = `-- name: GrantInitialCredit :exec
+WITH entry AS (
+    INSERT INTO credit_ledger (user_id, amount, reason)
+    VALUES ($1, $2, $3)
+    RETURNING user_id, amount
+)
+INSERT INTO usage (user_id, credits)
+SELECT user_id, amount FROM entry
 `
 
-type InsertCreditParams struct {
-	UserID  string
-	Credits int32
+type GrantInitialCreditParams struct {
+	UserID string
+	Amount int32
+	Reason string
 }
 
-func (q *Queries) InsertCredit(ctx context.Context, arg InsertCreditParams) error {
-	_, err := q.db.Exec(ctx, insertCredit, arg.UserID, arg.Credits)
+func (q *Queries) GrantInitialCredit(ctx context.Context, arg GrantInitialCreditParams) error {
+	_, err := q.db.Exec(ctx, grantInitialCredit, arg.UserID, arg.Amount, arg.Reason)
 	return err
 }
 
@@ -70,18 +221,40 @@ func (q *Queries) IsCreditExist(ctx context.Context, userID string) (bool, error
 	return exists, err
 }
 
-const updateCredit = `-- name: UpdateCredit :one
-UPDATE usage SET credits = $2 WHERE user_id = $1 RETURNING credits
+const refundJob = `-- name: RefundJob :one
+WITH job AS (
+    SELECT j.id, j.pod_id, j.charged_credits
+    FROM jobs j
+    WHERE j.id = $1 AND j.charged_credits > 0
+    FOR UPDATE
+), refunded AS (
+    UPDATE jobs j
+    SET charged_credits = 0, updated_at = CURRENT_TIMESTAMP
+    FROM job
+    WHERE j.id = job.id
+), entry AS (
+    INSERT INTO credit_ledger (user_id, amount, reason, pod_id, job_id)
+    SELECT $2, job.charged_credits, 'refund', job.pod_id, job.id
+    FROM job
+    RETURNING user_id, amount
+)
+UPDATE usage u
+SET credits = u.credits + entry.amount
+FROM entry
+WHERE u.user_id = entry.user_id
+RETURNING entry.amount
 `
 
-type UpdateCreditParams struct {
-	UserID  string
-	Credits int32
+type RefundJobParams struct {
+	JobID  int32
+	UserID string
 }
 
-func (q *Queries) UpdateCredit(ctx context.Context, arg UpdateCreditParams) (int32, error) {
-	row := q.db.QueryRow(ctx, updateCredit, arg.UserID, arg.Credits)
-	var credits int32
-	err := row.Scan(&credits)
-	return credits, err
+// Refunding zeroes the job's charged_credits, so a job is refunded at most
+// once per charge and is charged again if it is requeued.
+func (q *Queries) RefundJob(ctx context.Context, arg RefundJobParams) (int32, error) {
+	row := q.db.QueryRow(ctx, refundJob, arg.JobID, arg.UserID)
+	var amount int32
+	err := row.Scan(&amount)
+	return amount, err
 }
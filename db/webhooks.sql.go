@@ -0,0 +1,307 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: webhooks.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimWebhookDeliveries = `-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries d
+SET next_attempt_at = CURRENT_TIMESTAMP + ($1::int * INTERVAL '1 second')
+FROM webhooks w
+WHERE w.id = d.webhook_id AND d.id IN (
+    SELECT q.id FROM webhook_deliveries q
+    WHERE q.status = 'pending' AND q.next_attempt_at <= CURRENT_TIMESTAMP
+    ORDER BY q.next_attempt_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING d.id, d.webhook_id, d.event, d.payload, d.attempts, w.url, w.secret
+`
+
+type ClaimWebhookDeliveriesParams struct {
+	LeaseSeconds int32
+	BatchSize    int32
+}
+
+type ClaimWebhookDeliveriesRow struct {
+	ID        int32
+	WebhookID int32
+	Event     string
+	Payload   []byte
+	Attempts  int32
+	Url       string
+	Secret    string
+}
+
+func (q *Queries) ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]ClaimWebhookDeliveriesRow, error) {
+	rows, err := q.db.Query(ctx, claimWebhookDeliveries, arg.LeaseSeconds, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ClaimWebhookDeliveriesRow
+	for rows.Next() {
+		var i ClaimWebhookDeliveriesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.WebhookID,
+			&i.Event,
+			&i.Payload,
+			&i.Attempts,
+			&i.Url,
+			&i.Secret,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteWebhook = `-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE id = $1 AND user_id = $2
+`
+
+type DeleteWebhookParams struct {
+	ID     int32
+	UserID string
+}
+
+func (q *Queries) DeleteWebhook(ctx context.Context, arg DeleteWebhookParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteWebhook, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const enqueueWebhookDeliveries = `-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries(webhook_id, event, payload)
+SELECT w.id, $1, $2
+FROM webhooks w
+WHERE w.user_id = $3 AND $1::text = ANY(w.events)
+`
+
+type EnqueueWebhookDeliveriesParams struct {
+	Event   string
+	Payload []byte
+	UserID  string
+}
+
+func (q *Queries) EnqueueWebhookDeliveries(ctx context.Context, arg EnqueueWebhookDeliveriesParams) (int64, error) {
+	result, err := q.db.Exec(ctx, enqueueWebhookDeliveries, arg.Event, arg.Payload, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const failWebhookDelivery = `-- name: FailWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = 'failed',
+    attempts = attempts + 1,
+    response_status = $1,
+    last_error = $2
+WHERE id = $3
+`
+
+type FailWebhookDeliveryParams struct {
+	ResponseStatus pgtype.Int4
+	LastError      pgtype.Text
+	ID             int32
+}
+
+func (q *Queries) FailWebhookDelivery(ctx context.Context, arg FailWebhookDeliveryParams) error {
+	_, err := q.db.Exec(ctx, failWebhookDelivery, arg.ResponseStatus, arg.LastError, arg.ID)
+	return err
+}
+
+const getWebhookByID = `-- name: GetWebhookByID :one
+SELECT id, user_id, url, secret, events, created_at
+FROM webhooks
+WHERE id = $1
+`
+
+func (q *Queries) GetWebhookByID(ctx context.Context, id int32) (Webhook, error) {
+	row := q.db.QueryRow(ctx, getWebhookByID, id)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		&i.Events,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const insertWebhook = `-- name: InsertWebhook :one
+INSERT INTO webhooks(user_id, url, secret, events)
+VALUES($1, $2, $3, $4)
+RETURNING id, user_id, url, secret, events, created_at
+`
+
+type InsertWebhookParams struct {
+	UserID string
+	Url    string
+	Secret string
+	Events []string
+}
+
+func (q *Queries) InsertWebhook(ctx context.Context, arg InsertWebhookParams) (Webhook, error) {
+	row := q.db.QueryRow(ctx, insertWebhook,
+		arg.UserID,
+		arg.Url,
+		arg.Secret,
+		arg.Events,
+	)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		&i.Events,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listWebhookDeliveries = `-- name: ListWebhookDeliveries :many
+SELECT id, webhook_id, event, payload, status, attempts, response_status, last_error,
+       next_attempt_at, created_at, delivered_at
+FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY id DESC
+LIMIT $2 OFFSET $3
+`
+
+type ListWebhookDeliveriesParams struct {
+	WebhookID int32
+	Limit     int32
+	Offset    int32
+}
+
+func (q *Queries) ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.Query(ctx, listWebhookDeliveries, arg.WebhookID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.WebhookID,
+			&i.Event,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.ResponseStatus,
+			&i.LastError,
+			&i.NextAttemptAt,
+			&i.CreatedAt,
+			&i.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhooksByUser = `-- name: ListWebhooksByUser :many
+SELECT id, user_id, url, secret, events, created_at
+FROM webhooks
+WHERE user_id = $1
+ORDER BY id
+`
+
+func (q *Queries) ListWebhooksByUser(ctx context.Context, userID string) ([]Webhook, error) {
+	rows, err := q.db.Query(ctx, listWebhooksByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Webhook
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Url,
+			&i.Secret,
+			&i.Events,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markWebhookDelivered = `-- name: MarkWebhookDelivered :exec
+UPDATE webhook_deliveries
+SET status = 'delivered',
+    attempts = attempts + 1,
+    response_status = $2,
+    last_error = NULL,
+    delivered_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+type MarkWebhookDeliveredParams struct {
+	ID             int32
+	ResponseStatus pgtype.Int4
+}
+
+func (q *Queries) MarkWebhookDelivered(ctx context.Context, arg MarkWebhookDeliveredParams) error {
+	_, err := q.db.Exec(ctx, markWebhookDelivered, arg.ID, arg.ResponseStatus)
+	return err
+}
+
+const retryWebhookDelivery = `-- name: RetryWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET attempts = attempts + 1,
+    response_status = $1,
+    last_error = $2,
+    next_attempt_at = CURRENT_TIMESTAMP + ($3::int * INTERVAL '1 millisecond')
+WHERE id = $4
+`
+
+type RetryWebhookDeliveryParams struct {
+	ResponseStatus pgtype.Int4
+	LastError      pgtype.Text
+	DelayMs        int32
+	ID             int32
+}
+
+func (q *Queries) RetryWebhookDelivery(ctx context.Context, arg RetryWebhookDeliveryParams) error {
+	_, err := q.db.Exec(ctx, retryWebhookDelivery,
+		arg.ResponseStatus,
+		arg.LastError,
+		arg.DelayMs,
+		arg.ID,
+	)
+	return err
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ChunkConfig controls map-reduce article generation. Transcripts over
+// Budget tokens are split into chunks of about ChunkTokens tokens that share
+// OverlapTokens tokens with their neighbour, so no idea is cut in half.
+type ChunkConfig struct {
+	Budget        int
+	ChunkTokens   int
+	OverlapTokens int
+}
+
+// articleChunking reads ARTICLE_TOKEN_BUDGET, ARTICLE_CHUNK_TOKENS and
+// ARTICLE_CHUNK_OVERLAP_TOKENS. A budget of 0 turns chunking off.
+func articleChunking() ChunkConfig {
+	cfg := ChunkConfig{Budget: 60000, ChunkTokens: 20000, OverlapTokens: 500}
+	if v, err := strconv.Atoi(os.Getenv("ARTICLE_TOKEN_BUDGET")); err == nil && v >= 0 {
+		cfg.Budget = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("ARTICLE_CHUNK_TOKENS")); err == nil && v > 0 {
+		cfg.ChunkTokens = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("ARTICLE_CHUNK_OVERLAP_TOKENS")); err == nil && v >= 0 {
+		cfg.OverlapTokens = v
+	}
+	return cfg
+}
+
+// splitTranscript cuts text into overlapping chunks on word boundaries.
+// tokens is the token count of the whole text; chunk sizes are converted to
+// words using its ratio of words to tokens.
+func splitTranscript(text string, tokens int, cfg ChunkConfig) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 || tokens <= 0 {
+		return []string{text}
+	}
+	wordsPerToken := float64(len(words)) / float64(tokens)
+	size := max(1, int(float64(cfg.ChunkTokens)*wordsPerToken))
+	// Overlapping more than half a chunk would make little progress per chunk
+	overlap := min(int(float64(cfg.OverlapTokens)*wordsPerToken), size/2)
+
+	var chunks []string
+	for start := 0; ; start += size - overlap {
+		end := min(start+size, len(words))
+		chunks = append(chunks, strings.Join(words[start:end], " "))
+		if end == len(words) {
+			return chunks
+		}
+	}
+}
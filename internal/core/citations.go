@@ -0,0 +1,187 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/demirbey05/auth-demo/internal/store"
+)
+
+// timedLineSpan is about how much speech goes on one line of a timed
+// transcript. Segments are a few seconds long, which would make every other
+// word a timestamp.
+const timedLineSpan = 30 * time.Second
+
+// citationMarkerRe matches the [t=m:ss] or [t=h:mm:ss] markers the article
+// prompt ends section headings with.
+var citationMarkerRe = regexp.MustCompile(`[ \t]*\[t=(\d{1,3}(?::\d{2}){1,2})\]`)
+
+// TimedTranscript writes the transcript as lines starting with the [m:ss]
+// time they are spoken at. It returns false when the provider gave no timing,
+// in which case the plain text should be used.
+func TimedTranscript(t store.Transcript) (string, bool) {
+	timed := false
+	for _, s := range t.Segments {
+		if s.StartMs > 0 || s.DurationMs > 0 {
+			timed = true
+			break
+		}
+	}
+	if !timed {
+		return "", false
+	}
+
+	var b strings.Builder
+	lineStart := -1
+	for _, s := range sortedSegments(t.Segments) {
+		text := strings.TrimSpace(s.Text)
+		if text == "" {
+			continue
+		}
+		if lineStart < 0 || time.Duration(s.StartMs-lineStart)*time.Millisecond >= timedLineSpan {
+			if lineStart >= 0 {
+				b.WriteByte('\n')
+			}
+			lineStart = s.StartMs
+			fmt.Fprintf(&b, "[%s]", formatTimestamp(s.StartMs))
+		}
+		b.WriteByte(' ')
+		b.WriteString(text)
+	}
+	return b.String(), true
+}
+
+// ExtractCitations removes the [t=m:ss] markers from a generated article and
+// returns the clean article with the sections they cite, in article order.
+// Heading counts every heading of the article, as the section-N ids of the
+// exports do. Times outside the transcript are dropped; the others are moved
+// back to the start of the segment they fall in, so playback starts at the
+// beginning of a sentence. A section ends where the next cited section
+// starts, or at the end of the transcript.
+func ExtractCitations(article string, transcript store.Transcript) (string, []store.Citation) {
+	src := strings.ReplaceAll(strings.TrimSpace(article), "\r\n", "\n")
+	if m := mdWrappedRe.FindStringSubmatch(src); m != nil {
+		src = m[1]
+	}
+	segments := sortedSegments(transcript.Segments)
+
+	var citations []store.Citation
+	lines := strings.Split(src, "\n")
+	heading := 0
+	fence := ""
+	for i, line := range lines {
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if m := mdFenceRe.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+			continue
+		}
+
+		marker := citationMarkerRe.FindStringSubmatch(line)
+		line = citationMarkerRe.ReplaceAllString(line, "")
+		lines[i] = line
+		m := mdHeadingRe.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		heading++
+		if marker == nil {
+			continue
+		}
+		ms, ok := parseTimestamp(marker[1])
+		if !ok {
+			continue
+		}
+		if start, ok := segmentStart(segments, ms); ok {
+			citations = append(citations, store.Citation{
+				Heading: heading,
+				Section: spansText(parseInline(m[2])),
+				StartMs: start,
+			})
+		}
+	}
+
+	starts := make([]int, len(citations))
+	for i, c := range citations {
+		starts[i] = c.StartMs
+	}
+	sort.Ints(starts)
+	end := transcriptEnd(segments)
+	for i := range citations {
+		citations[i].EndMs = end
+		// The first start after this one, if any
+		if j := sort.SearchInts(starts, citations[i].StartMs+1); j < len(starts) {
+			citations[i].EndMs = starts[j]
+		}
+	}
+	return strings.Join(lines, "\n"), citations
+}
+
+// CitationURL links to the video at startMs. Only YouTube links can be
+// opened at a time; other links get "".
+func CitationURL(link string, startMs int) string {
+	canonical, err := CanonicalizeYouTubeURL(link)
+	if err != nil {
+		return ""
+	}
+	return canonical + "&t=" + url.QueryEscape(strconv.Itoa(startMs/1000)+"s")
+}
+
+// segmentStart returns the start of the segment spoken at ms. A gap after a
+// segment belongs to it, and a time before the first segment to the first.
+func segmentStart(segments []store.TranscriptSegment, ms int) (int, bool) {
+	if len(segments) == 0 || ms >= transcriptEnd(segments) {
+		return 0, false
+	}
+	i := sort.Search(len(segments), func(i int) bool { return segments[i].StartMs > ms })
+	if i == 0 {
+		return segments[0].StartMs, true
+	}
+	return segments[i-1].StartMs, true
+}
+
+// transcriptEnd is when the last segment of the transcript ends.
+func transcriptEnd(segments []store.TranscriptSegment) int {
+	end := 0
+	for _, s := range segments {
+		end = max(end, s.StartMs+s.DurationMs)
+	}
+	return end
+}
+
+func sortedSegments(segments []store.TranscriptSegment) []store.TranscriptSegment {
+	sorted := append([]store.TranscriptSegment(nil), segments...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].StartMs < sorted[j].StartMs })
+	return sorted
+}
+
+// formatTimestamp writes ms as m:ss, or h:mm:ss from an hour on.
+func formatTimestamp(ms int) string {
+	s := ms / 1000
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+// parseTimestamp reads m:ss or h:mm:ss into milliseconds.
+func parseTimestamp(s string) (int, bool) {
+	total := 0
+	for i, part := range strings.Split(s, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil || (i > 0 && n >= 60) {
+			return 0, false
+		}
+		total = total*60 + n
+	}
+	return total * 1000, true
+}
@@ -0,0 +1,67 @@
+package core_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/demirbey05/auth-demo/internal/core"
+	"github.com/demirbey05/auth-demo/internal/store"
+)
+
+var lecture = store.Transcript{Segments: []store.TranscriptSegment{
+	{Text: "Welcome.", StartMs: 0, DurationMs: 4000},
+	{Text: "Enzymes are proteins.", StartMs: 4000, DurationMs: 6000},
+	{Text: "They lower activation energy.", StartMs: 35000, DurationMs: 5000},
+	{Text: "Now inhibitors.", StartMs: 754000, DurationMs: 8000},
+}}
+
+func TestTimedTranscript(t *testing.T) {
+	text, ok := core.TimedTranscript(lecture)
+	if !ok {
+		t.Fatalf("expected the transcript to be timed")
+	}
+	want := "[0:00] Welcome. Enzymes are proteins.\n[0:35] They lower activation energy.\n[12:34] Now inhibitors."
+	if text != want {
+		t.Errorf("expected %q, got %q", want, text)
+	}
+
+	if _, ok := core.TimedTranscript(store.Transcript{Segments: []store.TranscriptSegment{{Text: "no timing"}}}); ok {
+		t.Errorf("expected a transcript without timing not to be timed")
+	}
+}
+
+func TestExtractCitations(t *testing.T) {
+	article := "```markdown\n# Enzymes\n\n## What Enzymes Are [t=0:06]\n\nText [t=0:10] here.\n\n```\n## Not a heading [t=0:35]\n```\n\n" +
+		"## **Activation** Energy [t=0:37]\n\n### Made Up [t=59:00]\n\n## Inhibitors [t=12:40]\n```"
+	text, citations := core.ExtractCitations(article, lecture)
+
+	if strings.Contains(text, "[t=0:06]") || strings.Contains(text, "[t=0:10]") || strings.HasPrefix(text, "```") {
+		t.Errorf("expected the markers and wrapping fence to be removed:\n%s", text)
+	}
+	if !strings.Contains(text, "## Not a heading [t=0:35]") {
+		t.Errorf("expected code blocks to be left alone:\n%s", text)
+	}
+
+	want := []store.Citation{
+		{Heading: 2, Section: "What Enzymes Are", StartMs: 4000, EndMs: 35000},
+		{Heading: 3, Section: "Activation Energy", StartMs: 35000, EndMs: 754000},
+		{Heading: 5, Section: "Inhibitors", StartMs: 754000, EndMs: 762000},
+	}
+	if len(citations) != len(want) {
+		t.Fatalf("expected %d citations, got %+v", len(want), citations)
+	}
+	for i := range want {
+		if citations[i] != want[i] {
+			t.Errorf("citation %d: expected %+v, got %+v", i, want[i], citations[i])
+		}
+	}
+}
+
+func TestCitationURL(t *testing.T) {
+	if got := core.CitationURL("https://youtu.be/abc123?si=x", 754900); got != "https://www.youtube.com/watch?v=abc123&t=754s" {
+		t.Errorf("unexpected url %q", got)
+	}
+	if got := core.CitationURL("https://example.com/lecture", 1000); got != "" {
+		t.Errorf("expected no url for other links, got %q", got)
+	}
+}
@@ -0,0 +1,155 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/demirbey05/auth-demo/internal/llm"
+	"github.com/demirbey05/auth-demo/internal/store"
+)
+
+// Signals a content verdict can be based on.
+const (
+	VerdictSourceYouTubeCategory = "youtube_category"
+	VerdictSourceModel           = "model"
+)
+
+// youtubeCategories names the YouTube video categories. Uploads in the
+// educational ones are accepted without asking the model; the others are only
+// a hint, since plenty of lectures are filed under Entertainment or People & Blogs.
+var youtubeCategories = map[string]string{
+	"1":  "Film & Animation",
+	"2":  "Autos & Vehicles",
+	"10": "Music",
+	"15": "Pets & Animals",
+	"17": "Sports",
+	"19": "Travel & Events",
+	"20": "Gaming",
+	"22": "People & Blogs",
+	"23": "Comedy",
+	"24": "Entertainment",
+	"25": "News & Politics",
+	"26": "Howto & Style",
+	"27": "Education",
+	"28": "Science & Technology",
+	"29": "Nonprofits & Activism",
+}
+
+var educationalYouTubeCategories = map[string]bool{
+	"27": true,
+	"28": true,
+}
+
+// wordsPerMinute approximates speech rate to cut a plain transcript to its first minutes.
+const wordsPerMinute = 150
+
+// ClassifierConfig controls how much of a video the classifier looks at and
+// how sure it must be to reject it.
+type ClassifierConfig struct {
+	// TranscriptMinutes is how much of the start of the transcript is sent to the model.
+	TranscriptMinutes int
+	// MinConfidence is the confidence a "not educational" verdict needs to
+	// reject the content. Less certain rejections give the content the benefit of the doubt.
+	MinConfidence float64
+}
+
+// classifierConfig reads CLASSIFIER_TRANSCRIPT_MINUTES (default 3) and
+// CLASSIFIER_MIN_CONFIDENCE (default 0.6).
+func classifierConfig() ClassifierConfig {
+	cfg := ClassifierConfig{TranscriptMinutes: 3, MinConfidence: 0.6}
+	if v, err := strconv.Atoi(os.Getenv("CLASSIFIER_TRANSCRIPT_MINUTES")); err == nil && v > 0 {
+		cfg.TranscriptMinutes = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("CLASSIFIER_MIN_CONFIDENCE"), 64); err == nil && v >= 0 && v <= 1 {
+		cfg.MinConfidence = v
+	}
+	return cfg
+}
+
+// Rejects reports whether verdict is a confident enough "not educational".
+func (c ClassifierConfig) Rejects(verdict store.ContentVerdict) bool {
+	return !verdict.Educational && verdict.Confidence >= c.MinConfidence
+}
+
+// ContentInfo is what the classifier knows about a video.
+type ContentInfo struct {
+	Title           string
+	YouTubeCategory string
+	Transcript      string
+}
+
+var verdictSchema = &llm.Schema{
+	Type: llm.TypeObject,
+	Properties: map[string]*llm.Schema{
+		"educational": {Type: llm.TypeBoolean},
+		"category":    {Type: llm.TypeString},
+		"confidence":  {Type: llm.TypeNumber},
+		"reason":      {Type: llm.TypeString},
+	},
+	Required: []string{"educational", "category", "confidence", "reason"},
+}
+
+// ClassifyContent decides whether content is educational, using the cheapest
+// signal that settles it: the YouTube category first, then the model on the
+// opening minutes of the transcript. A nil prompt uses the built-in default.
+func ClassifyContent(ctx context.Context, provider llm.Provider, prompt *PromptTemplate, info ContentInfo, cfg ClassifierConfig) (store.ContentVerdict, error) {
+	category := youtubeCategories[info.YouTubeCategory]
+	if educationalYouTubeCategories[info.YouTubeCategory] {
+		return store.ContentVerdict{
+			Educational: true,
+			Category:    strings.ToLower(category),
+			Confidence:  1,
+			Reason:      fmt.Sprintf("The video is published in the YouTube %s category.", category),
+			Source:      VerdictSourceYouTubeCategory,
+		}, nil
+	}
+
+	if prompt == nil {
+		prompt = DefaultPrompt(PromptClassify)
+	}
+	text, err := prompt.Render(PromptData{
+		Title:      info.Title,
+		Category:   category,
+		Transcript: transcriptHead(info.Transcript, cfg.TranscriptMinutes),
+	})
+	if err != nil {
+		return store.ContentVerdict{}, err
+	}
+	resp, err := provider.Generate(ctx, llm.Request{Prompt: text, Schema: verdictSchema, Purpose: PurposeClassify})
+	if err != nil {
+		return store.ContentVerdict{}, err
+	}
+
+	var verdict struct {
+		Educational *bool   `json:"educational"`
+		Category    string  `json:"category"`
+		Confidence  float64 `json:"confidence"`
+		Reason      string  `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(resp.Text), &verdict); err != nil {
+		return store.ContentVerdict{}, fmt.Errorf("failed to parse classifier response: %v", err)
+	}
+	if verdict.Educational == nil {
+		return store.ContentVerdict{}, fmt.Errorf("classifier response has no verdict")
+	}
+	return store.ContentVerdict{
+		Educational: *verdict.Educational,
+		Category:    verdict.Category,
+		Confidence:  min(max(verdict.Confidence, 0), 1),
+		Reason:      verdict.Reason,
+		Source:      VerdictSourceModel,
+	}, nil
+}
+
+// transcriptHead returns roughly the first minutes of a transcript.
+func transcriptHead(transcript string, minutes int) string {
+	words := strings.Fields(transcript)
+	if limit := minutes * wordsPerMinute; len(words) > limit {
+		words = words[:limit]
+	}
+	return strings.Join(words, " ")
+}
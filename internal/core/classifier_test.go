@@ -0,0 +1,65 @@
+package core_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/demirbey05/auth-demo/internal/core"
+	"github.com/demirbey05/auth-demo/internal/llm"
+)
+
+func TestClassifyContentTrustsEducationalCategories(t *testing.T) {
+	provider := llm.NewFake()
+
+	verdict, err := core.ClassifyContent(context.Background(), provider, nil, core.ContentInfo{
+		Title:           "Linear Algebra, Lecture 1",
+		YouTubeCategory: "27",
+		Transcript:      "Today we start with vectors.",
+	}, core.ClassifierConfig{TranscriptMinutes: 3, MinConfidence: 0.6})
+	if err != nil {
+		t.Fatalf("ClassifyContent failed: %v", err)
+	}
+	if !verdict.Educational || verdict.Source != core.VerdictSourceYouTubeCategory {
+		t.Errorf("expected an educational verdict from the category, got %+v", verdict)
+	}
+	if n := len(provider.Requests()); n != 0 {
+		t.Errorf("expected no model calls, got %d", n)
+	}
+}
+
+func TestClassifyContentAsksTheModel(t *testing.T) {
+	provider := llm.NewFake()
+	provider.Reply = func(req llm.Request) (string, error) {
+		return `{"educational": false, "category": "music", "confidence": 0.55, "reason": "It is a song."}`, nil
+	}
+	transcript := strings.Repeat("la ", 1000)
+	cfg := core.ClassifierConfig{TranscriptMinutes: 1, MinConfidence: 0.6}
+
+	verdict, err := core.ClassifyContent(context.Background(), provider, nil, core.ContentInfo{
+		Title:           "Summer Song",
+		YouTubeCategory: "10",
+		Transcript:      transcript,
+	}, cfg)
+	if err != nil {
+		t.Fatalf("ClassifyContent failed: %v", err)
+	}
+	if verdict.Educational || verdict.Category != "music" || verdict.Source != core.VerdictSourceModel {
+		t.Errorf("unexpected verdict %+v", verdict)
+	}
+	if cfg.Rejects(verdict) {
+		t.Error("expected a verdict below the confidence threshold not to reject the content")
+	}
+	verdict.Confidence = 0.9
+	if !cfg.Rejects(verdict) {
+		t.Error("expected a confident verdict to reject the content")
+	}
+
+	requests := provider.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("expected one model call, got %d", len(requests))
+	}
+	if prompt := requests[0].Prompt; !strings.Contains(prompt, "YouTube category: Music") || strings.Count(prompt, "la") > 200 {
+		t.Errorf("expected the category and only the opening of the transcript in the prompt, got %q", prompt)
+	}
+}
@@ -0,0 +1,501 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/demirbey05/auth-demo/internal/store"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// Source types of pods.
+const (
+	SourceYouTube = "youtube"
+	SourceMedia   = "media"
+	SourceText    = "text"
+	SourcePDF     = "pdf"
+	SourceURL     = "url"
+)
+
+// SourceTypes lists the source types create-pod accepts. Media files have
+// their own upload endpoint.
+var SourceTypes = []string{SourceYouTube, SourceText, SourcePDF, SourceURL}
+
+// MaxDocumentBytes bounds uploaded PDFs and fetched web pages.
+const MaxDocumentBytes = 50 << 20
+
+// ErrEmptyDocument is returned when a source has no text to learn from.
+var ErrEmptyDocument = errors.New("no text found")
+
+// Document is the text of a pasted text, PDF or web page, split in paragraphs.
+type Document struct {
+	Title      string
+	Paragraphs []string
+}
+
+// Text joins the paragraphs with blank lines.
+func (d Document) Text() string {
+	return strings.Join(d.Paragraphs, "\n\n")
+}
+
+// Words counts the words of the document.
+func (d Document) Words() int {
+	n := 0
+	for _, p := range d.Paragraphs {
+		n += len(strings.Fields(p))
+	}
+	return n
+}
+
+// Transcript stores the document as the transcript articles are written
+// from, one paragraph a segment.
+func (d Document) Transcript(sourceType string) store.Transcript {
+	t := store.Transcript{Provider: sourceType}
+	for _, p := range d.Paragraphs {
+		t.Segments = append(t.Segments, store.TranscriptSegment{Text: p})
+	}
+	return t
+}
+
+// WordCost prices a document by its word count, at the price of the minutes
+// it would take to read it out.
+func WordCost(words int) int {
+	return int(math.Ceil(float64(words)/wordsPerMinute)) * costPerMinute
+}
+
+// DocumentLink is the pod link of pasted text and PDFs, which have no URL.
+// It is derived from the text so the same document can reuse earlier pods.
+func DocumentLink(sourceType string, doc Document) string {
+	sum := sha256.Sum256([]byte(doc.Text()))
+	return sourceType + ":" + hex.EncodeToString(sum[:16])
+}
+
+// ExtractText splits pasted text into paragraphs. The title defaults to the
+// start of the first line.
+func ExtractText(title, text string) (Document, error) {
+	doc := Document{Title: strings.TrimSpace(title), Paragraphs: splitParagraphs(text)}
+	if len(doc.Paragraphs) == 0 {
+		return Document{}, ErrEmptyDocument
+	}
+	if doc.Title == "" {
+		doc.Title = titleFromText(doc.Paragraphs[0])
+	}
+	return doc, nil
+}
+
+var paragraphBreakRe = regexp.MustCompile(`\n\s*\n`)
+
+// splitParagraphs splits text on blank lines and joins the lines of each
+// paragraph, undoing hyphenation at line ends.
+func splitParagraphs(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var paragraphs []string
+	for _, block := range paragraphBreakRe.Split(text, -1) {
+		var b strings.Builder
+		for _, line := range strings.Split(block, "\n") {
+			line = strings.Join(strings.Fields(line), " ")
+			if line == "" {
+				continue
+			}
+			if s := b.String(); strings.HasSuffix(s, "-") && len(s) > 1 && s[len(s)-2] != ' ' {
+				b.Reset()
+				b.WriteString(strings.TrimSuffix(s, "-"))
+			} else if b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(line)
+		}
+		if b.Len() > 0 {
+			paragraphs = append(paragraphs, b.String())
+		}
+	}
+	return paragraphs
+}
+
+// titleFromText takes up to the first ten words of a line.
+func titleFromText(line string) string {
+	words := strings.Fields(line)
+	if len(words) > 10 {
+		return strings.Join(words[:10], " ") + "…"
+	}
+	return strings.Join(words, " ")
+}
+
+// webPageClient fetches web pages for url sources. Users choose the URL, so it
+// refuses to connect to private addresses, and skips proxies which would hide
+// the address.
+var webPageClient = &http.Client{
+	Timeout: 30 * time.Second,
+	Transport: &http.Transport{
+		DialContext:           publicDialer(nil).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 15 * time.Second,
+	},
+}
+
+// publicDialer refuses to connect to addresses that are not public, unless
+// they are in one of allowed. The check runs on the resolved address, so
+// host names that resolve to private addresses are refused too.
+func publicDialer(allowed []*net.IPNet) *net.Dialer {
+	return &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isAllowedIP(ip, allowed) {
+				return fmt.Errorf("connecting to %s is not allowed", host)
+			}
+			return nil
+		},
+	}
+}
+
+func isAllowedIP(ip net.IP, allowed []*net.IPNet) bool {
+	for _, network := range allowed {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return isPublicIP(ip)
+}
+
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	// Carrier-grade NAT addresses are private too
+	_, cgnat, _ := net.ParseCIDR("100.64.0.0/10")
+	return !cgnat.Contains(ip)
+}
+
+// FetchWebPage downloads a web page and extracts its main content. Plain
+// text and PDF responses are read as such.
+func FetchWebPage(ctx context.Context, pageURL string) (Document, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Document{}, fmt.Errorf("invalid url %q", pageURL)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Document{}, err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain,application/pdf;q=0.9")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; PodcoBot/1.0)")
+
+	resp, err := webPageClient.Do(req)
+	if err != nil {
+		return Document{}, fmt.Errorf("error fetching %s: %v", u.Host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Document{}, fmt.Errorf("%s returned %s", u.Host, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxDocumentBytes+1))
+	if err != nil {
+		return Document{}, fmt.Errorf("error reading %s: %v", u.Host, err)
+	}
+	if len(body) > MaxDocumentBytes {
+		return Document{}, fmt.Errorf("%s is larger than %d MB", u.Host, MaxDocumentBytes>>20)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/pdf" || bytes.HasPrefix(body, []byte("%PDF-")):
+		return ExtractPDF(body)
+	case mediaType == "text/plain":
+		return ExtractText("", string(body))
+	}
+	r, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return Document{}, err
+	}
+	return ExtractHTML(r)
+}
+
+// Elements that never hold the content of a page.
+var skippedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true, atom.Svg: true,
+	atom.Nav: true, atom.Header: true, atom.Footer: true, atom.Aside: true, atom.Form: true,
+	atom.Iframe: true, atom.Button: true, atom.Select: true, atom.Dialog: true, atom.Head: true,
+}
+
+// boilerplateRe matches the class and id of page chrome such as sidebars and share buttons.
+var boilerplateRe = regexp.MustCompile(`(?i)\b(comments?|sidebar|footer|navbar|menu|share|social|related|advert|ads?|promo|cookie|subscribe|newsletter|breadcrumbs?|popup|modal)\b`)
+
+// ExtractHTML pulls the main content out of a web page: the largest article
+// or main element when there is one, and otherwise the element holding the
+// most paragraph text. Navigation, scripts and similar chrome are left out.
+func ExtractHTML(r io.Reader) (Document, error) {
+	root, err := html.Parse(r)
+	if err != nil {
+		return Document{}, err
+	}
+	doc := Document{Title: htmlTitle(root)}
+	removeBoilerplate(root)
+
+	content := mainContent(root)
+	if content == nil {
+		return Document{}, ErrEmptyDocument
+	}
+	var w htmlTextWriter
+	w.write(content)
+	doc.Paragraphs = w.paragraphs()
+	if len(doc.Paragraphs) == 0 {
+		return Document{}, ErrEmptyDocument
+	}
+	if doc.Title == "" {
+		doc.Title = titleFromText(doc.Paragraphs[0])
+	}
+	return doc, nil
+}
+
+// htmlTitle prefers the og:title of a page over its title element.
+func htmlTitle(root *html.Node) string {
+	var title, ogTitle string
+	walkHTML(root, func(n *html.Node) bool {
+		switch n.DataAtom {
+		case atom.Title:
+			if title == "" {
+				title = strings.TrimSpace(nodeText(n))
+			}
+		case atom.Meta:
+			if htmlAttr(n, "property") == "og:title" && ogTitle == "" {
+				ogTitle = strings.TrimSpace(htmlAttr(n, "content"))
+			}
+		}
+		return true
+	})
+	if ogTitle != "" {
+		return ogTitle
+	}
+	return strings.Join(strings.Fields(title), " ")
+}
+
+func removeBoilerplate(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.CommentNode || (c.Type == html.ElementNode && isBoilerplate(c)) {
+			n.RemoveChild(c)
+		} else {
+			removeBoilerplate(c)
+		}
+		c = next
+	}
+}
+
+func isBoilerplate(n *html.Node) bool {
+	if skippedElements[n.DataAtom] {
+		return true
+	}
+	switch n.DataAtom {
+	case atom.Html, atom.Body, atom.Article, atom.Main:
+		return false
+	}
+	switch htmlAttr(n, "role") {
+	case "navigation", "banner", "contentinfo", "complementary", "dialog":
+		return true
+	}
+	if htmlAttr(n, "aria-hidden") == "true" {
+		return true
+	}
+	for _, a := range n.Attr {
+		if a.Key == "hidden" {
+			return true
+		}
+	}
+	if !boilerplateRe.MatchString(htmlAttr(n, "class") + " " + htmlAttr(n, "id")) {
+		return false
+	}
+	// Class names are only a hint; keep wrappers that hold the content
+	return findHTML(n, atom.Article) == nil && findHTML(n, atom.Main) == nil && len(nodeText(n)) < 2000
+}
+
+// mainContent picks the node the content of the page is read from.
+func mainContent(root *html.Node) *html.Node {
+	var best *html.Node
+	bestLen := 0
+	walkHTML(root, func(n *html.Node) bool {
+		if n.DataAtom == atom.Article || n.DataAtom == atom.Main || htmlAttr(n, "role") == "main" {
+			if l := len(nodeText(n)); l > bestLen {
+				best, bestLen = n, l
+			}
+		}
+		return true
+	})
+	if best != nil && bestLen > 200 {
+		return best
+	}
+
+	// Score the parents of paragraphs by how much text they hold that is not links
+	scores := make(map[*html.Node]float64)
+	walkHTML(root, func(n *html.Node) bool {
+		if n.DataAtom != atom.P && n.DataAtom != atom.Pre && n.DataAtom != atom.Blockquote {
+			return true
+		}
+		text := strings.TrimSpace(nodeText(n))
+		if len(text) < 25 {
+			return false
+		}
+		score := float64(len(text)) * (1 - linkDensity(n))
+		if p := n.Parent; p != nil {
+			scores[p] += score
+			if gp := p.Parent; gp != nil {
+				scores[gp] += score / 2
+			}
+		}
+		return false
+	})
+	bestScore := 0.0
+	for n, score := range scores {
+		if score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+	if best != nil {
+		return best
+	}
+	return findHTML(root, atom.Body)
+}
+
+func linkDensity(n *html.Node) float64 {
+	total := len(nodeText(n))
+	if total == 0 {
+		return 0
+	}
+	links := 0
+	walkHTML(n, func(c *html.Node) bool {
+		if c.DataAtom == atom.A {
+			links += len(nodeText(c))
+			return false
+		}
+		return true
+	})
+	return float64(links) / float64(total)
+}
+
+// htmlTextWriter turns HTML into paragraphs, with markdown markers for headings and list items.
+type htmlTextWriter struct {
+	blocks  []string
+	current strings.Builder
+	prefix  string
+}
+
+func (w *htmlTextWriter) flush() {
+	if text := strings.Join(strings.Fields(w.current.String()), " "); text != "" {
+		w.blocks = append(w.blocks, w.prefix+text)
+	}
+	w.current.Reset()
+	w.prefix = ""
+}
+
+func (w *htmlTextWriter) write(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.current.WriteString(n.Data)
+		return
+	case html.ElementNode, html.DocumentNode:
+	default:
+		return
+	}
+
+	block := true
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		w.flush()
+		w.prefix = strings.Repeat("#", int(n.Data[1]-'0')) + " "
+	case atom.Li:
+		w.flush()
+		w.prefix = "- "
+	case atom.Pre:
+		// Keep the lines of code
+		w.flush()
+		w.blocks = append(w.blocks, strings.TrimRight(nodeText(n), "\n "))
+		return
+	case atom.Br:
+		w.current.WriteByte(' ')
+		return
+	case atom.Img:
+		return
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Main, atom.Blockquote, atom.Ul, atom.Ol,
+		atom.Table, atom.Tr, atom.Dl, atom.Dt, atom.Dd, atom.Figure, atom.Figcaption, atom.Body:
+		w.flush()
+	case atom.Td, atom.Th:
+		block = false
+		w.current.WriteByte(' ')
+	default:
+		block = false
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.write(c)
+	}
+	if block {
+		w.flush()
+	}
+}
+
+func (w *htmlTextWriter) paragraphs() []string {
+	w.flush()
+	return w.blocks
+}
+
+// walkHTML calls fn on n and its descendants, skipping the children of nodes
+// fn returns false for.
+func walkHTML(n *html.Node, fn func(*html.Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walkHTML(c, fn)
+	}
+}
+
+func findHTML(root *html.Node, a atom.Atom) *html.Node {
+	var found *html.Node
+	walkHTML(root, func(n *html.Node) bool {
+		if found == nil && n.DataAtom == a {
+			found = n
+		}
+		return found == nil
+	})
+	return found
+}
+
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	walkHTML(n, func(c *html.Node) bool {
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+		}
+		return true
+	})
+	return b.String()
+}
+
+func htmlAttr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
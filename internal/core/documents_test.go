@@ -0,0 +1,230 @@
+package core_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/demirbey05/auth-demo/internal/core"
+)
+
+func TestExtractText(t *testing.T) {
+	doc, err := core.ExtractText("", "Photosynthesis turns light into chemical\nenergy in plants.\r\n\r\n  Chloro-\nphyll absorbs red and blue light.  \n\n\n")
+	if err != nil {
+		t.Fatalf("ExtractText failed: %v", err)
+	}
+	want := []string{"Photosynthesis turns light into chemical energy in plants.", "Chlorophyll absorbs red and blue light."}
+	if !reflect.DeepEqual(doc.Paragraphs, want) {
+		t.Errorf("unexpected paragraphs %q", doc.Paragraphs)
+	}
+	if doc.Title != "Photosynthesis turns light into chemical energy in plants." {
+		t.Errorf("expected the first line as the title, got %q", doc.Title)
+	}
+	if doc.Words() != 14 {
+		t.Errorf("expected 14 words, got %d", doc.Words())
+	}
+	if core.DocumentLink(core.SourceText, doc) != core.DocumentLink(core.SourceText, core.Document{Paragraphs: want}) {
+		t.Errorf("expected the link to depend only on the text")
+	}
+
+	if _, err := core.ExtractText("Notes", " \n\n\t"); !errors.Is(err, core.ErrEmptyDocument) {
+		t.Errorf("expected ErrEmptyDocument, got %v", err)
+	}
+	for words, want := range map[int]int{1: 25, 150: 25, 151: 50, 1500: 250} {
+		if got := core.WordCost(words); got != want {
+			t.Errorf("WordCost(%d): expected %d, got %d", words, want, got)
+		}
+	}
+}
+
+const articlePage = `<!doctype html>
+<html><head><title>Cells | Biology Site</title><meta property="og:title" content="How cells divide"></head>
+<body>
+<header><a href="/">Home</a> <a href="/topics">Topics</a></header>
+<div class="sidebar"><p>Popular: the ten weirdest animals, a list you will not believe.</p></div>
+<div id="content">
+  <h1>How cells divide</h1>
+  <p>Cells divide through mitosis, in which one cell becomes two identical daughter cells with the same chromosomes.</p>
+  <p>Before dividing, a cell copies its DNA so that each daughter cell receives a complete set of genetic instructions.</p>
+  <h2>Phases</h2>
+  <ul><li>Prophase</li><li>Metaphase</li></ul>
+  <div class="share-buttons"><a href="#">Share</a></div>
+</div>
+<div class="comments"><p>Great article, thanks for posting this explanation of cell division!</p></div>
+<footer>Copyright 2025</footer>
+<script>track()</script>
+</body></html>`
+
+func TestExtractHTMLKeepsTheMainContent(t *testing.T) {
+	doc, err := core.ExtractHTML(strings.NewReader(articlePage))
+	if err != nil {
+		t.Fatalf("ExtractHTML failed: %v", err)
+	}
+	if doc.Title != "How cells divide" {
+		t.Errorf("expected the og:title, got %q", doc.Title)
+	}
+	want := []string{
+		"# How cells divide",
+		"Cells divide through mitosis, in which one cell becomes two identical daughter cells with the same chromosomes.",
+		"Before dividing, a cell copies its DNA so that each daughter cell receives a complete set of genetic instructions.",
+		"## Phases",
+		"- Prophase",
+		"- Metaphase",
+	}
+	if !reflect.DeepEqual(doc.Paragraphs, want) {
+		t.Errorf("unexpected paragraphs %q", doc.Paragraphs)
+	}
+}
+
+func TestFetchWebPageRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("expected no request to reach a loopback server")
+		w.Write([]byte(articlePage))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, link := range []string{srv.URL, "file:///etc/passwd", "not a url"} {
+		if _, err := core.FetchWebPage(ctx, link); err == nil {
+			t.Errorf("expected %q to be refused", link)
+		}
+	}
+}
+
+func TestExtractPDFReadsExportedArticles(t *testing.T) {
+	exported, err := core.ExportArticle(core.ArticleDocument{
+		PodID:     3,
+		Title:     "Şekerler ve enzimler",
+		Source:    "https://www.youtube.com/watch?v=abc123",
+		Language:  "Turkish",
+		CreatedAt: time.Date(2025, 6, 20, 10, 0, 0, 0, time.UTC),
+		Markdown: "# How Enzymes Work\n\nEnzymes are **proteins** that *speed up* reactions in every living cell. Without them, " +
+			"digesting a single meal would take years instead of hours.\n\n## Key Ideas\n\nŞeker ve ğüzel.",
+	}, core.ExportPDF)
+	if err != nil {
+		t.Fatalf("ExportArticle failed: %v", err)
+	}
+
+	doc, err := core.ExtractPDF(exported.Body)
+	if err != nil {
+		t.Fatalf("ExtractPDF failed: %v", err)
+	}
+	if doc.Title != "Şekerler ve enzimler" {
+		t.Errorf("expected the title of the document info, got %q", doc.Title)
+	}
+	for _, want := range []string{
+		"How Enzymes Work",
+		"Enzymes are proteins that speed up reactions in every living cell. Without them, digesting a single meal would take years instead of hours.",
+		"Key Ideas",
+		"Şeker ve ğüzel.",
+	} {
+		found := false
+		for _, p := range doc.Paragraphs {
+			found = found || p == want
+		}
+		if !found {
+			t.Errorf("expected a paragraph %q in %q", want, doc.Paragraphs)
+		}
+	}
+}
+
+// simplePDF writes a PDF with one page and a compressed content stream that
+// shows text in a Type1 font with a custom encoding.
+func simplePDF(content string, trailer string) []byte {
+	var stream bytes.Buffer
+	zw := zlib.NewWriter(&stream)
+	zw.Write([]byte(content))
+	zw.Close()
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 /Resources << /Font << /F1 5 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R >>",
+		fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", stream.Len(), stream.Bytes()),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /FirstChar 32 /Widths [278] /FontDescriptor 6 0 R " +
+			"/Encoding << /Type /Encoding /Differences [1 /fi /eacute /quoteright] >> >>",
+		"<< /Type /FontDescriptor /MissingWidth 556 >>",
+	}
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	for i, obj := range objects {
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size 7 /Root 1 0 R %s>>\n%%%%EOF\n", trailer)
+	return b.Bytes()
+}
+
+const simpleContent = `BT /F1 12 Tf 14 TL 72 700 Td
+[(The) -250 (\001rst) -250 (caf\002) ] TJ
+(it\003s here) '
+0 -40 Td [(New para) 20 (graph)] TJ
+ET
+BI /W 1 /H 1 /BPC 8 /CS /G ID x EI`
+
+func TestExtractPDFReadsSimpleFonts(t *testing.T) {
+	content := simpleContent
+	doc, err := core.ExtractPDF(simplePDF(content, ""))
+	if err != nil {
+		t.Fatalf("ExtractPDF failed: %v", err)
+	}
+	want := []string{"The first café it’s here", "New paragraph"}
+	if !reflect.DeepEqual(doc.Paragraphs, want) {
+		t.Errorf("unexpected paragraphs %q", doc.Paragraphs)
+	}
+
+	if _, err := core.ExtractPDF(simplePDF(content, "/Encrypt 9 0 R ")); !errors.Is(err, core.ErrEncryptedPDF) {
+		t.Errorf("expected ErrEncryptedPDF, got %v", err)
+	}
+	if _, err := core.ExtractPDF(simplePDF("BT ET", "")); !errors.Is(err, core.ErrEmptyDocument) {
+		t.Errorf("expected ErrEmptyDocument for a page without text, got %v", err)
+	}
+}
+
+// malformedPDFs broke the extractor before it checked what it reads.
+var malformedPDFs = [][]byte{
+	simplePDF("BT Tf ET", ""),
+	simplePDF("BT /F1 Tf (x) Tj ' \" TJ Do cm Tm ET", ""),
+	[]byte("%PDF-1.4\n1 0 obj\n<< /Length -5 >>\nstream\nabc\nendstream\nendobj\n"),
+	[]byte("%PDF-1.4\n1 0 obj\n<< /Type /ObjStm /N 1 /First -100 >>\nstream\n1 0\nendstream\nendobj\n"),
+	[]byte("%PDF-1.4\n1 0 obj\n<abc"),
+	[]byte("%PDF-1.4\n1 0 obj\n" + strings.Repeat("[", 1000)),
+	[]byte("%PDF-1.4\n1 0 obj\n<< /ToUnicode << >> >>\nendobj\n"),
+}
+
+func TestExtractPDFSurvivesMalformedFiles(t *testing.T) {
+	cmap := "/CIDInit /ProcSet findresource begin 1 begincodespacerange <00> <FF> endcodespacerange " +
+		"1 beginbfrange <00> <05> [] endbfrange 1 beginbfrange <0000> <FFFF> <0041> endbfrange end"
+	fonts := "<< /Type /Font /Subtype /Type0 /DescendantFonts [<< /W [0 [] 1 1e18 500 0 -1e18] >>] /ToUnicode 7 0 R >>"
+	withCMap := bytes.Replace(simplePDF("BT /F1 12 Tf <0001> Tj ET", ""), []byte("trailer"),
+		[]byte(fmt.Sprintf("7 0 obj\n<< /Length %d >>\nstream\n%s\nendstream\nendobj\ntrailer", len(cmap), cmap)), 1)
+	withCMap = bytes.Replace(withCMap, []byte("5 0 obj\n<< /Type /Font /Subtype /Type1"), []byte("5 0 obj\n"+fonts+" << /Subtype /Type1"), 1)
+
+	for _, pdf := range append(malformedPDFs, withCMap) {
+		// Only panics fail; the result of a broken file does not matter
+		core.ExtractPDF(pdf)
+	}
+}
+
+func FuzzExtractPDF(f *testing.F) {
+	f.Add(simplePDF(simpleContent, ""))
+	exported, err := core.ExportArticle(core.ArticleDocument{Title: "Fuzz", Markdown: "# Title\n\nSome *text*."}, core.ExportPDF)
+	if err != nil {
+		f.Fatalf("ExportArticle failed: %v", err)
+	}
+	f.Add(exported.Body)
+	for _, pdf := range malformedPDFs {
+		f.Add(pdf)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		core.ExtractPDF(data)
+	})
+}
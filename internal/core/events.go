@@ -0,0 +1,95 @@
+package core
+
+import "sync"
+
+// Event types sent to pod subscribers.
+const (
+	EventStage    = "stage"
+	EventProgress = "progress"
+	EventArticle  = "article"
+	EventDone     = "done"
+	EventFailed   = "failed"
+)
+
+// PodEvent is a pipeline transition for a single pod.
+type PodEvent struct {
+	Type      string `json:"type"`
+	PodID     int    `json:"pod_id"`
+	JobID     int    `json:"job_id"`
+	Stage     string `json:"stage"`
+	ArticleID int    `json:"article_id,omitempty"`
+	QuizID    int    `json:"quiz_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// ChunksDone and ChunksTotal are set on progress events of long transcripts.
+	ChunksDone  int `json:"chunks_done,omitempty"`
+	ChunksTotal int `json:"chunks_total,omitempty"`
+}
+
+// EventBroker fans pipeline events out to in-process subscribers of a pod.
+// Delivery is best effort: a subscriber that falls behind misses events
+// instead of blocking the pipeline.
+type EventBroker struct {
+	mu     sync.Mutex
+	subs   map[int]map[chan PodEvent]struct{}
+	closed bool
+}
+
+func NewEventBroker() *EventBroker {
+	return &EventBroker{subs: make(map[int]map[chan PodEvent]struct{})}
+}
+
+// Subscribe returns a channel receiving events for podID and a func that releases it.
+// The channel is closed when the broker is closed.
+func (b *EventBroker) Subscribe(podID int) (<-chan PodEvent, func()) {
+	ch := make(chan PodEvent, 16)
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	if b.subs[podID] == nil {
+		b.subs[podID] = make(map[chan PodEvent]struct{})
+	}
+	b.subs[podID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subs[podID], ch)
+		if len(b.subs[podID]) == 0 {
+			delete(b.subs, podID)
+		}
+		b.mu.Unlock()
+	}
+}
+
+func (b *EventBroker) Publish(event PodEvent) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs[event.PodID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Close ends every subscription so open streams can finish during shutdown.
+func (b *EventBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for podID, subs := range b.subs {
+		for ch := range subs {
+			close(ch)
+		}
+		delete(b.subs, podID)
+	}
+}
@@ -0,0 +1,60 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/demirbey05/auth-demo/internal/core"
+)
+
+func TestEventBrokerDeliversToPodSubscribers(t *testing.T) {
+	broker := core.NewEventBroker()
+
+	sub, unsubscribe := broker.Subscribe(1)
+	other, unsubscribeOther := broker.Subscribe(2)
+	defer unsubscribeOther()
+
+	broker.Publish(core.PodEvent{Type: core.EventStage, PodID: 1, Stage: core.StageGeneratingArticle})
+
+	select {
+	case event := <-sub:
+		if event.Stage != core.StageGeneratingArticle {
+			t.Errorf("expected stage %s, got %s", core.StageGeneratingArticle, event.Stage)
+		}
+	default:
+		t.Fatal("expected an event for pod 1")
+	}
+
+	select {
+	case event := <-other:
+		t.Errorf("pod 2 subscriber received event for pod %d", event.PodID)
+	default:
+	}
+
+	unsubscribe()
+	broker.Publish(core.PodEvent{Type: core.EventDone, PodID: 1})
+	select {
+	case <-sub:
+		t.Error("received event after unsubscribing")
+	default:
+	}
+}
+
+func TestEventBrokerCloseEndsSubscriptions(t *testing.T) {
+	broker := core.NewEventBroker()
+
+	sub, unsubscribe := broker.Subscribe(1)
+	defer unsubscribe()
+
+	broker.Close()
+	if _, ok := <-sub; ok {
+		t.Error("expected subscription to be closed")
+	}
+
+	late, _ := broker.Subscribe(1)
+	if _, ok := <-late; ok {
+		t.Error("expected subscription after close to be closed")
+	}
+
+	// Publishing after close must not panic
+	broker.Publish(core.PodEvent{Type: core.EventDone, PodID: 1})
+}
@@ -0,0 +1,302 @@
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Formats an article can be exported in.
+const (
+	ExportMarkdown = "md"
+	ExportHTML     = "html"
+	ExportPDF      = "pdf"
+	ExportEPUB     = "epub"
+)
+
+// ExportFormats lists every export format.
+var ExportFormats = []string{ExportHTML, ExportPDF, ExportEPUB, ExportMarkdown}
+
+var ErrUnknownExportFormat = errors.New("unknown export format")
+
+// ArticleDocument is an article with the pod details recorded in its exports.
+type ArticleDocument struct {
+	PodID     int
+	Title     string
+	Source    string
+	Language  string
+	CreatedAt time.Time
+	Markdown  string
+}
+
+// ExportedArticle is an article rendered in one format.
+type ExportedArticle struct {
+	Body        []byte
+	ContentType string
+	FileName    string
+}
+
+// ExportArticle renders doc in format. The title and source link are part of
+// the document metadata in every format.
+func ExportArticle(doc ArticleDocument, format string) (ExportedArticle, error) {
+	var (
+		body        []byte
+		contentType string
+		err         error
+	)
+	switch format {
+	case ExportMarkdown:
+		body, contentType = renderMarkdownExport(doc), "text/markdown; charset=utf-8"
+	case ExportHTML:
+		body, contentType = renderHTMLExport(doc), "text/html; charset=utf-8"
+	case ExportPDF:
+		contentType = "application/pdf"
+		body, err = renderPDF(doc)
+	case ExportEPUB:
+		contentType = "application/epub+zip"
+		body, err = renderEPUB(doc)
+	default:
+		return ExportedArticle{}, fmt.Errorf("%w %q", ErrUnknownExportFormat, format)
+	}
+	if err != nil {
+		return ExportedArticle{}, fmt.Errorf("error exporting article of pod %d as %s: %v", doc.PodID, format, err)
+	}
+	return ExportedArticle{Body: body, ContentType: contentType, FileName: exportFileName(doc) + "." + format}, nil
+}
+
+// exportFileName turns the title into a file name, keeping letters of any script.
+func exportFileName(doc ArticleDocument) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(doc.Title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	name := []rune(strings.TrimSuffix(b.String(), "-"))
+	if len(name) > 80 {
+		name = []rune(strings.TrimRight(string(name[:80]), "-"))
+	}
+	if len(name) == 0 {
+		return fmt.Sprintf("pod-%d-article", doc.PodID)
+	}
+	return string(name)
+}
+
+// renderMarkdownExport prepends YAML front matter with the metadata.
+func renderMarkdownExport(doc ArticleDocument) []byte {
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "title: %s\n", strconv.Quote(doc.Title))
+	fmt.Fprintf(&b, "source: %s\n", strconv.Quote(doc.Source))
+	fmt.Fprintf(&b, "language: %s\n", strconv.Quote(doc.Language))
+	fmt.Fprintf(&b, "date: %s\n", doc.CreatedAt.UTC().Format(time.RFC3339))
+	b.WriteString("---\n\n")
+	b.WriteString(strings.TrimSpace(doc.Markdown))
+	b.WriteByte('\n')
+	return []byte(b.String())
+}
+
+const exportCSS = `body { font-family: Georgia, serif; line-height: 1.5; max-width: 42em; margin: 2em auto; padding: 0 1em; color: #111; }
+header { border-bottom: 1px solid #ccc; margin-bottom: 1.5em; }
+header p { color: #555; font-size: 0.9em; }
+pre { background: #f0f0f0; padding: 0.5em; overflow-x: auto; white-space: pre-wrap; }
+code { font-family: monospace; }
+blockquote { border-left: 3px solid #ccc; margin-left: 0; padding-left: 1em; color: #444; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #999; padding: 0.3em 0.5em; text-align: left; vertical-align: top; }
+th { background: #eee; }
+a { color: #0b5cad; }
+@media print { body { margin: 0; max-width: none; } a { color: inherit; } }
+`
+
+// articleHeader is the title and source link shown above the article.
+func articleHeader(doc ArticleDocument) string {
+	header := fmt.Sprintf("<header>\n<h1 class=\"title\">%s</h1>\n", escapeXML(doc.Title))
+	if doc.Source != "" {
+		source := escapeXML(doc.Source)
+		if href := safeLinkURL(doc.Source); href != "" {
+			source = fmt.Sprintf("<a href=\"%s\" rel=\"nofollow noopener\">%s</a>", escapeXML(href), source)
+		}
+		header += fmt.Sprintf("<p>Source: %s</p>\n", source)
+	}
+	return header + "</header>\n"
+}
+
+func renderHTMLExport(doc ArticleDocument) []byte {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n")
+	fmt.Fprintf(&b, "<html lang=\"%s\">\n<head>\n<meta charset=\"utf-8\" />\n", languageTag(doc.Language))
+	fmt.Fprintf(&b, "<title>%s</title>\n", escapeXML(doc.Title))
+	fmt.Fprintf(&b, "<meta name=\"dc.title\" content=\"%s\" />\n", escapeXML(doc.Title))
+	fmt.Fprintf(&b, "<meta name=\"dc.source\" content=\"%s\" />\n", escapeXML(doc.Source))
+	fmt.Fprintf(&b, "<meta name=\"dc.date\" content=\"%s\" />\n", doc.CreatedAt.UTC().Format("2006-01-02"))
+	fmt.Fprintf(&b, "<style>\n%s</style>\n</head>\n<body>\n", exportCSS)
+	b.WriteString(articleHeader(doc))
+	fmt.Fprintf(&b, "<article>\n%s</article>\n</body>\n</html>\n", renderMarkdownHTML(parseMarkdown(doc.Markdown)))
+	return []byte(b.String())
+}
+
+// languageNames maps the language names users pick to BCP 47 tags, which
+// HTML and EPUB require.
+var languageNames = map[string]string{
+	"arabic": "ar", "chinese": "zh", "czech": "cs", "danish": "da", "dutch": "nl",
+	"english": "en", "finnish": "fi", "french": "fr", "german": "de", "greek": "el",
+	"hebrew": "he", "hindi": "hi", "hungarian": "hu", "indonesian": "id", "italian": "it",
+	"japanese": "ja", "korean": "ko", "norwegian": "no", "persian": "fa", "polish": "pl",
+	"portuguese": "pt", "romanian": "ro", "russian": "ru", "spanish": "es", "swedish": "sv",
+	"thai": "th", "turkish": "tr", "ukrainian": "uk", "vietnamese": "vi",
+	"türkçe": "tr", "deutsch": "de", "français": "fr", "español": "es",
+}
+
+// languageTag returns the tag of a language name, or "und" for unknown ones.
+func languageTag(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if tag, ok := languageNames[language]; ok {
+		return tag
+	}
+	if len(language) == 2 && strings.Trim(language, "abcdefghijklmnopqrstuvwxyz") == "" {
+		return language
+	}
+	return "und"
+}
+
+// renderEPUB writes an EPUB 3 book holding the article as a single chapter,
+// with a table of contents from its headings. The title and source link are
+// recorded as dc:title and dc:source.
+func renderEPUB(doc ArticleDocument) ([]byte, error) {
+	blocks := parseMarkdown(doc.Markdown)
+	lang := languageTag(doc.Language)
+	title := escapeXML(doc.Title)
+	id := fmt.Sprintf("urn:pod:%d", doc.PodID)
+
+	type tocEntry struct {
+		id    int
+		title string
+	}
+	var toc []tocEntry
+	section := 0
+	for _, block := range blocks {
+		if block.kind == mdHeading {
+			section++
+			if block.level <= 3 {
+				toc = append(toc, tocEntry{section, escapeXML(spansText(parseInline(block.text)))})
+			}
+		}
+	}
+	if len(toc) == 0 {
+		toc = []tocEntry{{0, title}}
+	}
+	anchor := func(e tocEntry) string {
+		if e.id == 0 {
+			return "article.xhtml"
+		}
+		return fmt.Sprintf("article.xhtml#section-%d", e.id)
+	}
+
+	var nav, ncx strings.Builder
+	for i, e := range toc {
+		fmt.Fprintf(&nav, "<li><a href=\"%s\">%s</a></li>\n", anchor(e), e.title)
+		fmt.Fprintf(&ncx, "<navPoint id=\"nav-%d\" playOrder=\"%d\"><navLabel><text>%s</text></navLabel><content src=\"%s\" /></navPoint>\n", i+1, i+1, e.title, anchor(e))
+	}
+
+	files := []struct {
+		name string
+		body string
+	}{
+		{"META-INF/container.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml" /></rootfiles>
+</container>
+`},
+		{"OEBPS/content.opf", fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="pub-id" xml:lang="%s">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="pub-id">%s</dc:identifier>
+<dc:title>%s</dc:title>
+<dc:language>%s</dc:language>
+<dc:source>%s</dc:source>
+<dc:date>%s</dc:date>
+<meta property="dcterms:modified">%s</meta>
+</metadata>
+<manifest>
+<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav" />
+<item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml" />
+<item id="style" href="style.css" media-type="text/css" />
+<item id="article" href="article.xhtml" media-type="application/xhtml+xml" />
+</manifest>
+<spine toc="ncx">
+<itemref idref="article" />
+</spine>
+</package>
+`, lang, id, title, lang, escapeXML(doc.Source), doc.CreatedAt.UTC().Format("2006-01-02"), doc.CreatedAt.UTC().Format("2006-01-02T15:04:05Z"))},
+		{"OEBPS/nav.xhtml", fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="%s" lang="%s">
+<head><title>%s</title></head>
+<body>
+<nav epub:type="toc" id="toc">
+<h1>%s</h1>
+<ol>
+%s</ol>
+</nav>
+</body>
+</html>
+`, lang, lang, title, title, nav.String())},
+		{"OEBPS/toc.ncx", fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+<head><meta name="dtb:uid" content="%s" /></head>
+<docTitle><text>%s</text></docTitle>
+<navMap>
+%s</navMap>
+</ncx>
+`, id, title, ncx.String())},
+		{"OEBPS/style.css", exportCSS},
+		{"OEBPS/article.xhtml", fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="%s" lang="%s">
+<head>
+<title>%s</title>
+<link rel="stylesheet" type="text/css" href="style.css" />
+</head>
+<body>
+%s<article>
+%s</article>
+</body>
+</html>
+`, lang, lang, title, articleHeader(doc), renderMarkdownHTML(blocks))},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	// The mimetype must come first and be stored uncompressed
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store, Modified: doc.CreatedAt})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write([]byte("application/epub+zip")); err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: doc.CreatedAt})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(f.body)); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
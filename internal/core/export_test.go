@@ -0,0 +1,327 @@
+package core_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/demirbey05/auth-demo/internal/core"
+	"golang.org/x/net/html"
+)
+
+var exportDoc = core.ArticleDocument{
+	PodID:     7,
+	Title:     "Şekerler & <Enzymes>",
+	Source:    "https://www.youtube.com/watch?v=abc123",
+	Language:  "Turkish",
+	CreatedAt: time.Date(2025, 6, 20, 10, 0, 0, 0, time.UTC),
+	Markdown: "```markdown\n# How Enzymes Work\n\nEnzymes are **proteins** that *speed up* reactions. See [the notes](https://example.com/notes) " +
+		"or [this](javascript:alert(1)).\n\n<script>alert('x')</script>\n\n## Key Ideas\n\n- Active site\n  - Substrate binds\n- Catalysis\n\n" +
+		"1. First\n2. Second\n\n| Term | Meaning |\n| --- | --- |\n| `Km` | Affinity |\n\n> A quote\n\n---\n\n```\ncode <b>\n```\n```",
+}
+
+func TestExportArticleHTMLIsSanitized(t *testing.T) {
+	exported, err := core.ExportArticle(exportDoc, core.ExportHTML)
+	if err != nil {
+		t.Fatalf("ExportArticle failed: %v", err)
+	}
+	body := string(exported.Body)
+
+	for _, unsafe := range []string{"<script>", "javascript:", "<b>", "<Enzymes>"} {
+		if strings.Contains(body, unsafe) {
+			t.Errorf("expected %q to be escaped or dropped:\n%s", unsafe, body)
+		}
+	}
+	for _, want := range []string{
+		`<html lang="tr">`,
+		`<title>Şekerler &amp; &lt;Enzymes&gt;</title>`,
+		`<meta name="dc.source" content="https://www.youtube.com/watch?v=abc123" />`,
+		`<h1 id="section-1">How Enzymes Work</h1>`,
+		`<strong>proteins</strong>`,
+		`<em>speed up</em>`,
+		`<a href="https://example.com/notes" rel="nofollow noopener">the notes</a>`,
+		`or this.`,
+		"<ul>\n<li>Active site<ul>\n<li>Substrate binds</li></ul>\n</li>\n<li>Catalysis</li></ul>",
+		"<ol>\n<li>First</li>\n<li>Second</li></ol>",
+		`<th>Term</th>`,
+		`<td><code>Km</code></td>`,
+		`<blockquote><p>A quote</p></blockquote>`,
+		`<hr />`,
+		`<pre><code>code &lt;b&gt;</code></pre>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected HTML to contain %q:\n%s", want, body)
+		}
+	}
+	if exported.ContentType != "text/html; charset=utf-8" || exported.FileName != "şekerler-enzymes.html" {
+		t.Errorf("unexpected content type %q or file name %q", exported.ContentType, exported.FileName)
+	}
+}
+
+func TestExportArticleMarkdownHasFrontMatter(t *testing.T) {
+	exported, err := core.ExportArticle(exportDoc, core.ExportMarkdown)
+	if err != nil {
+		t.Fatalf("ExportArticle failed: %v", err)
+	}
+	want := "---\ntitle: \"Şekerler & <Enzymes>\"\nsource: \"https://www.youtube.com/watch?v=abc123\"\n"
+	if !strings.HasPrefix(string(exported.Body), want) {
+		t.Errorf("expected front matter %q, got:\n%s", want, exported.Body)
+	}
+}
+
+func TestExportArticlePDF(t *testing.T) {
+	exported, err := core.ExportArticle(exportDoc, core.ExportPDF)
+	if err != nil {
+		t.Fatalf("ExportArticle failed: %v", err)
+	}
+	pdf := exported.Body
+	if !bytes.HasPrefix(pdf, []byte("%PDF-")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatalf("output is not a PDF")
+	}
+
+	// Every xref entry must point at its object
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdf)
+	if m == nil {
+		t.Fatalf("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdf[xref:], -1)
+	if len(entries) == 0 {
+		t.Fatalf("empty xref table")
+	}
+	for i, e := range entries {
+		off, _ := strconv.Atoi(string(e[1]))
+		if want := fmt.Sprintf("%d 0 obj", i+1); !bytes.HasPrefix(pdf[off:], []byte(want)) {
+			t.Errorf("xref entry %d points at %q", i+1, pdf[off:off+12])
+		}
+	}
+
+	for _, want := range []string{"/Title <FEFF015E", "/Source <FEFF0068007400740070", "/URI (https://example.com/notes)", "/BaseFont /GoBold"} {
+		if !bytes.Contains(pdf, []byte(want)) {
+			t.Errorf("expected PDF to contain %q", want)
+		}
+	}
+	if bytes.Contains(pdf, []byte("javascript")) {
+		t.Errorf("expected the javascript link to be dropped")
+	}
+}
+
+func TestExportArticleEPUB(t *testing.T) {
+	exported, err := core.ExportArticle(exportDoc, core.ExportEPUB)
+	if err != nil {
+		t.Fatalf("ExportArticle failed: %v", err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(exported.Body), int64(len(exported.Body)))
+	if err != nil {
+		t.Fatalf("EPUB is not a zip: %v", err)
+	}
+	if first := r.File[0]; first.Name != "mimetype" || first.Method != zip.Store {
+		t.Fatalf("expected an uncompressed mimetype first, got %s", first.Name)
+	}
+
+	files := make(map[string]string)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("error opening %s: %v", f.Name, err)
+		}
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(body)
+
+		if strings.HasSuffix(f.Name, ".xhtml") || strings.HasSuffix(f.Name, ".opf") || strings.HasSuffix(f.Name, ".ncx") {
+			d := xml.NewDecoder(bytes.NewReader(body))
+			for {
+				if _, err := d.Token(); err != nil {
+					if !errors.Is(err, io.EOF) {
+						t.Errorf("%s is not well-formed: %v", f.Name, err)
+					}
+					break
+				}
+			}
+		}
+	}
+
+	opf := files["OEBPS/content.opf"]
+	for _, want := range []string{
+		"<dc:title>Şekerler &amp; &lt;Enzymes&gt;</dc:title>",
+		"<dc:source>https://www.youtube.com/watch?v=abc123</dc:source>",
+		"<dc:language>tr</dc:language>",
+	} {
+		if !strings.Contains(opf, want) {
+			t.Errorf("expected content.opf to contain %q:\n%s", want, opf)
+		}
+	}
+	if nav := files["OEBPS/nav.xhtml"]; !strings.Contains(nav, `<a href="article.xhtml#section-2">Key Ideas</a>`) {
+		t.Errorf("expected the table of contents to link the headings:\n%s", nav)
+	}
+}
+
+func TestExportArticleRejectsUnknownFormats(t *testing.T) {
+	if _, err := core.ExportArticle(exportDoc, "docx"); !errors.Is(err, core.ErrUnknownExportFormat) {
+		t.Errorf("expected ErrUnknownExportFormat, got %v", err)
+	}
+}
+
+// xssVectors try to get markup or script URLs past the markdown renderer.
+var xssVectors = []string{
+	"<script>alert(1)</script>",
+	"<img src=x onerror=alert(1)>",
+	"<a href=\"javascript:alert(1)\">x</a>",
+	"[x](javascript:alert(1))",
+	"[x](JaVaScRiPt:alert(1))",
+	"[x](java\tscript:alert(1))",
+	"[x](  javascript:alert(1))",
+	"[x](<javascript:alert(1)>)",
+	"[x](&#106;avascript:alert(1))",
+	"[x](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)",
+	"[x](vbscript:msgbox(1))",
+	"[x](//evil.example/a)",
+	"![x](javascript:alert(1))",
+	"[x](https://ok.example/\"onmouseover=\"alert(1))",
+	"[x](https://ok.example/' onclick='alert(1))",
+	"[x](https://ok.example/\"><script>alert(1)</script>)",
+	"[<img src=x onerror=alert(1)>](https://ok.example)",
+	"[[x](javascript:alert(1))](https://ok.example)",
+	"[x](mailto:a@b.example?body=\"><script>)",
+	"# <svg onload=alert(1)>",
+	"**<iframe src=javascript:alert(1)>**",
+	"`<script>alert(1)</script>`",
+	"| a | b |\n| - | - |\n| <b onclick=alert(1)> | [x](javascript:alert(1)) |",
+	"- <style>*{}</style>\n  - [x](javascript:alert(1))",
+	"> <object data=javascript:alert(1)>",
+	"```\n</code></pre><script>alert(1)</script>\n```",
+	"Control \x00\x05\x1b characters and invalid \xff UTF-8",
+}
+
+// checkSafeHTML fails unless the rendered article only contains the elements
+// and attributes the renderer is meant to produce, with links limited to http,
+// https and mailto.
+func checkSafeHTML(t *testing.T, markdown string) {
+	t.Helper()
+	exported, err := core.ExportArticle(core.ArticleDocument{Title: "XSS", Markdown: markdown}, core.ExportHTML)
+	if err != nil {
+		t.Fatalf("ExportArticle failed: %v", err)
+	}
+	body := string(exported.Body)
+	start, end := strings.Index(body, "<article>"), strings.LastIndex(body, "</article>")
+	if start < 0 || end < start {
+		t.Fatalf("missing article in:\n%s", body)
+	}
+	article := body[start : end+len("</article>")]
+
+	allowed := map[string]bool{
+		"article": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "p": true,
+		"ul": true, "ol": true, "li": true, "blockquote": true, "pre": true, "code": true, "hr": true, "br": true,
+		"table": true, "thead": true, "tbody": true, "tr": true, "th": true, "td": true,
+		"strong": true, "em": true, "a": true,
+	}
+	tokens := html.NewTokenizer(strings.NewReader(article))
+	for {
+		tt := tokens.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		token := tokens.Token()
+		if !allowed[token.Data] {
+			t.Errorf("%q rendered a <%s> element:\n%s", markdown, token.Data, article)
+		}
+		for _, attr := range token.Attr {
+			switch attr.Key {
+			case "href":
+				if !strings.HasPrefix(attr.Val, "http://") && !strings.HasPrefix(attr.Val, "https://") && !strings.HasPrefix(attr.Val, "mailto:") {
+					t.Errorf("%q rendered a link to %q", markdown, attr.Val)
+				}
+			case "rel", "id", "start":
+			default:
+				t.Errorf("%q rendered a %s attribute on <%s>:\n%s", markdown, attr.Key, token.Data, article)
+			}
+		}
+	}
+
+	// EPUB embeds the same markup, so it must be well-formed XML too
+	decoder := xml.NewDecoder(strings.NewReader(article))
+	for {
+		if _, err := decoder.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Errorf("%q rendered malformed XHTML: %v\n%s", markdown, err, article)
+			break
+		}
+	}
+}
+
+func TestExportArticleHTMLResistsXSSVectors(t *testing.T) {
+	for _, vector := range xssVectors {
+		checkSafeHTML(t, vector)
+		checkSafeHTML(t, "Some text "+vector+" and more.")
+	}
+}
+
+func TestExportArticleHTMLHandlesPathologicalBrackets(t *testing.T) {
+	for name, markdown := range map[string]string{
+		"unclosed brackets": strings.Repeat("[", 100000),
+		"unclosed targets":  strings.Repeat("[a](", 25000),
+		"nested links":      strings.Repeat("[a", 20000) + strings.Repeat("](x)", 20000),
+	} {
+		began := time.Now()
+		if _, err := core.ExportArticle(core.ArticleDocument{Title: name, Markdown: markdown}, core.ExportHTML); err != nil {
+			t.Fatalf("%s: ExportArticle failed: %v", name, err)
+		}
+		if took := time.Since(began); took > 2*time.Second {
+			t.Errorf("%s took %v to render", name, took)
+		}
+	}
+
+	// Link text cannot hold another link
+	exported, err := core.ExportArticle(core.ArticleDocument{Title: "Links", Markdown: "[see [x](https://a.example)](https://b.example)"}, core.ExportHTML)
+	if err != nil {
+		t.Fatalf("ExportArticle failed: %v", err)
+	}
+	want := `<a href="https://b.example" rel="nofollow noopener">see [x](https://a.example)</a>`
+	if !strings.Contains(string(exported.Body), want) {
+		t.Errorf("expected HTML to contain %q:\n%s", want, exported.Body)
+	}
+}
+
+func FuzzExportArticleHTML(f *testing.F) {
+	f.Add(exportDoc.Markdown)
+	for _, vector := range xssVectors {
+		f.Add(vector)
+	}
+	f.Fuzz(func(t *testing.T, markdown string) {
+		checkSafeHTML(t, markdown)
+	})
+}
+
+func FuzzExportArticlePDF(f *testing.F) {
+	f.Add(exportDoc.Markdown)
+	for _, vector := range xssVectors {
+		f.Add(vector)
+	}
+	f.Fuzz(func(t *testing.T, markdown string) {
+		exported, err := core.ExportArticle(core.ArticleDocument{Title: "Fuzz", Markdown: markdown}, core.ExportPDF)
+		if err != nil {
+			t.Fatalf("ExportArticle failed: %v", err)
+		}
+		if !bytes.HasPrefix(exported.Body, []byte("%PDF-")) || !bytes.HasSuffix(exported.Body, []byte("%%EOF\n")) {
+			t.Fatalf("output is not a PDF")
+		}
+		for _, uri := range bytes.Split(exported.Body, []byte("/URI ("))[1:] {
+			if !bytes.HasPrefix(uri, []byte("http://")) && !bytes.HasPrefix(uri, []byte("https://")) && !bytes.HasPrefix(uri, []byte("mailto:")) {
+				t.Errorf("%q rendered a link to %.40q", markdown, uri)
+			}
+		}
+	})
+}
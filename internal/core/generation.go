@@ -5,121 +5,192 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
-	"os"
 	"strings"
 
-	"github.com/google/generative-ai-go/genai"
-	"google.golang.org/api/option"
+	"github.com/demirbey05/auth-demo/internal/llm"
+	"github.com/demirbey05/auth-demo/internal/store"
 )
 
-func GenerateArticleFromTranscript(transcript, language string) (string, error) {
-	ctx := context.Background()
+// ErrNotEducational is returned when the classifier rejects a transcript, or the
+// model refuses to build an article, because it is not educational content.
+var ErrNotEducational = errors.New("content is not educational")
+
+// ArticleOptions tune GenerateArticleFromTranscript.
+type ArticleOptions struct {
+	Chunking ChunkConfig
+	// OnChunk is called with the number of chunks summarized so far, starting
+	// at 0, when a transcript is generated in chunks.
+	OnChunk func(done, total int)
+	// Prompt and NotesPrompt override the article and chunk notes templates.
+	// Nil uses the built-in defaults.
+	Prompt      *PromptTemplate
+	NotesPrompt *PromptTemplate
+	// Timed is set when the transcript comes from TimedTranscript. The
+	// article then ends its section headings with [t=m:ss] markers, which
+	// ExtractCitations turns into citations.
+	Timed bool
+}
 
-	apiKey, ok := os.LookupEnv("LLM_KEY")
-	if !ok {
-		log.Fatalln("Environment variable LLM_KEY not set")
+func (o ArticleOptions) prompts() (article, notes *PromptTemplate) {
+	article, notes = o.Prompt, o.NotesPrompt
+	if article == nil {
+		article = DefaultPrompt(PromptArticle)
+	}
+	if notes == nil {
+		notes = DefaultPrompt(PromptChunkNotes)
 	}
+	return article, notes
+}
 
-	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+// GenerateArticleFromTranscript writes an article in a single call when the
+// transcript fits the token budget. Longer transcripts are split into
+// overlapping chunks, each chunk is condensed into section notes, and the
+// article is written from the notes.
+func GenerateArticleFromTranscript(ctx context.Context, provider llm.Provider, transcript, language string, opts ArticleOptions) (string, error) {
+	articlePrompt, notesPrompt := opts.prompts()
+	if opts.Chunking.Budget <= 0 {
+		return generateArticle(ctx, provider, articlePrompt, PromptData{Transcript: transcript, Timed: opts.Timed, Language: language})
+	}
+	tokens, err := llm.CountTokens(ctx, provider, transcript)
 	if err != nil {
-		log.Fatalf("Error creating client: %v", err)
+		return "", err
+	}
+	if tokens <= opts.Chunking.Budget {
+		return generateArticle(ctx, provider, articlePrompt, PromptData{Transcript: transcript, Timed: opts.Timed, Language: language})
 	}
-	defer client.Close()
 
-	model := client.GenerativeModel("gemini-2.5-flash-preview-04-17")
+	chunks := splitTranscript(transcript, tokens, opts.Chunking)
+	fmt.Printf("transcript has %d tokens, generating from %d chunks\n", tokens, len(chunks))
+	onChunk := opts.OnChunk
+	if onChunk == nil {
+		onChunk = func(done, total int) {}
+	}
 
-	model.SetTemperature(1)
-	model.SetTopK(40)
-	model.SetTopP(0.95)
-	model.SetMaxOutputTokens(8192)
-	model.ResponseMIMEType = "text/plain"
+	onChunk(0, len(chunks))
+	notes := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		prompt, err := notesPrompt.Render(PromptData{Chunk: chunk, Index: i + 1, Total: len(chunks), Timed: opts.Timed, Language: language})
+		if err != nil {
+			return "", err
+		}
+		resp, err := provider.Generate(ctx, llm.Request{Prompt: prompt, Purpose: PurposeChunkNotes})
+		if err != nil {
+			return "", fmt.Errorf("error summarizing chunk %d of %d: %w", i+1, len(chunks), err)
+		}
+		notes[i] = resp.Text
+		onChunk(i+1, len(chunks))
+	}
 
-	session := model.StartChat()
-	session.History = []*genai.Content{}
+	return generateArticle(ctx, provider, articlePrompt, PromptData{Notes: notes, Timed: opts.Timed, Language: language})
+}
 
-	resp, err := session.SendMessage(ctx, genai.Text(generateArticlePrompt(transcript, language)))
+// generateArticle renders and sends an article prompt and turns the model's
+// refusal JSON into ErrNotEducational. Content is normally rejected by the
+// classifier before this; the refusal is a last line of defence.
+func generateArticle(ctx context.Context, provider llm.Provider, tmpl *PromptTemplate, data PromptData) (string, error) {
+	prompt, err := tmpl.Render(data)
 	if err != nil {
-		return "", fmt.Errorf("error sending message: %v", err)
+		return "", err
 	}
-
-	var article strings.Builder
-	for _, part := range resp.Candidates[0].Content.Parts {
-		article.WriteString(fmt.Sprintf("%v", part))
+	resp, err := provider.Generate(ctx, llm.Request{Prompt: prompt, Purpose: PurposeArticle})
+	if err != nil {
+		return "", err
 	}
-	if strings.Contains(article.String(), `"error"`) {
-		// Parse the JSON to extract the error message
-		var errorResponse struct {
-			Error string `json:"error"`
-		}
-		err := json.Unmarshal([]byte(article.String()), &errorResponse)
-		if err == nil && errorResponse.Error != "" {
-			return "", errors.New(errorResponse.Error)
-		}
-		// Fallback error if parsing fails
-		return "", errors.New("the provided content could not be processed as educational material")
+
+	article := resp.Text
+	if reason, ok := refusalReason(article); ok {
+		return "", fmt.Errorf("%w: %s", ErrNotEducational, reason)
 	}
 
-	return article.String(), nil
+	return article, nil
+}
+
+// refusalReason reports whether the whole answer is the refusal JSON the
+// article prompt asks for, rather than an article that merely mentions "error".
+func refusalReason(answer string) (string, bool) {
+	answer = strings.TrimSpace(answer)
+	answer = strings.TrimPrefix(answer, "```json")
+	answer = strings.TrimSuffix(strings.TrimPrefix(answer, "```"), "```")
+	answer = strings.TrimSpace(answer)
+	if !strings.HasPrefix(answer, "{") {
+		return "", false
+	}
+	var refusal struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(answer), &refusal); err != nil || refusal.Error == "" {
+		return "", false
+	}
+	return refusal.Error, true
 }
 
+// QuizQuestion is a question as the model writes it. Which answer field is
+// set depends on Type; questions without a type are single choice.
 type QuizQuestion struct {
+	Type     string   `json:"type"`
 	Question string   `json:"question"`
 	Options  []string `json:"options"`
-	Answer   int      `json:"true_answer_index"`
+	// Answer is the correct option of single choice questions. It is nil
+	// when the model left it out, which a zero index could not tell apart.
+	Answer *int `json:"true_answer_index"`
+	// AnswerIndices are the correct options of multiple select questions.
+	AnswerIndices []int `json:"true_answer_indices"`
+	// AnswerValue is the answer to true/false questions.
+	AnswerValue *bool `json:"true_answer_value"`
+	// AcceptedAnswers are the answers that fill the blank of fill-in-the-blank questions.
+	AcceptedAnswers []string `json:"accepted_answers"`
+	// SampleAnswer is a model answer to short answer questions.
+	SampleAnswer string `json:"sample_answer"`
+	// Explanation is why the answer is right, shown after answering.
+	Explanation string `json:"explanation"`
 }
 
 type Quiz struct {
 	Questions []QuizQuestion `json:"questions"`
 }
 
-func GenerateQuizzesFromArticle(article, language string) (*Quiz, error) {
-	ctx := context.Background()
+// QuizOptions tune GenerateQuizzesFromArticle.
+type QuizOptions struct {
+	// Prompt overrides the quiz template. Nil uses the built-in default.
+	Prompt *PromptTemplate
+	// Settings are the question count and difficulty asked for.
+	Settings store.QuizSettings
+}
 
-	apiKey, ok := os.LookupEnv("LLM_KEY")
-	if !ok {
-		log.Fatalln("Environment variable LLM_KEY not set")
+// GenerateQuizzesFromArticle asks the model for a quiz in JSON mode and
+// validates it. An answer that cannot be parsed or breaks the quiz invariants
+// gets one corrective re-prompt listing what was wrong.
+func GenerateQuizzesFromArticle(ctx context.Context, provider llm.Provider, article, language string, opts QuizOptions) (*Quiz, error) {
+	tmpl := opts.Prompt
+	if tmpl == nil {
+		tmpl = DefaultPrompt(PromptQuiz)
 	}
-
-	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+	prompt, err := tmpl.Render(PromptData{
+		Article:       article,
+		Language:      language,
+		QuestionCount: opts.Settings.QuestionCount,
+		Difficulty:    opts.Settings.Difficulty,
+	})
 	if err != nil {
-		log.Fatalf("Error creating client: %v", err)
+		return nil, err
 	}
-
-	model := client.GenerativeModel("gemini-2.5-flash-preview-04-17")
-
-	model.SetTemperature(1)
-	model.SetTopK(40)
-	model.SetTopP(0.95)
-	model.SetMaxOutputTokens(8192)
-	model.ResponseMIMEType = "text/plain"
-	session := model.StartChat()
-	session.History = []*genai.Content{}
-
-	prompt := generateQuizPrompt(article, language)
-
-	resp, err := session.SendMessage(ctx, genai.Text(prompt))
+	resp, err := provider.Generate(ctx, llm.Request{Prompt: prompt, Schema: quizSchema, Purpose: PurposeQuiz})
 	if err != nil {
-		return nil, fmt.Errorf("error sending message: %v", err)
+		return nil, err
 	}
-	var rawResponse strings.Builder
-	for _, part := range resp.Candidates[0].Content.Parts {
-		rawResponse.WriteString(fmt.Sprintf("%v", part))
+	quiz, err := parseQuiz(resp.Text, opts.Settings)
+	if err == nil {
+		return quiz, nil
 	}
 
-	// Clean up the response by removing markdown code block markers
-	cleanedResponse := strings.TrimPrefix(rawResponse.String(), "```json\n")
-	cleanedResponse = strings.TrimSuffix(cleanedResponse, "\n")
-	cleanedResponse = strings.TrimSuffix(cleanedResponse, "```")
-	cleanedResponse = strings.TrimSpace(cleanedResponse) // Remove any remaining whitespace
-
-	var quizResp Quiz
-	if err := json.Unmarshal([]byte(cleanedResponse), &quizResp); err != nil {
-		fmt.Println(cleanedResponse)
-		return nil, fmt.Errorf("failed to parse quiz response: %v", err)
+	fmt.Printf("quiz rejected, asking for a repair: %v\n", err)
+	resp, err = provider.Generate(ctx, llm.Request{Prompt: repairQuizPrompt(prompt, resp.Text, err), Schema: quizSchema, Purpose: PurposeQuizRepair})
+	if err != nil {
+		return nil, err
 	}
-
-	fmt.Println("Quiz generated successfully")
-
-	return &quizResp, nil
+	quiz, err = parseQuiz(resp.Text, opts.Settings)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuiz, err)
+	}
+	return quiz, nil
 }
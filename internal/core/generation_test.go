@@ -1,10 +1,15 @@
 package core_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/demirbey05/auth-demo/internal/core"
-	"github.com/joho/godotenv"
+	"github.com/demirbey05/auth-demo/internal/llm"
+	"github.com/demirbey05/auth-demo/internal/store"
 )
 
 var article = `Okay, here's a Medium-style article based on the provided podcast transcript. I've aimed for clarity, readability, and the kind of engaging tone you often find on the platform:
@@ -74,13 +79,9 @@ Ultimately, the field of AI will continue to evolve as we push these new technol
 Let me know if you want any changes or further adjustments!"`
 
 func TestGenerateQuizzesFromArticle(t *testing.T) {
+	provider := llm.NewFake()
 
-	// Load environment variables same as main code
-	if err := godotenv.Load("../../.env"); err != nil {
-		t.Log("No .env file found")
-	}
-
-	quiz, err := core.GenerateQuizzesFromArticle(article, "Turkish")
+	quiz, err := core.GenerateQuizzesFromArticle(context.Background(), provider, article, "Turkish", core.QuizOptions{})
 	if err != nil {
 		t.Fatalf("GenerateQuizzesFromArticle failed: %v", err)
 	}
@@ -90,8 +91,8 @@ func TestGenerateQuizzesFromArticle(t *testing.T) {
 	}
 
 	// Basic validation of quiz structure
-	if len(quiz.Questions) != 5 {
-		t.Errorf("Expected 5 questions, got %d", len(quiz.Questions))
+	if len(quiz.Questions) < core.MinQuizQuestions || len(quiz.Questions) > core.MaxQuizQuestions {
+		t.Errorf("Expected %d-%d questions, got %d", core.MinQuizQuestions, core.MaxQuizQuestions, len(quiz.Questions))
 	}
 
 	for i, q := range quiz.Questions {
@@ -101,8 +102,250 @@ func TestGenerateQuizzesFromArticle(t *testing.T) {
 		if len(q.Options) != 4 {
 			t.Errorf("Question %d has %d options, expected 4", i, len(q.Options))
 		}
-		if q.Answer < 0 || q.Answer > 3 {
-			t.Errorf("Question %d has invalid answer index %d", i, q.Answer)
+		if q.Answer == nil || *q.Answer < 0 || *q.Answer > 3 {
+			t.Errorf("Question %d has invalid answer index %v", i, q.Answer)
+		}
+	}
+}
+
+func TestGenerateArticleRejectsNonEducationalContent(t *testing.T) {
+	provider := llm.NewFake()
+	provider.Reply = func(req llm.Request) (string, error) {
+		return `{"error": "The provided content does not appear to be educational."}`, nil
+	}
+
+	_, err := core.GenerateArticleFromTranscript(context.Background(), provider, "la la la", "English", core.ArticleOptions{})
+	if !errors.Is(err, core.ErrNotEducational) {
+		t.Fatalf("expected ErrNotEducational, got %v", err)
+	}
+	if requests := provider.Requests(); len(requests) != 1 || !strings.Contains(requests[0].Prompt, "la la la") {
+		t.Errorf("expected one prompt containing the transcript, got %v", requests)
+	}
+}
+
+func TestGenerateArticleKeepsArticlesAboutErrors(t *testing.T) {
+	want := "## Handling Errors\n\nAn API might answer `{\"error\": \"not found\"}` when a record is missing."
+	provider := llm.NewFake()
+	provider.Reply = func(req llm.Request) (string, error) {
+		return want, nil
+	}
+
+	got, err := core.GenerateArticleFromTranscript(context.Background(), provider, "a lecture on APIs", "English", core.ArticleOptions{})
+	if err != nil {
+		t.Fatalf("GenerateArticleFromTranscript failed: %v", err)
+	}
+	if got != want {
+		t.Errorf("expected the article unchanged, got %q", got)
+	}
+}
+
+func TestGenerateQuizzesRepairsInvalidQuiz(t *testing.T) {
+	provider := llm.NewFake()
+	provider.Reply = func(req llm.Request) (string, error) {
+		if req.Schema == nil {
+			t.Error("expected quiz requests to carry a schema")
+		}
+		if strings.Contains(req.Prompt, "previous answer") {
+			return llm.FakeQuiz, nil
+		}
+		return `{"questions": [{"question": "Q", "options": ["A", "A", "B"], "true_answer_index": 5}]}`, nil
+	}
+
+	quiz, err := core.GenerateQuizzesFromArticle(context.Background(), provider, article, "English", core.QuizOptions{})
+	if err != nil {
+		t.Fatalf("GenerateQuizzesFromArticle failed: %v", err)
+	}
+	if err := core.ValidateQuiz(quiz, store.QuizSettings{}); err != nil {
+		t.Errorf("repaired quiz is invalid: %v", err)
+	}
+
+	requests := provider.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	for _, problem := range []string{"expected 7-10 questions", "duplicate option", "out of range"} {
+		if !strings.Contains(requests[1].Prompt, problem) {
+			t.Errorf("repair prompt does not mention %q", problem)
+		}
+	}
+}
+
+func TestGenerateQuizzesHonorsQuizSettings(t *testing.T) {
+	short := `{"questions": [
+	{"question": "Q1", "options": ["A", "B", "C", "D"], "true_answer_index": 0, "explanation": "E"},
+	{"question": "Q2", "options": ["A", "B", "C", "D"], "true_answer_index": 1, "explanation": "E"},
+	{"question": "Q3", "options": ["A", "B", "C", "D"], "true_answer_index": 2, "explanation": "E"}
+]}`
+	provider := llm.NewFake()
+	provider.Reply = func(req llm.Request) (string, error) {
+		if strings.Contains(req.Prompt, "previous answer") {
+			return short, nil
+		}
+		return llm.FakeQuiz, nil
+	}
+
+	settings := store.QuizSettings{QuestionCount: 3, Difficulty: core.DifficultyHard}
+	quiz, err := core.GenerateQuizzesFromArticle(context.Background(), provider, article, "English", core.QuizOptions{Settings: settings})
+	if err != nil {
+		t.Fatalf("GenerateQuizzesFromArticle failed: %v", err)
+	}
+	if len(quiz.Questions) != 3 {
+		t.Errorf("expected 3 questions, got %d", len(quiz.Questions))
+	}
+
+	requests := provider.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("expected a rejected quiz and a repair, got %d requests", len(requests))
+	}
+	for _, want := range []string{"Create exactly 3 questions", "Make the questions hard"} {
+		if !strings.Contains(requests[0].Prompt, want) {
+			t.Errorf("quiz prompt does not contain %q", want)
+		}
+	}
+	if !strings.Contains(requests[1].Prompt, "expected exactly 3 questions, got 7") {
+		t.Errorf("repair prompt does not mention the question count")
+	}
+}
+
+func TestGenerateQuizzesSupportsQuestionTypes(t *testing.T) {
+	mixed := `{"questions": [
+	{"type": "single_choice", "question": "Q1", "options": ["A", "B", "C", "D"], "true_answer_index": 2, "explanation": "E"},
+	{"type": "true_false", "question": "Q2", "true_answer_value": false, "explanation": "E"},
+	{"type": "multiple_select", "question": "Q3", "options": ["A", "B", "C", "D"], "true_answer_indices": [0, 3], "explanation": "E"},
+	{"type": "fill_blank", "question": "Q4 is ___.", "accepted_answers": ["blank"], "explanation": "E"},
+	{"type": "short_answer", "question": "Q5", "sample_answer": "An answer.", "explanation": "E"}
+]}`
+	provider := llm.NewFake()
+	provider.Reply = func(req llm.Request) (string, error) {
+		return mixed, nil
+	}
+
+	settings := store.QuizSettings{QuestionCount: 5}
+	quiz, err := core.GenerateQuizzesFromArticle(context.Background(), provider, article, "English", core.QuizOptions{Settings: settings})
+	if err != nil {
+		t.Fatalf("GenerateQuizzesFromArticle failed: %v", err)
+	}
+	for i, want := range core.QuestionTypes {
+		if quiz.Questions[i].Type != want {
+			t.Errorf("question %d has type %q, expected %q", i+1, quiz.Questions[i].Type, want)
+		}
+	}
+	if n := len(provider.Requests()); n != 1 {
+		t.Errorf("expected the quiz to be accepted without a repair, got %d requests", n)
+	}
+
+	broken := []core.QuizQuestion{
+		{Type: core.QuestionMultipleSelect, Question: "Q", Options: []string{"A", "B", "C", "D"}, AnswerIndices: []int{1, 1, 4}, Explanation: "E"},
+		{Type: core.QuestionTrueFalse, Question: "Q", Explanation: "E"},
+		{Type: core.QuestionFillBlank, Question: "No blank here", AcceptedAnswers: []string{"x"}, Explanation: "E"},
+		{Type: "essay", Question: "Q", Explanation: "E"},
+	}
+	err = core.ValidateQuiz(&core.Quiz{Questions: broken}, store.QuizSettings{QuestionCount: 4})
+	for _, problem := range []string{"repeats true_answer_indices entry 1", "entry 4 out of range", "no true_answer_value", "does not mark the blank", "unknown type \"essay\""} {
+		if err == nil || !strings.Contains(err.Error(), problem) {
+			t.Errorf("expected validation error to mention %q, got %v", problem, err)
+		}
+	}
+}
+
+func TestGenerateQuizzesRejectsChoicesWithoutAnswer(t *testing.T) {
+	// Before the answer was optional, an omitted index decoded as option A
+	quiz := `{"questions": [
+	{"type": "single_choice", "question": "Q1", "options": ["A", "B", "C", "D"], "explanation": "E"},
+	{"type": "single_choice", "question": "Q2", "options": ["A", "B"], "true_answer_index": 0, "explanation": "E"},
+	{"type": "multiple_select", "question": "Q3", "options": ["A", "B", "C", "D"], "explanation": "E"}
+]}`
+	provider := llm.NewFake()
+	provider.Reply = func(req llm.Request) (string, error) {
+		return quiz, nil
+	}
+
+	_, err := core.GenerateQuizzesFromArticle(context.Background(), provider, article, "English", core.QuizOptions{Settings: store.QuizSettings{QuestionCount: 3}})
+	if !errors.Is(err, core.ErrInvalidQuiz) {
+		t.Fatalf("expected ErrInvalidQuiz, got %v", err)
+	}
+	requests := provider.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("expected a repair request, got %d requests", len(requests))
+	}
+	for _, problem := range []string{"question 1 has no true_answer_index", "question 2 has 2 options, expected 4", "question 3 has no true_answer_indices"} {
+		if !strings.Contains(requests[1].Prompt, problem) {
+			t.Errorf("expected the repair prompt to mention %q", problem)
+		}
+	}
+}
+
+func TestGenerateQuizzesGivesUpAfterOneRepair(t *testing.T) {
+	provider := llm.NewFake()
+	provider.Reply = func(req llm.Request) (string, error) {
+		return "not json", nil
+	}
+
+	_, err := core.GenerateQuizzesFromArticle(context.Background(), provider, article, "English", core.QuizOptions{})
+	if !errors.Is(err, core.ErrInvalidQuiz) {
+		t.Fatalf("expected ErrInvalidQuiz, got %v", err)
+	}
+	if n := len(provider.Requests()); n != 2 {
+		t.Errorf("expected 2 requests, got %d", n)
+	}
+}
+
+func TestGenerateArticleChunksLongTranscripts(t *testing.T) {
+	provider := llm.NewFake()
+	provider.Reply = func(req llm.Request) (string, error) {
+		if strings.Contains(req.Prompt, "Transcript notes:") {
+			return "## The Article", nil
 		}
+		return "- a note", nil
+	}
+
+	// 1000 words of 8 bytes each are about 2250 estimated tokens, so chunks
+	// hold about 222 words and overlap by 22
+	words := make([]string, 1000)
+	for i := range words {
+		words[i] = fmt.Sprintf("word%04d", i)
+	}
+	transcript := strings.Join(words, " ")
+
+	var progress [][2]int
+	article, err := core.GenerateArticleFromTranscript(context.Background(), provider, transcript, "English", core.ArticleOptions{
+		Chunking: core.ChunkConfig{Budget: 1000, ChunkTokens: 500, OverlapTokens: 50},
+		OnChunk:  func(done, total int) { progress = append(progress, [2]int{done, total}) },
+	})
+	if err != nil {
+		t.Fatalf("GenerateArticleFromTranscript failed: %v", err)
+	}
+	if article != "## The Article" {
+		t.Errorf("expected the synthesized article, got %q", article)
+	}
+
+	requests := provider.Requests()
+	chunks := len(requests) - 1
+	if chunks < 4 {
+		t.Fatalf("expected at least 4 chunk requests, got %d", chunks)
+	}
+	// Neighbouring chunks overlap, so the last word of one chunk reappears in the next
+	if !strings.Contains(requests[0].Prompt, "word0210") || !strings.Contains(requests[1].Prompt, "word0210") {
+		t.Error("expected the first two chunks to overlap")
+	}
+	if !strings.Contains(requests[chunks-1].Prompt, "word0999") {
+		t.Error("expected the last chunk to end the transcript")
+	}
+	if len(progress) != chunks+1 || progress[0] != [2]int{0, chunks} || progress[chunks] != [2]int{chunks, chunks} {
+		t.Errorf("unexpected progress reports %v", progress)
+	}
+}
+
+func TestGenerateArticleSinglePassWithinBudget(t *testing.T) {
+	provider := llm.NewFake()
+
+	_, err := core.GenerateArticleFromTranscript(context.Background(), provider, "a short lecture", "English", core.ArticleOptions{
+		Chunking: core.ChunkConfig{Budget: 1000, ChunkTokens: 500},
+	})
+	if err != nil {
+		t.Fatalf("GenerateArticleFromTranscript failed: %v", err)
+	}
+	if n := len(provider.Requests()); n != 1 {
+		t.Errorf("expected a single request, got %d", n)
 	}
 }
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/demirbey05/auth-demo/internal/llm"
+	"github.com/demirbey05/auth-demo/internal/store"
+)
+
+// Purposes recorded with every LLM call.
+const (
+	PurposeArticle    = "article"
+	PurposeChunkNotes = "chunk_notes"
+	PurposeQuiz       = "quiz"
+	PurposeQuizRepair = "quiz_repair"
+	PurposeClassify   = "classify"
+)
+
+// meteredProvider records the tokens and latency of every call made for a job.
+type meteredProvider struct {
+	provider  llm.Provider
+	callStore store.LLMCallStore
+	job       store.Job
+}
+
+func (m *meteredProvider) Generate(ctx context.Context, req llm.Request) (llm.Response, error) {
+	start := time.Now()
+	resp, err := m.provider.Generate(ctx, req)
+
+	call := store.LLMCall{
+		PodID:        m.job.PodID,
+		JobID:        m.job.ID,
+		UserID:       m.job.UserID,
+		Model:        resp.Model,
+		Purpose:      req.Purpose,
+		InputTokens:  resp.Usage.InputTokens,
+		OutputTokens: resp.Usage.OutputTokens,
+		TotalTokens:  resp.Usage.TotalTokens,
+		Latency:      time.Since(start),
+	}
+	if call.Model == "" {
+		call.Model = "unknown"
+	}
+	if err != nil {
+		call.Error = err.Error()
+	}
+	// Failed calls are recorded too, they may still have been billed
+	if rerr := m.callStore.RecordLLMCall(context.WithoutCancel(ctx), call); rerr != nil {
+		fmt.Printf("job %d: error recording LLM call: %v\n", m.job.ID, rerr)
+	}
+	return resp, err
+}
+
+func (m *meteredProvider) CountTokens(ctx context.Context, text string) (int, error) {
+	return llm.CountTokens(ctx, m.provider, text)
+}
@@ -0,0 +1,490 @@
+package core
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// The markdown the model writes is parsed into a small tree of blocks and
+// styled spans that every export format renders from. Only the subset the
+// article prompt asks for is understood: headings, paragraphs, nested lists,
+// block quotes, code, rules, tables and inline bold, italic, code and links.
+// Anything else, raw HTML included, is kept as plain text.
+
+type mdBlockKind int
+
+const (
+	mdParagraph mdBlockKind = iota
+	mdHeading
+	mdListItem
+	mdQuote
+	mdCode
+	mdRule
+	mdTable
+)
+
+type mdBlock struct {
+	kind mdBlockKind
+	// level is the heading level, or the nesting depth of a list item.
+	level   int
+	ordered bool
+	number  int
+	// text is inline markdown, or the literal lines of a code block.
+	text string
+	// rows are the cells of a table, header row first.
+	rows [][]string
+}
+
+// mdSpan is a run of text with a single style.
+type mdSpan struct {
+	text      string
+	bold      bool
+	italic    bool
+	code      bool
+	href      string
+	lineBreak bool
+}
+
+var (
+	mdHeadingRe  = regexp.MustCompile(`^(#{1,6})(?:\s+(.*?))?(?:\s+#+)?\s*$`)
+	mdListRe     = regexp.MustCompile(`^(\s*)([-*+]|\d{1,9}[.)])\s+(.*)$`)
+	mdRuleRe     = regexp.MustCompile(`^ {0,3}(?:(?:\*\s*){3,}|(?:-\s*){3,}|(?:_\s*){3,})$`)
+	mdFenceRe    = regexp.MustCompile("^\\s*(```+|~~~+)")
+	mdTableSepRe = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	mdWrappedRe  = regexp.MustCompile("^```(?:markdown|md)?\n([\\s\\S]*)\n```$")
+)
+
+// parseMarkdown splits markdown into blocks. A fence wrapping the whole
+// article, which models sometimes add, is removed first.
+func parseMarkdown(src string) []mdBlock {
+	src = strings.ReplaceAll(strings.TrimSpace(src), "\r\n", "\n")
+	if m := mdWrappedRe.FindStringSubmatch(src); m != nil {
+		src = m[1]
+	}
+	lines := strings.Split(src, "\n")
+
+	var blocks []mdBlock
+	// listIndents holds the indentation of each open list level.
+	var listIndents []int
+	// open is the block plain lines are appended to, if any.
+	open := -1
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.ReplaceAll(lines[i], "\t", "    ")
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "" {
+			open = -1
+			continue
+		}
+
+		if m := mdFenceRe.FindStringSubmatch(line); m != nil {
+			var code []string
+			for i++; i < len(lines); i++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), m[1]) {
+					break
+				}
+				code = append(code, lines[i])
+			}
+			blocks = append(blocks, mdBlock{kind: mdCode, text: strings.Join(code, "\n")})
+			open, listIndents = -1, nil
+			continue
+		}
+
+		if m := mdHeadingRe.FindStringSubmatch(trimmed); m != nil {
+			blocks = append(blocks, mdBlock{kind: mdHeading, level: len(m[1]), text: m[2]})
+			open, listIndents = -1, nil
+			continue
+		}
+
+		if mdRuleRe.MatchString(line) {
+			blocks = append(blocks, mdBlock{kind: mdRule})
+			open, listIndents = -1, nil
+			continue
+		}
+
+		if strings.Contains(trimmed, "|") && i+1 < len(lines) && strings.Contains(lines[i+1], "-") && mdTableSepRe.MatchString(lines[i+1]) {
+			rows := [][]string{splitTableRow(trimmed)}
+			for i += 2; i < len(lines) && strings.Contains(lines[i], "|"); i++ {
+				rows = append(rows, splitTableRow(strings.TrimSpace(lines[i])))
+			}
+			i--
+			blocks = append(blocks, mdBlock{kind: mdTable, rows: rows})
+			open, listIndents = -1, nil
+			continue
+		}
+
+		if m := mdListRe.FindStringSubmatch(line); m != nil {
+			indent := len(m[1])
+			for len(listIndents) > 0 && listIndents[len(listIndents)-1] > indent {
+				listIndents = listIndents[:len(listIndents)-1]
+			}
+			if len(listIndents) == 0 || listIndents[len(listIndents)-1] < indent {
+				listIndents = append(listIndents, indent)
+			}
+			item := mdBlock{kind: mdListItem, level: len(listIndents) - 1, text: m[3]}
+			if marker := m[2]; marker[0] >= '0' && marker[0] <= '9' {
+				item.ordered = true
+				fmt.Sscan(marker[:len(marker)-1], &item.number)
+			}
+			blocks = append(blocks, item)
+			open = len(blocks) - 1
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, ">") {
+			text := strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))
+			if open >= 0 && blocks[open].kind == mdQuote {
+				blocks[open].text += "\n" + text
+			} else {
+				blocks = append(blocks, mdBlock{kind: mdQuote, text: text})
+				open = len(blocks) - 1
+			}
+			listIndents = nil
+			continue
+		}
+
+		if open >= 0 {
+			// Keep trailing spaces, which mark a hard line break.
+			blocks[open].text += "\n" + strings.TrimLeft(line, " ")
+			continue
+		}
+		blocks = append(blocks, mdBlock{kind: mdParagraph, text: strings.TrimLeft(line, " ")})
+		open = len(blocks) - 1
+		listIndents = nil
+	}
+	return blocks
+}
+
+func splitTableRow(line string) []string {
+	line = strings.TrimSuffix(strings.TrimPrefix(line, "|"), "|")
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// parseInline splits inline markdown into styled spans. Emphasis markers
+// without a closing partner, and underscores inside words, are kept as text.
+func parseInline(src string) []mdSpan {
+	p := inlineParser{}
+	p.parse(src)
+	p.flush()
+	return p.spans
+}
+
+type inlineParser struct {
+	spans  []mdSpan
+	cur    strings.Builder
+	bold   bool
+	italic bool
+	href   string
+	// inLink is set while parsing the text of a link, which cannot hold
+	// another link.
+	inLink bool
+}
+
+func (p *inlineParser) flush() {
+	if p.cur.Len() == 0 {
+		return
+	}
+	p.spans = append(p.spans, mdSpan{text: p.cur.String(), bold: p.bold, italic: p.italic, href: p.href})
+	p.cur.Reset()
+}
+
+func (p *inlineParser) parse(s string) {
+	var closing map[int]int
+	if !p.inLink && strings.IndexByte(s, '[') >= 0 {
+		closing = matchBrackets(s)
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_{}[]()#+-.!|<>~", s[i+1]) >= 0:
+			p.cur.WriteByte(s[i+1])
+			i++
+
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			p.lineBreak()
+			i++
+
+		case c == '\n':
+			if strings.HasSuffix(p.cur.String(), "  ") {
+				trimmed := strings.TrimRight(p.cur.String(), " ")
+				p.cur.Reset()
+				p.cur.WriteString(trimmed)
+				p.lineBreak()
+			} else {
+				p.cur.WriteByte(' ')
+			}
+
+		case c == '`':
+			run := 1
+			for i+run < len(s) && s[i+run] == '`' {
+				run++
+			}
+			fence := s[i : i+run]
+			end := strings.Index(s[i+run:], fence)
+			if end < 0 {
+				p.cur.WriteString(fence)
+				i += run - 1
+				continue
+			}
+			p.flush()
+			code := strings.TrimSpace(strings.ReplaceAll(s[i+run:i+run+end], "\n", " "))
+			p.spans = append(p.spans, mdSpan{text: code, code: true, href: p.href})
+			i += run + end + run - 1
+
+		case c == '[' && !p.inLink:
+			closeText, ok := closing[i]
+			if !ok || closeText+1 >= len(s) || s[closeText+1] != '(' {
+				p.cur.WriteByte(c)
+				continue
+			}
+			closeURL, ok := closing[closeText+1]
+			if !ok {
+				p.cur.WriteByte(c)
+				continue
+			}
+			target := strings.TrimSpace(s[closeText+2 : closeURL])
+			if title := strings.IndexAny(target, " \t"); title >= 0 {
+				target = target[:title]
+			}
+			p.flush()
+			p.href, p.inLink = safeLinkURL(strings.Trim(target, "<>")), true
+			p.parse(s[i+1 : closeText])
+			p.flush()
+			p.href, p.inLink = "", false
+			i = closeURL
+
+		case (c == '*' || c == '_') && i+1 < len(s) && s[i+1] == c:
+			marker := s[i : i+2]
+			if p.bold || (i+2 < len(s) && s[i+2] != ' ' && strings.Contains(s[i+2:], marker)) {
+				p.flush()
+				p.bold = !p.bold
+			} else {
+				p.cur.WriteString(marker)
+			}
+			i++
+
+		case c == '*' || c == '_':
+			intraword := c == '_' && i > 0 && isWordByte(s[i-1]) && i+1 < len(s) && isWordByte(s[i+1])
+			opens := !p.italic && i+1 < len(s) && s[i+1] != ' ' && strings.IndexByte(s[i+1:], c) >= 0
+			if !intraword && (p.italic || opens) {
+				p.flush()
+				p.italic = !p.italic
+			} else {
+				p.cur.WriteByte(c)
+			}
+
+		default:
+			p.cur.WriteByte(c)
+		}
+	}
+}
+
+func (p *inlineParser) lineBreak() {
+	p.flush()
+	p.spans = append(p.spans, mdSpan{lineBreak: true})
+}
+
+// matchBrackets pairs the brackets of s in one pass and maps the index of
+// every "[" and "(" to the index of the bracket closing it. Escaped square
+// brackets are skipped; parentheses may nest inside a link target.
+func matchBrackets(s string) map[int]int {
+	closing := make(map[int]int)
+	var squares, parens []int
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) && (s[i+1] == '[' || s[i+1] == ']') {
+				i++
+			}
+		case '[':
+			squares = append(squares, i)
+		case ']':
+			if n := len(squares); n > 0 {
+				closing[squares[n-1]] = i
+				squares = squares[:n-1]
+			}
+		case '(':
+			parens = append(parens, i)
+		case ')':
+			if n := len(parens); n > 0 {
+				closing[parens[n-1]] = i
+				parens = parens[:n-1]
+			}
+		}
+	}
+	return closing
+}
+
+func isWordByte(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// safeLinkURL returns target if it is an absolute http, https or mailto URL,
+// and "" otherwise so the link is rendered as plain text.
+func safeLinkURL(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		if u.Host == "" {
+			return ""
+		}
+	case "mailto":
+	default:
+		return ""
+	}
+	return u.String()
+}
+
+// escapeXML escapes s for HTML and XHTML. Characters XML does not allow,
+// such as control characters, are dropped and invalid UTF-8 is replaced, so
+// the EPUB export stays well-formed.
+func escapeXML(s string) string {
+	return html.EscapeString(strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0xFFFE || r == 0xFFFF {
+			return -1
+		}
+		return r
+	}, s))
+}
+
+// spansText returns the text of spans without any styling.
+func spansText(spans []mdSpan) string {
+	var b strings.Builder
+	for _, s := range spans {
+		if s.lineBreak {
+			b.WriteByte(' ')
+		}
+		b.WriteString(s.text)
+	}
+	return b.String()
+}
+
+// renderMarkdownHTML renders markdown as an XHTML fragment. Every piece of
+// text is escaped and only the elements below are produced, with links
+// restricted to safeLinkURL, so the result is safe to embed whatever the
+// model wrote. Headings get ids section-1, section-2, ... in order.
+func renderMarkdownHTML(blocks []mdBlock) string {
+	var b strings.Builder
+	// lists holds whether each open list is ordered.
+	var lists []bool
+	closeLists := func(depth int) {
+		for len(lists) > depth {
+			if lists[len(lists)-1] {
+				b.WriteString("</li></ol>\n")
+			} else {
+				b.WriteString("</li></ul>\n")
+			}
+			lists = lists[:len(lists)-1]
+		}
+	}
+
+	section := 0
+	for _, block := range blocks {
+		if block.kind != mdListItem {
+			closeLists(0)
+		}
+		switch block.kind {
+		case mdHeading:
+			section++
+			fmt.Fprintf(&b, "<h%d id=\"section-%d\">%s</h%d>\n", block.level, section, renderInlineHTML(parseInline(block.text)), block.level)
+		case mdParagraph:
+			fmt.Fprintf(&b, "<p>%s</p>\n", renderInlineHTML(parseInline(block.text)))
+		case mdQuote:
+			fmt.Fprintf(&b, "<blockquote><p>%s</p></blockquote>\n", renderInlineHTML(parseInline(block.text)))
+		case mdCode:
+			fmt.Fprintf(&b, "<pre><code>%s</code></pre>\n", escapeXML(block.text))
+		case mdRule:
+			b.WriteString("<hr />\n")
+		case mdTable:
+			renderTableHTML(&b, block.rows)
+		case mdListItem:
+			depth := min(block.level, len(lists))
+			closeLists(depth + 1)
+			if len(lists) == depth+1 && lists[depth] != block.ordered {
+				closeLists(depth)
+			}
+			if len(lists) == depth+1 {
+				b.WriteString("</li>\n<li>")
+			} else {
+				switch {
+				case !block.ordered:
+					b.WriteString("<ul>\n<li>")
+				case block.number > 1:
+					fmt.Fprintf(&b, "<ol start=\"%d\">\n<li>", block.number)
+				default:
+					b.WriteString("<ol>\n<li>")
+				}
+				lists = append(lists, block.ordered)
+			}
+			b.WriteString(renderInlineHTML(parseInline(block.text)))
+		}
+	}
+	closeLists(0)
+	return b.String()
+}
+
+func renderTableHTML(b *strings.Builder, rows [][]string) {
+	b.WriteString("<table>\n")
+	for i, row := range rows {
+		cell := "td"
+		if i == 0 {
+			cell = "th"
+			b.WriteString("<thead>\n")
+		}
+		b.WriteString("<tr>")
+		for _, text := range row {
+			fmt.Fprintf(b, "<%s>%s</%s>", cell, renderInlineHTML(parseInline(text)), cell)
+		}
+		b.WriteString("</tr>\n")
+		if i == 0 {
+			b.WriteString("</thead>\n<tbody>\n")
+		}
+	}
+	b.WriteString("</tbody>\n</table>\n")
+}
+
+func renderInlineHTML(spans []mdSpan) string {
+	var b strings.Builder
+	for _, s := range spans {
+		if s.lineBreak {
+			b.WriteString("<br />")
+			continue
+		}
+		text := escapeXML(s.text)
+		if s.code {
+			text = "<code>" + text + "</code>"
+		}
+		if s.italic {
+			text = "<em>" + text + "</em>"
+		}
+		if s.bold {
+			text = "<strong>" + text + "</strong>"
+		}
+		if s.href != "" {
+			text = fmt.Sprintf("<a href=\"%s\" rel=\"nofollow noopener\">%s</a>", escapeXML(s.href), text)
+		}
+		b.WriteString(text)
+	}
+	return b.String()
+}
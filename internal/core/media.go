@@ -0,0 +1,343 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/demirbey05/auth-demo/internal/store"
+)
+
+// TranscriptWhisper is the provider name of transcripts made by a Whisper
+// compatible speech-to-text server.
+const TranscriptWhisper = "whisper"
+
+// ErrMediaTooLarge is returned when an upload exceeds MediaStore.MaxBytes.
+var ErrMediaTooLarge = errors.New("media file is too large")
+
+// mediaLinkPrefix marks the links of pods made from uploaded files. The rest
+// of the link is the name of the file in the MediaStore.
+const mediaLinkPrefix = "media:"
+
+// MediaExtensions are the file extensions accepted for uploads.
+var MediaExtensions = []string{".mp3", ".wav", ".flac", ".ogg", ".opus", ".m4a", ".mp4", ".mov", ".webm", ".mkv"}
+
+// MediaLink returns the pod link of an uploaded file.
+func MediaLink(name string) string {
+	return mediaLinkPrefix + name
+}
+
+// MediaName returns the file name of a media link, or false for other links.
+func MediaName(link string) (string, bool) {
+	return strings.CutPrefix(link, mediaLinkPrefix)
+}
+
+// MediaStore keeps uploaded media files on disk until the pipeline has stored
+// their transcript. Files whose transcription keeps failing stay for retries.
+// The API and the workers must share the directory.
+type MediaStore struct {
+	dir string
+	// MaxBytes is the largest upload accepted.
+	MaxBytes int64
+}
+
+// NewMediaStore creates dir if needed. An empty dir uses "media" and a
+// maxBytes of 0 allows 500 MB.
+func NewMediaStore(dir string, maxBytes int64) (*MediaStore, error) {
+	if dir == "" {
+		dir = "media"
+	}
+	if maxBytes <= 0 {
+		maxBytes = 500 << 20
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("error creating media directory: %v", err)
+	}
+	return &MediaStore{dir: dir, MaxBytes: maxBytes}, nil
+}
+
+// Save writes r to a new file with the extension ext and returns its name.
+// Nothing is kept when r is longer than MaxBytes.
+func (s *MediaStore) Save(r io.Reader, ext string) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	name := hex.EncodeToString(id) + strings.ToLower(ext)
+	f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return "", fmt.Errorf("error creating media file: %v", err)
+	}
+	n, err := io.Copy(f, io.LimitReader(r, s.MaxBytes+1))
+	if err == nil && n > s.MaxBytes {
+		err = ErrMediaTooLarge
+	}
+	if err != nil {
+		f.Close()
+		s.Remove(name)
+		return "", fmt.Errorf("error writing media file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		s.Remove(name)
+		return "", fmt.Errorf("error writing media file: %v", err)
+	}
+	return name, nil
+}
+
+// Open opens a stored file. Names are never paths, so a link cannot reach
+// outside the directory.
+func (s *MediaStore) Open(name string) (*os.File, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("invalid media name %q", name)
+	}
+	return os.Open(filepath.Join(s.dir, name))
+}
+
+// Duration probes the duration of a stored file.
+func (s *MediaStore) Duration(name string) (time.Duration, error) {
+	f, err := s.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return ProbeMediaDuration(f, info.Size())
+}
+
+// Remove deletes a stored file, for uploads that did not become a pod.
+func (s *MediaStore) Remove(name string) {
+	if f, err := s.Open(name); err == nil {
+		f.Close()
+		if err := os.Remove(f.Name()); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// SpeechToText transcribes audio or video. language is the language name the
+// user picked for the pod.
+type SpeechToText interface {
+	Transcribe(ctx context.Context, media io.Reader, fileName, language string) (store.Transcript, error)
+}
+
+// SpeechToTextConfig points at a Whisper compatible server.
+type SpeechToTextConfig struct {
+	// URL is the base URL of the OpenAI style API, such as http://localhost:8000/v1.
+	// Media uploads are disabled without it.
+	URL    string
+	Model  string
+	APIKey string
+	// Timeout bounds a whole transcription, which takes a while for long files.
+	Timeout time.Duration
+}
+
+// SpeechToTextConfigFromEnv reads WHISPER_URL, WHISPER_MODEL (default
+// whisper-1), WHISPER_API_KEY and WHISPER_TIMEOUT (default 10m).
+func SpeechToTextConfigFromEnv() SpeechToTextConfig {
+	cfg := SpeechToTextConfig{
+		URL:     os.Getenv("WHISPER_URL"),
+		Model:   os.Getenv("WHISPER_MODEL"),
+		APIKey:  os.Getenv("WHISPER_API_KEY"),
+		Timeout: 10 * time.Minute,
+	}
+	if cfg.Model == "" {
+		cfg.Model = "whisper-1"
+	}
+	if v, err := time.ParseDuration(os.Getenv("WHISPER_TIMEOUT")); err == nil && v > 0 {
+		cfg.Timeout = v
+	}
+	return cfg
+}
+
+// WhisperTranscriber sends files to the /audio/transcriptions endpoint of a
+// Whisper compatible server, such as faster-whisper-server or whisper.cpp.
+type WhisperTranscriber struct {
+	client *http.Client
+	cfg    SpeechToTextConfig
+}
+
+func NewWhisperTranscriber(cfg SpeechToTextConfig) (*WhisperTranscriber, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("WHISPER_URL is required for media uploads")
+	}
+	cfg.URL = strings.TrimSuffix(cfg.URL, "/")
+	return &WhisperTranscriber{client: &http.Client{Timeout: cfg.Timeout}, cfg: cfg}, nil
+}
+
+// WhisperResponse is the verbose_json response of a transcription. Segment
+// times are in seconds.
+type WhisperResponse struct {
+	Language string `json:"language"`
+	Text     string `json:"text"`
+	Segments []struct {
+		Start float64 `json:"start"`
+		End   float64 `json:"end"`
+		Text  string  `json:"text"`
+	} `json:"segments"`
+}
+
+func (w *WhisperTranscriber) Transcribe(ctx context.Context, media io.Reader, fileName, language string) (store.Transcript, error) {
+	// Stream the file instead of holding it in memory
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		writer.CloseWithError(writeWhisperForm(form, media, fileName, w.cfg.Model, languageMap[language]))
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL+"/audio/transcriptions", body)
+	if err != nil {
+		return store.Transcript{}, fmt.Errorf("%s: failed to create request: %v", TranscriptWhisper, err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if w.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+w.cfg.APIKey)
+	}
+
+	var resp WhisperResponse
+	if err := doTranscriptJSON(w.client, TranscriptWhisper, req, &resp); err != nil {
+		return store.Transcript{}, err
+	}
+
+	t := store.Transcript{Provider: TranscriptWhisper, Language: languageCode(resp.Language)}
+	for _, seg := range resp.Segments {
+		t.Segments = append(t.Segments, store.TranscriptSegment{
+			Text:       strings.TrimSpace(seg.Text),
+			StartMs:    int(math.Round(seg.Start * 1000)),
+			DurationMs: int(math.Round((seg.End - seg.Start) * 1000)),
+		})
+	}
+	if len(t.Segments) == 0 {
+		t.Segments = []store.TranscriptSegment{{Text: resp.Text}}
+	}
+	if t.Language == "" {
+		t.Language = languageMap[language]
+	}
+	if t.Text() == "" {
+		return store.Transcript{}, fmt.Errorf("%s: %w", TranscriptWhisper, ErrEmptyTranscript)
+	}
+	return t, nil
+}
+
+func writeWhisperForm(form *multipart.Writer, media io.Reader, fileName, model, langCode string) error {
+	fields := [][2]string{{"model", model}, {"response_format", "verbose_json"}, {"timestamp_granularities[]", "segment"}}
+	// Whisper detects the language when it is not given
+	if langCode != "" {
+		fields = append(fields, [2]string{"language", langCode})
+	}
+	for _, f := range fields {
+		if err := form.WriteField(f[0], f[1]); err != nil {
+			return err
+		}
+	}
+	part, err := form.CreateFormFile("file", fileName)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, media); err != nil {
+		return err
+	}
+	return form.Close()
+}
+
+// languageCode turns the language names Whisper reports, such as "english",
+// into codes. Codes are returned as they are.
+func languageCode(language string) string {
+	for name, code := range languageMap {
+		if strings.EqualFold(name, language) {
+			return code
+		}
+	}
+	return language
+}
+
+// MediaTranscriber transcribes the uploaded file of a media link.
+type MediaTranscriber struct {
+	media  *MediaStore
+	speech SpeechToText
+}
+
+func NewMediaTranscriber(media *MediaStore, speech SpeechToText) *MediaTranscriber {
+	return &MediaTranscriber{media: media, speech: speech}
+}
+
+func (m *MediaTranscriber) FetchTranscript(ctx context.Context, link, language string) (store.Transcript, error) {
+	name, ok := MediaName(link)
+	if !ok {
+		return store.Transcript{}, fmt.Errorf("%s: %q is not a media link", TranscriptWhisper, link)
+	}
+	f, err := m.media.Open(name)
+	if err != nil {
+		return store.Transcript{}, fmt.Errorf("%s: %v", TranscriptWhisper, err)
+	}
+	defer f.Close()
+	return m.speech.Transcribe(ctx, f, name, language)
+}
+
+// ReleaseSource deletes the uploaded file of link once its transcript is stored.
+func (m *MediaTranscriber) ReleaseSource(link string) {
+	if name, ok := MediaName(link); ok {
+		m.media.Remove(name)
+	}
+}
+
+// SourceTranscripts sends media links to Media and every other link to Video.
+type SourceTranscripts struct {
+	Video TranscriptProvider
+	// Media is nil when uploads are disabled.
+	Media TranscriptProvider
+}
+
+func (s SourceTranscripts) FetchTranscript(ctx context.Context, link, language string) (store.Transcript, error) {
+	if _, ok := MediaName(link); ok {
+		if s.Media == nil {
+			return store.Transcript{}, errors.New("media transcription is not configured")
+		}
+		return s.Media.FetchTranscript(ctx, link, language)
+	}
+	return s.Video.FetchTranscript(ctx, link, language)
+}
+
+func (s SourceTranscripts) ReleaseSource(link string) {
+	provider := s.Video
+	if _, ok := MediaName(link); ok {
+		provider = s.Media
+	}
+	if releaser, ok := provider.(SourceReleaser); ok {
+		releaser.ReleaseSource(link)
+	}
+}
+
+// MediaCost prices an uploaded file like a video of the same length, with
+// partial minutes rounded up so short recordings are not free.
+func MediaCost(d time.Duration) int {
+	return int(math.Ceil(d.Minutes())) * costPerMinute
+}
+
+// sourceCost is the full price of generating a pod from link.
+func sourceCost(link string, media *MediaStore) (int, error) {
+	name, ok := MediaName(link)
+	if !ok {
+		return CalculateCost(link)
+	}
+	if media == nil {
+		return 0, errors.New("media uploads are not configured")
+	}
+	d, err := media.Duration(name)
+	if err != nil {
+		return 0, err
+	}
+	return MediaCost(d), nil
+}
@@ -0,0 +1,163 @@
+package core_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/demirbey05/auth-demo/internal/core"
+	"github.com/demirbey05/auth-demo/internal/llm"
+	"github.com/demirbey05/auth-demo/internal/store"
+)
+
+func TestMediaUploadIsTranscribedByWhisper(t *testing.T) {
+	audio := wavFile(2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/transcriptions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer key" {
+			t.Errorf("expected the API key, got %q", got)
+		}
+		for field, want := range map[string]string{"model": "large-v3", "language": "tr", "response_format": "verbose_json"} {
+			if got := r.FormValue(field); got != want {
+				t.Errorf("expected %s=%q, got %q", field, want, got)
+			}
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			t.Fatalf("missing file: %v", err)
+		}
+		if body, _ := io.ReadAll(file); !bytes.Equal(body, audio) {
+			t.Errorf("expected the uploaded file to be sent")
+		}
+		w.Write([]byte(`{"language": "turkish", "text": "Merhaba dünya", "segments": [
+			{"start": 0.0, "end": 1.5, "text": " Merhaba"},
+			{"start": 1.5, "end": 2.0, "text": " dünya"}
+		]}`))
+	}))
+	defer srv.Close()
+
+	media, err := core.NewMediaStore(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatalf("NewMediaStore failed: %v", err)
+	}
+	name, err := media.Save(bytes.NewReader(audio), ".WAV")
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if d, err := media.Duration(name); err != nil || d != 2*time.Second {
+		t.Errorf("expected a 2s file, got %v, %v", d, err)
+	}
+	if cost := core.MediaCost(2 * time.Second); cost != 25 {
+		t.Errorf("expected a started minute to cost 25, got %d", cost)
+	}
+
+	speech, err := core.NewWhisperTranscriber(core.SpeechToTextConfig{URL: srv.URL + "/v1/", Model: "large-v3", APIKey: "key", Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("NewWhisperTranscriber failed: %v", err)
+	}
+	transcripts := core.SourceTranscripts{Media: core.NewMediaTranscriber(media, speech)}
+	transcript, err := transcripts.FetchTranscript(context.Background(), core.MediaLink(name), "Turkish")
+	if err != nil {
+		t.Fatalf("FetchTranscript failed: %v", err)
+	}
+	if transcript.Text() != "Merhaba dünya" || transcript.Provider != core.TranscriptWhisper || transcript.Language != "tr" {
+		t.Errorf("unexpected transcript %+v", transcript)
+	}
+	if s := transcript.Segments[1]; s.StartMs != 1500 || s.DurationMs != 500 {
+		t.Errorf("unexpected segment timing %+v", s)
+	}
+}
+
+func TestMediaStoreLimits(t *testing.T) {
+	media, err := core.NewMediaStore(t.TempDir(), 10)
+	if err != nil {
+		t.Fatalf("NewMediaStore failed: %v", err)
+	}
+	if _, err := media.Save(bytes.NewReader(make([]byte, 11)), ".mp3"); !errors.Is(err, core.ErrMediaTooLarge) {
+		t.Errorf("expected ErrMediaTooLarge, got %v", err)
+	}
+	// An ID3 tag claiming to be longer than the file
+	name, err := media.Save(bytes.NewReader([]byte("ID30000000")), ".mp3")
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := media.Duration(name); !errors.Is(err, core.ErrUnknownMediaFormat) {
+		t.Errorf("expected ErrUnknownMediaFormat for a truncated mp3, got %v", err)
+	}
+	if _, err := media.Open("../secrets.env"); err == nil {
+		t.Errorf("expected names with paths to be rejected")
+	}
+	if _, err := (core.SourceTranscripts{}).FetchTranscript(context.Background(), core.MediaLink("x.mp3"), "English"); err == nil {
+		t.Errorf("expected media links to fail without a media transcriber")
+	}
+}
+
+// fakeSpeech transcribes every file to the same transcript.
+type fakeSpeech struct {
+	transcript store.Transcript
+}
+
+func (f fakeSpeech) Transcribe(ctx context.Context, media io.Reader, fileName, language string) (store.Transcript, error) {
+	if _, err := io.Copy(io.Discard, media); err != nil {
+		return store.Transcript{}, err
+	}
+	return f.transcript, nil
+}
+
+func TestPipelineRemovesUploadsOnceTranscribed(t *testing.T) {
+	t.Setenv("RETRY_COST_PERCENT", "100")
+	ctx := context.Background()
+	media, err := core.NewMediaStore(t.TempDir(), 4<<20)
+	if err != nil {
+		t.Fatalf("NewMediaStore failed: %v", err)
+	}
+	name, err := media.Save(bytes.NewReader(wavFile(90)), ".wav")
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	pod := store.Pod{ID: 7, Link: core.MediaLink(name), Title: "Talk", Language: "English", SourceType: core.SourceMedia, QuizSettings: store.QuizSettings{Difficulty: core.DifficultyMixed}}
+	pods := newFakePodStore(pod, nil)
+	usage := newFakeUsageStore(pods, 1000)
+
+	speech := fakeSpeech{transcript: store.Transcript{Provider: core.TranscriptWhisper, Segments: []store.TranscriptSegment{
+		{Text: strings.Repeat("word ", 200), StartMs: 0, DurationMs: 90000},
+	}}}
+	provider := llm.NewFake()
+	provider.Reply = func(req llm.Request) (string, error) {
+		switch {
+		case strings.Contains(req.Prompt, "true_answer_index"):
+			return "", errors.New("provider unavailable")
+		case strings.Contains(req.Prompt, `"confidence"`):
+			return llm.FakeVerdict, nil
+		}
+		return llm.FakeArticle, nil
+	}
+	transcripts := core.SourceTranscripts{Media: core.NewMediaTranscriber(media, speech)}
+	pipeline := core.NewPipeline(pods, usage, nil, nil, provider, transcripts, nil, nil, core.RetryPolicy{MaxAttempts: 1})
+
+	jobID, _ := pods.InsertPodJob(ctx, pod.ID, pod.Language, core.MediaCost(90*time.Second))
+	if err := pipeline.Run(ctx, pods.job(jobID, "user", 0)); err == nil {
+		t.Fatalf("expected the quiz to fail")
+	}
+	if _, err := media.Open(name); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the upload to be removed once its transcript was stored, got %v", err)
+	}
+
+	// The retry is priced from the stored transcript now that the file is gone
+	retried, err := core.RetryPod(pod.ID, "user", pods, usage, media)
+	if err != nil {
+		t.Fatalf("RetryPod failed: %v", err)
+	}
+	if want := core.MediaCost(90 * time.Second); retried.Cost != want {
+		t.Errorf("expected the retry to cost %d, got %d", want, retried.Cost)
+	}
+}
@@ -0,0 +1,424 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// ErrUnknownMediaFormat is returned for files ProbeMediaDuration cannot read.
+var ErrUnknownMediaFormat = errors.New("unsupported media format")
+
+// ProbeMediaDuration reads the duration of an audio or video file from its
+// headers, without decoding it. MP3, WAV, FLAC, Ogg (Vorbis and Opus),
+// MP4/M4A/MOV and WebM/Matroska files are supported.
+func ProbeMediaDuration(r io.ReaderAt, size int64) (time.Duration, error) {
+	head := make([]byte, 12)
+	n, _ := r.ReadAt(head, 0)
+	head = head[:n]
+
+	var (
+		d   time.Duration
+		err error
+	)
+	switch {
+	case len(head) == 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WAVE":
+		d, err = probeWAV(r, size)
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		d, err = probeFLAC(r)
+	case bytes.HasPrefix(head, []byte("OggS")):
+		d, err = probeOgg(r, size)
+	case bytes.HasPrefix(head, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		d, err = probeMatroska(r, size)
+	case len(head) >= 8 && isMP4Box(string(head[4:8])):
+		d, err = probeMP4(r, size)
+	case bytes.HasPrefix(head, []byte("ID3")) || (len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0):
+		d, err = probeMP3(r, size)
+	default:
+		return 0, ErrUnknownMediaFormat
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrUnknownMediaFormat, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%w: no duration found", ErrUnknownMediaFormat)
+	}
+	return d, nil
+}
+
+// readAt reads exactly n bytes at off. Offsets and sizes come from the file,
+// so negative ones are rejected rather than trusted.
+func readAt(r io.ReaderAt, off int64, n int) ([]byte, error) {
+	if off < 0 || n < 0 {
+		return nil, fmt.Errorf("invalid read of %d bytes at %d", n, off)
+	}
+	b := make([]byte, n)
+	if _, err := r.ReadAt(b, off); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// fraction converts count units of 1/rate seconds into a duration.
+func fraction(count, rate float64) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+	return time.Duration(count / rate * float64(time.Second))
+}
+
+// probeWAV divides the size of the data chunk by the byte rate of the fmt chunk.
+func probeWAV(r io.ReaderAt, size int64) (time.Duration, error) {
+	var byteRate uint32
+	for off := int64(12); off+8 <= size; {
+		header, err := readAt(r, off, 8)
+		if err != nil {
+			return 0, err
+		}
+		chunkSize := int64(binary.LittleEndian.Uint32(header[4:]))
+		switch string(header[:4]) {
+		case "fmt ":
+			fmtChunk, err := readAt(r, off+8, 16)
+			if err != nil {
+				return 0, err
+			}
+			byteRate = binary.LittleEndian.Uint32(fmtChunk[8:])
+		case "data":
+			if byteRate == 0 {
+				return 0, errors.New("wav data before fmt chunk")
+			}
+			// Streamed files leave the size unset
+			if off+8+chunkSize > size {
+				chunkSize = size - off - 8
+			}
+			return fraction(float64(chunkSize), float64(byteRate)), nil
+		}
+		off += 8 + chunkSize + chunkSize%2
+	}
+	return 0, errors.New("wav has no data chunk")
+}
+
+// probeFLAC reads the sample rate and sample count of the STREAMINFO block,
+// which always comes first.
+func probeFLAC(r io.ReaderAt) (time.Duration, error) {
+	info, err := readAt(r, 4, 4+18)
+	if err != nil {
+		return 0, err
+	}
+	if info[0]&0x7F != 0 {
+		return 0, errors.New("flac does not start with STREAMINFO")
+	}
+	packed := binary.BigEndian.Uint64(info[4+10:])
+	rate := packed >> 44
+	samples := packed & (1<<36 - 1)
+	return fraction(float64(samples), float64(rate)), nil
+}
+
+// probeOgg reads the sample rate from the identification header of the first
+// stream and the granule position of the last page, which counts samples.
+func probeOgg(r io.ReaderAt, size int64) (time.Duration, error) {
+	page, err := readAt(r, 0, 27)
+	if err != nil {
+		return 0, err
+	}
+	serial := binary.LittleEndian.Uint32(page[14:])
+	packet, err := readAt(r, 27+int64(page[26]), 19)
+	if err != nil {
+		return 0, err
+	}
+	var rate, preSkip float64
+	switch {
+	case bytes.HasPrefix(packet, []byte("\x01vorbis")):
+		rate = float64(binary.LittleEndian.Uint32(packet[12:]))
+	case bytes.HasPrefix(packet, []byte("OpusHead")):
+		// Opus granules always count 48 kHz samples
+		rate = 48000
+		preSkip = float64(binary.LittleEndian.Uint16(packet[10:]))
+	default:
+		return 0, errors.New("ogg stream is neither vorbis nor opus")
+	}
+
+	tailSize := min(size, 64<<10)
+	tail, err := readAt(r, size-tailSize, int(tailSize))
+	if err != nil {
+		return 0, err
+	}
+	for i := bytes.LastIndex(tail, []byte("OggS")); i >= 0; i = bytes.LastIndex(tail[:i], []byte("OggS")) {
+		if i+27 > len(tail) || binary.LittleEndian.Uint32(tail[i+14:]) != serial {
+			continue
+		}
+		granule := int64(binary.LittleEndian.Uint64(tail[i+6:]))
+		if granule < 0 {
+			continue
+		}
+		return fraction(float64(granule)-preSkip, rate), nil
+	}
+	return 0, errors.New("ogg has no final page")
+}
+
+func isMP4Box(typ string) bool {
+	switch typ {
+	case "ftyp", "moov", "mdat", "free", "skip", "wide":
+		return true
+	}
+	return false
+}
+
+// probeMP4 reads the timescale and duration of the movie header box.
+func probeMP4(r io.ReaderAt, size int64) (time.Duration, error) {
+	moov, moovEnd, err := findMP4Box(r, 0, size, "moov")
+	if err != nil {
+		return 0, err
+	}
+	mvhd, _, err := findMP4Box(r, moov, moovEnd, "mvhd")
+	if err != nil {
+		return 0, err
+	}
+	header, err := readAt(r, mvhd, 32)
+	if err != nil {
+		return 0, err
+	}
+	if header[0] == 1 {
+		return fraction(float64(binary.BigEndian.Uint64(header[24:])), float64(binary.BigEndian.Uint32(header[20:]))), nil
+	}
+	return fraction(float64(binary.BigEndian.Uint32(header[16:])), float64(binary.BigEndian.Uint32(header[12:]))), nil
+}
+
+// findMP4Box returns where the content of the first box of typ between start
+// and end begins and ends.
+func findMP4Box(r io.ReaderAt, start, end int64, typ string) (int64, int64, error) {
+	for off := start; off+8 <= end; {
+		header, err := readAt(r, off, 8)
+		if err != nil {
+			return 0, 0, err
+		}
+		boxSize, headerSize := int64(binary.BigEndian.Uint32(header)), int64(8)
+		switch boxSize {
+		case 0:
+			boxSize = end - off
+		case 1:
+			large, err := readAt(r, off+8, 8)
+			if err != nil {
+				return 0, 0, err
+			}
+			boxSize, headerSize = int64(binary.BigEndian.Uint64(large)), 16
+		}
+		if boxSize < headerSize {
+			return 0, 0, fmt.Errorf("invalid %q box size", header[4:8])
+		}
+		// Boxes cannot reach past their parent
+		boxSize = min(boxSize, end-off)
+		if string(header[4:8]) == typ {
+			return off + headerSize, off + boxSize, nil
+		}
+		off += boxSize
+	}
+	return 0, 0, fmt.Errorf("no %s box", typ)
+}
+
+// Matroska element IDs read by probeMatroska.
+const (
+	mkvSegment       = 0x18538067
+	mkvInfo          = 0x1549A966
+	mkvTimecodeScale = 0x2AD7B1
+	mkvDuration      = 0x4489
+	mkvCluster       = 0x1F43B675
+)
+
+// probeMatroska reads the Duration of the segment Info element, in units of
+// its TimecodeScale nanoseconds.
+func probeMatroska(r io.ReaderAt, size int64) (time.Duration, error) {
+	for off := int64(0); off < size; {
+		id, dataOff, dataSize, err := readEBMLHeader(r, off, size)
+		if err != nil {
+			return 0, err
+		}
+		switch id {
+		case mkvSegment:
+			// Descend into the segment
+			off = dataOff
+			continue
+		case mkvCluster:
+			return 0, errors.New("matroska info not found before the first cluster")
+		case mkvInfo:
+			return readMatroskaInfo(r, dataOff, dataOff+dataSize)
+		}
+		off = dataOff + dataSize
+	}
+	return 0, errors.New("matroska has no info element")
+}
+
+func readMatroskaInfo(r io.ReaderAt, start, end int64) (time.Duration, error) {
+	scale, duration := 1000000.0, 0.0
+	for off := start; off < end; {
+		id, dataOff, dataSize, err := readEBMLHeader(r, off, end)
+		if err != nil {
+			return 0, err
+		}
+		if (id == mkvTimecodeScale || id == mkvDuration) && dataSize <= 8 {
+			data, err := readAt(r, dataOff, int(dataSize))
+			if err != nil {
+				return 0, err
+			}
+			if id == mkvTimecodeScale {
+				scale = 0
+				for _, b := range data {
+					scale = scale*256 + float64(b)
+				}
+			} else {
+				switch len(data) {
+				case 4:
+					duration = float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+				case 8:
+					duration = math.Float64frombits(binary.BigEndian.Uint64(data))
+				}
+			}
+		}
+		off = dataOff + dataSize
+	}
+	return time.Duration(duration * scale), nil
+}
+
+// readEBMLHeader reads the ID and size of the element at off. An unknown
+// size runs to end.
+func readEBMLHeader(r io.ReaderAt, off, end int64) (id uint64, dataOff, dataSize int64, err error) {
+	b, err := readAt(r, off, int(min(12, end-off)))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	id, idLen, _ := readVint(b, true)
+	size, sizeLen, unknown := readVint(b[idLen:], false)
+	if idLen == 0 || sizeLen == 0 {
+		return 0, 0, 0, errors.New("invalid ebml element")
+	}
+	dataOff = off + int64(idLen+sizeLen)
+	dataSize = int64(size)
+	if unknown || dataOff+dataSize > end {
+		dataSize = end - dataOff
+	}
+	return id, dataOff, dataSize, nil
+}
+
+// readVint reads an EBML variable length integer. IDs keep their length
+// marker; sizes drop it and report whether all bits are set, meaning unknown.
+func readVint(b []byte, keepMarker bool) (uint64, int, bool) {
+	if len(b) == 0 || b[0] == 0 {
+		return 0, 0, false
+	}
+	n := 1
+	for mask := byte(0x80); b[0]&mask == 0; mask >>= 1 {
+		n++
+	}
+	if n > len(b) {
+		return 0, 0, false
+	}
+	v := uint64(b[0])
+	if !keepMarker {
+		v &= uint64(0xFF >> n)
+	}
+	for _, c := range b[1:n] {
+		v = v<<8 | uint64(c)
+	}
+	allOnes := uint64(1)<<(7*n) - 1
+	return v, n, !keepMarker && v == allOnes
+}
+
+// MPEG audio tables, indexed by version (MPEG-1 or not) and layer.
+var (
+	mp3Bitrates = [2][3][15]int{
+		{
+			{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+			{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+		},
+		{
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		},
+	}
+	mp3SampleRates = map[byte][3]int{
+		3: {44100, 48000, 32000}, // MPEG-1
+		2: {22050, 24000, 16000}, // MPEG-2
+		0: {11025, 12000, 8000},  // MPEG-2.5
+	}
+)
+
+// probeMP3 counts the frames of a VBR file from its Xing, Info or VBRI
+// header, and otherwise divides the audio size by the bitrate of the first frame.
+func probeMP3(r io.ReaderAt, size int64) (time.Duration, error) {
+	start := int64(0)
+	if id3, err := readAt(r, 0, 10); err == nil && string(id3[:3]) == "ID3" {
+		// The tag size is stored in 7-bit bytes
+		start = 10 + (int64(id3[6]&0x7F)<<21 | int64(id3[7]&0x7F)<<14 | int64(id3[8]&0x7F)<<7 | int64(id3[9]&0x7F))
+		if id3[5]&0x10 != 0 {
+			start += 10
+		}
+	}
+	end := size
+	if tag, err := readAt(r, size-128, 3); err == nil && string(tag) == "TAG" {
+		end -= 128
+	}
+	if start >= end {
+		return 0, errors.New("id3 tag runs past the end of the file")
+	}
+
+	buf, err := readAt(r, start, int(min(64<<10, end-start)))
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i+4 <= len(buf); i++ {
+		if buf[i] != 0xFF || buf[i+1]&0xE0 != 0xE0 {
+			continue
+		}
+		version, layer := (buf[i+1]>>3)&3, (buf[i+1]>>1)&3
+		bitrateIndex, rateIndex := buf[i+2]>>4, (buf[i+2]>>2)&3
+		rates, ok := mp3SampleRates[version]
+		if !ok || layer == 0 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+			continue
+		}
+		mpeg1 := version == 3
+		table := 1
+		if mpeg1 {
+			table = 0
+		}
+		bitrate := mp3Bitrates[table][3-layer][bitrateIndex] * 1000
+		sampleRate := rates[rateIndex]
+		samples := 1152
+		switch {
+		case layer == 3:
+			samples = 384
+		case layer == 1 && !mpeg1:
+			samples = 576
+		}
+
+		// The Xing header follows the side information of the first frame
+		sideInfo := 32
+		mono := buf[i+3]>>6 == 3
+		switch {
+		case mpeg1 && mono, !mpeg1 && !mono:
+			sideInfo = 17
+		case !mpeg1 && mono:
+			sideInfo = 9
+		}
+		frame := buf[i:]
+		if x := 4 + sideInfo; layer == 1 && len(frame) >= x+12 {
+			tag := string(frame[x : x+4])
+			if (tag == "Xing" || tag == "Info") && binary.BigEndian.Uint32(frame[x+4:])&1 != 0 {
+				frames := binary.BigEndian.Uint32(frame[x+8:])
+				return fraction(float64(frames)*float64(samples), float64(sampleRate)), nil
+			}
+		}
+		if len(frame) >= 36+18 && string(frame[36:40]) == "VBRI" {
+			frames := binary.BigEndian.Uint32(frame[36+14:])
+			return fraction(float64(frames)*float64(samples), float64(sampleRate)), nil
+		}
+		audio := end - start - int64(i)
+		return fraction(float64(audio*8), float64(bitrate)), nil
+	}
+	return 0, errors.New("no mpeg audio frame found")
+}
@@ -0,0 +1,125 @@
+package core_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/demirbey05/auth-demo/internal/core"
+)
+
+func le32(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+func be32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+
+func concat(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+func wavFile(seconds int) []byte {
+	const byteRate = 16000 * 2
+	fmtChunk := concat([]byte{1, 0, 1, 0}, le32(16000), le32(byteRate), []byte{2, 0, 16, 0})
+	data := make([]byte, seconds*byteRate)
+	return concat([]byte("RIFF"), le32(uint32(36+len(data))), []byte("WAVE"),
+		[]byte("fmt "), le32(16), fmtChunk,
+		[]byte("data"), le32(uint32(len(data))), data)
+}
+
+func mp4Box(typ string, content ...[]byte) []byte {
+	body := concat(content...)
+	return concat(be32(uint32(8+len(body))), []byte(typ), body)
+}
+
+func mp4File(timescale, duration uint32) []byte {
+	mvhd := concat(make([]byte, 12), be32(timescale), be32(duration), make([]byte, 80))
+	return concat(mp4Box("ftyp", []byte("isom"), be32(0)), mp4Box("mdat", make([]byte, 100)), mp4Box("moov", mp4Box("mvhd", mvhd)))
+}
+
+func flacFile(rate, samples uint64) []byte {
+	info := make([]byte, 34)
+	binary.BigEndian.PutUint64(info[10:], rate<<44|2<<41|15<<36|samples)
+	return concat([]byte("fLaC"), []byte{0x80, 0, 0, 34}, info)
+}
+
+func oggPage(granule uint64, packet []byte) []byte {
+	header := concat([]byte("OggS"), []byte{0, 0}, binary.LittleEndian.AppendUint64(nil, granule), le32(7), le32(0), le32(0), []byte{1, byte(len(packet))})
+	return concat(header, packet)
+}
+
+func opusFile(seconds int) []byte {
+	head := concat([]byte("OpusHead"), []byte{1, 1}, []byte{0x38, 0x01}, le32(48000), []byte{0, 0, 0})
+	return concat(oggPage(0, head), oggPage(0, []byte("OpusTags")), oggPage(uint64(seconds*48000+312), make([]byte, 50)))
+}
+
+func mp3Frames(header []byte, frameSize, count int) []byte {
+	frame := make([]byte, frameSize)
+	copy(frame, header)
+	return bytes.Repeat(frame, count)
+}
+
+func webmFile(durationMs float64) []byte {
+	info := concat([]byte{0x2A, 0xD7, 0xB1, 0x83, 0x0F, 0x42, 0x40}, // TimecodeScale 1000000
+		[]byte{0x44, 0x89, 0x88}, binary.BigEndian.AppendUint64(nil, math.Float64bits(durationMs)))
+	segment := concat([]byte{0x15, 0x49, 0xA9, 0x66, 0x80 | byte(len(info))}, info)
+	return concat([]byte{0x1A, 0x45, 0xDF, 0xA3, 0x84, 0x42, 0x86, 0x81, 0x01},
+		// Segments are often written with an unknown size
+		[]byte{0x18, 0x53, 0x80, 0x67, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, segment)
+}
+
+func TestProbeMediaDuration(t *testing.T) {
+	// MPEG-1 Layer III, 128 kbps, 44.1 kHz, stereo: 417 bytes a frame
+	cbrHeader := []byte{0xFF, 0xFB, 0x90, 0x00}
+	xing := concat(cbrHeader, make([]byte, 32), []byte("Xing"), be32(1), be32(2297))
+	id3 := concat([]byte("ID3"), []byte{4, 0, 0, 0, 0, 1, 0}, make([]byte, 128))
+
+	cases := []struct {
+		name string
+		file []byte
+		want time.Duration
+	}{
+		{"wav", wavFile(3), 3 * time.Second},
+		{"mp4", mp4File(600, 90000), 150 * time.Second},
+		{"flac", flacFile(44100, 44100*75), 75 * time.Second},
+		{"opus", opusFile(42), 42 * time.Second},
+		{"webm", webmFile(61500), 61500 * time.Millisecond},
+		{"mp3 cbr", concat(id3, mp3Frames(cbrHeader, 417, 960)), 25 * time.Second},
+		{"mp3 xing", concat(mp3Frames(xing, 417, 1), mp3Frames(cbrHeader, 417, 10)), 60 * time.Second},
+	}
+	for _, tc := range cases {
+		got, err := core.ProbeMediaDuration(bytes.NewReader(tc.file), int64(len(tc.file)))
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if diff := got - tc.want; diff < -50*time.Millisecond || diff > 50*time.Millisecond {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestProbeMediaDurationRejectsOtherFiles(t *testing.T) {
+	for _, file := range [][]byte{[]byte("%PDF-1.7 not media"), mp4Box("ftyp", []byte("isom")), {}, []byte("ID30000000")} {
+		if _, err := core.ProbeMediaDuration(bytes.NewReader(file), int64(len(file))); !errors.Is(err, core.ErrUnknownMediaFormat) {
+			t.Errorf("expected ErrUnknownMediaFormat for %q, got %v", file, err)
+		}
+	}
+}
+
+func FuzzProbeMediaDuration(f *testing.F) {
+	cbrHeader := []byte{0xFF, 0xFB, 0x90, 0x00}
+	for _, file := range [][]byte{
+		wavFile(1), mp4File(600, 90000), flacFile(44100, 44100), opusFile(1), webmFile(1500),
+		mp3Frames(cbrHeader, 417, 4), []byte("ID30000000"),
+	} {
+		f.Add(file)
+	}
+	f.Fuzz(func(t *testing.T, file []byte) {
+		d, err := core.ProbeMediaDuration(bytes.NewReader(file), int64(len(file)))
+		if err != nil && !errors.Is(err, core.ErrUnknownMediaFormat) {
+			t.Errorf("expected errors to wrap ErrUnknownMediaFormat, got %v", err)
+		}
+		if err == nil && d <= 0 {
+			t.Errorf("expected a positive duration, got %v", d)
+		}
+	})
+}
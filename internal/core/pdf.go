@@ -0,0 +1,661 @@
+package core
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// PDFs are laid out on A4 pages and embed the Go fonts, which cover Latin,
+// Greek and Cyrillic scripts, as Unicode CID fonts. Lengths are in points.
+const (
+	pdfPageWidth   = 595.28
+	pdfPageHeight  = 841.89
+	pdfMargin      = 56.0
+	pdfContentW    = pdfPageWidth - 2*pdfMargin
+	pdfBodySize    = 11.0
+	pdfLeading     = 1.4
+	pdfListIndent  = 18.0
+	pdfCellPadding = 4.0
+)
+
+type pdfFace int
+
+const (
+	faceRegular pdfFace = iota
+	faceBold
+	faceItalic
+	faceBoldItalic
+	faceMono
+	faceCount
+)
+
+var pdfFaceNames = [faceCount]string{"GoRegular", "GoBold", "GoItalic", "GoBoldItalic", "GoMono"}
+
+var pdfFaceData = [faceCount][]byte{goregular.TTF, gobold.TTF, goitalic.TTF, gobolditalic.TTF, gomono.TTF}
+
+var parsedPDFFaces = sync.OnceValues(func() ([faceCount]*sfnt.Font, error) {
+	var faces [faceCount]*sfnt.Font
+	for i, data := range pdfFaceData {
+		f, err := sfnt.Parse(data)
+		if err != nil {
+			return faces, fmt.Errorf("error parsing font %s: %v", pdfFaceNames[i], err)
+		}
+		faces[i] = f
+	}
+	return faces, nil
+})
+
+type pdfColor [3]float64
+
+var (
+	pdfBlack = pdfColor{0, 0, 0}
+	pdfGray  = pdfColor{0.35, 0.35, 0.35}
+	pdfBlue  = pdfColor{0.05, 0.35, 0.75}
+)
+
+// pdfFont is a face as used by one document, remembering the glyphs it needs
+// to describe when the font is written out.
+type pdfFont struct {
+	face pdfFace
+	sfnt *sfnt.Font
+	buf  sfnt.Buffer
+	upem float64
+	// glyphs caches the glyph of each rune and its advance in 1/1000 em.
+	glyphs map[rune]pdfGlyph
+	used   map[sfnt.GlyphIndex]pdfGlyph
+}
+
+type pdfGlyph struct {
+	id    sfnt.GlyphIndex
+	r     rune
+	width float64
+}
+
+func (f *pdfFont) glyph(r rune) pdfGlyph {
+	if g, ok := f.glyphs[r]; ok {
+		return g
+	}
+	g := pdfGlyph{r: r}
+	if id, err := f.sfnt.GlyphIndex(&f.buf, r); err == nil {
+		g.id = id
+	}
+	if adv, err := f.sfnt.GlyphAdvance(&f.buf, g.id, fixed.I(int(f.upem)), font.HintingNone); err == nil {
+		g.width = float64(adv) / 64 * 1000 / f.upem
+	}
+	f.glyphs[r] = g
+	return g
+}
+
+func (f *pdfFont) width(s string, size float64) float64 {
+	w := 0.0
+	for _, r := range s {
+		w += f.glyph(r).width
+	}
+	return w * size / 1000
+}
+
+// encode returns s as a hex string of glyph IDs for the Identity-H encoding.
+func (f *pdfFont) encode(s string) string {
+	var b strings.Builder
+	b.WriteByte('<')
+	for _, r := range s {
+		g := f.glyph(r)
+		if _, ok := f.used[g.id]; !ok {
+			f.used[g.id] = g
+		}
+		fmt.Fprintf(&b, "%04X", uint16(g.id))
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+// pdfWord is an unbreakable piece of a line: a word, part of a word in one
+// style, a space or a forced line break.
+type pdfWord struct {
+	text      string
+	font      *pdfFont
+	size      float64
+	color     pdfColor
+	href      string
+	width     float64
+	space     bool
+	lineBreak bool
+}
+
+type pdfLink struct {
+	x, y, w, h float64
+	href       string
+}
+
+type pdfPage struct {
+	content bytes.Buffer
+	links   []pdfLink
+}
+
+type pdfDoc struct {
+	fonts [faceCount]*pdfFont
+	pages []*pdfPage
+	page  *pdfPage
+	// y is the distance from the top of the page to the next line.
+	y float64
+}
+
+func newPDFDoc() (*pdfDoc, error) {
+	faces, err := parsedPDFFaces()
+	if err != nil {
+		return nil, err
+	}
+	d := &pdfDoc{}
+	for i, f := range faces {
+		d.fonts[i] = &pdfFont{
+			face:   pdfFace(i),
+			sfnt:   f,
+			upem:   float64(f.UnitsPerEm()),
+			glyphs: make(map[rune]pdfGlyph),
+			used:   make(map[sfnt.GlyphIndex]pdfGlyph),
+		}
+	}
+	d.newPage()
+	return d, nil
+}
+
+func (d *pdfDoc) newPage() {
+	d.page = &pdfPage{}
+	d.pages = append(d.pages, d.page)
+	d.y = pdfMargin
+}
+
+// ensure starts a new page unless h more points fit on the current one.
+func (d *pdfDoc) ensure(h float64) {
+	if d.y+h > pdfPageHeight-pdfMargin && d.y > pdfMargin {
+		d.newPage()
+	}
+}
+
+func (d *pdfDoc) spanFont(s mdSpan, bold bool) *pdfFont {
+	switch {
+	case s.code:
+		return d.fonts[faceMono]
+	case (s.bold || bold) && s.italic:
+		return d.fonts[faceBoldItalic]
+	case s.bold || bold:
+		return d.fonts[faceBold]
+	case s.italic:
+		return d.fonts[faceItalic]
+	}
+	return d.fonts[faceRegular]
+}
+
+// words splits spans into words. Text is set in bold when bold is true.
+func (d *pdfDoc) words(spans []mdSpan, size float64, color pdfColor, bold bool) []pdfWord {
+	var words []pdfWord
+	for _, s := range spans {
+		if s.lineBreak {
+			words = append(words, pdfWord{lineBreak: true})
+			continue
+		}
+		f := d.spanFont(s, bold)
+		c := color
+		if s.href != "" {
+			c = pdfBlue
+		}
+		for i, part := range strings.Split(s.text, " ") {
+			if i > 0 {
+				words = append(words, pdfWord{text: " ", font: f, size: size, space: true, width: f.width(" ", size)})
+			}
+			if part != "" {
+				words = append(words, pdfWord{text: part, font: f, size: size, color: c, href: s.href, width: f.width(part, size)})
+			}
+		}
+	}
+	return words
+}
+
+// wrap breaks words into lines no wider than width. Lines only break at
+// spaces, except inside words too long to fit on a line of their own.
+func wrap(words []pdfWord, width float64) [][]pdfWord {
+	var lines [][]pdfWord
+	var line []pdfWord
+	lineW := 0.0
+	flush := func() {
+		for len(line) > 0 && line[len(line)-1].space {
+			line = line[:len(line)-1]
+		}
+		lines = append(lines, line)
+		line, lineW = nil, 0
+	}
+
+	for i := 0; i < len(words); {
+		w := words[i]
+		if w.lineBreak {
+			flush()
+			i++
+			continue
+		}
+		if w.space {
+			if len(line) > 0 {
+				line = append(line, w)
+				lineW += w.width
+			}
+			i++
+			continue
+		}
+
+		j, groupW := i, 0.0
+		for j < len(words) && !words[j].space && !words[j].lineBreak {
+			groupW += words[j].width
+			j++
+		}
+		if lineW+groupW > width && len(line) > 0 {
+			flush()
+		}
+		if groupW <= width {
+			line = append(line, words[i:j]...)
+			lineW += groupW
+			i = j
+			continue
+		}
+		for _, part := range words[i:j] {
+			for rest := part.text; rest != ""; {
+				// Take the longest prefix that fits, at least one character
+				n, w := 0, 0.0
+				for k, r := range rest {
+					rw := part.font.width(string(r), part.size)
+					if lineW+w+rw > width && (n > 0 || len(line) > 0) {
+						break
+					}
+					n, w = k+utf8.RuneLen(r), w+rw
+				}
+				if n == 0 {
+					flush()
+					continue
+				}
+				piece := part
+				piece.text, piece.width = rest[:n], w
+				line = append(line, piece)
+				lineW += w
+				if rest = rest[n:]; rest != "" {
+					flush()
+				}
+			}
+		}
+		i = j
+	}
+	if len(line) > 0 {
+		flush()
+	}
+	return lines
+}
+
+func lineWidth(line []pdfWord) float64 {
+	w := 0.0
+	for _, word := range line {
+		w += word.width
+	}
+	return w
+}
+
+// drawLine draws words starting at x with their baseline at baseline points
+// from the top of the page.
+func (d *pdfDoc) drawLine(line []pdfWord, x, baseline float64) {
+	y := pdfPageHeight - baseline
+	for _, w := range line {
+		if !w.space {
+			fmt.Fprintf(&d.page.content, "BT %.3f %.3f %.3f rg /F%d %.2f Tf %.2f %.2f Td %s Tj ET\n",
+				w.color[0], w.color[1], w.color[2], w.font.face, w.size, x, y, w.font.encode(w.text))
+		}
+		if w.href != "" {
+			d.page.links = append(d.page.links, pdfLink{x: x, y: y - w.size*0.25, w: w.width, h: w.size * 1.1, href: w.href})
+		}
+		x += w.width
+	}
+}
+
+func (d *pdfDoc) fillRect(x, top, w, h float64, c pdfColor) {
+	fmt.Fprintf(&d.page.content, "%.3f %.3f %.3f rg %.2f %.2f %.2f %.2f re f\n", c[0], c[1], c[2], x, pdfPageHeight-top-h, w, h)
+}
+
+func (d *pdfDoc) strokeRect(x, top, w, h float64) {
+	fmt.Fprintf(&d.page.content, "0.6 0.6 0.6 RG 0.5 w %.2f %.2f %.2f %.2f re S\n", x, pdfPageHeight-top-h, w, h)
+}
+
+func (d *pdfDoc) hline(x, top, w float64) {
+	fmt.Fprintf(&d.page.content, "0.7 0.7 0.7 RG 0.75 w %.2f %.2f m %.2f %.2f l S\n", x, pdfPageHeight-top, x+w, pdfPageHeight-top)
+}
+
+// paragraph lays out words from x to the right margin, calling before for
+// each line once its position on the page is known.
+func (d *pdfDoc) paragraph(words []pdfWord, x, size float64, before func(top, leading float64)) {
+	leading := size * pdfLeading
+	for _, line := range wrap(words, pdfContentW-(x-pdfMargin)) {
+		d.ensure(leading)
+		if before != nil {
+			before(d.y, leading)
+		}
+		d.drawLine(line, x, d.y+leading*0.75)
+		d.y += leading
+	}
+}
+
+func (d *pdfDoc) heading(text string, level int) {
+	size := map[int]float64{1: 20, 2: 16, 3: 13.5}[level]
+	if size == 0 {
+		size = 12
+	}
+	if d.y > pdfMargin {
+		d.y += size * 0.6
+	}
+	// Keep the heading with the first lines of its section
+	d.ensure(size*pdfLeading + 3*pdfBodySize*pdfLeading)
+	d.paragraph(d.words(parseInline(text), size, pdfBlack, true), pdfMargin, size, nil)
+	d.y += 4
+}
+
+func (d *pdfDoc) codeBlock(text string) {
+	const size = 9.0
+	mono := d.fonts[faceMono]
+	for _, src := range strings.Split(text, "\n") {
+		words := []pdfWord{{text: src, font: mono, size: size, color: pdfBlack, width: mono.width(src, size)}}
+		if src == "" {
+			words = nil
+		}
+		lines := wrap(words, pdfContentW-2*pdfCellPadding)
+		if len(lines) == 0 {
+			lines = [][]pdfWord{nil}
+		}
+		for _, line := range lines {
+			leading := size * pdfLeading
+			d.ensure(leading)
+			d.fillRect(pdfMargin, d.y, pdfContentW, leading, pdfColor{0.94, 0.94, 0.94})
+			d.drawLine(line, pdfMargin+pdfCellPadding, d.y+leading*0.75)
+			d.y += leading
+		}
+	}
+}
+
+func (d *pdfDoc) table(rows [][]string) {
+	const size = 10.0
+	cols := 0
+	for _, row := range rows {
+		cols = max(cols, len(row))
+	}
+	colW := pdfContentW / float64(cols)
+	leading := size * pdfLeading
+	for i, row := range rows {
+		cells := make([][][]pdfWord, cols)
+		lines := 1
+		for j, text := range row {
+			cells[j] = wrap(d.words(parseInline(text), size, pdfBlack, i == 0), colW-2*pdfCellPadding)
+			lines = max(lines, len(cells[j]))
+		}
+		h := float64(lines)*leading + 2*pdfCellPadding
+		d.ensure(h)
+		for j := range cells {
+			x := pdfMargin + float64(j)*colW
+			if i == 0 {
+				d.fillRect(x, d.y, colW, h, pdfColor{0.92, 0.92, 0.92})
+			}
+			d.strokeRect(x, d.y, colW, h)
+			for k, line := range cells[j] {
+				d.drawLine(line, x+pdfCellPadding, d.y+pdfCellPadding+float64(k)*leading+leading*0.75)
+			}
+		}
+		d.y += h
+	}
+}
+
+// renderPDF lays out the article and writes it as a PDF with the title and
+// source link in its document information.
+func renderPDF(doc ArticleDocument) ([]byte, error) {
+	d, err := newPDFDoc()
+	if err != nil {
+		return nil, err
+	}
+
+	d.paragraph(d.words([]mdSpan{{text: doc.Title}}, 22, pdfBlack, true), pdfMargin, 22, nil)
+	if doc.Source != "" {
+		d.y += 2
+		source := []mdSpan{{text: "Source: "}, {text: doc.Source, href: safeLinkURL(doc.Source)}}
+		d.paragraph(d.words(source, 10, pdfGray, false), pdfMargin, 10, nil)
+	}
+	d.y += 8
+	d.hline(pdfMargin, d.y, pdfContentW)
+	d.y += 12
+
+	// counters numbers the items of the open ordered lists, by depth.
+	var counters []int
+	for _, block := range parseMarkdown(doc.Markdown) {
+		if block.kind != mdListItem {
+			counters = nil
+		}
+		switch block.kind {
+		case mdHeading:
+			d.heading(block.text, block.level)
+		case mdParagraph:
+			d.paragraph(d.words(parseInline(block.text), pdfBodySize, pdfBlack, false), pdfMargin, pdfBodySize, nil)
+			d.y += pdfBodySize * 0.7
+		case mdQuote:
+			x := pdfMargin + pdfListIndent
+			d.paragraph(d.words(parseInline(block.text), pdfBodySize, pdfGray, false), x, pdfBodySize, func(top, leading float64) {
+				d.fillRect(pdfMargin+4, top, 2, leading, pdfColor{0.75, 0.75, 0.75})
+			})
+			d.y += pdfBodySize * 0.7
+		case mdListItem:
+			counters = counters[:min(len(counters), block.level+1)]
+			for len(counters) <= block.level {
+				counters = append(counters, block.number-1)
+			}
+			counters[block.level]++
+			marker := "•"
+			if block.ordered {
+				marker = fmt.Sprintf("%d.", counters[block.level])
+			}
+			x := pdfMargin + pdfListIndent*float64(block.level+1)
+			first := true
+			d.paragraph(d.words(parseInline(block.text), pdfBodySize, pdfBlack, false), x, pdfBodySize, func(top, leading float64) {
+				if first {
+					m := d.words([]mdSpan{{text: marker}}, pdfBodySize, pdfBlack, false)
+					d.drawLine(m, x-lineWidth(m)-5, top+leading*0.75)
+					first = false
+				}
+			})
+			d.y += 2
+		case mdCode:
+			d.codeBlock(block.text)
+			d.y += pdfBodySize * 0.7
+		case mdRule:
+			d.ensure(16)
+			d.hline(pdfMargin, d.y+8, pdfContentW)
+			d.y += 16
+		case mdTable:
+			d.table(block.rows)
+			d.y += pdfBodySize * 0.7
+		}
+	}
+
+	for i, page := range d.pages {
+		d.page = page
+		footer := d.words([]mdSpan{{text: fmt.Sprintf("%d / %d", i+1, len(d.pages))}}, 9, pdfGray, false)
+		d.drawLine(footer, (pdfPageWidth-lineWidth(footer))/2, pdfPageHeight-pdfMargin/2)
+	}
+	return d.write(doc)
+}
+
+// pdfWriter numbers objects and records their offsets for the xref table.
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+func (w *pdfWriter) alloc() int {
+	w.offsets = append(w.offsets, 0)
+	return len(w.offsets)
+}
+
+func (w *pdfWriter) object(n int, body string) {
+	w.offsets[n-1] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n%s\nendobj\n", n, body)
+}
+
+func (w *pdfWriter) stream(n int, dict string, data []byte) error {
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	w.offsets[n-1] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n<< %s /Filter /FlateDecode /Length %d >>\nstream\n", n, dict, z.Len())
+	w.buf.Write(z.Bytes())
+	w.buf.WriteString("\nendstream\nendobj\n")
+	return nil
+}
+
+func (d *pdfDoc) write(doc ArticleDocument) ([]byte, error) {
+	w := &pdfWriter{}
+	w.buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	catalog, pages, info := w.alloc(), w.alloc(), w.alloc()
+
+	var fonts strings.Builder
+	for _, f := range d.fonts {
+		if len(f.used) == 0 {
+			continue
+		}
+		n, err := f.write(w)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&fonts, "/F%d %d 0 R ", f.face, n)
+	}
+
+	var kids strings.Builder
+	for _, page := range d.pages {
+		n, content := w.alloc(), w.alloc()
+		var annots strings.Builder
+		for _, l := range page.links {
+			a := w.alloc()
+			w.object(a, fmt.Sprintf("<< /Type /Annot /Subtype /Link /Rect [%.2f %.2f %.2f %.2f] /Border [0 0 0] /A << /S /URI /URI %s >> >>",
+				l.x, l.y, l.x+l.w, l.y+l.h, pdfLiteral(l.href)))
+			fmt.Fprintf(&annots, "%d 0 R ", a)
+		}
+		w.object(n, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << %s>> >> /Contents %d 0 R /Annots [%s] >>",
+			pages, pdfPageWidth, pdfPageHeight, fonts.String(), content, annots.String()))
+		if err := w.stream(content, "", page.content.Bytes()); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&kids, "%d 0 R ", n)
+	}
+
+	w.object(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pages))
+	w.object(pages, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids.String(), len(d.pages)))
+	created := "D:" + doc.CreatedAt.UTC().Format("20060102150405") + "Z"
+	w.object(info, fmt.Sprintf("<< /Title %s /Subject %s /Source %s /Creator (auth-demo) /Producer (auth-demo) /CreationDate (%s) /ModDate (%s) >>",
+		pdfText(doc.Title), pdfText(doc.Source), pdfText(doc.Source), created, created))
+
+	xref := w.buf.Len()
+	fmt.Fprintf(&w.buf, "xref\n0 %d\n0000000000 65535 f \n", len(w.offsets)+1)
+	for _, off := range w.offsets {
+		fmt.Fprintf(&w.buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&w.buf, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(w.offsets)+1, catalog, info, xref)
+	return w.buf.Bytes(), nil
+}
+
+// write embeds the font as a CID font with the widths and Unicode mapping
+// of the glyphs the document uses, and returns its object number.
+func (f *pdfFont) write(w *pdfWriter) (int, error) {
+	font0, cidFont, descriptor, file, toUnicode := w.alloc(), w.alloc(), w.alloc(), w.alloc(), w.alloc()
+	name := pdfFaceNames[f.face]
+
+	ids := make([]int, 0, len(f.used))
+	for id := range f.used {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	var widths, cmap strings.Builder
+	for i, id := range ids {
+		g := f.used[sfnt.GlyphIndex(id)]
+		fmt.Fprintf(&widths, "%d [%.0f] ", id, g.width)
+		if i%100 == 0 {
+			if i > 0 {
+				cmap.WriteString("endbfchar\n")
+			}
+			fmt.Fprintf(&cmap, "%d beginbfchar\n", min(100, len(ids)-i))
+		}
+		fmt.Fprintf(&cmap, "<%04X> <", id)
+		for _, u := range utf16.Encode([]rune{g.r}) {
+			fmt.Fprintf(&cmap, "%04X", u)
+		}
+		cmap.WriteString(">\n")
+	}
+	cmap.WriteString("endbfchar\n")
+
+	ppem := fixed.I(int(f.upem))
+	scale := func(v fixed.Int26_6) float64 { return float64(v) / 64 * 1000 / f.upem }
+	metrics, err := f.sfnt.Metrics(&f.buf, ppem, font.HintingNone)
+	if err != nil {
+		return 0, err
+	}
+	bounds, err := f.sfnt.Bounds(&f.buf, ppem, font.HintingNone)
+	if err != nil {
+		return 0, err
+	}
+	flags, italicAngle := 32, 0
+	switch f.face {
+	case faceItalic, faceBoldItalic:
+		flags, italicAngle = flags|64, -10
+	case faceMono:
+		flags |= 1
+	}
+
+	w.object(font0, fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>", name, cidFont, toUnicode))
+	w.object(cidFont, fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /CIDToGIDMap /Identity /W [%s] >>", name, descriptor, widths.String()))
+	w.object(descriptor, fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags %d /FontBBox [%.0f %.0f %.0f %.0f] /ItalicAngle %d /Ascent %.0f /Descent %.0f /CapHeight %.0f /StemV 80 /FontFile2 %d 0 R >>",
+		name, flags, scale(bounds.Min.X), -scale(bounds.Max.Y), scale(bounds.Max.X), -scale(bounds.Min.Y), italicAngle, scale(metrics.Ascent), -scale(metrics.Descent), scale(metrics.CapHeight), file))
+	if err := w.stream(file, fmt.Sprintf("/Length1 %d", len(pdfFaceData[f.face])), pdfFaceData[f.face]); err != nil {
+		return 0, err
+	}
+	cmapBody := "/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n" + cmap.String() +
+		"endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n"
+	if err := w.stream(toUnicode, "", []byte(cmapBody)); err != nil {
+		return 0, err
+	}
+	return font0, nil
+}
+
+// pdfLiteral quotes an ASCII string as a PDF literal string.
+func pdfLiteral(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`, "\r", `\r`, "\n", `\n`)
+	return "(" + r.Replace(s) + ")"
+}
+
+// pdfText encodes a text string as UTF-16 so any script survives.
+func pdfText(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteByte('>')
+	return b.String()
+}
@@ -0,0 +1,1204 @@
+package core
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrEncryptedPDF is returned for PDFs that need a password or were encrypted
+// to forbid copying their text.
+var ErrEncryptedPDF = errors.New("pdf is encrypted")
+
+// Limits on the work a PDF can cause, as PDFs are uploaded by users. They are
+// far above what real documents need.
+const (
+	// pdfMaxStreamBytes bounds a single decoded stream and pdfMaxDecodedBytes
+	// all of them together.
+	pdfMaxStreamBytes  = 32 << 20
+	pdfMaxDecodedBytes = 256 << 20
+	// pdfMaxNesting bounds how deep arrays and dictionaries nest.
+	pdfMaxNesting = 64
+	// pdfMaxOperators bounds the content operators run, and bytes of text
+	// shown, for the whole file and pdfMaxForms the Form XObjects drawn,
+	// which may draw each other.
+	pdfMaxOperators = 10_000_000
+	pdfMaxForms     = 2_000
+	// pdfMaxRangeCodes bounds the codes a font's CMap and widths may define.
+	pdfMaxRangeCodes = 1 << 16
+)
+
+var errPDFNesting = errors.New("pdf objects nest too deep")
+
+// ExtractPDF reads the text of a PDF. Text is ordered as it is drawn, which
+// follows the reading order for the PDFs word processors and LaTeX write;
+// spaces and paragraph breaks are recovered from where the text is placed.
+func ExtractPDF(data []byte) (Document, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\n\r "), []byte("%PDF-")) {
+		return Document{}, errors.New("not a pdf")
+	}
+	f := parsePDF(data)
+	for _, t := range f.trailers {
+		if _, ok := t["Encrypt"]; ok {
+			return Document{}, ErrEncryptedPDF
+		}
+	}
+
+	var text strings.Builder
+	for _, page := range f.pages() {
+		w := pdfTextWriter{}
+		resources, _ := f.resolve(page["Resources"]).(pdfDict)
+		f.runContent(&w, f.pageContent(page), resources, pdfIdentity, 0)
+		text.WriteString(w.b.String())
+		text.WriteString("\n\n")
+	}
+
+	title := ""
+	for _, t := range f.trailers {
+		if info, ok := f.resolve(t["Info"]).(pdfDict); ok {
+			if s, ok := f.resolve(info["Title"]).(pdfString); ok {
+				title = strings.TrimSpace(pdfTextString(s))
+			}
+		}
+	}
+	return ExtractText(title, text.String())
+}
+
+// PDF objects are read into these types. Numbers are float64, booleans bool
+// and null nil.
+type (
+	pdfName    string
+	pdfString  []byte
+	pdfKeyword string
+	pdfArray   []any
+	pdfDict    map[pdfName]any
+	pdfRef     struct{ num, gen int }
+	pdfStream  struct {
+		dict pdfDict
+		data []byte
+	}
+)
+
+// pdfLexer reads objects from a PDF file or content stream.
+type pdfLexer struct {
+	b     []byte
+	pos   int
+	depth int
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelim(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.b) {
+		switch c := l.b[l.pos]; {
+		case isPDFSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.b) && l.b[l.pos] != '\n' && l.b[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// next reads one object, reading arrays and dictionaries whole. Operators and
+// closing brackets come back as pdfKeyword.
+func (l *pdfLexer) next() (any, error) {
+	l.skipSpace()
+	if l.pos >= len(l.b) {
+		return nil, io.EOF
+	}
+	switch c := l.b[l.pos]; c {
+	case '/':
+		return l.name(), nil
+	case '(':
+		return l.literalString(), nil
+	case '<':
+		if l.pos+1 < len(l.b) && l.b[l.pos+1] == '<' {
+			l.pos += 2
+			if l.depth >= pdfMaxNesting {
+				return nil, errPDFNesting
+			}
+			l.depth++
+			defer func() { l.depth-- }()
+			return l.dict()
+		}
+		return l.hexString(), nil
+	case '[':
+		l.pos++
+		if l.depth >= pdfMaxNesting {
+			return nil, errPDFNesting
+		}
+		l.depth++
+		defer func() { l.depth-- }()
+		return l.array()
+	case '>':
+		if l.pos+1 < len(l.b) && l.b[l.pos+1] == '>' {
+			l.pos += 2
+			return pdfKeyword(">>"), nil
+		}
+		l.pos++
+		return pdfKeyword(">"), nil
+	case ']', ')', '{', '}':
+		l.pos++
+		return pdfKeyword(c), nil
+	}
+
+	token := l.regular()
+	switch token {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	n, err := strconv.ParseFloat(token, 64)
+	if err != nil {
+		return pdfKeyword(token), nil
+	}
+	// An integer may start a "num gen R" reference
+	if n == math.Trunc(n) && n >= 0 {
+		save := l.pos
+		l.skipSpace()
+		gen, err := strconv.Atoi(l.regular())
+		l.skipSpace()
+		if err == nil && l.regular() == "R" {
+			return pdfRef{int(n), gen}, nil
+		}
+		l.pos = save
+	}
+	return n, nil
+}
+
+// regular reads a run of regular characters.
+func (l *pdfLexer) regular() string {
+	start := l.pos
+	for l.pos < len(l.b) && !isPDFSpace(l.b[l.pos]) && !isPDFDelim(l.b[l.pos]) {
+		l.pos++
+	}
+	if l.pos == start && l.pos < len(l.b) {
+		// A stray delimiter
+		l.pos++
+	}
+	return string(l.b[start:l.pos])
+}
+
+func (l *pdfLexer) name() pdfName {
+	l.pos++
+	raw := l.regular()
+	if !strings.Contains(raw, "#") {
+		return pdfName(raw)
+	}
+	var b strings.Builder
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '#' && i+2 < len(raw) {
+			if v, err := strconv.ParseUint(raw[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(raw[i])
+	}
+	return pdfName(b.String())
+}
+
+func (l *pdfLexer) literalString() pdfString {
+	l.pos++
+	var s []byte
+	depth := 1
+	for l.pos < len(l.b) {
+		c := l.b[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return s
+			}
+		case '\\':
+			if l.pos >= len(l.b) {
+				return s
+			}
+			c = l.b[l.pos]
+			l.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// A line continuation
+				if l.pos < len(l.b) && l.b[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					v := int(c - '0')
+					for k := 0; k < 2 && l.pos < len(l.b) && l.b[l.pos] >= '0' && l.b[l.pos] <= '7'; k++ {
+						v = v*8 + int(l.b[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				}
+			}
+		}
+		s = append(s, c)
+	}
+	return s
+}
+
+func (l *pdfLexer) hexString() pdfString {
+	l.pos++
+	var digits []byte
+	for l.pos < len(l.b) && l.b[l.pos] != '>' {
+		if c := l.b[l.pos]; !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	if l.pos < len(l.b) {
+		l.pos++
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	s := make([]byte, len(digits)/2)
+	hex.Decode(s, digits)
+	return s
+}
+
+func (l *pdfLexer) array() (pdfArray, error) {
+	var a pdfArray
+	for {
+		v, err := l.next()
+		if err != nil {
+			return a, err
+		}
+		if v == pdfKeyword("]") {
+			return a, nil
+		}
+		a = append(a, v)
+	}
+}
+
+func (l *pdfLexer) dict() (pdfDict, error) {
+	d := make(pdfDict)
+	for {
+		k, err := l.next()
+		if err != nil {
+			return d, err
+		}
+		if k == pdfKeyword(">>") {
+			return d, nil
+		}
+		key, ok := k.(pdfName)
+		if !ok {
+			continue
+		}
+		v, err := l.next()
+		if err != nil {
+			return d, err
+		}
+		if v == pdfKeyword(">>") {
+			return d, nil
+		}
+		d[key] = v
+	}
+}
+
+// object reads an object and the stream that may follow its dictionary.
+func (l *pdfLexer) object() any {
+	v, err := l.next()
+	if err != nil {
+		return nil
+	}
+	d, ok := v.(pdfDict)
+	if !ok {
+		return v
+	}
+	l.skipSpace()
+	if !bytes.HasPrefix(l.b[l.pos:], []byte("stream")) {
+		return d
+	}
+	l.pos += len("stream")
+	if l.pos < len(l.b) && l.b[l.pos] == '\r' {
+		l.pos++
+	}
+	if l.pos < len(l.b) && l.b[l.pos] == '\n' {
+		l.pos++
+	}
+	start := l.pos
+	if n, ok := d["Length"].(float64); ok && n >= 0 && n <= float64(len(l.b)-start) {
+		end := start + int(n)
+		rest := bytes.TrimLeft(l.b[end:min(end+16, len(l.b))], "\r\n \t")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			l.pos = end
+			return pdfStream{dict: d, data: l.b[start:end]}
+		}
+	}
+	// The length is a reference or wrong; the data ends before endstream
+	end := bytes.Index(l.b[start:], []byte("endstream"))
+	if end < 0 {
+		end = len(l.b) - start
+	}
+	l.pos = start + end
+	return pdfStream{dict: d, data: bytes.TrimSuffix(bytes.TrimSuffix(l.b[start:l.pos], []byte("\n")), []byte("\r"))}
+}
+
+// pdfFile holds the objects of a PDF. Objects are found by scanning for
+// "n g obj" rather than through the cross-reference table, which is often
+// damaged; later definitions replace earlier ones, as incremental updates do.
+type pdfFile struct {
+	objects  map[int]any
+	trailers []pdfDict
+	// decoded, operators and forms count toward pdfMaxDecodedBytes,
+	// pdfMaxOperators and pdfMaxForms.
+	decoded, operators, forms int
+}
+
+var pdfObjectRe = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+func parsePDF(data []byte) *pdfFile {
+	f := &pdfFile{objects: make(map[int]any)}
+	skipUntil := 0
+	for _, m := range pdfObjectRe.FindAllSubmatchIndex(data, -1) {
+		if m[0] < skipUntil {
+			// Inside the stream of the previous object
+			continue
+		}
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		l := &pdfLexer{b: data, pos: m[1]}
+		obj := l.object()
+		f.objects[num] = obj
+		skipUntil = l.pos
+		if s, ok := obj.(pdfStream); ok && s.dict["Type"] == pdfName("XRef") {
+			f.trailers = append(f.trailers, s.dict)
+		}
+	}
+	for i := 0; ; {
+		j := bytes.Index(data[i:], []byte("trailer"))
+		if j < 0 {
+			break
+		}
+		l := &pdfLexer{b: data, pos: i + j + len("trailer")}
+		if d, ok := l.object().(pdfDict); ok {
+			f.trailers = append(f.trailers, d)
+		}
+		i += j + len("trailer")
+	}
+
+	// Objects compressed into object streams
+	for _, obj := range f.objects {
+		s, ok := obj.(pdfStream)
+		if !ok || s.dict["Type"] != pdfName("ObjStm") {
+			continue
+		}
+		data, err := f.decode(s)
+		if err != nil {
+			continue
+		}
+		n, _ := s.dict["N"].(float64)
+		first, _ := s.dict["First"].(float64)
+		l := &pdfLexer{b: data}
+		for k := 0; k < int(n); k++ {
+			num, err1 := l.next()
+			off, err2 := l.next()
+			nf, ok1 := num.(float64)
+			of, ok2 := off.(float64)
+			if err1 != nil || err2 != nil || !ok1 || !ok2 {
+				break
+			}
+			offset := first + of
+			if _, exists := f.objects[int(nf)]; exists || offset < 0 || offset >= float64(len(data)) {
+				continue
+			}
+			o := &pdfLexer{b: data, pos: int(offset)}
+			f.objects[int(nf)] = o.object()
+		}
+	}
+	return f
+}
+
+// resolve follows references.
+func (f *pdfFile) resolve(v any) any {
+	for i := 0; i < 16; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = f.objects[ref.num]
+	}
+	return nil
+}
+
+// dictOf returns the dictionary of a dictionary or stream.
+func (f *pdfFile) dictOf(v any) pdfDict {
+	switch v := f.resolve(v).(type) {
+	case pdfDict:
+		return v
+	case pdfStream:
+		return v.dict
+	}
+	return nil
+}
+
+// pages lists the page dictionaries in order, with inherited resources filled in.
+func (f *pdfFile) pages() []pdfDict {
+	var root pdfDict
+	for _, t := range f.trailers {
+		if d := f.dictOf(t["Root"]); d != nil {
+			root = d
+		}
+	}
+	if root == nil {
+		for _, obj := range f.objects {
+			if d, ok := obj.(pdfDict); ok && d["Type"] == pdfName("Catalog") {
+				root = d
+			}
+		}
+	}
+
+	var pages []pdfDict
+	visited := make(map[any]bool)
+	var walk func(node any, resources any)
+	walk = func(node any, resources any) {
+		if ref, ok := node.(pdfRef); ok {
+			if visited[ref] {
+				return
+			}
+			visited[ref] = true
+		}
+		d := f.dictOf(node)
+		if d == nil {
+			return
+		}
+		if r, ok := d["Resources"]; ok {
+			resources = r
+		}
+		if kids, ok := f.resolve(d["Kids"]).(pdfArray); ok {
+			for _, kid := range kids {
+				walk(kid, resources)
+			}
+			return
+		}
+		page := make(pdfDict, len(d)+1)
+		for k, v := range d {
+			page[k] = v
+		}
+		page["Resources"] = resources
+		pages = append(pages, page)
+	}
+	if root != nil {
+		walk(root["Pages"], nil)
+	}
+	if len(pages) > 0 {
+		return pages
+	}
+
+	// Without a page tree, take the pages in object order
+	var nums []int
+	for num, obj := range f.objects {
+		if d, ok := obj.(pdfDict); ok && d["Type"] == pdfName("Page") {
+			nums = append(nums, num)
+		}
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		pages = append(pages, f.objects[num].(pdfDict))
+	}
+	return pages
+}
+
+// pageContent decodes and joins the content streams of a page.
+func (f *pdfFile) pageContent(page pdfDict) []byte {
+	var parts []any
+	switch c := f.resolve(page["Contents"]).(type) {
+	case pdfStream:
+		parts = append(parts, c)
+	case pdfArray:
+		parts = c
+	}
+	var content []byte
+	for _, part := range parts {
+		if s, ok := f.resolve(part).(pdfStream); ok {
+			if data, err := f.decode(s); err == nil {
+				content = append(content, data...)
+				content = append(content, '\n')
+			}
+		}
+	}
+	return content
+}
+
+// decode applies the filters of a stream. Image filters are not supported.
+func (f *pdfFile) decode(s pdfStream) ([]byte, error) {
+	var filters []any
+	switch v := f.resolve(s.dict["Filter"]).(type) {
+	case pdfName:
+		filters = []any{v}
+	case pdfArray:
+		filters = v
+	}
+	data := s.data
+	for _, filter := range filters {
+		var err error
+		switch f.resolve(filter) {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			data, err = inflate(data)
+		case pdfName("ASCIIHexDecode"), pdfName("AHx"):
+			l := &pdfLexer{b: append(append([]byte{'<'}, data...), '>')}
+			data = l.hexString()
+		case pdfName("ASCII85Decode"), pdfName("A85"):
+			data = bytes.TrimPrefix(bytes.TrimSpace(data), []byte("<~"))
+			if i := bytes.Index(data, []byte("~>")); i >= 0 {
+				data = data[:i]
+			}
+			data, err = readLimited(ascii85.NewDecoder(bytes.NewReader(data)))
+		default:
+			return nil, fmt.Errorf("unsupported pdf filter %v", filter)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if f.decoded += len(data); f.decoded > pdfMaxDecodedBytes {
+		return nil, errors.New("pdf streams are too large")
+	}
+	return data, nil
+}
+
+// inflate decompresses zlib data, keeping what was read from truncated or
+// damaged streams.
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	out, err := readLimited(r)
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	return out, nil
+}
+
+// readLimited reads a decoded stream, failing for streams that decode to more
+// than pdfMaxStreamBytes, such as zip bombs.
+func readLimited(r io.Reader) ([]byte, error) {
+	out, err := io.ReadAll(io.LimitReader(r, pdfMaxStreamBytes+1))
+	if len(out) > pdfMaxStreamBytes {
+		return nil, errors.New("pdf stream is too large")
+	}
+	return out, err
+}
+
+// pdfTextString decodes text strings outside content streams, such as the
+// title, which are UTF-16 with a byte order mark or PDFDocEncoding.
+func pdfTextString(s pdfString) string {
+	if len(s) >= 2 && s[0] == 0xFE && s[1] == 0xFF {
+		units := make([]uint16, (len(s)-2)/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(s[2+2*i:])
+		}
+		return string(utf16.Decode(units))
+	}
+	var b strings.Builder
+	for _, c := range s {
+		b.WriteRune(charmap.Windows1252.DecodeByte(c))
+	}
+	return b.String()
+}
+
+// pdfMatrix is a PDF transformation matrix [a b c d e f].
+type pdfMatrix [6]float64
+
+var pdfIdentity = pdfMatrix{1, 0, 0, 1, 0, 0}
+
+// mul returns m × n, applying m first.
+func (m pdfMatrix) mul(n pdfMatrix) pdfMatrix {
+	return pdfMatrix{
+		m[0]*n[0] + m[1]*n[2], m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2], m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4], m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func translate(x, y float64) pdfMatrix {
+	return pdfMatrix{1, 0, 0, 1, x, y}
+}
+
+// pdfTextWriter collects the text of a page, adding spaces and line breaks
+// where the position of the next piece of text jumps.
+type pdfTextWriter struct {
+	b strings.Builder
+	// endX and endY are where the last piece of text ended, in page space.
+	endX, endY float64
+	started    bool
+	// lineGap is the usual distance between lines and size the last font
+	// size, used to tell paragraphs and headings apart.
+	lineGap, size float64
+}
+
+func (w *pdfTextWriter) last() byte {
+	s := w.b.String()
+	if s == "" {
+		return '\n'
+	}
+	return s[len(s)-1]
+}
+
+// place starts a piece of text at x, y in a font of size points.
+func (w *pdfTextWriter) place(x, y, size float64) {
+	lastSize := w.size
+	w.size = size
+	if !w.started {
+		w.started = true
+		return
+	}
+	gap := w.endY - y
+	switch {
+	case math.Abs(gap) > size*0.5:
+		paragraph := gap < 0 || math.Abs(size-lastSize) > size*0.1
+		if w.lineGap > 0 {
+			paragraph = paragraph || gap > w.lineGap*1.3
+		} else {
+			paragraph = paragraph || gap > size*1.8
+		}
+		if !paragraph {
+			w.lineGap = gap
+		}
+		if w.last() != '\n' {
+			w.b.WriteByte('\n')
+		}
+		if paragraph {
+			w.b.WriteByte('\n')
+		}
+	case x-w.endX > size*0.2 || w.endX-x > size:
+		if c := w.last(); c != ' ' && c != '\n' {
+			w.b.WriteByte(' ')
+		}
+	}
+}
+
+func (w *pdfTextWriter) text(s string, endX, endY float64) {
+	w.b.WriteString(s)
+	w.endX, w.endY = endX, endY
+}
+
+// pdfTextFont maps the codes of a font to text and glyph widths.
+type pdfTextFont struct {
+	// toUnicode maps codes to text when the font has a ToUnicode CMap.
+	toUnicode map[string]string
+	// codespace holds the [low, high] byte ranges of multi-byte codes.
+	codespace [][2][]byte
+	composite bool
+	// simple maps the codes of simple fonts through their encoding.
+	simple [256]string
+	widths map[int]float64
+	// defaultWidth is used for codes without a width, in 1/1000 of the font size.
+	defaultWidth float64
+}
+
+func (f *pdfFile) font(v any) *pdfTextFont {
+	d := f.dictOf(v)
+	font := &pdfTextFont{widths: make(map[int]float64), defaultWidth: 500}
+	if d == nil {
+		return font
+	}
+	font.composite = d["Subtype"] == pdfName("Type0")
+
+	if font.composite {
+		font.defaultWidth = 1000
+		if descendants, ok := f.resolve(d["DescendantFonts"]).(pdfArray); ok && len(descendants) > 0 {
+			cid := f.dictOf(descendants[0])
+			if dw, ok := f.resolve(cid["DW"]).(float64); ok {
+				font.defaultWidth = dw
+			}
+			if w, ok := f.resolve(cid["W"]).(pdfArray); ok {
+				// defined counts the codes given a width against pdfMaxRangeCodes
+				defined := 0
+				for i := 0; i+1 < len(w) && defined < pdfMaxRangeCodes; {
+					first, _ := f.resolve(w[i]).(float64)
+					if list, ok := f.resolve(w[i+1]).(pdfArray); ok {
+						for k, v := range list {
+							defined++
+							width, _ := f.resolve(v).(float64)
+							font.widths[int(first)+k] = width
+						}
+						i += 2
+						continue
+					}
+					if i+2 >= len(w) {
+						break
+					}
+					last, _ := f.resolve(w[i+1]).(float64)
+					width, _ := f.resolve(w[i+2]).(float64)
+					for c := int(first); c <= int(last) && defined < pdfMaxRangeCodes; c++ {
+						defined++
+						font.widths[c] = width
+					}
+					i += 3
+				}
+			}
+		}
+	} else {
+		first, _ := f.resolve(d["FirstChar"]).(float64)
+		if widths, ok := f.resolve(d["Widths"]).(pdfArray); ok {
+			for k, v := range widths {
+				width, _ := f.resolve(v).(float64)
+				font.widths[int(first)+k] = width
+			}
+		}
+		if desc := f.dictOf(d["FontDescriptor"]); desc != nil {
+			if mw, ok := f.resolve(desc["MissingWidth"]).(float64); ok && mw > 0 {
+				font.defaultWidth = mw
+			}
+		}
+		font.simple = simpleEncoding(f, d["Encoding"])
+	}
+
+	if s, ok := f.resolve(d["ToUnicode"]).(pdfStream); ok {
+		if data, err := f.decode(s); err == nil {
+			font.parseCMap(data)
+		}
+	}
+	return font
+}
+
+// simpleEncoding reads the encoding of a simple font: a base encoding, by
+// default WinAnsi, with the glyph names of a Differences array applied.
+func simpleEncoding(f *pdfFile, v any) [256]string {
+	cm := charmap.Windows1252
+	var differences pdfArray
+	switch e := f.resolve(v).(type) {
+	case pdfName:
+		if e == "MacRomanEncoding" {
+			cm = charmap.Macintosh
+		}
+	case pdfDict:
+		if e["BaseEncoding"] == pdfName("MacRomanEncoding") {
+			cm = charmap.Macintosh
+		}
+		differences, _ = f.resolve(e["Differences"]).(pdfArray)
+	}
+	var enc [256]string
+	for c := 32; c < 256; c++ {
+		if r := cm.DecodeByte(byte(c)); r != '�' {
+			enc[c] = string(r)
+		}
+	}
+	code := 0
+	for _, d := range differences {
+		switch d := f.resolve(d).(type) {
+		case float64:
+			code = int(d)
+		case pdfName:
+			if code >= 0 && code < 256 {
+				enc[code] = glyphText(string(d))
+			}
+			code++
+		}
+	}
+	return enc
+}
+
+// parseCMap reads the codespace ranges and bfchar and bfrange mappings of a
+// ToUnicode CMap.
+func (font *pdfTextFont) parseCMap(data []byte) {
+	font.toUnicode = make(map[string]string)
+	l := &pdfLexer{b: data}
+	var operands []any
+	// mapped counts the codes of bfrange mappings against pdfMaxRangeCodes
+	mapped := 0
+	for {
+		v, err := l.next()
+		if err != nil {
+			return
+		}
+		op, ok := v.(pdfKeyword)
+		if !ok {
+			operands = append(operands, v)
+			continue
+		}
+		switch op {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 && len(lo) == len(hi) && len(lo) > 0 {
+					font.codespace = append(font.codespace, [2][]byte{lo, hi})
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					font.toUnicode[string(src)] = utf16Text(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 || len(lo) != len(hi) || len(lo) == 0 {
+					continue
+				}
+				start, end := codeValue(lo), codeValue(hi)
+				for c := start; c <= end && mapped < pdfMaxRangeCodes; c++ {
+					mapped++
+					code := make([]byte, len(lo))
+					for k, v := len(code)-1, c; k >= 0; k, v = k-1, v>>8 {
+						code[k] = byte(v)
+					}
+					switch dst := operands[i+2].(type) {
+					case pdfString:
+						// The last byte counts up through the range
+						text := append([]byte(nil), dst...)
+						if len(text) > 0 {
+							text[len(text)-1] += byte(c - start)
+						}
+						font.toUnicode[string(code)] = utf16Text(text)
+					case pdfArray:
+						if k := c - start; k < len(dst) {
+							if s, ok := dst[k].(pdfString); ok {
+								font.toUnicode[string(code)] = utf16Text(s)
+							}
+						}
+					}
+				}
+			}
+		}
+		if strings.HasPrefix(string(op), "end") || strings.HasPrefix(string(op), "begin") {
+			operands = operands[:0]
+		}
+	}
+}
+
+func codeValue(b []byte) int {
+	v := 0
+	for _, c := range b {
+		v = v<<8 | int(c)
+	}
+	return v
+}
+
+func utf16Text(b []byte) string {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = binary.BigEndian.Uint16(b[2*i:])
+	}
+	if len(b) == 1 {
+		return string(rune(b[0]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// codes splits a string shown in the font into its character codes.
+func (font *pdfTextFont) codes(s []byte) [][]byte {
+	var codes [][]byte
+	for i := 0; i < len(s); {
+		n := 1
+		if font.composite {
+			n = 2
+		}
+		for _, r := range font.codespace {
+			lo, hi := r[0], r[1]
+			if i+len(lo) > len(s) {
+				continue
+			}
+			in := true
+			for k := range lo {
+				if s[i+k] < lo[k] || s[i+k] > hi[k] {
+					in = false
+					break
+				}
+			}
+			if in {
+				n = len(lo)
+				break
+			}
+		}
+		n = min(n, len(s)-i)
+		codes = append(codes, s[i:i+n])
+		i += n
+	}
+	return codes
+}
+
+// decodeCode returns the text and width, in 1/1000 of the font size, of a code.
+func (font *pdfTextFont) decodeCode(code []byte) (string, float64) {
+	width, ok := font.widths[codeValue(code)]
+	if !ok {
+		width = font.defaultWidth
+	}
+	if text, ok := font.toUnicode[string(code)]; ok {
+		return text, width
+	}
+	// Composite fonts without a ToUnicode CMap use glyph ids that cannot be read
+	if font.composite || len(code) != 1 {
+		return "", width
+	}
+	return font.simple[code[0]], width
+}
+
+// pdfTextState is the part of the graphics state that places text.
+type pdfTextState struct {
+	font                       *pdfTextFont
+	size, charSpace, wordSpace float64
+	scale, leading             float64
+	tm, tlm                    pdfMatrix
+}
+
+// runContent interprets the text operators of a content stream. Form
+// XObjects are run with their own resources, a few levels deep.
+func (f *pdfFile) runContent(w *pdfTextWriter, content []byte, resources pdfDict, ctm pdfMatrix, depth int) {
+	fonts := make(map[pdfName]*pdfTextFont)
+	fontDicts := f.dictOf(resources["Font"])
+	ts := pdfTextState{font: &pdfTextFont{defaultWidth: 500}, scale: 1, tm: pdfIdentity, tlm: pdfIdentity}
+	var stack []pdfMatrix
+
+	num := func(operands []any, i int) float64 {
+		if i < len(operands) {
+			if n, ok := operands[i].(float64); ok {
+				return n
+			}
+		}
+		return 0
+	}
+	moveLine := func(tx, ty float64) {
+		ts.tlm = translate(tx, ty).mul(ts.tlm)
+		ts.tm = ts.tlm
+	}
+	show := func(s pdfString) {
+		if f.operators += len(s); f.operators > pdfMaxOperators {
+			return
+		}
+		trm := ts.tm.mul(ctm)
+		size := ts.size * math.Hypot(trm[2], trm[3])
+		w.place(trm[4], trm[5], size)
+		var text strings.Builder
+		for _, code := range ts.font.codes(s) {
+			t, width := ts.font.decodeCode(code)
+			text.WriteString(t)
+			advance := width/1000*ts.size + ts.charSpace
+			if len(code) == 1 && code[0] == ' ' {
+				advance += ts.wordSpace
+			}
+			ts.tm = translate(advance*ts.scale, 0).mul(ts.tm)
+		}
+		end := ts.tm.mul(ctm)
+		w.text(text.String(), end[4], end[5])
+	}
+
+	l := &pdfLexer{b: content}
+	var operands []any
+	for {
+		v, err := l.next()
+		if err != nil {
+			return
+		}
+		op, ok := v.(pdfKeyword)
+		if !ok {
+			operands = append(operands, v)
+			continue
+		}
+		if f.operators++; f.operators > pdfMaxOperators {
+			return
+		}
+		switch op {
+		case "q":
+			stack = append(stack, ctm)
+		case "Q":
+			if len(stack) > 0 {
+				ctm, stack = stack[len(stack)-1], stack[:len(stack)-1]
+			}
+		case "cm":
+			var m pdfMatrix
+			for i := range m {
+				m[i] = num(operands, i)
+			}
+			ctm = m.mul(ctm)
+		case "BT":
+			ts.tm, ts.tlm = pdfIdentity, pdfIdentity
+		case "Tf":
+			if len(operands) != 2 {
+				break
+			}
+			if name, ok := operands[0].(pdfName); ok {
+				if fonts[name] == nil {
+					fonts[name] = f.font(fontDicts[name])
+				}
+				ts.font, ts.size = fonts[name], num(operands, 1)
+			}
+		case "Tc":
+			ts.charSpace = num(operands, 0)
+		case "Tw":
+			ts.wordSpace = num(operands, 0)
+		case "Tz":
+			ts.scale = num(operands, 0) / 100
+		case "TL":
+			ts.leading = num(operands, 0)
+		case "Td":
+			moveLine(num(operands, 0), num(operands, 1))
+		case "TD":
+			ts.leading = -num(operands, 1)
+			moveLine(num(operands, 0), num(operands, 1))
+		case "Tm":
+			for i := range ts.tm {
+				ts.tm[i] = num(operands, i)
+			}
+			ts.tlm = ts.tm
+		case "T*":
+			moveLine(0, -ts.leading)
+		case "Tj":
+			if s, ok := lastOperand(operands).(pdfString); ok {
+				show(s)
+			}
+		case "'", "\"":
+			if op == "\"" {
+				ts.wordSpace, ts.charSpace = num(operands, 0), num(operands, 1)
+			}
+			moveLine(0, -ts.leading)
+			if s, ok := lastOperand(operands).(pdfString); ok {
+				show(s)
+			}
+		case "TJ":
+			parts, _ := lastOperand(operands).(pdfArray)
+			for _, part := range parts {
+				switch p := part.(type) {
+				case pdfString:
+					show(p)
+				case float64:
+					ts.tm = translate(-p/1000*ts.size*ts.scale, 0).mul(ts.tm)
+				}
+			}
+		case "Do":
+			name, _ := lastOperand(operands).(pdfName)
+			xobject, ok := f.resolve(f.dictOf(resources["XObject"])[name]).(pdfStream)
+			if ok && depth < 8 && f.forms < pdfMaxForms && xobject.dict["Subtype"] == pdfName("Form") {
+				f.forms++
+				data, err := f.decode(xobject)
+				formResources, _ := f.resolve(xobject.dict["Resources"]).(pdfDict)
+				if formResources == nil {
+					formResources = resources
+				}
+				m := pdfIdentity
+				if matrix, ok := f.resolve(xobject.dict["Matrix"]).(pdfArray); ok && len(matrix) == 6 {
+					for i := range m {
+						m[i] = num(matrix, i)
+					}
+				}
+				if err == nil {
+					f.runContent(w, data, formResources, m.mul(ctm), depth+1)
+				}
+			}
+		case "BI":
+			// Skip inline image data up to EI
+			if i := bytes.Index(l.b[l.pos:], []byte("EI")); i >= 0 {
+				l.pos += i + 2
+			} else {
+				l.pos = len(l.b)
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+func lastOperand(operands []any) any {
+	if len(operands) == 0 {
+		return nil
+	}
+	return operands[len(operands)-1]
+}
+
+// Glyph names of the Adobe Glyph List that are not a letter with an accent.
+var glyphNames = map[string]string{
+	"space": " ", "exclam": "!", "quotedbl": "\"", "numbersign": "#", "dollar": "$", "percent": "%",
+	"ampersand": "&", "quotesingle": "'", "quoteright": "’", "parenleft": "(", "parenright": ")",
+	"asterisk": "*", "plus": "+", "comma": ",", "hyphen": "-", "period": ".", "slash": "/",
+	"zero": "0", "one": "1", "two": "2", "three": "3", "four": "4", "five": "5", "six": "6",
+	"seven": "7", "eight": "8", "nine": "9", "colon": ":", "semicolon": ";", "less": "<",
+	"equal": "=", "greater": ">", "question": "?", "at": "@", "bracketleft": "[", "backslash": "\\",
+	"bracketright": "]", "asciicircum": "^", "underscore": "_", "grave": "`", "quoteleft": "‘",
+	"braceleft": "{", "bar": "|", "braceright": "}", "asciitilde": "~", "bullet": "•",
+	"endash": "–", "emdash": "—", "quotedblleft": "“", "quotedblright": "”", "quotesinglbase": "‚",
+	"quotedblbase": "„", "ellipsis": "…", "dagger": "†", "daggerdbl": "‡", "perthousand": "‰",
+	"guilsinglleft": "‹", "guilsinglright": "›", "guillemotleft": "«", "guillemotright": "»",
+	"trademark": "™", "copyright": "©", "registered": "®", "degree": "°", "plusminus": "±",
+	"multiply": "×", "divide": "÷", "minus": "−", "section": "§", "paragraph": "¶",
+	"periodcentered": "·", "cent": "¢", "sterling": "£", "yen": "¥", "Euro": "€", "euro": "€",
+	"dotlessi": "ı", "germandbls": "ß", "ae": "æ", "AE": "Æ", "oe": "œ", "OE": "Œ", "oslash": "ø",
+	"Oslash": "Ø", "lslash": "ł", "Lslash": "Ł", "eth": "ð", "Eth": "Ð", "thorn": "þ", "Thorn": "Þ",
+	"mu": "µ", "exclamdown": "¡", "questiondown": "¿", "nbspace": " ", "nonbreakingspace": " ",
+	"fi": "fi", "fl": "fl", "ff": "ff", "ffi": "ffi", "ffl": "ffl",
+}
+
+// Accents that follow the base letter in glyph names such as "scedilla".
+var glyphAccents = map[string]rune{
+	"acute": '́', "grave": '̀', "circumflex": '̂', "dieresis": '̈',
+	"tilde": '̃', "cedilla": '̧', "caron": '̌', "breve": '̆', "ring": '̊',
+	"macron": '̄', "ogonek": '̨', "dotaccent": '̇', "hungarumlaut": '̋',
+	"commaaccent": '̦',
+}
+
+// glyphText returns the text of a glyph name.
+func glyphText(name string) string {
+	// Variants such as "a.sc" are the letter they are based on
+	if base, _, ok := strings.Cut(name, "."); ok && base != "" {
+		name = base
+	}
+	if t, ok := glyphNames[name]; ok {
+		return t
+	}
+	if len(name) == 1 {
+		return name
+	}
+	if hexCodes, ok := strings.CutPrefix(name, "uni"); ok && len(hexCodes)%4 == 0 {
+		if b, err := hex.DecodeString(hexCodes); err == nil {
+			return utf16Text(b)
+		}
+	}
+	if hexCode, ok := strings.CutPrefix(name, "u"); ok && len(hexCode) >= 4 && len(hexCode) <= 6 {
+		if v, err := strconv.ParseUint(hexCode, 16, 32); err == nil {
+			return string(rune(v))
+		}
+	}
+	if accent, ok := glyphAccents[name[1:]]; ok {
+		return norm.NFC.String(name[:1] + string(accent))
+	}
+	return ""
+}
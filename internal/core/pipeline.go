@@ -0,0 +1,383 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/demirbey05/auth-demo/internal/llm"
+	"github.com/demirbey05/auth-demo/internal/store"
+	"github.com/jackc/pgx/v5"
+)
+
+// Stages a pod job moves through. Done, failed and dead_letter are terminal;
+// dead_letter jobs kept failing after every retry and wait for an operator.
+const (
+	StageQueued             = "queued"
+	StageFetchingTranscript = "fetching_transcript"
+	StageClassifying        = "classifying"
+	StageGeneratingArticle  = "generating_article"
+	StageGeneratingQuiz     = "generating_quiz"
+	StageDone               = "done"
+	StageFailed             = "failed"
+	StageDeadLetter         = "dead_letter"
+)
+
+// Failure reasons of jobs that are not worth retrying as they are.
+const (
+	// ReasonNotEducational is the failure reason of jobs whose content was rejected.
+	ReasonNotEducational = "content is not educational"
+	// ReasonInsufficientCredits is the failure reason of jobs the user could no longer pay for.
+	ReasonInsufficientCredits = "insufficient credits"
+)
+
+// stageError pairs a pipeline failure with the human-readable reason stored on the job.
+type stageError struct {
+	reason string
+	err    error
+}
+
+func (e *stageError) Error() string { return fmt.Sprintf("%s: %v", e.reason, e.err) }
+func (e *stageError) Unwrap() error { return e.err }
+
+// Pipeline turns a claimed job into an article and a quiz for its pod.
+type Pipeline struct {
+	podStore     store.PodStore
+	usageStore   store.UsageStore
+	webhookStore store.WebhookStore
+	callStore    store.LLMCallStore
+	llm          llm.Provider
+	transcripts  TranscriptProvider
+	prompts      *PromptRegistry
+	chunking     ChunkConfig
+	classifier   ClassifierConfig
+	events       *EventBroker
+	retryPolicy  RetryPolicy
+	timeout      time.Duration
+}
+
+func NewPipeline(podStore store.PodStore, usageStore store.UsageStore, webhookStore store.WebhookStore, callStore store.LLMCallStore, provider llm.Provider, transcripts TranscriptProvider, prompts *PromptRegistry, events *EventBroker, retryPolicy RetryPolicy) *Pipeline {
+	if retryPolicy.MaxAttempts < 1 {
+		retryPolicy.MaxAttempts = 1
+	}
+	return &Pipeline{podStore: podStore, usageStore: usageStore, webhookStore: webhookStore, callStore: callStore, llm: provider, transcripts: transcripts, prompts: prompts, chunking: articleChunking(), classifier: classifierConfig(), events: events, retryPolicy: retryPolicy, timeout: jobTimeout()}
+}
+
+// jobTimeout reads JOB_TIMEOUT, the time a job may run before it fails
+// (default 15m).
+func jobTimeout() time.Duration {
+	if v, err := time.ParseDuration(os.Getenv("JOB_TIMEOUT")); err == nil && v > 0 {
+		return v
+	}
+	return 15 * time.Minute
+}
+
+// finishTimeout bounds the writes that record how a job ended. They run after
+// the job's own deadline may have passed.
+const finishTimeout = 30 * time.Second
+
+// ErrJobInterrupted is returned by Run when the job was cut short because its
+// context was cancelled. The job is left unfinished so it can be released.
+var ErrJobInterrupted = errors.New("job interrupted")
+
+// Run drives a claimed job through the transcript, classification, article and quiz stages
+// and records the terminal stage on the job. Cancelling parent interrupts the
+// job instead of failing it.
+func (p *Pipeline) Run(parent context.Context, job store.Job) error {
+	ctx, cancel := context.WithTimeout(parent, p.timeout)
+	defer cancel()
+
+	p.events.Publish(PodEvent{Type: EventStage, PodID: job.PodID, JobID: job.ID, Stage: StageFetchingTranscript})
+
+	articleID, quizID, err := p.runStages(ctx, &job)
+	if err != nil && parent.Err() != nil {
+		return fmt.Errorf("%w: %v", ErrJobInterrupted, err)
+	}
+
+	// A job that ran out of time is still failed and refunded
+	finishCtx, cancelFinish := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+	defer cancelFinish()
+	if err != nil {
+		reason := "internal error"
+		var se *stageError
+		if errors.As(err, &se) {
+			reason = se.reason
+		}
+		stage := StageFailed
+		if errors.Is(err, errRetriesExhausted) {
+			stage = StageDeadLetter
+		}
+		if ferr := p.podStore.FinishPodJob(finishCtx, job.ID, stage, reason, err.Error()); ferr != nil {
+			fmt.Println(ferr)
+		}
+		p.fail(finishCtx, job, stage, reason, articleID)
+		return err
+	}
+
+	if err := p.podStore.FinishPodJob(finishCtx, job.ID, StageDone, "", ""); err != nil {
+		return err
+	}
+	p.events.Publish(PodEvent{Type: EventDone, PodID: job.PodID, JobID: job.ID, Stage: StageDone, ArticleID: articleID, QuizID: quizID})
+	return nil
+}
+
+// runStages generates whatever the pod is still missing. A requeued job whose
+// article was already stored goes straight to the quiz stage. The job is
+// charged once its content is classified as educational; job.ChargedCredits
+// is updated so a later failure refunds it.
+func (p *Pipeline) runStages(ctx context.Context, job *store.Job) (int, int, error) {
+	articleID, quizID, err := p.podStore.GetPodArtifactIDs(ctx, job.PodID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var transcript store.Transcript
+	if articleID == 0 {
+		transcript, err = p.transcript(ctx, *job)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if err := p.setStage(ctx, *job, StageClassifying); err != nil {
+			return 0, 0, err
+		}
+		if err := p.classify(ctx, *job, transcript.Text()); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if err := p.charge(ctx, job); err != nil {
+		return articleID, 0, err
+	}
+
+	var article string
+	if articleID == 0 {
+		if err := p.setStage(ctx, *job, StageGeneratingArticle); err != nil {
+			return 0, 0, err
+		}
+		article, articleID, err = p.generateArticle(ctx, *job, transcript)
+		if err != nil {
+			return 0, 0, err
+		}
+		p.events.Publish(PodEvent{Type: EventArticle, PodID: job.PodID, JobID: job.ID, Stage: StageGeneratingArticle, ArticleID: articleID})
+		p.emitWebhook(ctx, *job, WebhookPodArticleGenerated, WebhookPodData{ArticleID: articleID})
+	} else {
+		stored, err := p.podStore.GetArticleByPodID(ctx, job.PodID)
+		if err != nil {
+			return 0, 0, err
+		}
+		article = stored.Text
+	}
+
+	if quizID == 0 {
+		if err := p.setStage(ctx, *job, StageGeneratingQuiz); err != nil {
+			return articleID, 0, err
+		}
+		quizID, err = p.generateQuiz(ctx, *job, article)
+		if err != nil {
+			return articleID, 0, err
+		}
+		p.emitWebhook(ctx, *job, WebhookPodQuizGenerated, WebhookPodData{ArticleID: articleID, QuizID: quizID})
+	}
+	return articleID, quizID, nil
+}
+
+// transcript returns the transcript stored for the pod, fetching and storing
+// it first when there is none, so retried jobs do not pay for it again.
+func (p *Pipeline) transcript(ctx context.Context, job store.Job) (store.Transcript, error) {
+	transcript, err := p.podStore.GetTranscriptByPodID(ctx, job.PodID)
+	if err == nil {
+		return transcript, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return store.Transcript{}, err
+	}
+
+	err = p.retry(ctx, job, func() error {
+		var err error
+		transcript, err = p.transcripts.FetchTranscript(ctx, job.Link, job.Language)
+		return err
+	})
+	if err != nil {
+		return store.Transcript{}, &stageError{reason: "could not fetch the video transcript", err: err}
+	}
+	if err := p.podStore.InsertTranscript(ctx, job.PodID, transcript); err != nil {
+		return store.Transcript{}, err
+	}
+	// Retries start from the stored transcript, so the source is not needed again
+	if releaser, ok := p.transcripts.(SourceReleaser); ok {
+		releaser.ReleaseSource(job.Link)
+	}
+	return transcript, nil
+}
+
+// classify stores a verdict on the pod and rejects content that is not
+// educational. Pods already found educational, by an earlier job of a
+// retried pod, are not classified again.
+func (p *Pipeline) classify(ctx context.Context, job store.Job, transcript string) error {
+	pod, err := p.podStore.GetPod(ctx, job.PodID)
+	if err != nil {
+		return err
+	}
+	if pod.Verdict != nil && pod.Verdict.Educational {
+		return nil
+	}
+
+	prompt := p.prompts.Pick(ctx, PromptClassify)
+	var verdict store.ContentVerdict
+	err = p.retry(ctx, job, func() error {
+		var err error
+		verdict, err = ClassifyContent(ctx, p.llmFor(job), prompt, ContentInfo{
+			Title:           pod.Title,
+			YouTubeCategory: pod.YouTubeCategory,
+			Transcript:      transcript,
+		}, p.classifier)
+		return err
+	})
+	if err != nil {
+		return &stageError{reason: "content classification failed", err: err}
+	}
+	if err := p.podStore.SetPodVerdict(ctx, job.PodID, verdict); err != nil {
+		return fmt.Errorf("error storing verdict: %v", err)
+	}
+
+	if p.classifier.Rejects(verdict) {
+		return &stageError{reason: ReasonNotEducational, err: fmt.Errorf("%w: %s", ErrNotEducational, verdict.Reason)}
+	}
+	return nil
+}
+
+// charge debits the job's cost unless it was already charged by an earlier
+// run. Users whose balance dropped below the cost since queueing are failed
+// instead.
+func (p *Pipeline) charge(ctx context.Context, job *store.Job) error {
+	if job.Cost <= 0 || job.ChargedCredits > 0 {
+		return nil
+	}
+	if _, err := p.usageStore.ChargeJob(ctx, job.UserID, job.ID); err != nil {
+		if errors.Is(err, ErrInsufficientCredits) {
+			return &stageError{reason: ReasonInsufficientCredits, err: err}
+		}
+		return fmt.Errorf("error charging job: %v", err)
+	}
+	job.ChargedCredits = job.Cost
+	return nil
+}
+
+// fail refunds a job that ended in stage without a result and notifies the
+// pod's subscribers and webhooks.
+func (p *Pipeline) fail(ctx context.Context, job store.Job, stage, reason string, articleID int) {
+	p.refund(ctx, job)
+	p.events.Publish(PodEvent{Type: EventFailed, PodID: job.PodID, JobID: job.ID, Stage: stage, ArticleID: articleID, Error: reason})
+	p.emitWebhook(ctx, job, WebhookPodFailed, WebhookPodData{ArticleID: articleID, Stage: stage, Error: reason})
+}
+
+// emitWebhook fills in the job's pod details and queues event for the pod owner's webhooks.
+func (p *Pipeline) emitWebhook(ctx context.Context, job store.Job, event string, data WebhookPodData) {
+	data.PodID, data.JobID, data.Link, data.Language = job.PodID, job.ID, job.Link, job.Language
+	emitWebhook(ctx, p.webhookStore, job.UserID, event, data)
+}
+
+// llmFor returns the provider to use for job, recording its calls when a call store is set.
+func (p *Pipeline) llmFor(job store.Job) llm.Provider {
+	if p.callStore == nil {
+		return p.llm
+	}
+	return &meteredProvider{provider: p.llm, callStore: p.callStore, job: job}
+}
+
+// refund returns the credits charged for a job that ended without a result.
+func (p *Pipeline) refund(ctx context.Context, job store.Job) {
+	if job.ChargedCredits <= 0 {
+		return
+	}
+	refunded, err := p.usageStore.RefundJob(ctx, job.UserID, job.ID)
+	if err != nil {
+		fmt.Printf("job %d: error refunding %d credits: %v\n", job.ID, job.ChargedCredits, err)
+		return
+	}
+	if refunded > 0 {
+		fmt.Printf("job %d: refunded %d credits to %s\n", job.ID, refunded, job.UserID)
+	}
+}
+
+func (p *Pipeline) setStage(ctx context.Context, job store.Job, stage string) error {
+	if err := p.podStore.UpdatePodJob(ctx, job.ID, stage); err != nil {
+		return err
+	}
+	p.events.Publish(PodEvent{Type: EventStage, PodID: job.PodID, JobID: job.ID, Stage: stage})
+	return nil
+}
+
+// generateArticle writes the article from the timed transcript when the
+// provider gave segment times, and stores the sections it cites.
+func (p *Pipeline) generateArticle(ctx context.Context, job store.Job, transcript store.Transcript) (string, int, error) {
+	text, timed := TimedTranscript(transcript)
+	if !timed {
+		text = transcript.Text()
+	}
+	prompt := p.prompts.Pick(ctx, PromptArticle)
+	notesPrompt := p.prompts.Pick(ctx, PromptChunkNotes)
+	var article, notesVersion string
+	err := p.retry(ctx, job, func() error {
+		var err error
+		notesVersion = ""
+		article, err = GenerateArticleFromTranscript(ctx, p.llmFor(job), text, job.Language, ArticleOptions{
+			Chunking:    p.chunking,
+			Prompt:      prompt,
+			NotesPrompt: notesPrompt,
+			Timed:       timed,
+			OnChunk: func(done, total int) {
+				notesVersion = notesPrompt.Version
+				if err := p.podStore.UpdatePodJobProgress(ctx, job.ID, done, total); err != nil {
+					fmt.Println(err)
+				}
+				p.events.Publish(PodEvent{Type: EventProgress, PodID: job.PodID, JobID: job.ID, Stage: StageGeneratingArticle, ChunksDone: done, ChunksTotal: total})
+			},
+		})
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, ErrNotEducational) {
+			return "", 0, &stageError{reason: ReasonNotEducational, err: err}
+		}
+		return "", 0, &stageError{reason: "article generation failed", err: err}
+	}
+
+	article, citations := ExtractCitations(article, transcript)
+	articleID, err := p.podStore.InsertArticle(ctx, job.PodID, article, prompt.Version, notesVersion, citations)
+	if err != nil {
+		return "", 0, fmt.Errorf("error inserting article: %v", err)
+	}
+	return article, articleID, nil
+}
+
+func (p *Pipeline) generateQuiz(ctx context.Context, job store.Job, article string) (int, error) {
+	prompt := p.prompts.Pick(ctx, PromptQuiz)
+	var quiz *Quiz
+	err := p.retry(ctx, job, func() error {
+		var err error
+		quiz, err = GenerateQuizzesFromArticle(ctx, p.llmFor(job), article, job.Language, QuizOptions{Prompt: prompt, Settings: job.Quiz})
+		return err
+	})
+	if err != nil {
+		return 0, &stageError{reason: "quiz generation failed", err: err}
+	}
+
+	records := make([]store.Question, len(quiz.Questions))
+	for i, question := range quiz.Questions {
+		records[i], err = question.record()
+		if err != nil {
+			return 0, err
+		}
+	}
+	quizID, err := p.podStore.InsertQuiz(ctx, job.PodID, prompt.Version, job.Quiz, records)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting quiz: %v", err)
+	}
+
+	fmt.Println("Quiz submitted successfully")
+	return quizID, nil
+}
@@ -0,0 +1,75 @@
+package core_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/demirbey05/auth-demo/internal/core"
+	"github.com/demirbey05/auth-demo/internal/llm"
+	"github.com/demirbey05/auth-demo/internal/store"
+)
+
+func TestPipelineFailsAndRefundsTimedOutJobs(t *testing.T) {
+	t.Setenv("JOB_TIMEOUT", "50ms")
+	ctx := context.Background()
+	pod, transcript := textPod(200)
+	pods := newFakePodStore(pod, transcript)
+	usage := newFakeUsageStore(pods, 1000)
+
+	// The article takes longer than the job may run
+	provider := llm.NewFake()
+	provider.Reply = func(req llm.Request) (string, error) {
+		if strings.Contains(req.Prompt, `"confidence"`) {
+			return llm.FakeVerdict, nil
+		}
+		time.Sleep(100 * time.Millisecond)
+		return llm.FakeArticle, nil
+	}
+	pipeline := core.NewPipeline(pods, usage, nil, nil, provider, nil, nil, nil, core.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Second})
+
+	jobID, _ := pods.InsertPodJob(ctx, pod.ID, pod.Language, core.WordCost(200))
+	if err := pipeline.Run(ctx, pods.job(jobID, "user", 0)); err == nil {
+		t.Fatalf("expected the job to time out")
+	}
+	status, _ := pods.GetJobStatusByPodID(ctx, pod.ID)
+	if status.Stage != core.StageFailed {
+		t.Errorf("expected the job to end failed, got %q", status.Stage)
+	}
+	if len(usage.ledger) != 2 || usage.ledger[1].Reason != store.CreditRefund {
+		t.Errorf("expected the job to be charged and refunded, got %+v", usage.ledger)
+	}
+	if got := usage.balance(); got != 1000 {
+		t.Errorf("expected the balance to be restored, got %d", got)
+	}
+}
+
+func TestPipelineRecordsChunkNotesPromptVersion(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name   string
+		budget string
+		notes  string
+	}{
+		{"single prompt", "0", ""},
+		{"chunked", "50", core.DefaultPrompt(core.PromptChunkNotes).Version},
+	} {
+		t.Setenv("ARTICLE_TOKEN_BUDGET", tc.budget)
+		t.Setenv("ARTICLE_CHUNK_TOKENS", "100")
+		pod, transcript := textPod(200)
+		pods := newFakePodStore(pod, transcript)
+		usage := newFakeUsageStore(pods, 1000)
+		pipeline := core.NewPipeline(pods, usage, nil, nil, llm.NewFake(), nil, nil, nil, core.RetryPolicy{MaxAttempts: 1})
+
+		jobID, _ := pods.InsertPodJob(ctx, pod.ID, pod.Language, core.WordCost(200))
+		if err := pipeline.Run(ctx, pods.job(jobID, "user", 0)); err != nil {
+			t.Fatalf("%s: Run failed: %v", tc.name, err)
+		}
+		want := []string{core.DefaultPrompt(core.PromptArticle).Version, tc.notes}
+		if !reflect.DeepEqual(pods.promptVersions, want) {
+			t.Errorf("%s: expected the article prompt versions %q, got %q", tc.name, want, pods.promptVersions)
+		}
+	}
+}
@@ -1,275 +1,319 @@
 package core
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/demirbey05/auth-demo/internal/store"
+	"github.com/jackc/pgx/v5"
 )
 
-var languageMap = map[string]string{
-	"English":    "en",
-	"Spanish":    "es",
-	"French":     "fr",
-	"German":     "de",
-	"Italian":    "it",
-	"Portuguese": "pt",
-	"Dutch":      "nl",
-	"Polish":     "pl",
-	"Russian":    "ru",
-	"Japanese":   "ja",
-	"Korean":     "ko",
-	"Chinese":    "zh",
-	"Turkish":    "tr",
-	"Hindi":      "hi",
+// PodRequest is what a user asks for when creating a pod.
+type PodRequest struct {
+	// SourceType is one of the Source constants; empty means SourceYouTube.
+	SourceType string
+	Link       string
+	UserID     string
+	Language   string
+	// ForceRegenerate skips reusing an earlier generation of the same video and language.
+	ForceRegenerate bool
+	// Quiz is the question count and difficulty of the quiz; the zero value asks for the defaults.
+	Quiz store.QuizSettings
+	// Media is set for pods made from an uploaded file, whose Link is a MediaLink.
+	Media *MediaInfo
+	// Document is the extracted text of text, pdf and url pods. Link is the
+	// page address of url pods and is derived from the text for the others.
+	Document *Document
 }
 
-const (
-	ArticleGenerated int = iota
-	QuizGenerated
-	Error
-)
+// MediaInfo describes an uploaded file.
+type MediaInfo struct {
+	Title    string
+	Duration time.Duration
+}
+
+// CreatedPod is the result of CreateNewPod.
+type CreatedPod struct {
+	PodID int
+	JobID int
+	// RemainingCredit is the balance before Cost is charged, unless the pod
+	// was Reused. Queued pods are only charged once their content is
+	// classified as educational.
+	RemainingCredit int
+	Cost            int
+	// Reused is set when the content was copied from an earlier pod instead of being queued for generation.
+	Reused bool
+}
 
-func CreateNewPod(link, userID, language string, podStore store.PodStore, usageStore store.UsageStore) (int, int, int, error) {
+// reuseCostPercent is the share of the full price charged when a pod reuses an
+// earlier generation, read from REUSE_COST_PERCENT and defaulting to 20.
+func reuseCostPercent() int {
+	percent, err := strconv.Atoi(os.Getenv("REUSE_COST_PERCENT"))
+	if err != nil || percent < 0 {
+		return 20
+	}
+	return percent
+}
+
+func CreateNewPod(req PodRequest, podStore store.PodStore, usageStore store.UsageStore, webhookStore store.WebhookStore) (CreatedPod, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 	// Insert a pod, job and set goroutines
-	canonLink, err := CanonicalizeYouTubeURL(link)
-	if err != nil {
-		return 0, 0, 0, fmt.Errorf("error canonicalizing link: %v", err)
+	sourceType := req.SourceType
+	if sourceType == "" {
+		sourceType = SourceYouTube
+	}
+	link, cost := req.Link, 0
+	switch sourceType {
+	case SourceMedia:
+		if req.Media == nil {
+			return CreatedPod{}, errors.New("missing media file")
+		}
+		cost = MediaCost(req.Media.Duration)
+	case SourceText, SourcePDF, SourceURL:
+		if req.Document == nil {
+			return CreatedPod{}, ErrEmptyDocument
+		}
+		if sourceType != SourceURL {
+			link = DocumentLink(sourceType, *req.Document)
+		}
+		cost = WordCost(req.Document.Words())
+	case SourceYouTube:
+		var err error
+		link, err = CanonicalizeYouTubeURL(req.Link)
+		if err != nil {
+			return CreatedPod{}, fmt.Errorf("error canonicalizing link: %v", err)
+		}
+		// Get cost of the job
+		cost, err = CalculateCost(link)
+		if err != nil {
+			return CreatedPod{}, fmt.Errorf("error calculating cost: %v", err)
+		}
+	default:
+		return CreatedPod{}, fmt.Errorf("unknown source type %q", sourceType)
+	}
+	if cost == 0 {
+		return CreatedPod{}, fmt.Errorf("invalid link")
 	}
-	link = canonLink
-	// Get cost of the job
-	cost, err := CalculateCost(link)
+	quiz, err := NormalizeQuizSettings(req.Quiz)
 	if err != nil {
-		return 0, 0, 0, fmt.Errorf("error calculating cost: %v", err)
+		return CreatedPod{}, err
 	}
-	if cost == 0 {
-		return 0, 0, 0, fmt.Errorf("invalid link")
+
+	// Someone may already have generated this video or document in this
+	// language with the same kind of quiz. Uploads are never shared.
+	sourcePodID := 0
+	if !req.ForceRegenerate && sourceType != SourceMedia {
+		sourcePodID, err = podStore.FindReusablePod(ctx, link, req.Language, quiz, StageDone)
+		if err != nil {
+			return CreatedPod{}, fmt.Errorf("error finding reusable pod: %v", err)
+		}
+		if sourcePodID != 0 {
+			cost = cost * reuseCostPercent() / 100
+		}
 	}
+
 	// Check Credit
-	remaining, err := usageStore.GetRemainingCredits(ctx, userID)
+	remaining, err := usageStore.GetRemainingCredits(ctx, req.UserID)
 	if err != nil {
-		return 0, 0, 0, fmt.Errorf("error getting remaining credits: %v", err)
+		return CreatedPod{}, fmt.Errorf("error getting remaining credits: %v", err)
 	}
 	if remaining < cost {
-		return 0, 0, 0, fmt.Errorf("insufficient credits")
+		return CreatedPod{}, ErrInsufficientCredits
 	}
 
-	title, err := GetYouTubeVideoTitle(link)
-	if err != nil {
-		return 0, 0, 0, fmt.Errorf("error getting video title: %v", err)
+	var snippet YouTubeSnippet
+	switch {
+	case req.Media != nil:
+		snippet.Title = req.Media.Title
+	case req.Document != nil:
+		snippet.Title = req.Document.Title
+	default:
+		snippet, err = GetYouTubeVideoSnippet(link)
+		if err != nil {
+			return CreatedPod{}, fmt.Errorf("error getting video title: %v", err)
+		}
 	}
-	podId, err := podStore.InsertPod(ctx, link, title, userID)
+	podId, err := podStore.InsertPod(ctx, sourceType, link, snippet.Title, req.UserID, req.Language, sourcePodID, snippet.CategoryID, quiz)
 	if err != nil {
-		return 0, 0, 0, fmt.Errorf("error inserting pod: %v", err)
+		return CreatedPod{}, fmt.Errorf("error inserting pod: %v", err)
 	}
-	jobId, err := podStore.InsertPodJob(ctx, podId)
-	if err != nil {
-		return 0, 0, 0, fmt.Errorf("error inserting job: %v", err)
+	// Documents need no fetching; the workers find the text stored as the
+	// transcript, so it is stored before the job is queued
+	if req.Document != nil {
+		if err := podStore.InsertTranscript(ctx, podId, req.Document.Transcript(sourceType)); err != nil {
+			return CreatedPod{}, fmt.Errorf("error inserting transcript: %v", err)
+		}
 	}
-	remaining, err = usageStore.DecrementCredit(ctx, userID, cost)
+	jobId, err := podStore.InsertPodJob(ctx, podId, req.Language, cost)
 	if err != nil {
-		return 0, 0, 0, fmt.Errorf("error decrementing credit: %v", err)
+		return CreatedPod{}, fmt.Errorf("error inserting job: %v", err)
 	}
 
-	var trans string
-	if os.Getenv("ENV") == "dev" {
-		trans, err = getTranscript(link, language)
-	} else {
-		trans, err = getTranscriptFromAPI(link)
-	}
-	if err != nil {
-		return 0, 0, 0, fmt.Errorf("error getting transcript: %v", err)
+	created := CreatedPod{PodID: podId, JobID: jobId, RemainingCredit: remaining, Cost: cost}
+	hook := WebhookPodData{PodID: podId, JobID: jobId, Link: link, Language: req.Language}
+	emitWebhook(ctx, webhookStore, req.UserID, WebhookPodCreated, hook)
+	if sourcePodID != 0 {
+		articleID, quizID, err := podStore.ClonePodContent(ctx, sourcePodID, podId)
+		if err != nil {
+			return CreatedPod{}, fmt.Errorf("error reusing pod %d: %v", sourcePodID, err)
+		}
+		// The job never reaches the queue, so workers will not pick it up
+		// and it is charged here; the source pod was already classified. A
+		// failed charge rolls the whole pod back with the transaction
+		created.RemainingCredit, err = usageStore.ChargeJob(ctx, req.UserID, jobId)
+		if errors.Is(err, ErrInsufficientCredits) {
+			return CreatedPod{}, ErrInsufficientCredits
+		}
+		if err != nil {
+			return CreatedPod{}, fmt.Errorf("error charging job: %v", err)
+		}
+		if err := podStore.FinishPodJob(ctx, jobId, StageDone, "", ""); err != nil {
+			return CreatedPod{}, fmt.Errorf("error finishing job: %v", err)
+		}
+		hook.ArticleID = articleID
+		emitWebhook(ctx, webhookStore, req.UserID, WebhookPodArticleGenerated, hook)
+		hook.QuizID = quizID
+		emitWebhook(ctx, webhookStore, req.UserID, WebhookPodQuizGenerated, hook)
+		created.Reused = true
+		return created, nil
 	}
 
-	if err := generateArticleJob(trans, language, podStore, podId, jobId); err != nil {
-		return 0, 0, 0, err
-	}
-	return podId, jobId, remaining, nil
+	// Transcript, article and quiz generation are picked up by the worker pool
+	return created, nil
 
 }
 
-// TranscriptResponse represents the JSON structure returned by the transcriber service.
-type TranscriptResponse struct {
-	VideoID    string `json:"video_id"`
-	Transcript string `json:"transcript"`
+var (
+	ErrPodInProgress       = errors.New("pod is still being generated")
+	ErrNothingToRetry      = errors.New("pod has nothing to retry")
+	ErrNotRetriable        = errors.New("pod content is not educational")
+	ErrInsufficientCredits = store.ErrInsufficientCredits
+)
+
+// RetriedPod is the result of RetryPod.
+type RetriedPod struct {
+	JobID int
+	// RemainingCredit is the balance before the new job charges Cost.
+	RemainingCredit int
+	Cost            int
+	// Missing lists the artifacts the new job will generate.
+	Missing []string
 }
 
-func getTranscript(link, language string) (string, error) {
-	// Load TRANSCRIBER_URL from environment variables
-	transcriberURL, exists := os.LookupEnv("TRANSCRIBER_URL")
-	if !exists {
-		return "", fmt.Errorf("TRANSCRIBER_URL is not set in the environment")
+// retryCostPercent is the share of the outstanding price charged for retrying
+// a pod, read from RETRY_COST_PERCENT. Retries are free unless it is set.
+func retryCostPercent() int {
+	percent, err := strconv.Atoi(os.Getenv("RETRY_COST_PERCENT"))
+	if err != nil || percent < 0 {
+		return 0
 	}
+	return percent
+}
 
-	// Construct the full URL with query parameters
-	endpoint := fmt.Sprintf("%s/transcript", transcriberURL)
-	reqURL, err := url.Parse(endpoint)
+// RetryPod queues a new job for a pod whose last job failed. The pipeline keeps
+// what was already stored and only generates the missing artifacts. media
+// prices pods made from uploads and may be nil when uploads are disabled.
+// podStore must run in a transaction, which holds the pod lock until it ends.
+func RetryPod(podID int, userID string, podStore store.PodStore, usageStore store.UsageStore, media *MediaStore) (RetriedPod, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// Concurrent retries wait here and then find the job queued by the first
+	if err := podStore.LockPod(ctx, podID); err != nil {
+		return RetriedPod{}, err
+	}
+	status, err := podStore.GetJobStatusByPodID(ctx, podID)
 	if err != nil {
-		return "", fmt.Errorf("invalid transcriber URL: %v", err)
+		return RetriedPod{}, err
 	}
-	langCode, ok := languageMap[language]
-	if !ok {
-		fmt.Println(language)
-		return "", fmt.Errorf("invalid language")
+	switch status.Stage {
+	case StageDone, StageFailed, StageDeadLetter:
+	default:
+		return RetriedPod{}, ErrPodInProgress
 	}
-	// Add the 'url' query parameter
-	query := reqURL.Query()
-	query.Set("url", link)
-	query.Set("language", langCode)
-	reqURL.RawQuery = query.Encode()
-
-	// Create an HTTP client with a timeout
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+	if status.Error == ReasonNotEducational {
+		return RetriedPod{}, ErrNotRetriable
 	}
 
-	// Create a new HTTP GET request with context
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	fmt.Println(reqURL.String())
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL.String(), nil)
+	articleID, quizID, err := podStore.GetPodArtifactIDs(ctx, podID)
 	if err != nil {
-		return "", fmt.Errorf("failed to create HTTP request: %v", err)
+		return RetriedPod{}, err
 	}
-
-	// Send the HTTP request
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("error sending request to transcriber: %v", err)
+	var missing []string
+	if articleID == 0 {
+		missing = append(missing, "transcript", "article")
 	}
-	defer resp.Body.Close()
-
-	// Check for non-200 status codes
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("transcriber returned non-OK status: %s", resp.Status)
+	if quizID == 0 {
+		missing = append(missing, "quiz")
 	}
-
-	// Decode the JSON response
-	var transcriptResp TranscriptResponse
-	decoder := json.NewDecoder(resp.Body)
-	if err := decoder.Decode(&transcriptResp); err != nil {
-		return "", fmt.Errorf("error decoding transcriber response: %v", err)
+	if len(missing) == 0 {
+		return RetriedPod{}, ErrNothingToRetry
 	}
 
-	return transcriptResp.Transcript, nil
-}
-
-// TranscriptResponse represents the structure of the API response
-type APITranscriptResponse struct {
-	Content []struct {
-		Text string `json:"text"`
-	} `json:"content"`
-}
-
-// getTranscript makes an API call to retrieve the transcript and merges the text segments
-func getTranscriptFromAPI(videoURL string) (string, error) {
-	// Create the request URL
-	url := fmt.Sprintf("https://api.supadata.ai/v1/youtube/transcript?url=%s", videoURL)
-
-	// Create a new HTTP request
-	req, err := http.NewRequest("GET", url, nil)
+	pod, err := podStore.GetPod(ctx, podID)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
+		return RetriedPod{}, err
 	}
 
-	// Set the API key in the request header
-	req.Header.Set("x-api-key", os.Getenv("SUPADATA_API_KEY"))
-
-	// Send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to send request: %v", err)
+	// The outstanding price is that of a new pod, less what earlier jobs
+	// kept after their refunds; 100 percent makes a retry cost what
+	// generating the pod once would have
+	cost := 0
+	if percent := retryCostPercent(); percent > 0 {
+		fullCost, err := podCost(ctx, pod, podStore, media)
+		if err != nil {
+			return RetriedPod{}, fmt.Errorf("error calculating cost: %v", err)
+		}
+		kept, err := usageStore.GetPodKeptCredits(ctx, podID)
+		if err != nil {
+			return RetriedPod{}, fmt.Errorf("error getting pod charges: %v", err)
+		}
+		cost = max(fullCost-kept, 0) * percent / 100
 	}
-	defer resp.Body.Close()
-
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
+	remaining, err := usageStore.GetRemainingCredits(ctx, userID)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %v", err)
+		return RetriedPod{}, fmt.Errorf("error getting remaining credits: %v", err)
 	}
-
-	// Parse the JSON response
-	var transcript APITranscriptResponse
-	if err := json.Unmarshal(body, &transcript); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %v", err)
-	}
-
-	// Merge the text segments
-	var mergedText bytes.Buffer
-	for _, segment := range transcript.Content {
-		mergedText.WriteString(segment.Text)
-		mergedText.WriteString(" ") // Add a space between segments
+	if remaining < cost {
+		return RetriedPod{}, ErrInsufficientCredits
 	}
 
-	return mergedText.String(), nil
-}
-
-func generateArticleJob(transcript, language string, podStore store.PodStore, podId, jobId int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-	article, err := GenerateArticleFromTranscript(transcript, language)
+	jobID, err := podStore.InsertPodJob(ctx, podID, pod.Language, cost)
 	if err != nil {
-		podStore.UpdatePodJob(ctx, jobId, Error)
-		return fmt.Errorf("content is not educational")
-	}
-
-	if err := podStore.InsertArticle(ctx, podId, article); err != nil {
-		podStore.UpdatePodJob(ctx, jobId, Error)
-		return fmt.Errorf("internal error")
+		return RetriedPod{}, fmt.Errorf("error inserting job: %v", err)
 	}
 
-	if err := podStore.UpdatePodJob(ctx, jobId, ArticleGenerated); err != nil {
-		podStore.UpdatePodJob(ctx, jobId, Error)
-		return fmt.Errorf("internal error")
-	}
-
-	generateQuizJob(article, language, podStore, podId, jobId)
-	return nil
+	return RetriedPod{JobID: jobID, RemainingCredit: remaining, Cost: cost, Missing: missing}, nil
 }
-func generateQuizJob(article, language string, podStore store.PodStore, podID, jobId int) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-	quiz, err := GenerateQuizzesFromArticle(article, language)
-	if err != nil {
-		fmt.Println(err)
-		podStore.UpdatePodJob(ctx, jobId, Error)
-		return
-	}
 
-	quizID, err := podStore.InsertQuiz(ctx, podID)
-	if err != nil {
-		fmt.Println(err)
-		podStore.UpdatePodJob(ctx, jobId, Error)
-		return
-	}
-
-	for _, question := range quiz.Questions {
-		if _, err := podStore.InsertQuestion(ctx, quizID, question.Question, question.Options, question.Answer); err != nil {
-			fmt.Println(err)
-			podStore.UpdatePodJob(ctx, jobId, Error)
-			return
+// podCost is the full price of generating pod again. Documents are priced by
+// the words of their stored text, and uploads by the length of their stored
+// transcript once the file is gone.
+func podCost(ctx context.Context, pod store.Pod, podStore store.PodStore, media *MediaStore) (int, error) {
+	switch pod.SourceType {
+	case SourceText, SourcePDF, SourceURL:
+		transcript, err := podStore.GetTranscriptByPodID(ctx, pod.ID)
+		if err != nil {
+			return 0, err
+		}
+		return WordCost(len(strings.Fields(transcript.Text()))), nil
+	case SourceMedia:
+		transcript, err := podStore.GetTranscriptByPodID(ctx, pod.ID)
+		if err == nil {
+			return MediaCost(transcript.Duration()), nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return 0, err
 		}
 	}
-
-	if err := podStore.UpdatePodJob(ctx, jobId, QuizGenerated); err != nil {
-		fmt.Println(err)
-		podStore.UpdatePodJob(ctx, jobId, Error)
-		return
-	}
-
-	fmt.Println("Quiz submitted successfully")
-
+	return sourceCost(pod.Link, media)
 }
 
 // CanonicalizeYouTubeURL converts a YouTube URL (e.g. youtu.be/VIDEO_ID)
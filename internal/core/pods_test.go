@@ -0,0 +1,399 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/demirbey05/auth-demo/internal/core"
+	"github.com/demirbey05/auth-demo/internal/llm"
+	"github.com/demirbey05/auth-demo/internal/store"
+	"github.com/jackc/pgx/v5"
+)
+
+// fakePodStore keeps one pod, its artifacts and its jobs in memory.
+type fakePodStore struct {
+	store.PodStore
+
+	mu         sync.Mutex
+	pod        store.Pod
+	transcript *store.Transcript
+	article    string
+	articleID  int
+	// promptVersions are the article and chunk notes versions of the article.
+	promptVersions []string
+	quizID         int
+	questions      []store.Question
+	jobs           []store.JobStatus
+	costs          map[int]int
+	// reusable is the pod FindReusablePod finds, clonedFrom the one cloned.
+	reusable   int
+	clonedFrom int
+}
+
+func newFakePodStore(pod store.Pod, transcript *store.Transcript) *fakePodStore {
+	return &fakePodStore{pod: pod, transcript: transcript, costs: make(map[int]int)}
+}
+
+func (s *fakePodStore) GetPod(ctx context.Context, podID int) (store.Pod, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pod, nil
+}
+
+func (s *fakePodStore) SetPodVerdict(ctx context.Context, podID int, verdict store.ContentVerdict) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pod.Verdict = &verdict
+	return nil
+}
+
+func (s *fakePodStore) FindReusablePod(ctx context.Context, link, language string, quiz store.QuizSettings, doneStage string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reusable, nil
+}
+
+// InsertPod replaces the pod with the new one, keeping the artifacts so that
+// they can be cloned.
+func (s *fakePodStore) InsertPod(ctx context.Context, sourceType, link, title, userID, language string, sourcePodID int, youtubeCategory string, quiz store.QuizSettings) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pod = store.Pod{ID: s.pod.ID + 1, Link: link, Title: title, Language: language, SourceType: sourceType, QuizSettings: quiz}
+	return s.pod.ID, nil
+}
+
+func (s *fakePodStore) ClonePodContent(ctx context.Context, sourcePodID, podID int) (int, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clonedFrom = sourcePodID
+	s.articleID, s.quizID = s.articleID+1, s.quizID+1
+	return s.articleID, s.quizID, nil
+}
+
+func (s *fakePodStore) GetTranscriptByPodID(ctx context.Context, podID int) (store.Transcript, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.transcript == nil {
+		return store.Transcript{}, pgx.ErrNoRows
+	}
+	return *s.transcript, nil
+}
+
+func (s *fakePodStore) InsertTranscript(ctx context.Context, podID int, transcript store.Transcript) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transcript = &transcript
+	return nil
+}
+
+func (s *fakePodStore) LockPod(ctx context.Context, podID int) error {
+	return nil
+}
+
+func (s *fakePodStore) GetPodArtifactIDs(ctx context.Context, podID int) (int, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.articleID, s.quizID, nil
+}
+
+func (s *fakePodStore) InsertArticle(ctx context.Context, podID int, content, promptVersion, notesPromptVersion string, citations []store.Citation) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.article, s.articleID = content, 1
+	s.promptVersions = []string{promptVersion, notesPromptVersion}
+	return s.articleID, nil
+}
+
+func (s *fakePodStore) GetArticleByPodID(ctx context.Context, podID int) (store.Article, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return store.Article{Text: s.article}, nil
+}
+
+func (s *fakePodStore) InsertQuiz(ctx context.Context, podID int, promptVersion string, quiz store.QuizSettings, questions []store.Question) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quizID, s.questions = 1, questions
+	return s.quizID, nil
+}
+
+func (s *fakePodStore) InsertPodJob(ctx context.Context, podID int, language string, cost int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := len(s.jobs) + 1
+	s.jobs = append(s.jobs, store.JobStatus{ID: id, PodID: podID, Stage: core.StageQueued})
+	s.costs[id] = cost
+	return id, nil
+}
+
+func (s *fakePodStore) UpdatePodJob(ctx context.Context, jobID int, stage string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[jobID-1].Stage = stage
+	return nil
+}
+
+func (s *fakePodStore) UpdatePodJobProgress(ctx context.Context, jobID int, chunksDone, chunksTotal int) error {
+	return nil
+}
+
+func (s *fakePodStore) RecordPodJobRetry(ctx context.Context, jobID int, lastError string) error {
+	return nil
+}
+
+func (s *fakePodStore) FinishPodJob(ctx context.Context, jobID int, stage, reason, lastError string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[jobID-1].Stage, s.jobs[jobID-1].Error = stage, reason
+	return nil
+}
+
+func (s *fakePodStore) GetJobStatusByPodID(ctx context.Context, podID int) (store.JobStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobs[len(s.jobs)-1], nil
+}
+
+// job returns the job as a worker would claim it.
+func (s *fakePodStore) job(jobID int, userID string, charged int) store.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return store.Job{ID: jobID, PodID: s.pod.ID, UserID: userID, Link: s.pod.Link, Language: s.pod.Language, Cost: s.costs[jobID], ChargedCredits: charged, Quiz: s.pod.QuizSettings}
+}
+
+// fakeUsageStore keeps a ledger of one user's credits for the jobs of pods.
+type fakeUsageStore struct {
+	store.UsageStore
+
+	mu      sync.Mutex
+	pods    *fakePodStore
+	credits int
+	ledger  []store.CreditTransaction
+	charged map[int]int
+}
+
+func newFakeUsageStore(pods *fakePodStore, credits int) *fakeUsageStore {
+	return &fakeUsageStore{pods: pods, credits: credits, charged: make(map[int]int)}
+}
+
+func (s *fakeUsageStore) GetRemainingCredits(ctx context.Context, userID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.credits, nil
+}
+
+func (s *fakeUsageStore) ChargeJob(ctx context.Context, userID string, jobID int) (int, error) {
+	s.pods.mu.Lock()
+	cost, podID := s.pods.costs[jobID], s.pods.pod.ID
+	s.pods.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cost <= 0 || s.charged[jobID] > 0 {
+		return s.credits, nil
+	}
+	if s.credits < cost {
+		return 0, store.ErrInsufficientCredits
+	}
+	s.charged[jobID] = cost
+	s.add(-cost, store.CreditPodDebit, podID, jobID)
+	return s.credits, nil
+}
+
+func (s *fakeUsageStore) RefundJob(ctx context.Context, userID string, jobID int) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	s.pods.mu.Lock()
+	podID := s.pods.pod.ID
+	s.pods.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	amount := s.charged[jobID]
+	if amount <= 0 {
+		return 0, nil
+	}
+	s.charged[jobID] = 0
+	s.add(amount, store.CreditRefund, podID, jobID)
+	return amount, nil
+}
+
+func (s *fakeUsageStore) GetPodKeptCredits(ctx context.Context, podID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := 0
+	for _, entry := range s.ledger {
+		if *entry.PodID == podID {
+			kept -= entry.Amount
+		}
+	}
+	return kept, nil
+}
+
+func (s *fakeUsageStore) add(amount int, reason string, podID, jobID int) {
+	s.credits += amount
+	s.ledger = append(s.ledger, store.CreditTransaction{ID: len(s.ledger) + 1, Amount: amount, Reason: reason, PodID: &podID, JobID: &jobID})
+}
+
+func (s *fakeUsageStore) balance() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.credits
+}
+
+// textPod is a pod made from pasted text, priced by its words.
+func textPod(words int) (store.Pod, *store.Transcript) {
+	text := strings.TrimSpace(strings.Repeat("word ", words))
+	pod := store.Pod{ID: 7, Link: "text:abc", Title: "Notes", Language: "English", SourceType: core.SourceText, QuizSettings: store.QuizSettings{Difficulty: core.DifficultyMixed}}
+	return pod, &store.Transcript{Segments: []store.TranscriptSegment{{Text: text}}}
+}
+
+func TestRetryPodChargesFailedPodsAgain(t *testing.T) {
+	t.Setenv("RETRY_COST_PERCENT", "100")
+	ctx := context.Background()
+	pod, transcript := textPod(200)
+	cost := core.WordCost(200)
+	pods := newFakePodStore(pod, transcript)
+	usage := newFakeUsageStore(pods, 1000)
+
+	failQuiz := true
+	provider := llm.NewFake()
+	provider.Reply = func(req llm.Request) (string, error) {
+		switch {
+		case strings.Contains(req.Prompt, "true_answer_index"):
+			if failQuiz {
+				return "", errors.New("provider unavailable")
+			}
+			return llm.FakeQuiz, nil
+		case strings.Contains(req.Prompt, `"confidence"`):
+			return llm.FakeVerdict, nil
+		}
+		return llm.FakeArticle, nil
+	}
+	pipeline := core.NewPipeline(pods, usage, nil, nil, provider, nil, nil, nil, core.RetryPolicy{MaxAttempts: 1})
+
+	// The first job is charged once classified and refunded when the quiz fails
+	first, _ := pods.InsertPodJob(ctx, pod.ID, pod.Language, cost)
+	if err := pipeline.Run(ctx, pods.job(first, "user", 0)); err == nil {
+		t.Fatalf("expected the first job to fail")
+	}
+	if got := usage.balance(); got != 1000 {
+		t.Errorf("expected the failed job to be refunded, balance is %d", got)
+	}
+
+	retried, err := core.RetryPod(pod.ID, "user", pods, usage, nil)
+	if err != nil {
+		t.Fatalf("RetryPod failed: %v", err)
+	}
+	if retried.Cost != cost {
+		t.Errorf("expected the retry to cost %d like a new pod, got %d", cost, retried.Cost)
+	}
+	if !reflect.DeepEqual(retried.Missing, []string{"quiz"}) {
+		t.Errorf("expected only the quiz to be missing, got %v", retried.Missing)
+	}
+
+	failQuiz = false
+	if err := pipeline.Run(ctx, pods.job(retried.JobID, "user", 0)); err != nil {
+		t.Fatalf("expected the retry to succeed: %v", err)
+	}
+	if len(pods.questions) != 7 {
+		t.Errorf("expected the quiz to be stored with its 7 questions, got %d", len(pods.questions))
+	}
+	if got := usage.balance(); got != 1000-cost {
+		t.Errorf("expected a balance of %d after the retry, got %d", 1000-cost, got)
+	}
+	reasons := make([]string, len(usage.ledger))
+	for i, entry := range usage.ledger {
+		reasons[i] = entry.Reason
+	}
+	want := []string{store.CreditPodDebit, store.CreditRefund, store.CreditPodDebit}
+	if !reflect.DeepEqual(reasons, want) {
+		t.Errorf("expected ledger entries %v, got %v", want, reasons)
+	}
+
+	if _, err := core.RetryPod(pod.ID, "user", pods, usage, nil); !errors.Is(err, core.ErrNothingToRetry) {
+		t.Errorf("expected ErrNothingToRetry once the pod is complete, got %v", err)
+	}
+}
+
+func TestRetryPodRefusesASecondRetry(t *testing.T) {
+	ctx := context.Background()
+	pod, transcript := textPod(200)
+	pods := newFakePodStore(pod, transcript)
+	usage := newFakeUsageStore(pods, 1000)
+
+	failed, _ := pods.InsertPodJob(ctx, pod.ID, pod.Language, core.WordCost(200))
+	pods.FinishPodJob(ctx, failed, core.StageFailed, "generation did not complete", "")
+
+	retried, err := core.RetryPod(pod.ID, "user", pods, usage, nil)
+	if err != nil {
+		t.Fatalf("RetryPod failed: %v", err)
+	}
+	if retried.Cost != 0 {
+		t.Errorf("expected retries to be free unless RETRY_COST_PERCENT is set, got %d", retried.Cost)
+	}
+	if _, err := core.RetryPod(pod.ID, "user", pods, usage, nil); !errors.Is(err, core.ErrPodInProgress) {
+		t.Errorf("expected ErrPodInProgress while the first retry is queued, got %v", err)
+	}
+	if len(pods.jobs) != 2 {
+		t.Errorf("expected one retry job to be queued, got %d jobs", len(pods.jobs))
+	}
+}
+
+func TestCreateNewPodReusesFinishedPods(t *testing.T) {
+	t.Setenv("REUSE_COST_PERCENT", "50")
+	pod, transcript := textPod(200)
+	pods := newFakePodStore(pod, transcript)
+	pods.reusable, pods.articleID, pods.quizID = pod.ID, 1, 1
+	usage := newFakeUsageStore(pods, 1000)
+	doc := core.Document{Title: "Notes", Paragraphs: []string{transcript.Text()}}
+	req := core.PodRequest{SourceType: core.SourceText, UserID: "user", Language: "English", Document: &doc}
+
+	created, err := core.CreateNewPod(req, pods, usage, nil)
+	if err != nil {
+		t.Fatalf("CreateNewPod failed: %v", err)
+	}
+	cost := core.WordCost(200) / 2
+	if !created.Reused || created.Cost != cost {
+		t.Errorf("expected the pod to be reused for %d credits, got %+v", cost, created)
+	}
+	if pods.clonedFrom != pod.ID {
+		t.Errorf("expected pod %d to be cloned, got %d", pod.ID, pods.clonedFrom)
+	}
+	if status, _ := pods.GetJobStatusByPodID(context.Background(), created.PodID); status.Stage != core.StageDone {
+		t.Errorf("expected the reused pod to be done, got %q", status.Stage)
+	}
+	if got := usage.balance(); got != 1000-cost {
+		t.Errorf("expected a balance of %d, got %d", 1000-cost, got)
+	}
+	if len(usage.ledger) != 1 || usage.ledger[0].Reason != store.CreditPodDebit || *usage.ledger[0].JobID != created.JobID {
+		t.Errorf("expected the reused job to be debited once, got %+v", usage.ledger)
+	}
+
+	// Regenerating is charged in full once the pipeline runs
+	req.ForceRegenerate = true
+	regenerated, err := core.CreateNewPod(req, pods, usage, nil)
+	if err != nil {
+		t.Fatalf("CreateNewPod failed: %v", err)
+	}
+	if regenerated.Reused || regenerated.Cost != core.WordCost(200) {
+		t.Errorf("expected a full price generation, got %+v", regenerated)
+	}
+
+	poor := newFakeUsageStore(pods, cost-1)
+	req.ForceRegenerate = false
+	if _, err := core.CreateNewPod(req, pods, poor, nil); !errors.Is(err, core.ErrInsufficientCredits) {
+		t.Errorf("expected ErrInsufficientCredits, got %v", err)
+	}
+	if len(poor.ledger) != 0 {
+		t.Errorf("expected nothing to be charged, got %+v", poor.ledger)
+	}
+}
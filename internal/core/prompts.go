@@ -1,100 +1,244 @@
 package core
 
-import "fmt"
-
-var rawArticlePrompt = `First, determine if this transcript contains substantive educational content suitable for creating an educational article and quiz.
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/demirbey05/auth-demo/internal/store"
+)
+
+// Kinds of prompt templates.
+const (
+	PromptArticle    = "article"
+	PromptChunkNotes = "chunk_notes"
+	PromptQuiz       = "quiz"
+	PromptClassify   = "classify"
+)
+
+// PromptKinds lists every kind of prompt template.
+var PromptKinds = []string{PromptArticle, PromptChunkNotes, PromptQuiz, PromptClassify}
+
+// defaultPromptVersions are the built-in versions used when no stored
+// version of a kind has a weight.
+var defaultPromptVersions = map[string]string{
+	PromptArticle:    "v2",
+	PromptChunkNotes: "v2",
+	PromptQuiz:       "v3",
+	PromptClassify:   "v1",
+}
 
-If the content is NOT educational (such as movie scenes, music clips, casual conversations, or promotional material), respond ONLY with:
-{
-    "error": "The provided content does not appear to be educational. Please provide a transcript of educational content like a lecture, documentary, or informative podcast."
+// Built-in templates are named <kind>.<version>.tmpl.
+//
+//go:embed prompts/*.tmpl
+var promptFiles embed.FS
+
+var builtinPrompts = mustLoadBuiltinPrompts()
+
+// PromptData is what prompt templates are rendered with. Each kind uses
+// only some of the fields.
+type PromptData struct {
+	Language   string
+	Transcript string
+	// Timed is set when each transcript line starts with its [m:ss] time in
+	// the video, so the article can cite the moments its sections come from.
+	Timed bool
+	// Notes replace Transcript when a long transcript was condensed in chunks.
+	Notes []string
+	// Chunk is the transcript section Index of Total being condensed.
+	Chunk string
+	Index int
+	Total int
+	// Article is what a quiz is written from, with QuestionCount questions
+	// (0 for the default range) at Difficulty.
+	Article       string
+	QuestionCount int
+	Difficulty    string
+	// Title and Category describe the video being classified.
+	Title    string
+	Category string
 }
 
-If the content IS educational, then you are an expert educator who deeply understands this subject. Transform this transcript into an educational article that effectively teaches the core concepts.
+// PromptTemplate is one version of a prompt.
+type PromptTemplate struct {
+	Kind    string
+	Version string
+	Weight  int
+	tmpl    *template.Template
+}
 
-As an experienced teacher, you will:
-1. IDENTIFY the 3-5 most important concepts or insights from the content
-2. EXPLAIN these concepts clearly, as if teaching a class of engaged students
-3. CONNECT ideas logically, building understanding progressively
-4. ILLUSTRATE concepts with relevant examples, analogies, or applications
-5. EMPHASIZE practical takeaways and "why this matters"
+func parsePrompt(kind, version, body string) (*PromptTemplate, error) {
+	tmpl, err := template.New(kind + "." + version).
+		Funcs(template.FuncMap{"inc": func(i int) int { return i + 1 }}).
+		Option("missingkey=error").
+		Parse(strings.TrimRight(body, "\n"))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s prompt %s: %v", kind, version, err)
+	}
+	return &PromptTemplate{Kind: kind, Version: version, tmpl: tmpl}, nil
+}
 
-Structure your article with:
-- A clear, informative title
-- An introduction setting context and stating learning objectives
-- Well-organized sections with descriptive headings
-- A conclusion reinforcing key learning points
+// ValidatePrompt reports whether body parses and renders as a template of kind.
+func ValidatePrompt(kind, body string) error {
+	t, err := parsePrompt(kind, "draft", body)
+	if err != nil {
+		return err
+	}
+	_, err = t.Render(PromptData{Language: "English", Transcript: "transcript", Chunk: "chunk", Index: 1, Total: 1, Article: "article", Title: "title", Category: "Education"})
+	return err
+}
 
-Format using appropriate markdown:
-- ## Main Headings and ### Subheadings
-- Bullet points for lists of related items
-- **Bold** for key terms and important concepts
-- Tables only when they enhance understanding
+// Render fills the template with data.
+func (t *PromptTemplate) Render(data PromptData) (string, error) {
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("error rendering %s prompt %s: %v", t.Kind, t.Version, err)
+	}
+	return b.String(), nil
+}
 
-If translation is needed:
-- Maintain the pedagogical clarity while adapting to the target language
-- Preserve technical terminology with brief explanations where needed
+func mustLoadBuiltinPrompts() map[string]*PromptTemplate {
+	prompts := make(map[string]*PromptTemplate)
+	err := fs.WalkDir(promptFiles, "prompts", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		kind, version, ok := strings.Cut(strings.TrimSuffix(path.Base(name), ".tmpl"), ".")
+		if !ok {
+			return fmt.Errorf("prompt file %s is not named <kind>.<version>.tmpl", name)
+		}
+		body, err := promptFiles.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		t, err := parsePrompt(kind, version, string(body))
+		if err != nil {
+			return err
+		}
+		prompts[kind+"."+version] = t
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+	for kind, version := range defaultPromptVersions {
+		if prompts[kind+"."+version] == nil {
+			panic(fmt.Sprintf("missing built-in %s prompt %s", kind, version))
+		}
+	}
+	return prompts
+}
 
-Present only the educational article without any framing text or respond with the error JSON if the content is not educational.`
+// DefaultPrompt returns the built-in default template of kind.
+func DefaultPrompt(kind string) *PromptTemplate {
+	return builtinPrompts[kind+"."+defaultPromptVersions[kind]]
+}
 
-func generateArticlePrompt(transcript, language string) string {
-	return fmt.Sprintf("%s\n\nTranscript: %s\n\nUser language: %s", rawArticlePrompt, transcript, language)
+// BuiltinPrompt returns the built-in template of kind and version, or nil.
+func BuiltinPrompt(kind, version string) *PromptTemplate {
+	return builtinPrompts[kind+"."+version]
 }
 
-var rawQuizPrompt = `As an experienced educator who has just taught this material, create an assessment that effectively measures student understanding of the key concepts.
-
-Your pedagogical approach should:
-1. TEST MASTERY of the fundamental concepts rather than memorization of details
-2. ASSESS different levels of understanding:
-   - Basic comprehension of core ideas
-   - Application of concepts to new situations
-   - Analysis of relationships between concepts
-3. PROVIDE questions that:
-   - Are clearly worded as you would present them in class
-   - Focus on what a good teacher would consider important
-   - Challenge students to demonstrate true understanding
-4. DESIGN thoughtful answer options that:
-   - Include one clearly correct answer
-   - Offer plausible distractors that reveal common misconceptions
-   - Help identify gaps in understanding
-
-Format your assessment as valid JSON:
-{
-    "questions": [
-        {
-            "question": "Clearly worded question testing an important concept",
-            "options": ["Correct answer", "Plausible distractor", "Plausible distractor", "Plausible distractor"],
-            "true_answer_index": 0,
-            "explanation": "Brief explanation of the concept as you would explain it to a student"
-        },
-		{
-            "question": "Clearly worded question testing an important concept",
-            "options": ["Plausible distracter", "Plausible distractor", "Correct answer", "Plausible distractor"],
-            "true_answer_index": 2,
-            "explanation": "Brief explanation of the concept as you would explain it to a student"
-        },
-		{
-            "question": "Clearly worded question testing an important concept",
-            "options": ["Plausible distracter", "Correct answer", "Plausible distractor", "Plausible distractor"],
-            "true_answer_index": 1,
-            "explanation": "Brief explanation of the concept as you would explain it to a student"
-        },
-		{
-            "question": "Clearly worded question testing an important concept",
-            "options": ["Plausible distracter", "Plausible distractor", "Plausible distractor", "Correct answer"],
-            "true_answer_index": 3,
-            "explanation": "Brief explanation of the concept as you would explain it to a student"
-        }
-    ]
+// PromptRegistry picks the prompt version used for each generation. Versions
+// stored in the database with a positive weight are assigned at random in
+// proportion to their weights; kinds without any fall back to the built-in
+// default. Stored bodies under a built-in version name are ignored. Stored versions are reloaded once they are older than ttl, so
+// prompts can change without a redeploy.
+type PromptRegistry struct {
+	promptStore store.PromptStore
+	ttl         time.Duration
+
+	mu       sync.Mutex
+	weighted map[string][]*PromptTemplate
+	loadedAt time.Time
 }
 
-IMPORTANT: Randomize the position of correct answers across your questions. DO NOT place all correct answers in the same position (e.g., all at index 0). Deliberately vary the true_answer_index values (0, 1, 2, or 3) throughout the quiz.
+func NewPromptRegistry(promptStore store.PromptStore, ttl time.Duration) *PromptRegistry {
+	return &PromptRegistry{promptStore: promptStore, ttl: ttl}
+}
 
-If translation is needed:
-- Ensure questions maintain their pedagogical clarity in the target language
-- Preserve the educational value of both questions and explanations
+// Pick returns the template to use for kind. It is safe on a nil registry,
+// which always returns the built-in default.
+func (r *PromptRegistry) Pick(ctx context.Context, kind string) *PromptTemplate {
+	if r == nil || r.promptStore == nil {
+		return DefaultPrompt(kind)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.loadedAt) > r.ttl {
+		if err := r.load(ctx); err != nil {
+			// Keep using what was loaded before
+			fmt.Printf("error loading prompt templates: %v\n", err)
+		}
+	}
+
+	candidates := r.weighted[kind]
+	total := 0
+	for _, t := range candidates {
+		total += t.Weight
+	}
+	if total == 0 {
+		return DefaultPrompt(kind)
+	}
+	n := rand.Intn(total)
+	for _, t := range candidates {
+		if n < t.Weight {
+			return t
+		}
+		n -= t.Weight
+	}
+	return DefaultPrompt(kind)
+}
 
-Create 7-10 questions that collectively assess mastery of the material's most important concepts.`
+func (r *PromptRegistry) load(ctx context.Context) error {
+	r.loadedAt = time.Now()
+	stored, err := r.promptStore.ListPromptTemplates(ctx)
+	if err != nil {
+		return err
+	}
+
+	weighted := make(map[string][]*PromptTemplate)
+	for _, s := range stored {
+		if s.Weight <= 0 {
+			continue
+		}
+		var t *PromptTemplate
+		if s.Body == "" {
+			builtin := BuiltinPrompt(s.Kind, s.Version)
+			if builtin == nil {
+				fmt.Printf("prompt template %d refers to unknown built-in %s prompt %s\n", s.ID, s.Kind, s.Version)
+				continue
+			}
+			copied := *builtin
+			t = &copied
+		} else if BuiltinPrompt(s.Kind, s.Version) != nil {
+			// A stored body must not pass for the built-in version it names
+			fmt.Printf("prompt template %d is skipped, %s prompt %s is built in\n", s.ID, s.Kind, s.Version)
+			continue
+		} else {
+			t, err = parsePrompt(s.Kind, s.Version, s.Body)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+		}
+		t.Weight = s.Weight
+		weighted[s.Kind] = append(weighted[s.Kind], t)
+	}
+	r.weighted = weighted
+	return nil
+}
 
-func generateQuizPrompt(article, language string) string {
-	return fmt.Sprintf("%s\n\nArticle: %s\n\nUser language: %s", rawQuizPrompt, article, language)
+// repairQuizPrompt repeats the quiz prompt with the rejected answer and the
+// reasons it was rejected.
+func repairQuizPrompt(prompt, previous string, problem error) string {
+	return fmt.Sprintf("%s\n\nYour previous answer was:\n%s\n\nIt was rejected because %v.\nFix every problem and answer again with the corrected JSON only.", prompt, previous, problem)
 }
@@ -0,0 +1,71 @@
+package core_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/demirbey05/auth-demo/internal/core"
+	"github.com/demirbey05/auth-demo/internal/store"
+)
+
+type fakePromptStore struct {
+	store.PromptStore
+	templates []store.PromptTemplate
+}
+
+func (s *fakePromptStore) ListPromptTemplates(ctx context.Context) ([]store.PromptTemplate, error) {
+	return s.templates, nil
+}
+
+func TestPromptRegistryPicksWeightedVersions(t *testing.T) {
+	promptStore := &fakePromptStore{templates: []store.PromptTemplate{
+		{ID: 1, Kind: core.PromptQuiz, Version: "v1", Weight: 0},
+		{ID: 2, Kind: core.PromptQuiz, Version: "exp-1", Body: "Quiz in {{.Language}}: {{.Article}}", Weight: 3},
+	}}
+	registry := core.NewPromptRegistry(promptStore, time.Minute)
+
+	quiz := registry.Pick(context.Background(), core.PromptQuiz)
+	if quiz.Version != "exp-1" {
+		t.Fatalf("expected the only weighted quiz version exp-1, got %s", quiz.Version)
+	}
+	prompt, err := quiz.Render(core.PromptData{Article: "the article", Language: "English"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if prompt != "Quiz in English: the article" {
+		t.Errorf("unexpected prompt %q", prompt)
+	}
+
+	article := registry.Pick(context.Background(), core.PromptArticle)
+	if article.Version != core.DefaultPrompt(core.PromptArticle).Version {
+		t.Errorf("expected the built-in article version, got %s", article.Version)
+	}
+	prompt, err = article.Render(core.PromptData{Transcript: "the transcript", Language: "English"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if !strings.Contains(prompt, "Transcript: the transcript") {
+		t.Errorf("expected the transcript in the article prompt, got %q", prompt)
+	}
+}
+
+func TestPromptRegistryIgnoresStoredBodiesOfBuiltinVersions(t *testing.T) {
+	promptStore := &fakePromptStore{templates: []store.PromptTemplate{
+		{ID: 1, Kind: core.PromptQuiz, Version: "v1", Body: "Shadowed quiz: {{.Article}}", Weight: 5},
+	}}
+	registry := core.NewPromptRegistry(promptStore, time.Minute)
+
+	quiz := registry.Pick(context.Background(), core.PromptQuiz)
+	prompt, err := quiz.Render(core.PromptData{Article: "the article", Language: "English"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if strings.HasPrefix(prompt, "Shadowed quiz") {
+		t.Errorf("expected the stored body not to replace built-in quiz v1, got %q", prompt)
+	}
+	if quiz.Version != core.DefaultPrompt(core.PromptQuiz).Version {
+		t.Errorf("expected the built-in default quiz version, got %s", quiz.Version)
+	}
+}
@@ -0,0 +1,271 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/demirbey05/auth-demo/internal/llm"
+	"github.com/demirbey05/auth-demo/internal/store"
+)
+
+// Shape every generated quiz must have. Quizzes without a requested
+// question count have between MinQuizQuestions and MaxQuizQuestions.
+const (
+	MinQuizQuestions = 7
+	MaxQuizQuestions = 10
+	QuizOptionCount  = 4
+)
+
+// Bounds of the question count a user can ask for.
+const (
+	MinQuestionCount = 3
+	MaxQuestionCount = 25
+)
+
+// Quiz difficulties. Mixed, the default, spreads questions across levels.
+const (
+	DifficultyEasy   = "easy"
+	DifficultyMedium = "medium"
+	DifficultyHard   = "hard"
+	DifficultyMixed  = "mixed"
+)
+
+var Difficulties = []string{DifficultyEasy, DifficultyMedium, DifficultyHard, DifficultyMixed}
+
+// ErrInvalidQuizSettings is returned for a question count or difficulty that cannot be generated.
+var ErrInvalidQuizSettings = errors.New("invalid quiz settings")
+
+// NormalizeQuizSettings fills in the default difficulty and checks that the
+// settings are within bounds.
+func NormalizeQuizSettings(settings store.QuizSettings) (store.QuizSettings, error) {
+	if settings.Difficulty == "" {
+		settings.Difficulty = DifficultyMixed
+	}
+	if !slices.Contains(Difficulties, settings.Difficulty) {
+		return settings, fmt.Errorf("%w: difficulty must be one of %s", ErrInvalidQuizSettings, strings.Join(Difficulties, ", "))
+	}
+	if settings.QuestionCount != 0 && (settings.QuestionCount < MinQuestionCount || settings.QuestionCount > MaxQuestionCount) {
+		return settings, fmt.Errorf("%w: question_count must be between %d and %d", ErrInvalidQuizSettings, MinQuestionCount, MaxQuestionCount)
+	}
+	return settings, nil
+}
+
+// ErrInvalidQuiz is returned when the model keeps answering with a quiz that
+// breaks the invariants checked by ValidateQuiz.
+var ErrInvalidQuiz = errors.New("invalid quiz")
+
+// Question types. Each stores a different answer payload:
+//
+//	single_choice    {"index": 2}
+//	true_false       {"value": true}
+//	multiple_select  {"indices": [0, 3]}
+//	fill_blank       {"accepted": ["photosynthesis"]}
+//	short_answer     {"sample_answer": "..."}
+const (
+	QuestionSingleChoice   = "single_choice"
+	QuestionTrueFalse      = "true_false"
+	QuestionMultipleSelect = "multiple_select"
+	QuestionFillBlank      = "fill_blank"
+	QuestionShortAnswer    = "short_answer"
+)
+
+var QuestionTypes = []string{QuestionSingleChoice, QuestionTrueFalse, QuestionMultipleSelect, QuestionFillBlank, QuestionShortAnswer}
+
+// fillBlankMarker marks the gap in the text of fill-in-the-blank questions.
+const fillBlankMarker = "___"
+
+// quizSchema is the JSON the quiz prompt asks for.
+var quizSchema = &llm.Schema{
+	Type: llm.TypeObject,
+	Properties: map[string]*llm.Schema{
+		"questions": {
+			Type: llm.TypeArray,
+			Items: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"type":                {Type: llm.TypeString, Enum: QuestionTypes},
+					"question":            {Type: llm.TypeString},
+					"options":             {Type: llm.TypeArray, Items: &llm.Schema{Type: llm.TypeString}},
+					"true_answer_index":   {Type: llm.TypeInteger},
+					"true_answer_indices": {Type: llm.TypeArray, Items: &llm.Schema{Type: llm.TypeInteger}},
+					"true_answer_value":   {Type: llm.TypeBoolean},
+					"accepted_answers":    {Type: llm.TypeArray, Items: &llm.Schema{Type: llm.TypeString}},
+					"sample_answer":       {Type: llm.TypeString},
+					"explanation":         {Type: llm.TypeString},
+				},
+				Required: []string{"question", "explanation"},
+			},
+		},
+	},
+	Required: []string{"questions"},
+}
+
+// QuizValidationError lists every problem found in a quiz.
+type QuizValidationError struct {
+	Problems []string
+}
+
+func (e *QuizValidationError) Error() string {
+	return "quiz is invalid: " + strings.Join(e.Problems, "; ")
+}
+
+// ValidateQuiz checks that a quiz has the requested number of questions, or
+// 7-10 when none was requested, and that each question is well formed for
+// its type. It returns a *QuizValidationError describing every violation.
+func ValidateQuiz(quiz *Quiz, settings store.QuizSettings) error {
+	if quiz == nil {
+		return &QuizValidationError{Problems: []string{"quiz is empty"}}
+	}
+
+	var problems []string
+	if n := len(quiz.Questions); settings.QuestionCount != 0 && n != settings.QuestionCount {
+		problems = append(problems, fmt.Sprintf("expected exactly %d questions, got %d", settings.QuestionCount, n))
+	} else if settings.QuestionCount == 0 && (n < MinQuizQuestions || n > MaxQuizQuestions) {
+		problems = append(problems, fmt.Sprintf("expected %d-%d questions, got %d", MinQuizQuestions, MaxQuizQuestions, n))
+	}
+	for i, q := range quiz.Questions {
+		problems = append(problems, validateQuestion(i+1, q)...)
+	}
+
+	if len(problems) > 0 {
+		return &QuizValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// validateQuestion checks question n. Choice questions need four distinct
+// non-empty options and answers pointing at them; the other types need the
+// answer field of their type.
+func validateQuestion(n int, q QuizQuestion) []string {
+	var problems []string
+	if strings.TrimSpace(q.Question) == "" {
+		problems = append(problems, fmt.Sprintf("question %d has no text", n))
+	}
+
+	switch q.Type {
+	case "", QuestionSingleChoice:
+		problems = append(problems, validateOptions(n, q.Options)...)
+		switch {
+		case q.Answer == nil:
+			problems = append(problems, fmt.Sprintf("question %d has no true_answer_index", n))
+		case *q.Answer < 0 || *q.Answer >= len(q.Options):
+			problems = append(problems, fmt.Sprintf("question %d has true_answer_index %d out of range", n, *q.Answer))
+		}
+	case QuestionMultipleSelect:
+		problems = append(problems, validateOptions(n, q.Options)...)
+		if len(q.AnswerIndices) == 0 {
+			problems = append(problems, fmt.Sprintf("question %d has no true_answer_indices", n))
+		}
+		seen := make(map[int]bool, len(q.AnswerIndices))
+		for _, index := range q.AnswerIndices {
+			if index < 0 || index >= len(q.Options) {
+				problems = append(problems, fmt.Sprintf("question %d has true_answer_indices entry %d out of range", n, index))
+			} else if seen[index] {
+				problems = append(problems, fmt.Sprintf("question %d repeats true_answer_indices entry %d", n, index))
+			}
+			seen[index] = true
+		}
+	case QuestionTrueFalse:
+		if q.AnswerValue == nil {
+			problems = append(problems, fmt.Sprintf("question %d has no true_answer_value", n))
+		}
+	case QuestionFillBlank:
+		if !strings.Contains(q.Question, fillBlankMarker) {
+			problems = append(problems, fmt.Sprintf("question %d does not mark the blank with %s", n, fillBlankMarker))
+		}
+		if len(q.AcceptedAnswers) == 0 || slices.ContainsFunc(q.AcceptedAnswers, func(a string) bool { return strings.TrimSpace(a) == "" }) {
+			problems = append(problems, fmt.Sprintf("question %d needs non-empty accepted_answers", n))
+		}
+	case QuestionShortAnswer:
+		if strings.TrimSpace(q.SampleAnswer) == "" {
+			problems = append(problems, fmt.Sprintf("question %d has no sample_answer", n))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("question %d has unknown type %q", n, q.Type))
+	}
+	return problems
+}
+
+func validateOptions(n int, options []string) []string {
+	var problems []string
+	if len(options) != QuizOptionCount {
+		problems = append(problems, fmt.Sprintf("question %d has %d options, expected %d", n, len(options), QuizOptionCount))
+	}
+	seen := make(map[string]bool, len(options))
+	for j, option := range options {
+		key := strings.ToLower(strings.TrimSpace(option))
+		if key == "" {
+			problems = append(problems, fmt.Sprintf("question %d option %d is empty", n, j+1))
+			continue
+		}
+		if seen[key] {
+			problems = append(problems, fmt.Sprintf("question %d has duplicate option %q", n, option))
+		}
+		seen[key] = true
+	}
+	return problems
+}
+
+// record converts a validated question into what the store keeps: the
+// type, the options choice questions need and the type specific answer.
+func (q QuizQuestion) record() (store.Question, error) {
+	question := store.Question{Type: q.Type, Text: q.Question, Explanation: q.Explanation}
+	var answer any
+	switch q.Type {
+	case "", QuestionSingleChoice:
+		question.Type = QuestionSingleChoice
+		question.Options = q.Options
+		question.AnswerIdx = q.Answer
+		answer = struct {
+			Index int `json:"index"`
+		}{*q.Answer}
+	case QuestionMultipleSelect:
+		question.Options = q.Options
+		answer = struct {
+			Indices []int `json:"indices"`
+		}{q.AnswerIndices}
+	case QuestionTrueFalse:
+		answer = struct {
+			Value bool `json:"value"`
+		}{*q.AnswerValue}
+	case QuestionFillBlank:
+		answer = struct {
+			Accepted []string `json:"accepted"`
+		}{q.AcceptedAnswers}
+	case QuestionShortAnswer:
+		answer = struct {
+			SampleAnswer string `json:"sample_answer"`
+		}{q.SampleAnswer}
+	default:
+		return store.Question{}, fmt.Errorf("unknown question type %q", q.Type)
+	}
+
+	payload, err := json.Marshal(answer)
+	if err != nil {
+		return store.Question{}, err
+	}
+	question.Answer = payload
+	return question, nil
+}
+
+// parseQuiz decodes a model answer, tolerating markdown code fences from
+// providers that ignore JSON mode.
+func parseQuiz(text string, settings store.QuizSettings) (*Quiz, error) {
+	cleaned := strings.TrimSpace(text)
+	cleaned = strings.TrimPrefix(cleaned, "```json")
+	cleaned = strings.TrimPrefix(cleaned, "```")
+	cleaned = strings.TrimSuffix(cleaned, "```")
+	cleaned = strings.TrimSpace(cleaned)
+
+	var quiz Quiz
+	if err := json.Unmarshal([]byte(cleaned), &quiz); err != nil {
+		return nil, fmt.Errorf("failed to parse quiz response: %v", err)
+	}
+	if err := ValidateQuiz(&quiz, settings); err != nil {
+		return nil, err
+	}
+	return &quiz, nil
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/demirbey05/auth-demo/internal/store"
+)
+
+// errRetriesExhausted marks a stage that kept failing until its attempt budget ran out.
+var errRetriesExhausted = errors.New("retries exhausted")
+
+// RetryPolicy controls how often a failing pipeline stage is retried.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// Delay returns how long to wait after the given failed attempt (starting at 1).
+// The delay doubles with every attempt up to MaxDelay, and half of it is jittered
+// so that jobs failing together do not retry in lockstep. A MaxDelay that is
+// not positive retries at once.
+func (r RetryPolicy) Delay(attempt int) time.Duration {
+	d := max(r.MaxDelay, 0)
+	if attempt < 32 {
+		if backoff := r.BaseDelay << (attempt - 1); backoff > 0 && backoff < d {
+			d = backoff
+		}
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// retry runs fn until it succeeds, fails permanently or the policy gives up.
+// Every retry is recorded on the job together with the error that caused it.
+func (p *Pipeline) retry(ctx context.Context, job store.Job, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || errors.Is(err, ErrNotEducational) {
+			return err
+		}
+		if ctx.Err() != nil {
+			// The job ran out of time; that is not worth dead-lettering
+			return err
+		}
+		if attempt >= p.retryPolicy.MaxAttempts {
+			return fmt.Errorf("%w after %d attempts: %v", errRetriesExhausted, attempt, err)
+		}
+
+		if rerr := p.podStore.RecordPodJobRetry(ctx, job.ID, err.Error()); rerr != nil {
+			fmt.Println(rerr)
+		}
+		delay := p.retryPolicy.Delay(attempt)
+		fmt.Printf("job %d: attempt %d failed, retrying in %s: %v\n", job.ID, attempt, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
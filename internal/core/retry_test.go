@@ -0,0 +1,51 @@
+package core_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/demirbey05/auth-demo/internal/core"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := core.RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	cases := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{64, 10 * time.Second},
+	}
+	for _, tc := range cases {
+		for i := 0; i < 50; i++ {
+			d := policy.Delay(tc.attempt)
+			if d < tc.max/2 || d > tc.max {
+				t.Fatalf("attempt %d: delay %s outside [%s, %s]", tc.attempt, d, tc.max/2, tc.max)
+			}
+		}
+	}
+}
+
+func TestRetryPolicyDelayWithoutMaxDelay(t *testing.T) {
+	cases := []struct {
+		name   string
+		policy core.RetryPolicy
+	}{
+		{"zero max delay", core.RetryPolicy{BaseDelay: time.Second}},
+		{"negative max delay", core.RetryPolicy{BaseDelay: time.Second, MaxDelay: -time.Second}},
+		{"negative delays", core.RetryPolicy{BaseDelay: -time.Second, MaxDelay: -time.Second}},
+		{"zero delays", core.RetryPolicy{}},
+	}
+	for _, tc := range cases {
+		for _, attempt := range []int{1, 2, 64} {
+			if d := tc.policy.Delay(attempt); d != 0 {
+				t.Errorf("%s: attempt %d waited %s, expected no delay", tc.name, attempt, d)
+			}
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/demirbey05/auth-demo/internal/store"
+)
+
+// Transcript providers that can be chained with TRANSCRIPT_PROVIDERS.
+const (
+	TranscriptSelfHosted = "self_hosted"
+	TranscriptSupadata   = "supadata"
+)
+
+// DefaultSupadataBaseURL is used when SUPADATA_BASE_URL is not set.
+const DefaultSupadataBaseURL = "https://api.supadata.ai/v1"
+
+// ErrEmptyTranscript is returned by providers that found no words to transcribe.
+var ErrEmptyTranscript = errors.New("transcript is empty")
+
+var languageMap = map[string]string{
+	"English":    "en",
+	"Spanish":    "es",
+	"French":     "fr",
+	"German":     "de",
+	"Italian":    "it",
+	"Portuguese": "pt",
+	"Dutch":      "nl",
+	"Polish":     "pl",
+	"Russian":    "ru",
+	"Japanese":   "ja",
+	"Korean":     "ko",
+	"Chinese":    "zh",
+	"Turkish":    "tr",
+	"Hindi":      "hi",
+}
+
+// TranscriptProvider fetches the transcript of a video. language is the
+// language name the user picked for the pod.
+type TranscriptProvider interface {
+	FetchTranscript(ctx context.Context, link, language string) (store.Transcript, error)
+}
+
+// SourceReleaser is implemented by providers that keep the source of a
+// transcript, such as an uploaded file, until the pipeline has stored it.
+type SourceReleaser interface {
+	ReleaseSource(link string)
+}
+
+// TranscriptConfig selects the transcript providers and where they are.
+type TranscriptConfig struct {
+	// Providers are tried in order until one returns a transcript.
+	Providers       []string
+	TranscriberURL  string
+	SupadataBaseURL string
+	SupadataAPIKey  string
+	// Timeout bounds each request to a provider.
+	Timeout time.Duration
+}
+
+// TranscriptConfigFromEnv reads TRANSCRIPT_PROVIDERS, a comma separated list
+// such as "self_hosted,supadata", along with TRANSCRIBER_URL,
+// SUPADATA_BASE_URL, SUPADATA_API_KEY and TRANSCRIPT_TIMEOUT (default 60s).
+// Without TRANSCRIPT_PROVIDERS the self-hosted transcriber is used when ENV
+// is dev and Supadata otherwise.
+func TranscriptConfigFromEnv() TranscriptConfig {
+	cfg := TranscriptConfig{
+		TranscriberURL:  os.Getenv("TRANSCRIBER_URL"),
+		SupadataBaseURL: os.Getenv("SUPADATA_BASE_URL"),
+		SupadataAPIKey:  os.Getenv("SUPADATA_API_KEY"),
+		Timeout:         time.Minute,
+	}
+	for _, name := range strings.Split(os.Getenv("TRANSCRIPT_PROVIDERS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			cfg.Providers = append(cfg.Providers, name)
+		}
+	}
+	if len(cfg.Providers) == 0 {
+		cfg.Providers = []string{TranscriptSupadata}
+		if os.Getenv("ENV") == "dev" {
+			cfg.Providers = []string{TranscriptSelfHosted}
+		}
+	}
+	if v, err := time.ParseDuration(os.Getenv("TRANSCRIPT_TIMEOUT")); err == nil && v > 0 {
+		cfg.Timeout = v
+	}
+	return cfg
+}
+
+// NewTranscriptProvider builds the providers listed in cfg, chained in order
+// when there is more than one.
+func NewTranscriptProvider(cfg TranscriptConfig) (TranscriptProvider, error) {
+	var chain TranscriptChain
+	for _, name := range cfg.Providers {
+		var (
+			p   TranscriptProvider
+			err error
+		)
+		switch name {
+		case TranscriptSelfHosted:
+			p, err = NewSelfHostedTranscriber(cfg.TranscriberURL, cfg.Timeout)
+		case TranscriptSupadata:
+			p, err = NewSupadataTranscriber(cfg.SupadataBaseURL, cfg.SupadataAPIKey, cfg.Timeout)
+		default:
+			err = fmt.Errorf("unknown transcript provider %q", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, p)
+	}
+	switch len(chain) {
+	case 0:
+		return nil, errors.New("no transcript provider is configured")
+	case 1:
+		return chain[0], nil
+	}
+	return chain, nil
+}
+
+// TranscriptChain asks each provider in turn and returns the first transcript
+// found, so a provider that is down or lacks a video falls back to the next.
+type TranscriptChain []TranscriptProvider
+
+func (c TranscriptChain) FetchTranscript(ctx context.Context, link, language string) (store.Transcript, error) {
+	var errs []error
+	for _, p := range c {
+		t, err := p.FetchTranscript(ctx, link, language)
+		if err == nil {
+			if len(errs) > 0 {
+				fmt.Printf("transcript: fell back to %s after: %v\n", t.Provider, errors.Join(errs...))
+			}
+			return t, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return store.Transcript{}, errors.Join(errs...)
+}
+
+// SelfHostedTranscriber calls the transcriber service deployed next to the API.
+type SelfHostedTranscriber struct {
+	client  *http.Client
+	baseURL string
+}
+
+func NewSelfHostedTranscriber(baseURL string, timeout time.Duration) (*SelfHostedTranscriber, error) {
+	if baseURL == "" {
+		return nil, errors.New("TRANSCRIBER_URL is required for the self_hosted transcript provider")
+	}
+	return &SelfHostedTranscriber{client: &http.Client{Timeout: timeout}, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+// TranscriptResponse represents the JSON structure returned by the transcriber
+// service. Segment times are in seconds; older versions return no segments.
+type TranscriptResponse struct {
+	VideoID    string `json:"video_id"`
+	Transcript string `json:"transcript"`
+	Language   string `json:"language"`
+	Segments   []struct {
+		Text     string  `json:"text"`
+		Start    float64 `json:"start"`
+		Duration float64 `json:"duration"`
+	} `json:"segments"`
+}
+
+func (s *SelfHostedTranscriber) FetchTranscript(ctx context.Context, link, language string) (store.Transcript, error) {
+	langCode, ok := languageMap[language]
+	if !ok {
+		return store.Transcript{}, fmt.Errorf("%s: unsupported language %q", TranscriptSelfHosted, language)
+	}
+	query := url.Values{"url": {link}, "language": {langCode}}
+
+	var resp TranscriptResponse
+	if err := getTranscriptJSON(ctx, s.client, TranscriptSelfHosted, s.baseURL+"/transcript?"+query.Encode(), nil, &resp); err != nil {
+		return store.Transcript{}, err
+	}
+
+	t := store.Transcript{Provider: TranscriptSelfHosted, Language: resp.Language}
+	for _, seg := range resp.Segments {
+		t.Segments = append(t.Segments, store.TranscriptSegment{
+			Text:       seg.Text,
+			StartMs:    int(math.Round(seg.Start * 1000)),
+			DurationMs: int(math.Round(seg.Duration * 1000)),
+		})
+	}
+	if len(t.Segments) == 0 {
+		t.Segments = []store.TranscriptSegment{{Text: resp.Transcript}}
+	}
+	if t.Language == "" {
+		t.Language = langCode
+	}
+	if t.Text() == "" {
+		return store.Transcript{}, fmt.Errorf("%s: %w", TranscriptSelfHosted, ErrEmptyTranscript)
+	}
+	return t, nil
+}
+
+// SupadataTranscriber uses the Supadata YouTube transcript API.
+type SupadataTranscriber struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+}
+
+func NewSupadataTranscriber(baseURL, apiKey string, timeout time.Duration) (*SupadataTranscriber, error) {
+	if baseURL == "" {
+		baseURL = DefaultSupadataBaseURL
+		// Only stub servers can do without a key
+		if apiKey == "" {
+			return nil, errors.New("SUPADATA_API_KEY is required for the supadata transcript provider")
+		}
+	}
+	return &SupadataTranscriber{client: &http.Client{Timeout: timeout}, baseURL: strings.TrimSuffix(baseURL, "/"), apiKey: apiKey}, nil
+}
+
+// APITranscriptResponse represents the structure of the Supadata response.
+// Segment offsets and durations are in milliseconds.
+type APITranscriptResponse struct {
+	Content []struct {
+		Text     string  `json:"text"`
+		Offset   float64 `json:"offset"`
+		Duration float64 `json:"duration"`
+	} `json:"content"`
+	Lang string `json:"lang"`
+}
+
+func (s *SupadataTranscriber) FetchTranscript(ctx context.Context, link, language string) (store.Transcript, error) {
+	query := url.Values{"url": {link}}
+	// Supadata falls back to the default track when the language is missing
+	if langCode, ok := languageMap[language]; ok {
+		query.Set("lang", langCode)
+	}
+
+	var resp APITranscriptResponse
+	header := http.Header{"X-Api-Key": {s.apiKey}}
+	if err := getTranscriptJSON(ctx, s.client, TranscriptSupadata, s.baseURL+"/youtube/transcript?"+query.Encode(), header, &resp); err != nil {
+		return store.Transcript{}, err
+	}
+
+	t := store.Transcript{Provider: TranscriptSupadata, Language: resp.Lang}
+	for _, seg := range resp.Content {
+		t.Segments = append(t.Segments, store.TranscriptSegment{
+			Text:       seg.Text,
+			StartMs:    int(math.Round(seg.Offset)),
+			DurationMs: int(math.Round(seg.Duration)),
+		})
+	}
+	if t.Text() == "" {
+		return store.Transcript{}, fmt.Errorf("%s: %w", TranscriptSupadata, ErrEmptyTranscript)
+	}
+	return t, nil
+}
+
+// getTranscriptJSON sends a GET request and decodes a 200 response into out.
+// Other statuses are returned as errors carrying the message the provider sent.
+func getTranscriptJSON(ctx context.Context, client *http.Client, provider, reqURL string, header http.Header, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return fmt.Errorf("%s: failed to create request: %v", provider, err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return doTranscriptJSON(client, provider, req, out)
+}
+
+// doTranscriptJSON sends req and decodes a 200 response into out, like getTranscriptJSON.
+func doTranscriptJSON(client *http.Client, provider string, req *http.Request, out any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: failed to send request: %v", provider, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		var apiErr struct {
+			Error   string `json:"error"`
+			Message string `json:"message"`
+		}
+		detail := strings.TrimSpace(string(body))
+		if json.Unmarshal(body, &apiErr) == nil && (apiErr.Message != "" || apiErr.Error != "") {
+			detail = strings.TrimSpace(apiErr.Error + " " + apiErr.Message)
+		}
+		return fmt.Errorf("%s: returned %s: %s", provider, resp.Status, detail)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s: failed to decode response: %v", provider, err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package core_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/demirbey05/auth-demo/internal/core"
+)
+
+const videoLink = "https://www.youtube.com/watch?v=abc123&t=10"
+
+func supadataStub(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/youtube/transcript" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if got := r.Header.Get("x-api-key"); got != "key" {
+			t.Errorf("expected the API key header, got %q", got)
+		}
+		if got := r.URL.Query().Get("url"); got != videoLink {
+			t.Errorf("expected the whole link as url, got %q", got)
+		}
+		if got := r.URL.Query().Get("lang"); got != "tr" {
+			t.Errorf("expected lang=tr, got %q", got)
+		}
+		w.Write([]byte(`{"lang": "tr", "content": [
+			{"text": "Merhaba", "offset": 0, "duration": 1500},
+			{"text": "dünya", "offset": 1500, "duration": 2000}
+		]}`))
+	}))
+}
+
+func TestSupadataTranscriber(t *testing.T) {
+	srv := supadataStub(t)
+	defer srv.Close()
+
+	provider, err := core.NewTranscriptProvider(core.TranscriptConfig{
+		Providers:       []string{core.TranscriptSupadata},
+		SupadataBaseURL: srv.URL,
+		SupadataAPIKey:  "key",
+		Timeout:         time.Second,
+	})
+	if err != nil {
+		t.Fatalf("NewTranscriptProvider failed: %v", err)
+	}
+	transcript, err := provider.FetchTranscript(context.Background(), videoLink, "Turkish")
+	if err != nil {
+		t.Fatalf("FetchTranscript failed: %v", err)
+	}
+	if transcript.Text() != "Merhaba dünya" || transcript.Provider != core.TranscriptSupadata || transcript.Language != "tr" {
+		t.Errorf("unexpected transcript %+v", transcript)
+	}
+	if s := transcript.Segments[1]; s.StartMs != 1500 || s.DurationMs != 2000 {
+		t.Errorf("unexpected segment timing %+v", s)
+	}
+}
+
+func TestTranscriptChainFallsBack(t *testing.T) {
+	selfHosted := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "proxy quota exceeded", http.StatusServiceUnavailable)
+	}))
+	defer selfHosted.Close()
+	supadata := supadataStub(t)
+	defer supadata.Close()
+
+	provider, err := core.NewTranscriptProvider(core.TranscriptConfig{
+		Providers:       []string{core.TranscriptSelfHosted, core.TranscriptSupadata},
+		TranscriberURL:  selfHosted.URL,
+		SupadataBaseURL: supadata.URL,
+		SupadataAPIKey:  "key",
+		Timeout:         time.Second,
+	})
+	if err != nil {
+		t.Fatalf("NewTranscriptProvider failed: %v", err)
+	}
+	transcript, err := provider.FetchTranscript(context.Background(), videoLink, "Turkish")
+	if err != nil {
+		t.Fatalf("FetchTranscript failed: %v", err)
+	}
+	if transcript.Provider != core.TranscriptSupadata {
+		t.Errorf("expected the supadata transcript, got %+v", transcript)
+	}
+}
+
+func TestTranscriptChainReportsEveryFailure(t *testing.T) {
+	selfHosted := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"video_id": "abc123", "transcript": "  "}`))
+	}))
+	defer selfHosted.Close()
+	supadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "transcript-unavailable", "message": "No transcript is available for this video"}`))
+	}))
+	defer supadata.Close()
+
+	provider, err := core.NewTranscriptProvider(core.TranscriptConfig{
+		Providers:       []string{core.TranscriptSelfHosted, core.TranscriptSupadata},
+		TranscriberURL:  selfHosted.URL,
+		SupadataBaseURL: supadata.URL,
+		Timeout:         time.Second,
+	})
+	if err != nil {
+		t.Fatalf("NewTranscriptProvider failed: %v", err)
+	}
+	_, err = provider.FetchTranscript(context.Background(), videoLink, "English")
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	for _, want := range []string{"self_hosted: transcript is empty", "supadata: returned 404 Not Found: transcript-unavailable No transcript is available"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %q", want, err)
+		}
+	}
+}
+
+func TestTranscriptProviderTimesOut(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	provider, err := core.NewTranscriptProvider(core.TranscriptConfig{
+		Providers:      []string{core.TranscriptSelfHosted},
+		TranscriberURL: slow.URL,
+		Timeout:        20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewTranscriptProvider failed: %v", err)
+	}
+	if _, err := provider.FetchTranscript(context.Background(), videoLink, "English"); err == nil {
+		t.Errorf("expected the request to time out")
+	}
+}
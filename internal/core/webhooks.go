@@ -0,0 +1,277 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/demirbey05/auth-demo/internal/store"
+)
+
+// Pod lifecycle events sent to webhooks.
+const (
+	WebhookPodCreated          = "pod.created"
+	WebhookPodArticleGenerated = "pod.article_generated"
+	WebhookPodQuizGenerated    = "pod.quiz_generated"
+	WebhookPodFailed           = "pod.failed"
+)
+
+// WebhookEvents lists every event a webhook can subscribe to.
+var WebhookEvents = []string{WebhookPodCreated, WebhookPodArticleGenerated, WebhookPodQuizGenerated, WebhookPodFailed}
+
+// Headers set on every webhook delivery. The signature is the hex HMAC-SHA256
+// of "<timestamp>.<body>" keyed with the webhook secret.
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// WebhookPayload is the JSON body of a webhook delivery.
+type WebhookPayload struct {
+	Event     string         `json:"event"`
+	CreatedAt time.Time      `json:"created_at"`
+	Data      WebhookPodData `json:"data"`
+}
+
+// WebhookPodData describes the pod an event is about.
+type WebhookPodData struct {
+	PodID     int    `json:"pod_id"`
+	JobID     int    `json:"job_id"`
+	Link      string `json:"link,omitempty"`
+	Language  string `json:"language,omitempty"`
+	ArticleID int    `json:"article_id,omitempty"`
+	QuizID    int    `json:"quiz_id,omitempty"`
+	Stage     string `json:"stage,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ErrInvalidWebhookURL is returned for webhook URLs that are not http(s) or
+// point at a private address.
+var ErrInvalidWebhookURL = errors.New("invalid webhook url")
+
+// ValidateWebhookURL checks a URL a user registers a webhook for. Host names
+// are only resolved when a delivery is sent, where the dialer refuses private
+// addresses again.
+func ValidateWebhookURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrInvalidWebhookURL
+	}
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil && !isAllowedIP(ip, webhookAllowedNetworks()) {
+		return fmt.Errorf("%w: %s is not a public address", ErrInvalidWebhookURL, host)
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		if !isAllowedIP(net.IPv4(127, 0, 0, 1), webhookAllowedNetworks()) {
+			return fmt.Errorf("%w: %s is not a public address", ErrInvalidWebhookURL, host)
+		}
+	}
+	return nil
+}
+
+// webhookAllowedNetworks reads WEBHOOK_ALLOWED_NETWORKS, comma-separated CIDRs
+// webhooks may reach although they are private, such as 127.0.0.0/8 to test
+// against a local server.
+func webhookAllowedNetworks() []*net.IPNet {
+	var allowed []*net.IPNet
+	for _, cidr := range strings.Split(os.Getenv("WEBHOOK_ALLOWED_NETWORKS"), ",") {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			fmt.Printf("webhooks: ignoring allowed network %q: %v\n", cidr, err)
+			continue
+		}
+		allowed = append(allowed, network)
+	}
+	return allowed
+}
+
+// webhookClient posts deliveries. Users choose the URLs, so like the web page
+// client it only connects to public addresses and those in allowed.
+func webhookClient(allowed []*net.IPNet) *http.Client {
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:           publicDialer(allowed).DialContext,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// SignWebhookPayload returns the value of the signature header for body.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// emitWebhook queues event for every webhook of userID subscribed to it.
+// Deliveries are written through webhookStore, so an event emitted inside a
+// transaction is only sent if the transaction commits. Failures are logged
+// and never fail the caller.
+func emitWebhook(ctx context.Context, webhookStore store.WebhookStore, userID, event string, data WebhookPodData) {
+	if webhookStore == nil || userID == "" {
+		return
+	}
+	payload, err := json.Marshal(WebhookPayload{Event: event, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if _, err := webhookStore.EnqueueWebhookEvent(ctx, userID, event, payload); err != nil {
+		fmt.Printf("error queueing webhook event %s for pod %d: %v\n", event, data.PodID, err)
+	}
+}
+
+// WebhookDispatcher sends pending webhook deliveries. Failed deliveries are
+// retried with the policy's backoff and marked failed once it is exhausted.
+type WebhookDispatcher struct {
+	webhookStore store.WebhookStore
+	client       *http.Client
+	retryPolicy  RetryPolicy
+	pollInterval time.Duration
+	batchSize    int
+
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+func NewWebhookDispatcher(webhookStore store.WebhookStore, retryPolicy RetryPolicy, pollInterval time.Duration) *WebhookDispatcher {
+	if retryPolicy.MaxAttempts < 1 {
+		retryPolicy.MaxAttempts = 1
+	}
+	return &WebhookDispatcher{
+		webhookStore: webhookStore,
+		client:       webhookClient(webhookAllowedNetworks()),
+		retryPolicy:  retryPolicy,
+		pollInterval: pollInterval,
+		batchSize:    20,
+	}
+}
+
+// Start polls for due deliveries until Shutdown is called.
+func (d *WebhookDispatcher) Start() {
+	ctx, stop := context.WithCancel(context.Background())
+	d.stop = stop
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		for {
+			if _, err := d.DispatchPending(ctx); err != nil && ctx.Err() == nil {
+				fmt.Printf("webhooks: error dispatching deliveries: %v\n", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(d.pollInterval):
+			}
+		}
+	}()
+}
+
+// Shutdown stops polling and waits for the deliveries in flight.
+func (d *WebhookDispatcher) Shutdown(ctx context.Context) error {
+	if d.stop == nil {
+		return nil
+	}
+	d.stop()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// DispatchPending sends one batch of due deliveries and returns how many were attempted.
+func (d *WebhookDispatcher) DispatchPending(ctx context.Context) (int, error) {
+	// The lease outlives one request, so a delivery is not sent twice while in flight
+	deliveries, err := d.webhookStore.ClaimWebhookDeliveries(ctx, d.batchSize, 2*d.client.Timeout)
+	if err != nil {
+		return 0, err
+	}
+	for _, delivery := range deliveries {
+		d.deliver(ctx, delivery)
+	}
+	return len(deliveries), nil
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery store.PendingWebhookDelivery) {
+	code, err := d.send(ctx, delivery)
+	// Record the outcome even if the dispatcher is being stopped
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	if err == nil {
+		if err := d.webhookStore.MarkWebhookDelivered(recordCtx, delivery.ID, code); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+
+	attempt := delivery.Attempts + 1
+	if attempt >= d.retryPolicy.MaxAttempts {
+		fmt.Printf("webhooks: delivery %d failed after %d attempts: %v\n", delivery.ID, attempt, err)
+		if err := d.webhookStore.FailWebhookDelivery(recordCtx, delivery.ID, code, err.Error()); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+	if err := d.webhookStore.RetryWebhookDelivery(recordCtx, delivery.ID, code, err.Error(), d.retryPolicy.Delay(attempt)); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// send posts the delivery and returns the response status, or 0 if there was no response.
+func (d *WebhookDispatcher) send(ctx context.Context, delivery store.PendingWebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "auth-demo-webhooks")
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookDeliveryHeader, strconv.Itoa(delivery.ID))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
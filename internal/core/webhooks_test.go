@@ -0,0 +1,175 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/demirbey05/auth-demo/internal/core"
+	"github.com/demirbey05/auth-demo/internal/store"
+)
+
+// fakeWebhookStore hands out a fixed set of deliveries and records their outcome.
+type fakeWebhookStore struct {
+	store.WebhookStore
+
+	mu        sync.Mutex
+	pending   []store.PendingWebhookDelivery
+	delivered map[int]int
+	retried   map[int]time.Duration
+	failed    map[int]string
+}
+
+func newFakeWebhookStore(pending ...store.PendingWebhookDelivery) *fakeWebhookStore {
+	return &fakeWebhookStore{
+		pending:   pending,
+		delivered: make(map[int]int),
+		retried:   make(map[int]time.Duration),
+		failed:    make(map[int]string),
+	}
+}
+
+func (s *fakeWebhookStore) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]store.PendingWebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	claimed := s.pending
+	s.pending = nil
+	return claimed, nil
+}
+
+func (s *fakeWebhookStore) MarkWebhookDelivered(ctx context.Context, deliveryID, statusCode int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delivered[deliveryID] = statusCode
+	return nil
+}
+
+func (s *fakeWebhookStore) RetryWebhookDelivery(ctx context.Context, deliveryID, statusCode int, lastError string, delay time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retried[deliveryID] = delay
+	return nil
+}
+
+func (s *fakeWebhookStore) FailWebhookDelivery(ctx context.Context, deliveryID, statusCode int, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed[deliveryID] = lastError
+	return nil
+}
+
+func TestWebhookDispatcherSignsDeliveries(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOWED_NETWORKS", "127.0.0.0/8, ::1/128")
+	payload := []byte(`{"event":"pod.created","data":{"pod_id":7}}`)
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	webhookStore := newFakeWebhookStore(store.PendingWebhookDelivery{
+		ID: 1, WebhookID: 3, Event: core.WebhookPodCreated, Payload: payload, URL: srv.URL, Secret: "s3cret",
+	})
+	dispatcher := core.NewWebhookDispatcher(webhookStore, core.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second}, time.Second)
+
+	n, err := dispatcher.DispatchPending(context.Background())
+	if err != nil {
+		t.Fatalf("DispatchPending failed: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 delivery, got %d", n)
+	}
+	if got == nil {
+		t.Fatal("webhook endpoint was not called")
+	}
+
+	if string(body) != string(payload) {
+		t.Errorf("expected body %s, got %s", payload, body)
+	}
+	if event := got.Header.Get(core.WebhookEventHeader); event != core.WebhookPodCreated {
+		t.Errorf("expected event header %s, got %s", core.WebhookPodCreated, event)
+	}
+	timestamp, err := strconv.ParseInt(got.Header.Get(core.WebhookTimestampHeader), 10, 64)
+	if err != nil {
+		t.Fatalf("invalid timestamp header: %v", err)
+	}
+	if sig := got.Header.Get(core.WebhookSignatureHeader); sig != core.SignWebhookPayload("s3cret", timestamp, body) {
+		t.Errorf("signature %s does not match the body", sig)
+	}
+	if code, ok := webhookStore.delivered[1]; !ok || code != http.StatusNoContent {
+		t.Errorf("expected delivery 1 to be marked delivered with 204, got %v", webhookStore.delivered)
+	}
+}
+
+func TestWebhookDispatcherRetriesThenFails(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOWED_NETWORKS", "127.0.0.0/8, ::1/128")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	webhookStore := newFakeWebhookStore(
+		store.PendingWebhookDelivery{ID: 1, Event: core.WebhookPodFailed, Payload: []byte(`{}`), URL: srv.URL, Attempts: 0},
+		store.PendingWebhookDelivery{ID: 2, Event: core.WebhookPodFailed, Payload: []byte(`{}`), URL: srv.URL, Attempts: 2},
+	)
+	dispatcher := core.NewWebhookDispatcher(webhookStore, core.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute}, time.Second)
+
+	if _, err := dispatcher.DispatchPending(context.Background()); err != nil {
+		t.Fatalf("DispatchPending failed: %v", err)
+	}
+
+	if delay, ok := webhookStore.retried[1]; !ok || delay <= 0 {
+		t.Errorf("expected delivery 1 to be retried with a delay, got %v", webhookStore.retried)
+	}
+	if _, ok := webhookStore.failed[2]; !ok {
+		t.Errorf("expected delivery 2 to be failed after its last attempt, got %v", webhookStore.failed)
+	}
+	if len(webhookStore.delivered) != 0 {
+		t.Errorf("expected no delivered webhooks, got %v", webhookStore.delivered)
+	}
+}
+
+func TestWebhookDispatcherRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("expected no request to reach a loopback server")
+	}))
+	defer srv.Close()
+
+	webhookStore := newFakeWebhookStore(store.PendingWebhookDelivery{ID: 1, Event: core.WebhookPodFailed, Payload: []byte(`{}`), URL: srv.URL})
+	dispatcher := core.NewWebhookDispatcher(webhookStore, core.RetryPolicy{MaxAttempts: 1}, time.Second)
+	if _, err := dispatcher.DispatchPending(context.Background()); err != nil {
+		t.Fatalf("DispatchPending failed: %v", err)
+	}
+	if _, ok := webhookStore.failed[1]; !ok {
+		t.Errorf("expected the delivery to fail, got %v", webhookStore.failed)
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	for _, link := range []string{"https://example.com/hooks", "http://93.184.216.34:8080/"} {
+		if err := core.ValidateWebhookURL(link); err != nil {
+			t.Errorf("expected %q to be accepted, got %v", link, err)
+		}
+	}
+	refused := []string{"ftp://example.com", "not a url", "http://localhost:8080", "http://127.0.0.1/", "http://[::1]/",
+		"http://10.0.0.5/", "http://169.254.169.254/latest/meta-data", "http://0.0.0.0/"}
+	for _, link := range refused {
+		if err := core.ValidateWebhookURL(link); !errors.Is(err, core.ErrInvalidWebhookURL) {
+			t.Errorf("expected %q to be refused, got %v", link, err)
+		}
+	}
+
+	t.Setenv("WEBHOOK_ALLOWED_NETWORKS", "127.0.0.0/8")
+	if err := core.ValidateWebhookURL("http://localhost:8080"); err != nil {
+		t.Errorf("expected an allowed network to be accepted, got %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/demirbey05/auth-demo/internal/store"
+)
+
+// ReasonInterrupted is the failure reason of jobs that were cut off too many
+// times by a crash or restart.
+const ReasonInterrupted = "generation was interrupted"
+
+// runningStages are the stages a job is in while a worker holds it.
+var runningStages = []string{StageFetchingTranscript, StageClassifying, StageGeneratingArticle, StageGeneratingQuiz}
+
+// StalePolicy decides when a running job was abandoned by a worker that died.
+type StalePolicy struct {
+	// After is how long a job may go untouched before it counts as stale.
+	// It must exceed the job timeout so jobs still running elsewhere are
+	// not touched.
+	After time.Duration
+	// MaxClaims is how often a job may be picked up before stale is final.
+	MaxClaims int
+	// Interval is how often the pool looks for stale jobs.
+	Interval time.Duration
+}
+
+// WorkerPool runs queued pod jobs in the background. Jobs are claimed from
+// Postgres, so any number of pools (and server instances) can share one queue.
+type WorkerPool struct {
+	pipeline     *Pipeline
+	jobStore     store.JobStore
+	size         int
+	pollInterval time.Duration
+	stale        StalePolicy
+	wg           sync.WaitGroup
+
+	// stopClaiming stops the claim loops; cancelJobs interrupts running jobs.
+	stopClaiming context.CancelFunc
+	cancelJobs   context.CancelFunc
+}
+
+func NewWorkerPool(pipeline *Pipeline, jobStore store.JobStore, size int, pollInterval time.Duration, stale StalePolicy) *WorkerPool {
+	if size < 1 {
+		size = 1
+	}
+	if stale.MaxClaims < 1 {
+		stale.MaxClaims = 1
+	}
+	return &WorkerPool{pipeline: pipeline, jobStore: jobStore, size: size, pollInterval: pollInterval, stale: stale}
+}
+
+// Start launches the workers and, when the stale policy has an interval, the
+// sweep for stale jobs. They run until Shutdown is called.
+func (p *WorkerPool) Start() {
+	claimCtx, stopClaiming := context.WithCancel(context.Background())
+	jobCtx, cancelJobs := context.WithCancel(context.Background())
+	p.stopClaiming, p.cancelJobs = stopClaiming, cancelJobs
+
+	for i := 0; i < p.size; i++ {
+		p.wg.Add(1)
+		go p.work(claimCtx, jobCtx, i)
+	}
+	if p.stale.Interval > 0 {
+		p.wg.Add(1)
+		go p.sweep(claimCtx)
+	}
+}
+
+// sweep recovers stale jobs every stale.Interval, so jobs of an instance that
+// died are picked up without waiting for a restart.
+func (p *WorkerPool) sweep(ctx context.Context) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.stale.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		recoverCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		if err := p.Recover(recoverCtx); err != nil {
+			fmt.Printf("worker pool: %v\n", err)
+		}
+		cancel()
+	}
+}
+
+// Wait blocks until every worker has returned.
+func (p *WorkerPool) Wait() {
+	p.wg.Wait()
+}
+
+// Shutdown stops claiming new jobs and waits for the running ones to finish.
+// When ctx expires first, running jobs are interrupted and put back in the
+// queue, and ctx.Err() is returned.
+func (p *WorkerPool) Shutdown(ctx context.Context) error {
+	if p.stopClaiming == nil {
+		return nil
+	}
+	p.stopClaiming()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		p.cancelJobs()
+		return nil
+	case <-ctx.Done():
+	}
+
+	fmt.Println("worker pool: shutdown timed out, interrupting running jobs")
+	p.cancelJobs()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		fmt.Println("worker pool: workers did not stop in time")
+	}
+	return ctx.Err()
+}
+
+// Recover cleans up jobs left running by a process that died without
+// releasing them. Jobs untouched for stale.After go back to the queue while
+// they were claimed fewer than stale.MaxClaims times; the rest are
+// dead-lettered and refunded.
+func (p *WorkerPool) Recover(ctx context.Context) error {
+	filter := store.StaleJobFilter{
+		Stages:     runningStages,
+		StaleAfter: p.stale.After,
+		MaxClaims:  p.stale.MaxClaims,
+	}
+
+	requeued, err := p.jobStore.RequeueStaleJobs(ctx, filter, StageQueued, "worker stopped while running the job")
+	if err != nil {
+		return fmt.Errorf("error requeueing stale jobs: %w", err)
+	}
+	for _, jobID := range requeued {
+		fmt.Printf("worker pool: requeued stale job %d\n", jobID)
+	}
+
+	failed, err := p.jobStore.FailStaleJobs(ctx, filter, StageDeadLetter, ReasonInterrupted, "worker stopped while running the job")
+	if err != nil {
+		return fmt.Errorf("error failing stale jobs: %w", err)
+	}
+	for _, job := range failed {
+		fmt.Printf("worker pool: dead-lettered stale job %d\n", job.ID)
+		p.pipeline.fail(ctx, job, StageDeadLetter, ReasonInterrupted, 0)
+	}
+	return nil
+}
+
+func (p *WorkerPool) work(claimCtx, jobCtx context.Context, id int) {
+	defer p.wg.Done()
+	for {
+		if claimCtx.Err() != nil {
+			return
+		}
+
+		job, err := p.jobStore.ClaimJob(claimCtx, StageQueued, StageFetchingTranscript)
+		if err != nil {
+			if !errors.Is(err, store.ErrNoJob) && claimCtx.Err() == nil {
+				fmt.Printf("worker %d: error claiming job: %v\n", id, err)
+			}
+			select {
+			case <-claimCtx.Done():
+				return
+			case <-time.After(p.pollInterval):
+			}
+			continue
+		}
+
+		fmt.Printf("worker %d: running job %d for pod %d\n", id, job.ID, job.PodID)
+		err = p.pipeline.Run(jobCtx, job)
+		if errors.Is(err, ErrJobInterrupted) {
+			p.release(job, id)
+			continue
+		}
+		if err != nil {
+			fmt.Printf("worker %d: job %d failed: %v\n", id, job.ID, err)
+		}
+	}
+}
+
+// release hands an interrupted job back to the queue so another worker, or
+// the next server process, picks it up.
+func (p *WorkerPool) release(job store.Job, id int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := p.jobStore.ReleaseJob(ctx, job.ID, StageQueued, "interrupted by shutdown"); err != nil {
+		fmt.Printf("worker %d: error releasing job %d: %v\n", id, job.ID, err)
+		return
+	}
+	fmt.Printf("worker %d: released job %d\n", id, job.ID)
+	p.pipeline.events.Publish(PodEvent{Type: EventStage, PodID: job.PodID, JobID: job.ID, Stage: StageQueued})
+}
@@ -13,14 +13,27 @@ import (
 // YouTubeVideoResponse represents the structure of the YouTube API response
 type YouTubeVideoResponse struct {
 	Items []struct {
-		Snippet struct {
-			Title string `json:"title"`
-		} `json:"snippet"`
+		Snippet YouTubeSnippet `json:"snippet"`
 	} `json:"items"`
 }
 
+// YouTubeSnippet holds the video details used when creating a pod.
+type YouTubeSnippet struct {
+	Title      string `json:"title"`
+	CategoryID string `json:"categoryId"`
+}
+
 // GetYouTubeVideoTitle fetches the title of a YouTube video using the YouTube Data API
 func GetYouTubeVideoTitle(videoURL string) (string, error) {
+	snippet, err := GetYouTubeVideoSnippet(videoURL)
+	if err != nil {
+		return "", err
+	}
+	return snippet.Title, nil
+}
+
+// GetYouTubeVideoSnippet fetches the title and category of a YouTube video using the YouTube Data API
+func GetYouTubeVideoSnippet(videoURL string) (YouTubeSnippet, error) {
 
 	apiKey := os.Getenv("YOUTUBE_API_KEY")
 	// Extract video ID from the URL
@@ -35,29 +48,28 @@ func GetYouTubeVideoTitle(videoURL string) (string, error) {
 	// Make the HTTP GET request
 	resp, err := http.Get(apiURL)
 	if err != nil {
-		return "", fmt.Errorf("failed to make API request: %v", err)
+		return YouTubeSnippet{}, fmt.Errorf("failed to make API request: %v", err)
 	}
 	defer resp.Body.Close()
 
 	// Read the response body
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %v", err)
+		return YouTubeSnippet{}, fmt.Errorf("failed to read response body: %v", err)
 	}
 
 	// Parse the JSON response
 	var videoResponse YouTubeVideoResponse
 	if err := json.Unmarshal(body, &videoResponse); err != nil {
-		return "", fmt.Errorf("failed to parse JSON response: %v", err)
+		return YouTubeSnippet{}, fmt.Errorf("failed to parse JSON response: %v", err)
 	}
 
 	// Check if the video was found
 	if len(videoResponse.Items) == 0 {
-		return "", fmt.Errorf("video not found")
+		return YouTubeSnippet{}, fmt.Errorf("video not found")
 	}
 
-	// Return the video title
-	return videoResponse.Items[0].Snippet.Title, nil
+	return videoResponse.Items[0].Snippet, nil
 }
 
 // VideoListResponse is used to parse the YouTube API response.
@@ -177,6 +189,9 @@ func GetYouTubeVideoDuration(canonicalURL string) (int, error) {
 	return dur, nil
 }
 
+// costPerMinute is the credit price of a minute of video.
+const costPerMinute = 25
+
 // GetYouTubeVideoDurationMinutes fetches the video duration from the YouTube Data API
 // and returns the duration in whole minutes (rounded up).
 func CalculateCost(canonicalURL string) (int, error) {
@@ -185,5 +200,5 @@ func CalculateCost(canonicalURL string) (int, error) {
 		return 0, err
 	}
 	// round to nearest minute
-	return dur * 25, nil
+	return dur * costPerMinute, nil
 }
@@ -0,0 +1,80 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// FakeArticle and FakeQuiz are the canned replies of a Fake without a Reply func.
+const (
+	FakeArticle = "## A Fake Article\n\nThis article was written by the fake LLM provider.\n\n### Key Points\n\n- **Determinism** makes tests repeatable\n- No network access is needed"
+	FakeQuiz    = `{"questions": [
+	{"question": "Question 1", "options": ["A", "B", "C", "D"], "true_answer_index": 0, "explanation": "Because it is."},
+	{"question": "Question 2", "options": ["A", "B", "C", "D"], "true_answer_index": 1, "explanation": "Because it is."},
+	{"question": "Question 3", "options": ["A", "B", "C", "D"], "true_answer_index": 2, "explanation": "Because it is."},
+	{"question": "Question 4", "options": ["A", "B", "C", "D"], "true_answer_index": 3, "explanation": "Because it is."},
+	{"question": "Question 5", "options": ["A", "B", "C", "D"], "true_answer_index": 0, "explanation": "Because it is."},
+	{"question": "Question 6", "options": ["A", "B", "C", "D"], "true_answer_index": 1, "explanation": "Because it is."},
+	{"question": "Question 7", "options": ["A", "B", "C", "D"], "true_answer_index": 2, "explanation": "Because it is."}
+]}`
+)
+
+// FakeVerdict is the canned reply of a Fake without a Reply func to content classification prompts.
+const FakeVerdict = `{"educational": true, "category": "lecture", "confidence": 0.9, "reason": "The fake LLM provider accepts everything."}`
+
+// FakeModel is the model name reported by Fake.
+const FakeModel = "fake"
+
+// Fake is a deterministic provider for tests and offline development. It
+// records every request it receives.
+type Fake struct {
+	// Reply produces the answer to a request. When nil, quiz prompts get
+	// FakeQuiz, classification prompts get FakeVerdict and anything else
+	// gets FakeArticle.
+	Reply func(req Request) (string, error)
+
+	mu       sync.Mutex
+	requests []Request
+}
+
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+func (f *Fake) Generate(ctx context.Context, req Request) (Response, error) {
+	if err := ctx.Err(); err != nil {
+		return Response{}, err
+	}
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	f.mu.Unlock()
+
+	var text string
+	switch {
+	case f.Reply != nil:
+		var err error
+		if text, err = f.Reply(req); err != nil {
+			return Response{}, err
+		}
+	case strings.Contains(req.Prompt, "true_answer_index"):
+		text = FakeQuiz
+	case strings.Contains(req.Prompt, `"confidence"`):
+		text = FakeVerdict
+	default:
+		text = FakeArticle
+	}
+	input, output := EstimateTokens(req.Prompt), EstimateTokens(text)
+	return Response{
+		Text:  text,
+		Model: FakeModel,
+		Usage: Usage{InputTokens: input, OutputTokens: output, TotalTokens: input + output},
+	}, nil
+}
+
+// Requests returns the requests received so far.
+func (f *Fake) Requests() []Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Request(nil), f.requests...)
+}
@@ -0,0 +1,81 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+)
+
+// DefaultGeminiModel is used when LLM_MODEL is not set.
+const DefaultGeminiModel = "gemini-2.5-flash-preview-04-17"
+
+// Gemini talks to Google's Gemini API through one shared client.
+type Gemini struct {
+	client *genai.Client
+	cfg    Config
+}
+
+func NewGemini(ctx context.Context, cfg Config) (*Gemini, error) {
+	if cfg.APIKey == "" {
+		return nil, ErrMissingAPIKey
+	}
+	if cfg.Model == "" {
+		cfg.Model = DefaultGeminiModel
+	}
+	client, err := genai.NewClient(ctx, option.WithAPIKey(cfg.APIKey))
+	if err != nil {
+		return nil, fmt.Errorf("error creating gemini client: %v", err)
+	}
+	return &Gemini{client: client, cfg: cfg}, nil
+}
+
+func (g *Gemini) Generate(ctx context.Context, req Request) (Response, error) {
+	model := g.client.GenerativeModel(g.cfg.Model)
+	model.SetTemperature(g.cfg.Temperature)
+	model.SetTopK(g.cfg.TopK)
+	model.SetTopP(g.cfg.TopP)
+	model.SetMaxOutputTokens(g.cfg.MaxOutputTokens)
+	model.ResponseMIMEType = "text/plain"
+	if req.Schema != nil {
+		model.ResponseMIMEType = "application/json"
+		model.ResponseSchema = req.Schema.genai()
+	}
+
+	resp, err := model.GenerateContent(ctx, genai.Text(req.Prompt))
+	if err != nil {
+		return Response{}, fmt.Errorf("error sending message: %v", err)
+	}
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return Response{}, errors.New("gemini returned no candidates")
+	}
+
+	var text strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		text.WriteString(fmt.Sprintf("%v", part))
+	}
+	out := Response{Text: text.String(), Model: g.cfg.Model}
+	if resp.UsageMetadata != nil {
+		out.Usage = Usage{
+			InputTokens:  int(resp.UsageMetadata.PromptTokenCount),
+			OutputTokens: int(resp.UsageMetadata.CandidatesTokenCount),
+			TotalTokens:  int(resp.UsageMetadata.TotalTokenCount),
+		}
+	}
+	return out, nil
+}
+
+func (g *Gemini) CountTokens(ctx context.Context, text string) (int, error) {
+	resp, err := g.client.GenerativeModel(g.cfg.Model).CountTokens(ctx, genai.Text(text))
+	if err != nil {
+		return 0, err
+	}
+	return int(resp.TotalTokens), nil
+}
+
+func (g *Gemini) Close() error {
+	return g.client.Close()
+}
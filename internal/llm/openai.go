@@ -0,0 +1,136 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultOpenAIBaseURL is used when LLM_BASE_URL is not set.
+const DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+// OpenAI talks to any server implementing the OpenAI chat completions API,
+// such as a local llama.cpp, vLLM or Ollama instance.
+type OpenAI struct {
+	client  *http.Client
+	baseURL string
+	cfg     Config
+}
+
+func NewOpenAI(cfg Config) (*OpenAI, error) {
+	if cfg.Model == "" {
+		return nil, errors.New("LLM_MODEL is required for the openai provider")
+	}
+	baseURL := strings.TrimSuffix(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = DefaultOpenAIBaseURL
+		// Only local servers can do without a key
+		if cfg.APIKey == "" {
+			return nil, ErrMissingAPIKey
+		}
+	}
+	return &OpenAI{client: &http.Client{Timeout: 5 * time.Minute}, baseURL: baseURL, cfg: cfg}, nil
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model          string          `json:"model"`
+	Messages       []chatMessage   `json:"messages"`
+	Temperature    float32         `json:"temperature"`
+	TopP           float32         `json:"top_p"`
+	MaxTokens      int32           `json:"max_tokens,omitempty"`
+	ResponseFormat *responseFormat `json:"response_format,omitempty"`
+}
+
+type responseFormat struct {
+	Type       string           `json:"type"`
+	JSONSchema *namedJSONSchema `json:"json_schema,omitempty"`
+}
+
+type namedJSONSchema struct {
+	Name   string         `json:"name"`
+	Schema map[string]any `json:"schema"`
+}
+
+type chatResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+func (o *OpenAI) Generate(ctx context.Context, req Request) (Response, error) {
+	chat := chatRequest{
+		Model:       o.cfg.Model,
+		Messages:    []chatMessage{{Role: "user", Content: req.Prompt}},
+		Temperature: o.cfg.Temperature,
+		TopP:        o.cfg.TopP,
+		MaxTokens:   o.cfg.MaxOutputTokens,
+	}
+	if req.Schema != nil {
+		chat.ResponseFormat = &responseFormat{
+			Type:       "json_schema",
+			JSONSchema: &namedJSONSchema{Name: "response", Schema: req.Schema.jsonSchema()},
+		}
+	}
+	body, err := json.Marshal(chat)
+	if err != nil {
+		return Response{}, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to create request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if o.cfg.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+o.cfg.APIKey)
+	}
+
+	resp, err := o.client.Do(httpReq)
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return Response{}, fmt.Errorf("model server returned %s: %s", resp.Status, msg)
+	}
+
+	var completion chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+		return Response{}, fmt.Errorf("error decoding model response: %v", err)
+	}
+	if len(completion.Choices) == 0 {
+		return Response{}, errors.New("model server returned no choices")
+	}
+	model := completion.Model
+	if model == "" {
+		model = o.cfg.Model
+	}
+	return Response{
+		Text:  completion.Choices[0].Message.Content,
+		Model: model,
+		Usage: Usage{
+			InputTokens:  completion.Usage.PromptTokens,
+			OutputTokens: completion.Usage.CompletionTokens,
+			TotalTokens:  completion.Usage.TotalTokens,
+		},
+	}, nil
+}
@@ -0,0 +1,84 @@
+package llm
+
+import "github.com/google/generative-ai-go/genai"
+
+// Schema types, named as in JSON Schema.
+const (
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+)
+
+// Schema describes the JSON a request must be answered with. It covers the
+// subset of JSON Schema that every provider can enforce.
+type Schema struct {
+	Type        string
+	Description string
+	Enum        []string
+	Items       *Schema
+	Properties  map[string]*Schema
+	Required    []string
+}
+
+func (s *Schema) genai() *genai.Schema {
+	if s == nil {
+		return nil
+	}
+	types := map[string]genai.Type{
+		TypeObject:  genai.TypeObject,
+		TypeArray:   genai.TypeArray,
+		TypeString:  genai.TypeString,
+		TypeInteger: genai.TypeInteger,
+		TypeNumber:  genai.TypeNumber,
+		TypeBoolean: genai.TypeBoolean,
+	}
+	out := &genai.Schema{
+		Type:        types[s.Type],
+		Description: s.Description,
+		Enum:        s.Enum,
+		Items:       s.Items.genai(),
+		Required:    s.Required,
+	}
+	if len(s.Enum) > 0 {
+		// Gemini only honours Enum on strings with the enum format
+		out.Format = "enum"
+	}
+	if s.Properties != nil {
+		out.Properties = make(map[string]*genai.Schema, len(s.Properties))
+		for name, prop := range s.Properties {
+			out.Properties[name] = prop.genai()
+		}
+	}
+	return out
+}
+
+// jsonSchema renders s as a JSON Schema document.
+func (s *Schema) jsonSchema() map[string]any {
+	if s == nil {
+		return nil
+	}
+	out := map[string]any{"type": s.Type}
+	if s.Description != "" {
+		out["description"] = s.Description
+	}
+	if len(s.Enum) > 0 {
+		out["enum"] = s.Enum
+	}
+	if s.Items != nil {
+		out["items"] = s.Items.jsonSchema()
+	}
+	if s.Properties != nil {
+		props := make(map[string]any, len(s.Properties))
+		for name, prop := range s.Properties {
+			props[name] = prop.jsonSchema()
+		}
+		out["properties"] = props
+	}
+	if len(s.Required) > 0 {
+		out["required"] = s.Required
+	}
+	return out
+}
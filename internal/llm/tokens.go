@@ -0,0 +1,32 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+// TokenCounter is implemented by providers that can count tokens the way
+// their model does.
+type TokenCounter interface {
+	CountTokens(ctx context.Context, text string) (int, error)
+}
+
+// CountTokens asks provider for the token count of text and falls back to
+// EstimateTokens when the provider cannot count.
+func CountTokens(ctx context.Context, provider Provider, text string) (int, error) {
+	counter, ok := provider.(TokenCounter)
+	if !ok {
+		return EstimateTokens(text), nil
+	}
+	n, err := counter.CountTokens(ctx, text)
+	if err != nil {
+		return 0, fmt.Errorf("error counting tokens: %v", err)
+	}
+	return n, nil
+}
+
+// EstimateTokens approximates a token count at four bytes per token, which
+// is close for English and errs on the high side for most other scripts.
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
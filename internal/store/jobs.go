@@ -0,0 +1,242 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/demirbey05/auth-demo/db"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ErrNoJob is returned by ClaimJob when there is no job waiting to be picked up.
+var ErrNoJob = errors.New("no job to claim")
+
+// ErrJobSuperseded is returned by RequeueJob when a newer job exists for the same pod.
+var ErrJobSuperseded = errors.New("a newer job exists for the pod")
+
+type JobStore interface {
+	ClaimJob(ctx context.Context, stage, newStage string) (Job, error)
+	ListJobs(ctx context.Context, stage string, limit, offset int) ([]JobDetail, error)
+	RequeueJob(ctx context.Context, jobID int, fromStages []string, toStage string) error
+	ReleaseJob(ctx context.Context, jobID int, toStage, lastError string) error
+	RequeueStaleJobs(ctx context.Context, filter StaleJobFilter, toStage, lastError string) ([]int, error)
+	FailStaleJobs(ctx context.Context, filter StaleJobFilter, toStage, reason, lastError string) ([]Job, error)
+}
+
+// StaleJobFilter selects running jobs that have not been touched for a while,
+// usually because the process running them died.
+type StaleJobFilter struct {
+	Stages     []string
+	StaleAfter time.Duration
+	// MaxClaims is how often a job may be picked up before it is given up on.
+	MaxClaims int
+}
+
+// Job is a claimed pod job together with what the pipeline needs to run it.
+type Job struct {
+	ID       int
+	PodID    int
+	UserID   string
+	Link     string
+	Language string
+	Cost     int
+	// ChargedCredits is what the user was actually debited, 0 until the
+	// pipeline charges the job's Cost.
+	ChargedCredits int
+	Quiz           QuizSettings
+}
+
+// JobStatus is the externally visible state of a pod job.
+type JobStatus struct {
+	ID    int    `json:"job_id"`
+	PodID int    `json:"pod_id"`
+	Stage string `json:"stage"`
+	Error string `json:"error,omitempty"`
+	// Attempts counts the stage retries of the job and Claims how often a
+	// worker picked it up.
+	Attempts int `json:"attempts"`
+	Claims   int `json:"claims"`
+	// ChunksDone and ChunksTotal report progress through a long transcript
+	// that is generated in chunks; both are 0 otherwise.
+	ChunksDone  int        `json:"chunks_done,omitempty"`
+	ChunksTotal int        `json:"chunks_total,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+}
+
+// JobDetail is the operator view of a job, including the last raw error and the pod it belongs to.
+type JobDetail struct {
+	JobStatus
+	LastError string `json:"last_error,omitempty"`
+	Link      string `json:"link"`
+	Language  string `json:"language"`
+	CreatedBy string `json:"created_by"`
+}
+
+func newJobStatus(job db.Job) JobStatus {
+	return JobStatus{
+		ID:          int(job.ID),
+		PodID:       int(job.PodID),
+		Stage:       job.Stage,
+		Error:       job.ErrorReason.String,
+		Attempts:    int(job.Attempts),
+		Claims:      int(job.Claims),
+		ChunksDone:  int(job.ChunksDone),
+		ChunksTotal: int(job.ChunksTotal),
+		CreatedAt:   job.CreatedAt.Time,
+		StartedAt:   timePtr(job.StartedAt),
+		FinishedAt:  timePtr(job.FinishedAt),
+	}
+}
+
+func timePtr(t pgtype.Timestamp) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+type DBJobStore struct {
+	queries *db.Queries
+}
+
+func NewDBJobStore(queries *db.Queries) *DBJobStore {
+	return &DBJobStore{queries: queries}
+}
+
+// ClaimJob atomically moves the oldest job in stage to newStage and returns it.
+// Rows locked by another worker are skipped, so concurrent callers never claim the same job.
+func (s *DBJobStore) ClaimJob(ctx context.Context, stage, newStage string) (Job, error) {
+	job, err := s.queries.ClaimJob(ctx, db.ClaimJobParams{Stage: stage, NewStage: newStage})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Job{}, ErrNoJob
+		}
+		return Job{}, err
+	}
+	return Job{
+		ID:             int(job.ID),
+		PodID:          int(job.PodID),
+		UserID:         job.CreatedBy,
+		Link:           job.Link,
+		Language:       job.Language,
+		Cost:           int(job.Cost),
+		ChargedCredits: int(job.ChargedCredits),
+		Quiz:           newQuizSettings(job.QuestionCount, job.QuizDifficulty),
+	}, nil
+}
+
+func (s *DBJobStore) ListJobs(ctx context.Context, stage string, limit, offset int) ([]JobDetail, error) {
+	rows, err := s.queries.ListJobsByStage(ctx, db.ListJobsByStageParams{Stage: stage, Limit: int32(limit), Offset: int32(offset)})
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]JobDetail, len(rows))
+	for i, row := range rows {
+		jobs[i] = JobDetail{
+			JobStatus: JobStatus{
+				ID:         int(row.ID),
+				PodID:      int(row.PodID),
+				Stage:      row.Stage,
+				Error:      row.ErrorReason.String,
+				Attempts:   int(row.Attempts),
+				Claims:     int(row.Claims),
+				CreatedAt:  row.CreatedAt.Time,
+				StartedAt:  timePtr(row.StartedAt),
+				FinishedAt: timePtr(row.FinishedAt),
+			},
+			LastError: row.LastError.String,
+			Link:      row.Link,
+			Language:  row.Language,
+			CreatedBy: row.CreatedBy,
+		}
+	}
+	return jobs, nil
+}
+
+// RequeueJob moves a job from one of fromStages back to toStage with fresh
+// retry and claim budgets. It returns ErrNoJob when the job does not exist or
+// is in another stage, and ErrJobSuperseded when the pod has a newer job. It
+// locks the pod like a retry does, so it must run in a transaction.
+func (s *DBJobStore) RequeueJob(ctx context.Context, jobID int, fromStages []string, toStage string) error {
+	if _, err := s.queries.LockJobPod(ctx, int32(jobID)); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNoJob
+		}
+		return fmt.Errorf("error locking pod: %w", err)
+	}
+	_, err := s.queries.RequeueJob(ctx, db.RequeueJobParams{ID: int32(jobID), QueuedStage: toStage, FromStages: fromStages})
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	newer, err := s.queries.HasNewerPodJob(ctx, int32(jobID))
+	if err != nil {
+		return fmt.Errorf("error checking newer jobs: %w", err)
+	}
+	if newer {
+		return ErrJobSuperseded
+	}
+	return ErrNoJob
+}
+
+// ReleaseJob puts a job interrupted by a shutdown back in toStage and gives
+// back the claim it used up.
+func (s *DBJobStore) ReleaseJob(ctx context.Context, jobID int, toStage, lastError string) error {
+	return s.queries.ReleaseJob(ctx, db.ReleaseJobParams{
+		ID:        int32(jobID),
+		Stage:     toStage,
+		LastError: pgtype.Text{String: lastError, Valid: lastError != ""},
+	})
+}
+
+// RequeueStaleJobs moves stale jobs that may still be claimed again to toStage and returns their IDs.
+func (s *DBJobStore) RequeueStaleJobs(ctx context.Context, filter StaleJobFilter, toStage, lastError string) ([]int, error) {
+	ids, err := s.queries.RequeueStaleJobs(ctx, db.RequeueStaleJobsParams{
+		QueuedStage:  toStage,
+		LastError:    pgtype.Text{String: lastError, Valid: lastError != ""},
+		Stages:       filter.Stages,
+		StaleSeconds: int32(filter.StaleAfter.Seconds()),
+		MaxClaims:    int32(filter.MaxClaims),
+	})
+	if err != nil {
+		return nil, err
+	}
+	jobIDs := make([]int, len(ids))
+	for i, id := range ids {
+		jobIDs[i] = int(id)
+	}
+	return jobIDs, nil
+}
+
+// FailStaleJobs finishes stale jobs that were claimed too often in toStage and returns them.
+func (s *DBJobStore) FailStaleJobs(ctx context.Context, filter StaleJobFilter, toStage, reason, lastError string) ([]Job, error) {
+	rows, err := s.queries.FailStaleJobs(ctx, db.FailStaleJobsParams{
+		FailedStage:  toStage,
+		ErrorReason:  pgtype.Text{String: reason, Valid: reason != ""},
+		LastError:    pgtype.Text{String: lastError, Valid: lastError != ""},
+		Stages:       filter.Stages,
+		StaleSeconds: int32(filter.StaleAfter.Seconds()),
+		MaxClaims:    int32(filter.MaxClaims),
+	})
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]Job, len(rows))
+	for i, row := range rows {
+		jobs[i] = Job{
+			ID:             int(row.ID),
+			PodID:          int(row.PodID),
+			UserID:         row.CreatedBy,
+			Link:           row.Link,
+			Language:       row.Language,
+			Cost:           int(row.Cost),
+			ChargedCredits: int(row.ChargedCredits),
+			Quiz:           newQuizSettings(row.QuestionCount, row.QuizDifficulty),
+		}
+	}
+	return jobs, nil
+}
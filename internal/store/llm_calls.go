@@ -0,0 +1,165 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/demirbey05/auth-demo/db"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Ways LLM usage can be grouped.
+const (
+	LLMUsageByPod  = "pod"
+	LLMUsageByUser = "user"
+	LLMUsageByDay  = "day"
+)
+
+type LLMCallStore interface {
+	RecordLLMCall(ctx context.Context, call LLMCall) error
+	AggregateLLMUsage(ctx context.Context, groupBy string, filter LLMUsageFilter) ([]LLMUsage, error)
+}
+
+// LLMCall is a single request to a language model made for a pod job.
+type LLMCall struct {
+	PodID        int
+	JobID        int
+	UserID       string
+	Model        string
+	Purpose      string
+	InputTokens  int
+	OutputTokens int
+	TotalTokens  int
+	Latency      time.Duration
+	Error        string
+}
+
+// LLMUsageFilter narrows an aggregation. Empty UserID and zero PodID match everything.
+type LLMUsageFilter struct {
+	UserID string
+	PodID  int
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
+
+// LLMUsage is the summed usage of one group. Only the field matching the
+// grouping is set among PodID, UserID and Day; ChargedCredits is only
+// reported per pod.
+type LLMUsage struct {
+	PodID          int    `json:"pod_id,omitempty"`
+	UserID         string `json:"user_id,omitempty"`
+	Day            string `json:"day,omitempty"`
+	Calls          int    `json:"calls"`
+	InputTokens    int64  `json:"input_tokens"`
+	OutputTokens   int64  `json:"output_tokens"`
+	TotalTokens    int64  `json:"total_tokens"`
+	AvgLatencyMs   int    `json:"avg_latency_ms"`
+	ChargedCredits *int64 `json:"charged_credits,omitempty"`
+}
+
+type DBLLMCallStore struct {
+	queries *db.Queries
+}
+
+func NewDBLLMCallStore(queries *db.Queries) *DBLLMCallStore {
+	return &DBLLMCallStore{queries: queries}
+}
+
+func (s *DBLLMCallStore) RecordLLMCall(ctx context.Context, call LLMCall) error {
+	return s.queries.InsertLLMCall(ctx, db.InsertLLMCallParams{
+		PodID:        pgtype.Int4{Int32: int32(call.PodID), Valid: call.PodID != 0},
+		JobID:        pgtype.Int4{Int32: int32(call.JobID), Valid: call.JobID != 0},
+		UserID:       call.UserID,
+		Model:        call.Model,
+		Purpose:      call.Purpose,
+		InputTokens:  int32(call.InputTokens),
+		OutputTokens: int32(call.OutputTokens),
+		TotalTokens:  int32(call.TotalTokens),
+		LatencyMs:    int32(call.Latency.Milliseconds()),
+		Error:        pgtype.Text{String: call.Error, Valid: call.Error != ""},
+	})
+}
+
+// AggregateLLMUsage sums LLM calls made between filter.From and filter.To by
+// pod, user or day, depending on groupBy.
+func (s *DBLLMCallStore) AggregateLLMUsage(ctx context.Context, groupBy string, filter LLMUsageFilter) ([]LLMUsage, error) {
+	userID := pgtype.Text{String: filter.UserID, Valid: filter.UserID != ""}
+	from := pgtype.Timestamp{Time: filter.From.UTC(), Valid: true}
+	to := pgtype.Timestamp{Time: filter.To.UTC(), Valid: true}
+
+	var usage []LLMUsage
+	switch groupBy {
+	case LLMUsageByPod:
+		rows, err := s.queries.LLMUsageByPod(ctx, db.LLMUsageByPodParams{
+			UserID:    userID,
+			PodID:     pgtype.Int4{Int32: int32(filter.PodID), Valid: filter.PodID != 0},
+			FromTime:  from,
+			ToTime:    to,
+			RowLimit:  int32(filter.Limit),
+			RowOffset: int32(filter.Offset),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			credits := row.ChargedCredits
+			usage = append(usage, LLMUsage{
+				PodID:          int(row.PodID.Int32),
+				Calls:          int(row.Calls),
+				InputTokens:    row.InputTokens,
+				OutputTokens:   row.OutputTokens,
+				TotalTokens:    row.TotalTokens,
+				AvgLatencyMs:   int(row.AvgLatencyMs),
+				ChargedCredits: &credits,
+			})
+		}
+	case LLMUsageByUser:
+		rows, err := s.queries.LLMUsageByUser(ctx, db.LLMUsageByUserParams{
+			UserID:    userID,
+			FromTime:  from,
+			ToTime:    to,
+			RowLimit:  int32(filter.Limit),
+			RowOffset: int32(filter.Offset),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			usage = append(usage, LLMUsage{
+				UserID:       row.UserID,
+				Calls:        int(row.Calls),
+				InputTokens:  row.InputTokens,
+				OutputTokens: row.OutputTokens,
+				TotalTokens:  row.TotalTokens,
+				AvgLatencyMs: int(row.AvgLatencyMs),
+			})
+		}
+	case LLMUsageByDay:
+		rows, err := s.queries.LLMUsageByDay(ctx, db.LLMUsageByDayParams{
+			UserID:    userID,
+			FromTime:  from,
+			ToTime:    to,
+			RowLimit:  int32(filter.Limit),
+			RowOffset: int32(filter.Offset),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			usage = append(usage, LLMUsage{
+				Day:          row.Day.Time.Format(time.DateOnly),
+				Calls:        int(row.Calls),
+				InputTokens:  row.InputTokens,
+				OutputTokens: row.OutputTokens,
+				TotalTokens:  row.TotalTokens,
+				AvgLatencyMs: int(row.AvgLatencyMs),
+			})
+		}
+	default:
+		return nil, fmt.Errorf("unknown LLM usage grouping %q", groupBy)
+	}
+	return usage, nil
+}
@@ -2,48 +2,153 @@ package store
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/demirbey05/auth-demo/db"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type PodStore interface {
 	GetPodsByLink(ctx context.Context, link string) ([]Pod, error)
-	InsertPod(ctx context.Context, link, title, userId string) (int, error)
-	InsertArticle(ctx context.Context, podId int, content string) error
-	InsertQuiz(ctx context.Context, podId int) (int, error)
-	InsertQuestion(ctx context.Context, quizId int, question string, options []string, correctIndex int) (int, error)
-	InsertPodJob(ctx context.Context, podId int) (int, error)
-	UpdatePodJob(ctx context.Context, jobId int, status int) error
-	GetArticleByPodID(ctx context.Context, podID int) (string, error)
+	InsertPod(ctx context.Context, sourceType, link, title, userId, language string, sourcePodID int, youtubeCategory string, quiz QuizSettings) (int, error)
+	FindReusablePod(ctx context.Context, link, language string, quiz QuizSettings, doneStage string) (int, error)
+	ClonePodContent(ctx context.Context, sourcePodID, podID int) (int, int, error)
+	InsertArticle(ctx context.Context, podId int, content, promptVersion, notesPromptVersion string, citations []Citation) (int, error)
+	InsertQuiz(ctx context.Context, podId int, promptVersion string, quiz QuizSettings, questions []Question) (int, error)
+	InsertPodJob(ctx context.Context, podId int, language string, cost int) (int, error)
+	UpdatePodJob(ctx context.Context, jobId int, stage string) error
+	UpdatePodJobProgress(ctx context.Context, jobId int, chunksDone, chunksTotal int) error
+	FinishPodJob(ctx context.Context, jobId int, stage, reason, lastError string) error
+	RecordPodJobRetry(ctx context.Context, jobId int, lastError string) error
+	GetArticleByPodID(ctx context.Context, podID int) (Article, error)
+	InsertTranscript(ctx context.Context, podID int, transcript Transcript) error
+	GetTranscriptByPodID(ctx context.Context, podID int) (Transcript, error)
 	GetQuizByPodID(ctx context.Context, podID int) (QuizWithQuestions, error)
-	GetJobStatus(ctx context.Context, jobID int) (int, error)
+	GetJobStatus(ctx context.Context, jobID int) (JobStatus, error)
+	GetJobStatusByPodID(ctx context.Context, podID int) (JobStatus, error)
 	GetPodsByUserID(ctx context.Context, userId string) ([]Pod, error)
 	UpdatePodIsPublic(ctx context.Context, podID int, isPublic bool) error
 	IsPodOwner(ctx context.Context, podID int, userID string) (bool, error)
+	IsPodCreator(ctx context.Context, podID int, userID string) (bool, error)
+	GetPod(ctx context.Context, podID int) (Pod, error)
+	LockPod(ctx context.Context, podID int) error
+	GetPodArtifactIDs(ctx context.Context, podID int) (int, int, error)
+	SetPodVerdict(ctx context.Context, podID int, verdict ContentVerdict) error
 }
 
 type Pod struct {
-	ID        int       `json:"id"`
-	Link      string    `json:"link"`
-	Title     string    `json:"title"`
-	CreatedAt time.Time `json:"created_at"`
-	IsPublic  bool      `json:"is_public"`
+	ID          int       `json:"id"`
+	Link        string    `json:"link"`
+	Title       string    `json:"title"`
+	Language    string    `json:"language"`
+	CreatedAt   time.Time `json:"created_at"`
+	IsPublic    bool      `json:"is_public"`
+	SourcePodID *int      `json:"source_pod_id,omitempty"`
+	// SourceType is what the pod was generated from, such as youtube or pdf.
+	SourceType string `json:"source_type"`
+	// YouTubeCategory is the category ID the uploader picked on YouTube.
+	YouTubeCategory string `json:"youtube_category,omitempty"`
+	// Verdict is nil until the content has been classified.
+	Verdict *ContentVerdict `json:"verdict,omitempty"`
+	QuizSettings
+}
+
+// Article is the article of a pod and the video moments its sections cite.
+type Article struct {
+	Text      string     `json:"article"`
+	Citations []Citation `json:"citations"`
+}
+
+// Citation points a section of an article at the moment of the video it was
+// written from, in milliseconds from the start of the video.
+type Citation struct {
+	// Heading is the position of the section heading among all headings of
+	// the article, starting at 1.
+	Heading int    `json:"heading"`
+	Section string `json:"section"`
+	StartMs int    `json:"start_ms"`
+	EndMs   int    `json:"end_ms"`
+	// URL opens the video at StartMs. It is filled in when serving the article.
+	URL string `json:"url,omitempty"`
+}
+
+// QuizSettings is the kind of quiz a user asked for.
+type QuizSettings struct {
+	// QuestionCount is the exact number of questions, or 0 for the default range.
+	QuestionCount int    `json:"question_count,omitempty"`
+	Difficulty    string `json:"difficulty"`
+}
+
+func newQuizSettings(questionCount pgtype.Int4, difficulty string) QuizSettings {
+	return QuizSettings{QuestionCount: int(questionCount.Int32), Difficulty: difficulty}
+}
+
+func (q QuizSettings) questionCount() pgtype.Int4 {
+	return pgtype.Int4{Int32: int32(q.QuestionCount), Valid: q.QuestionCount != 0}
+}
+
+// ContentVerdict is the outcome of classifying whether a pod's content is educational.
+type ContentVerdict struct {
+	Educational bool    `json:"educational"`
+	Category    string  `json:"category"`
+	Confidence  float64 `json:"confidence"`
+	Reason      string  `json:"reason"`
+	// Source tells which signal decided the verdict, such as the YouTube
+	// category or the model.
+	Source       string    `json:"source"`
+	ClassifiedAt time.Time `json:"classified_at"`
+}
+
+func newPod(pod db.Pod) Pod {
+	p := Pod{
+		ID:              int(pod.ID),
+		Link:            pod.Link,
+		SourceType:      pod.SourceType,
+		Title:           pod.Title,
+		Language:        pod.Language,
+		CreatedAt:       pod.CreatedAt.Time,
+		IsPublic:        pod.IsPublic.Bool,
+		SourcePodID:     intPtr(pod.SourcePodID),
+		YouTubeCategory: pod.YoutubeCategory,
+		QuizSettings:    newQuizSettings(pod.QuestionCount, pod.QuizDifficulty),
+	}
+	if pod.VerdictEducational.Valid {
+		p.Verdict = &ContentVerdict{
+			Educational:  pod.VerdictEducational.Bool,
+			Category:     pod.VerdictCategory.String,
+			Confidence:   float64(pod.VerdictConfidence.Float32),
+			Reason:       pod.VerdictReason.String,
+			Source:       pod.VerdictSource.String,
+			ClassifiedAt: pod.ClassifiedAt.Time,
+		}
+	}
+	return p
 }
 
 type QuizWithQuestions struct {
-	ID        int        `json:"id"`
-	PodID     int        `json:"pod_id"`
+	ID    int `json:"id"`
+	PodID int `json:"pod_id"`
+	QuizSettings
 	Questions []Question `json:"questions"`
 }
 
+// Question is one question of a quiz. Type tells how to read Answer, which
+// holds the type specific answer as JSON.
 type Question struct {
-	ID        int      `json:"id"`
-	Text      string   `json:"question"`
-	Options   []string `json:"options"`
-	AnswerIdx int      `json:"correct_answer_index"`
+	ID      int             `json:"id"`
+	Type    string          `json:"type"`
+	Text    string          `json:"question"`
+	Options []string        `json:"options"`
+	Answer  json.RawMessage `json:"answer"`
+	// AnswerIdx is the correct option of single choice questions, kept for
+	// clients that predate question types.
+	AnswerIdx *int `json:"correct_answer_index,omitempty"`
+	// Explanation tells the learner why the answer is right.
+	Explanation string `json:"explanation"`
 }
 
 type DBPodStore struct {
@@ -61,17 +166,19 @@ func (s *DBPodStore) GetPodsByLink(ctx context.Context, link string) ([]Pod, err
 	}
 	pods := make([]Pod, len(podDb))
 	for i, pod := range podDb {
-		pods[i] = Pod{
-			ID:        int(pod.ID),
-			Link:      pod.Link,
-			Title:     pod.Title,
-			CreatedAt: pod.CreatedAt.Time,
-			IsPublic:  pod.IsPublic.Bool,
-		}
+		pods[i] = newPod(pod)
 	}
 	return pods, nil
 }
 
+func (s *DBPodStore) GetPod(ctx context.Context, podID int) (Pod, error) {
+	pod, err := s.queries.GetPodByID(ctx, int32(podID))
+	if err != nil {
+		return Pod{}, fmt.Errorf("error getting pod: %w", err)
+	}
+	return newPod(pod), nil
+}
+
 func (s *DBPodStore) GetPodsByUserID(ctx context.Context, userId string) ([]Pod, error) {
 	podDb, err := s.queries.GetPodsByUserID(ctx, userId)
 	if err != nil {
@@ -79,74 +186,200 @@ func (s *DBPodStore) GetPodsByUserID(ctx context.Context, userId string) ([]Pod,
 	}
 	pods := make([]Pod, len(podDb))
 	for i, pod := range podDb {
-		pods[i] = Pod{
-			ID:        int(pod.ID),
-			Link:      pod.Link,
-			Title:     pod.Title,
-			CreatedAt: pod.CreatedAt.Time,
-			IsPublic:  pod.IsPublic.Bool,
-		}
+		pods[i] = newPod(pod)
 	}
 	return pods, nil
 }
 
-// InsertPod inserts a new Pod and returns its ID.
-func (s *DBPodStore) InsertPod(ctx context.Context, link, title, userId string) (int, error) {
-	pod, err := s.queries.InsertPod(ctx, db.InsertPodParams{Link: link, Title: title, CreatedBy: userId})
+// InsertPod inserts a new Pod and returns its ID. sourcePodID is the pod whose
+// content is reused, or 0 for a pod generated from scratch.
+func (s *DBPodStore) InsertPod(ctx context.Context, sourceType, link, title, userId, language string, sourcePodID int, youtubeCategory string, quiz QuizSettings) (int, error) {
+	pod, err := s.queries.InsertPod(ctx, db.InsertPodParams{
+		Link:            link,
+		Title:           title,
+		CreatedBy:       userId,
+		Language:        language,
+		SourcePodID:     pgtype.Int4{Int32: int32(sourcePodID), Valid: sourcePodID != 0},
+		YoutubeCategory: youtubeCategory,
+		QuestionCount:   quiz.questionCount(),
+		QuizDifficulty:  quiz.Difficulty,
+		SourceType:      sourceType,
+	})
 	if err != nil {
 		return 0, err
 	}
 	return int(pod), nil
 }
 
-// InsertArticle inserts a new Article.
-func (s *DBPodStore) InsertArticle(ctx context.Context, podId int, content string) error {
-	err := s.queries.InsertArticle(ctx, db.InsertArticleParams{
-		PodID:       pgtype.Int4{Int32: int32(podId), Valid: true},
-		ArticleText: content,
+// FindReusablePod returns the newest pod for link, language and quiz settings
+// whose job finished with both an article and a quiz, or 0 if there is none.
+func (s *DBPodStore) FindReusablePod(ctx context.Context, link, language string, quiz QuizSettings, doneStage string) (int, error) {
+	podID, err := s.queries.GetReusablePod(ctx, db.GetReusablePodParams{
+		Link:           link,
+		Language:       language,
+		QuestionCount:  quiz.questionCount(),
+		QuizDifficulty: quiz.Difficulty,
+		DoneStage:      doneStage,
 	})
-	return err
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return int(podID), nil
 }
 
-// InsertQuiz inserts a new Quiz and returns its ID.
-func (s *DBPodStore) InsertQuiz(ctx context.Context, podId int) (int, error) {
-	quiz, err := s.queries.InsertQuiz(ctx, pgtype.Int4{Int32: int32(podId), Valid: true})
+// ClonePodContent copies the transcript, article, quiz and content verdict of sourcePodID
+// to podID and returns the new article and quiz IDs.
+func (s *DBPodStore) ClonePodContent(ctx context.Context, sourcePodID, podID int) (int, int, error) {
+	if err := s.queries.CopyPodVerdict(ctx, db.CopyPodVerdictParams{SourcePodID: int32(sourcePodID), PodID: int32(podID)}); err != nil {
+		return 0, 0, fmt.Errorf("error copying verdict: %w", err)
+	}
+	if err := s.queries.CloneTranscript(ctx, db.CloneTranscriptParams{PodID: int32(podID), SourcePodID: int32(sourcePodID)}); err != nil {
+		return 0, 0, fmt.Errorf("error cloning transcript: %w", err)
+	}
+	articleID, err := s.queries.CloneArticle(ctx, db.CloneArticleParams{
+		PodID:       pgtype.Int4{Int32: int32(podID), Valid: true},
+		SourcePodID: pgtype.Int4{Int32: int32(sourcePodID), Valid: true},
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("error cloning article: %w", err)
+	}
+
+	sourceQuiz, err := s.queries.GetQuizByPodId(ctx, pgtype.Int4{Int32: int32(sourcePodID), Valid: true})
+	if err != nil {
+		return 0, 0, fmt.Errorf("error getting quiz: %w", err)
+	}
+	quizID, err := s.queries.InsertQuiz(ctx, db.InsertQuizParams{
+		PodID:         pgtype.Int4{Int32: int32(podID), Valid: true},
+		PromptVersion: sourceQuiz.PromptVersion,
+		QuestionCount: sourceQuiz.QuestionCount,
+		Difficulty:    sourceQuiz.Difficulty,
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("error inserting quiz: %w", err)
+	}
+	if err := s.queries.CloneQuestions(ctx, db.CloneQuestionsParams{
+		QuizID:       pgtype.Int4{Int32: quizID, Valid: true},
+		SourceQuizID: pgtype.Int4{Int32: sourceQuiz.ID, Valid: true},
+	}); err != nil {
+		return 0, 0, fmt.Errorf("error cloning questions: %w", err)
+	}
+	return int(articleID), int(quizID), nil
+}
+
+// InsertArticle inserts a new Article written with the given prompt version and returns its ID.
+// notesPromptVersion is the chunk notes version it was written from, empty
+// when the transcript fit in one prompt.
+func (s *DBPodStore) InsertArticle(ctx context.Context, podId int, content, promptVersion, notesPromptVersion string, citations []Citation) (int, error) {
+	if citations == nil {
+		citations = []Citation{}
+	}
+	encoded, err := json.Marshal(citations)
+	if err != nil {
+		return 0, err
+	}
+	article, err := s.queries.InsertArticle(ctx, db.InsertArticleParams{
+		PodID:              pgtype.Int4{Int32: int32(podId), Valid: true},
+		ArticleText:        content,
+		PromptVersion:      promptVersion,
+		NotesPromptVersion: notesPromptVersion,
+		Citations:          encoded,
+	})
 	if err != nil {
 		return 0, err
 	}
-	return int(quiz), nil
+	return int(article), nil
 }
 
-// InsertQuestion inserts a new Question and returns its ID.
-func (s *DBPodStore) InsertQuestion(ctx context.Context, quizId int, question string, options []string, correctIndex int) (int, error) {
-	questionRecord, err := s.queries.InsertQuestion(ctx, db.InsertQuestionParams{
-		QuizzesID:     pgtype.Int4{Int32: int32(quizId), Valid: true},
-		QuestionText:  question,
-		Options:       options,
-		CorrectOption: int32(correctIndex),
+// InsertQuiz inserts a quiz of the given settings together with its questions
+// and returns its ID. Either the whole quiz is stored or none of it is.
+func (s *DBPodStore) InsertQuiz(ctx context.Context, podId int, promptVersion string, quiz QuizSettings, questions []Question) (int, error) {
+	for i := range questions {
+		if questions[i].Options == nil {
+			questions[i].Options = []string{}
+		}
+	}
+	encoded, err := json.Marshal(questions)
+	if err != nil {
+		return 0, fmt.Errorf("error encoding questions: %w", err)
+	}
+	quizID, err := s.queries.InsertQuizWithQuestions(ctx, db.InsertQuizWithQuestionsParams{
+		PodID:         pgtype.Int4{Int32: int32(podId), Valid: true},
+		PromptVersion: promptVersion,
+		QuestionCount: quiz.questionCount(),
+		Difficulty:    quiz.Difficulty,
+		Questions:     encoded,
 	})
 	if err != nil {
 		return 0, err
 	}
-	return int(questionRecord), nil
+	return int(quizID), nil
 }
 
-func (s *DBPodStore) InsertPodJob(ctx context.Context, podId int) (int, error) {
-	job, err := s.queries.InsertJob(ctx, int32(podId))
+// InsertPodJob queues a job priced at cost. The user is only charged once the
+// pipeline accepts the content.
+func (s *DBPodStore) InsertPodJob(ctx context.Context, podId int, language string, cost int) (int, error) {
+	job, err := s.queries.InsertJob(ctx, db.InsertJobParams{PodID: int32(podId), Language: language, Cost: int32(cost)})
 	if err != nil {
 		return 0, err
 	}
 	return int(job), nil
 }
 
-func (s *DBPodStore) UpdatePodJob(ctx context.Context, jobId int, status int) error {
-	return s.queries.UpdateJobStatusByID(ctx, db.UpdateJobStatusByIDParams{ID: int32(jobId), JobStatus: int32(status)})
+// SetPodVerdict stores the content classification of a pod.
+func (s *DBPodStore) SetPodVerdict(ctx context.Context, podID int, verdict ContentVerdict) error {
+	return s.queries.SetPodVerdict(ctx, db.SetPodVerdictParams{
+		ID:                 int32(podID),
+		VerdictEducational: pgtype.Bool{Bool: verdict.Educational, Valid: true},
+		VerdictCategory:    pgtype.Text{String: verdict.Category, Valid: true},
+		VerdictConfidence:  pgtype.Float4{Float32: float32(verdict.Confidence), Valid: true},
+		VerdictReason:      pgtype.Text{String: verdict.Reason, Valid: true},
+		VerdictSource:      pgtype.Text{String: verdict.Source, Valid: true},
+	})
+}
+
+func (s *DBPodStore) UpdatePodJob(ctx context.Context, jobId int, stage string) error {
+	return s.queries.UpdateJobStage(ctx, db.UpdateJobStageParams{ID: int32(jobId), Stage: stage})
+}
+
+// UpdatePodJobProgress records how many transcript chunks of a long video are summarized.
+func (s *DBPodStore) UpdatePodJobProgress(ctx context.Context, jobId int, chunksDone, chunksTotal int) error {
+	return s.queries.UpdateJobChunkProgress(ctx, db.UpdateJobChunkProgressParams{
+		ID:          int32(jobId),
+		ChunksDone:  int32(chunksDone),
+		ChunksTotal: int32(chunksTotal),
+	})
+}
+
+// FinishPodJob moves the job to a terminal stage. An empty reason clears any previous
+// failure reason, an empty lastError keeps the one already recorded.
+func (s *DBPodStore) FinishPodJob(ctx context.Context, jobId int, stage, reason, lastError string) error {
+	return s.queries.FinishJob(ctx, db.FinishJobParams{
+		ID:          int32(jobId),
+		Stage:       stage,
+		ErrorReason: pgtype.Text{String: reason, Valid: reason != ""},
+		LastError:   pgtype.Text{String: lastError, Valid: lastError != ""},
+	})
+}
+
+// RecordPodJobRetry counts another attempt on the job and keeps the error that caused it.
+func (s *DBPodStore) RecordPodJobRetry(ctx context.Context, jobId int, lastError string) error {
+	return s.queries.RecordJobRetry(ctx, db.RecordJobRetryParams{
+		ID:        int32(jobId),
+		LastError: pgtype.Text{String: lastError, Valid: true},
+	})
 }
 
-func (s *DBPodStore) GetArticleByPodID(ctx context.Context, podID int) (string, error) {
-	article, err := s.queries.GetArticleByPodId(ctx, pgtype.Int4{Int32: int32(podID), Valid: true})
+func (s *DBPodStore) GetArticleByPodID(ctx context.Context, podID int) (Article, error) {
+	row, err := s.queries.GetArticleByPodId(ctx, pgtype.Int4{Int32: int32(podID), Valid: true})
 	if err != nil {
-		return "", fmt.Errorf("error getting article: %w", err)
+		return Article{}, fmt.Errorf("error getting article: %w", err)
+	}
+	article := Article{Text: row.ArticleText}
+	if err := json.Unmarshal(row.Citations, &article.Citations); err != nil {
+		return Article{}, fmt.Errorf("error decoding article citations: %v", err)
 	}
 	return article, nil
 }
@@ -163,28 +396,40 @@ func (s *DBPodStore) GetQuizByPodID(ctx context.Context, podID int) (QuizWithQue
 	}
 
 	result := QuizWithQuestions{
-		ID:    int(quiz.ID),
-		PodID: int(quiz.PodID.Int32),
+		ID:           int(quiz.ID),
+		PodID:        int(quiz.PodID.Int32),
+		QuizSettings: newQuizSettings(quiz.QuestionCount, quiz.Difficulty),
 	}
 
 	for _, q := range questions {
 		result.Questions = append(result.Questions, Question{
-			ID:        int(q.ID),
-			Text:      q.QuestionText,
-			Options:   q.Options,
-			AnswerIdx: int(q.CorrectOption),
+			ID:          int(q.ID),
+			Type:        q.QuestionType,
+			Text:        q.QuestionText,
+			Options:     q.Options,
+			Answer:      q.Answer,
+			AnswerIdx:   intPtr(q.CorrectOption),
+			Explanation: q.Explanation,
 		})
 	}
 
 	return result, nil
 }
 
-func (s *DBPodStore) GetJobStatus(ctx context.Context, jobID int) (int, error) {
-	status, err := s.queries.GetJobStatusByID(ctx, int32(jobID))
+func (s *DBPodStore) GetJobStatus(ctx context.Context, jobID int) (JobStatus, error) {
+	job, err := s.queries.GetJobByID(ctx, int32(jobID))
+	if err != nil {
+		return JobStatus{}, fmt.Errorf("error getting job status: %w", err)
+	}
+	return newJobStatus(job), nil
+}
+
+func (s *DBPodStore) GetJobStatusByPodID(ctx context.Context, podID int) (JobStatus, error) {
+	job, err := s.queries.GetLatestJobByPodID(ctx, int32(podID))
 	if err != nil {
-		return 0, fmt.Errorf("error getting job status: %w", err)
+		return JobStatus{}, fmt.Errorf("error getting job status: %w", err)
 	}
-	return int(status), nil
+	return newJobStatus(job), nil
 }
 func (s *DBPodStore) UpdatePodIsPublic(ctx context.Context, podID int, isPublic bool) error {
 	return s.queries.UpdatePodIsPublic(ctx, db.UpdatePodIsPublicParams{ID: int32(podID), IsPublic: pgtype.Bool{Bool: isPublic, Valid: true}})
@@ -196,3 +441,30 @@ func (s *DBPodStore) IsPodOwner(ctx context.Context, podID int, userID string) (
 	}
 	return podInfo.CreatedBy == userID || podInfo.IsPublic.Bool, nil
 }
+
+// IsPodCreator reports whether userID created the pod. Unlike IsPodOwner it ignores public access.
+func (s *DBPodStore) IsPodCreator(ctx context.Context, podID int, userID string) (bool, error) {
+	podInfo, err := s.queries.GetPodOwner(ctx, int32(podID))
+	if err != nil {
+		return false, fmt.Errorf("error getting pod owner: %w", err)
+	}
+	return podInfo.CreatedBy == userID, nil
+}
+
+// LockPod locks the pod row until the surrounding transaction ends, so that
+// only one request at a time queues work for the pod.
+func (s *DBPodStore) LockPod(ctx context.Context, podID int) error {
+	if err := s.queries.LockPod(ctx, int32(podID)); err != nil {
+		return fmt.Errorf("error locking pod: %w", err)
+	}
+	return nil
+}
+
+// GetPodArtifactIDs returns the article and quiz IDs of a pod, 0 for the ones not generated yet.
+func (s *DBPodStore) GetPodArtifactIDs(ctx context.Context, podID int) (int, int, error) {
+	ids, err := s.queries.GetPodArtifactIDs(ctx, pgtype.Int4{Int32: int32(podID), Valid: true})
+	if err != nil {
+		return 0, 0, fmt.Errorf("error getting pod artifacts: %w", err)
+	}
+	return int(ids.ArticleID), int(ids.QuizID), nil
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/demirbey05/auth-demo/db"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrPromptVersionExists is returned when a kind already has a template with the same version.
+var ErrPromptVersionExists = errors.New("prompt version already exists")
+
+type PromptStore interface {
+	ListPromptTemplates(ctx context.Context) ([]PromptTemplate, error)
+	CreatePromptTemplate(ctx context.Context, kind, version, body string, weight int) (PromptTemplate, error)
+	SetPromptTemplateWeight(ctx context.Context, id, weight int) (PromptTemplate, error)
+}
+
+// PromptTemplate is a prompt version stored in the database. An empty Body
+// refers to the built-in template of the same kind and version.
+type PromptTemplate struct {
+	ID        int       `json:"id"`
+	Kind      string    `json:"kind"`
+	Version   string    `json:"version"`
+	Body      string    `json:"body,omitempty"`
+	Weight    int       `json:"weight"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newPromptTemplate(t db.PromptTemplate) PromptTemplate {
+	return PromptTemplate{
+		ID:        int(t.ID),
+		Kind:      t.Kind,
+		Version:   t.Version,
+		Body:      t.Body,
+		Weight:    int(t.Weight),
+		CreatedAt: t.CreatedAt.Time,
+	}
+}
+
+type DBPromptStore struct {
+	queries *db.Queries
+}
+
+func NewDBPromptStore(queries *db.Queries) *DBPromptStore {
+	return &DBPromptStore{queries: queries}
+}
+
+func (s *DBPromptStore) ListPromptTemplates(ctx context.Context) ([]PromptTemplate, error) {
+	rows, err := s.queries.ListPromptTemplates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	templates := make([]PromptTemplate, len(rows))
+	for i, row := range rows {
+		templates[i] = newPromptTemplate(row)
+	}
+	return templates, nil
+}
+
+func (s *DBPromptStore) CreatePromptTemplate(ctx context.Context, kind, version, body string, weight int) (PromptTemplate, error) {
+	t, err := s.queries.InsertPromptTemplate(ctx, db.InsertPromptTemplateParams{
+		Kind:    kind,
+		Version: version,
+		Body:    body,
+		Weight:  int32(weight),
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return PromptTemplate{}, fmt.Errorf("%w: %s %s", ErrPromptVersionExists, kind, version)
+		}
+		return PromptTemplate{}, err
+	}
+	return newPromptTemplate(t), nil
+}
+
+func (s *DBPromptStore) SetPromptTemplateWeight(ctx context.Context, id, weight int) (PromptTemplate, error) {
+	t, err := s.queries.UpdatePromptTemplateWeight(ctx, db.UpdatePromptTemplateWeightParams{ID: int32(id), Weight: int32(weight)})
+	if err != nil {
+		return PromptTemplate{}, fmt.Errorf("error updating prompt template %d: %w", id, err)
+	}
+	return newPromptTemplate(t), nil
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/demirbey05/auth-demo/db"
+)
+
+// Transcript is the transcript a pod was generated from, as the provider
+// returned it.
+type Transcript struct {
+	// Provider is the name of the provider that returned it.
+	Provider string `json:"provider"`
+	// Language is the language code of the transcript, when the provider reports it.
+	Language  string              `json:"language"`
+	Segments  []TranscriptSegment `json:"segments"`
+	CreatedAt time.Time           `json:"created_at"`
+}
+
+// TranscriptSegment is a piece of a transcript and when it is spoken, in
+// milliseconds from the start of the video.
+type TranscriptSegment struct {
+	Text       string `json:"text"`
+	StartMs    int    `json:"start_ms"`
+	DurationMs int    `json:"duration_ms"`
+}
+
+// Duration is when the last segment ends, 0 for transcripts without timing.
+func (t Transcript) Duration() time.Duration {
+	var end int
+	for _, s := range t.Segments {
+		end = max(end, s.StartMs+s.DurationMs)
+	}
+	return time.Duration(end) * time.Millisecond
+}
+
+// Text joins the segments into the plain text prompts are written from.
+func (t Transcript) Text() string {
+	parts := make([]string, 0, len(t.Segments))
+	for _, s := range t.Segments {
+		if text := strings.TrimSpace(s.Text); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// InsertTranscript stores the transcript of a pod. A pod keeps the first
+// transcript stored for it.
+func (s *DBPodStore) InsertTranscript(ctx context.Context, podID int, transcript Transcript) error {
+	segments, err := json.Marshal(transcript.Segments)
+	if err != nil {
+		return err
+	}
+	if err := s.queries.InsertTranscript(ctx, db.InsertTranscriptParams{
+		PodID:    int32(podID),
+		Provider: transcript.Provider,
+		Language: transcript.Language,
+		Segments: segments,
+	}); err != nil {
+		return fmt.Errorf("error inserting transcript: %w", err)
+	}
+	return nil
+}
+
+func (s *DBPodStore) GetTranscriptByPodID(ctx context.Context, podID int) (Transcript, error) {
+	row, err := s.queries.GetTranscriptByPodId(ctx, int32(podID))
+	if err != nil {
+		return Transcript{}, fmt.Errorf("error getting transcript: %w", err)
+	}
+	transcript := Transcript{Provider: row.Provider, Language: row.Language, CreatedAt: row.CreatedAt.Time}
+	if err := json.Unmarshal(row.Segments, &transcript.Segments); err != nil {
+		return Transcript{}, fmt.Errorf("error decoding transcript segments: %v", err)
+	}
+	return transcript, nil
+}
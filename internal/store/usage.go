@@ -2,15 +2,51 @@ package store
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/demirbey05/auth-demo/db"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ErrInsufficientCredits is returned when a balance does not cover a charge.
+var ErrInsufficientCredits = errors.New("insufficient credits")
+
+// InitialCredits is granted to every user the first time their balance is touched.
+const InitialCredits = 3000
+
+// Reasons recorded on credit ledger entries.
+const (
+	CreditInitialGrant    = "initial_grant"
+	CreditPodDebit        = "pod_debit"
+	CreditRefund          = "refund"
+	CreditAdminAdjustment = "admin_adjustment"
+	CreditPurchase        = "purchase"
 )
 
 type UsageStore interface {
 	GetRemainingCredits(ctx context.Context, userID string) (int, error)
-	DecrementCredit(ctx context.Context, userID string, amount int) (int, error)
+	ChargeJob(ctx context.Context, userID string, jobID int) (int, error)
+	RefundJob(ctx context.Context, userID string, jobID int) (int, error)
+	GetPodKeptCredits(ctx context.Context, podID int) (int, error)
+	AddCredit(ctx context.Context, userID string, amount int, reason, note string) (int, error)
+	GetCreditHistory(ctx context.Context, userID string, limit, offset int) ([]CreditTransaction, int, error)
+}
+
+// CreditTransaction is one entry of a user's append-only credit ledger.
+type CreditTransaction struct {
+	ID        int       `json:"id"`
+	Amount    int       `json:"amount"`
+	Reason    string    `json:"reason"`
+	PodID     *int      `json:"pod_id,omitempty"`
+	JobID     *int      `json:"job_id,omitempty"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
+// DBUsageStore keeps usage.credits as a cached balance of the credit ledger.
+// Every change inserts a ledger entry and updates the balance in one statement.
 type DBUsageStore struct {
 	queries *db.Queries
 }
@@ -23,28 +59,129 @@ func (s *DBUsageStore) GetRemainingCredits(ctx context.Context, userID string) (
 	remaining, err := s.queries.GetRemainingCredits(ctx, userID)
 	if err != nil {
 		if err.Error() == "no rows in result set" {
-			return 3000, nil
+			return InitialCredits, nil
 		}
 		return 0, err
 	}
 	return int(remaining), nil
 }
 
-func (s *DBUsageStore) DecrementCredit(ctx context.Context, userID string, amount int) (int, error) {
-	remainingExist, err := s.queries.IsCreditExist(ctx, userID)
+// ensureAccount grants the initial credits to users that have no balance yet.
+func (s *DBUsageStore) ensureAccount(ctx context.Context, userID string) error {
+	exists, err := s.queries.IsCreditExist(ctx, userID)
 	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	return s.queries.GrantInitialCredit(ctx, db.GrantInitialCreditParams{
+		UserID: userID,
+		Amount: InitialCredits,
+		Reason: CreditInitialGrant,
+	})
+}
+
+// ChargeJob debits the user the cost of a pod job and returns the remaining
+// balance. A job is charged at most once; charging a free or already charged
+// job leaves the balance untouched. The balance is checked in the same
+// statement that debits it, so ErrInsufficientCredits is returned instead of
+// overdrawing the account.
+func (s *DBUsageStore) ChargeJob(ctx context.Context, userID string, jobID int) (int, error) {
+	if err := s.ensureAccount(ctx, userID); err != nil {
 		return 0, err
 	}
-	if !remainingExist {
-		err := s.queries.InsertCredit(ctx, db.InsertCreditParams{UserID: userID, Credits: int32(3000 - amount)})
+	remaining, err := s.queries.ChargeJob(ctx, db.ChargeJobParams{JobID: int32(jobID), UserID: userID})
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return 0, err
+		}
+		job, err := s.queries.GetJobCharge(ctx, int32(jobID))
 		if err != nil {
 			return 0, err
 		}
-		return 14000, nil
+		if job.Cost > 0 && job.ChargedCredits == 0 {
+			return 0, ErrInsufficientCredits
+		}
+		return s.GetRemainingCredits(ctx, userID)
+	}
+	return int(remaining), nil
+}
+
+// RefundJob gives the user back what a failed job was charged and returns the
+// amount refunded. The job is no longer charged afterwards, so it is refunded
+// at most once per charge; 0 is returned when there was nothing to refund.
+func (s *DBUsageStore) RefundJob(ctx context.Context, userID string, jobID int) (int, error) {
+	if err := s.ensureAccount(ctx, userID); err != nil {
+		return 0, err
+	}
+	amount, err := s.queries.RefundJob(ctx, db.RefundJobParams{JobID: int32(jobID), UserID: userID})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
 	}
-	remaining, err := s.queries.DecrementCredit(ctx, db.DecrementCreditParams{UserID: userID, Credits: int32(amount)})
+	return int(amount), nil
+}
+
+// GetPodKeptCredits returns what the jobs of a pod were charged, net of refunds.
+func (s *DBUsageStore) GetPodKeptCredits(ctx context.Context, podID int) (int, error) {
+	kept, err := s.queries.GetPodKeptCredits(ctx, pgtype.Int4{Int32: int32(podID), Valid: true})
+	if err != nil {
+		return 0, err
+	}
+	return int(kept), nil
+}
+
+// AddCredit records a transaction that is not tied to a pod, such as an admin
+// adjustment or a purchase, and returns the new balance. amount may be negative.
+func (s *DBUsageStore) AddCredit(ctx context.Context, userID string, amount int, reason, note string) (int, error) {
+	if err := s.ensureAccount(ctx, userID); err != nil {
+		return 0, err
+	}
+	remaining, err := s.queries.AddCreditTransaction(ctx, db.AddCreditTransactionParams{
+		UserID: userID,
+		Amount: int32(amount),
+		Reason: reason,
+		Note:   pgtype.Text{String: note, Valid: note != ""},
+	})
 	if err != nil {
 		return 0, err
 	}
 	return int(remaining), nil
 }
+
+// GetCreditHistory returns a page of the user's ledger, newest first, and the total number of entries.
+func (s *DBUsageStore) GetCreditHistory(ctx context.Context, userID string, limit, offset int) ([]CreditTransaction, int, error) {
+	rows, err := s.queries.GetCreditHistory(ctx, db.GetCreditHistoryParams{UserID: userID, Limit: int32(limit), Offset: int32(offset)})
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := s.queries.CountCreditHistory(ctx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	history := make([]CreditTransaction, len(rows))
+	for i, row := range rows {
+		history[i] = CreditTransaction{
+			ID:        int(row.ID),
+			Amount:    int(row.Amount),
+			Reason:    row.Reason,
+			PodID:     intPtr(row.PodID),
+			JobID:     intPtr(row.JobID),
+			Note:      row.Note.String,
+			CreatedAt: row.CreatedAt.Time,
+		}
+	}
+	return history, int(total), nil
+}
+
+func intPtr(v pgtype.Int4) *int {
+	if !v.Valid {
+		return nil
+	}
+	i := int(v.Int32)
+	return &i
+}
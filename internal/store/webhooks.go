@@ -0,0 +1,220 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/demirbey05/auth-demo/db"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Delivery states of a webhook delivery.
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookFailed    = "failed"
+)
+
+type WebhookStore interface {
+	CreateWebhook(ctx context.Context, userID, url, secret string, events []string) (Webhook, error)
+	ListWebhooks(ctx context.Context, userID string) ([]Webhook, error)
+	GetWebhook(ctx context.Context, webhookID int) (Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookID int, userID string) error
+	EnqueueWebhookEvent(ctx context.Context, userID, event string, payload []byte) (int, error)
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]PendingWebhookDelivery, error)
+	MarkWebhookDelivered(ctx context.Context, deliveryID, statusCode int) error
+	RetryWebhookDelivery(ctx context.Context, deliveryID, statusCode int, lastError string, delay time.Duration) error
+	FailWebhookDelivery(ctx context.Context, deliveryID, statusCode int, lastError string) error
+	ListWebhookDeliveries(ctx context.Context, webhookID, limit, offset int) ([]WebhookDelivery, error)
+}
+
+// Webhook is a user's registration for pod lifecycle events. The secret is
+// only sent back when the webhook is created.
+type Webhook struct {
+	ID        int       `json:"id"`
+	UserID    string    `json:"-"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PendingWebhookDelivery is a claimed delivery with what is needed to send it.
+type PendingWebhookDelivery struct {
+	ID        int
+	WebhookID int
+	Event     string
+	Payload   []byte
+	Attempts  int
+	URL       string
+	Secret    string
+}
+
+// WebhookDelivery is one entry of a webhook's delivery log.
+type WebhookDelivery struct {
+	ID             int             `json:"id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+func newWebhook(w db.Webhook) Webhook {
+	return Webhook{
+		ID:        int(w.ID),
+		UserID:    w.UserID,
+		URL:       w.Url,
+		Events:    w.Events,
+		CreatedAt: w.CreatedAt.Time,
+	}
+}
+
+// responseStatus maps an HTTP status to a column value; 0 means no response was received.
+func responseStatus(code int) pgtype.Int4 {
+	return pgtype.Int4{Int32: int32(code), Valid: code != 0}
+}
+
+type DBWebhookStore struct {
+	queries *db.Queries
+}
+
+func NewDBWebhookStore(queries *db.Queries) *DBWebhookStore {
+	return &DBWebhookStore{queries: queries}
+}
+
+func (s *DBWebhookStore) CreateWebhook(ctx context.Context, userID, url, secret string, events []string) (Webhook, error) {
+	w, err := s.queries.InsertWebhook(ctx, db.InsertWebhookParams{UserID: userID, Url: url, Secret: secret, Events: events})
+	if err != nil {
+		return Webhook{}, err
+	}
+	webhook := newWebhook(w)
+	webhook.Secret = w.Secret
+	return webhook, nil
+}
+
+func (s *DBWebhookStore) ListWebhooks(ctx context.Context, userID string) ([]Webhook, error) {
+	rows, err := s.queries.ListWebhooksByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	webhooks := make([]Webhook, len(rows))
+	for i, row := range rows {
+		webhooks[i] = newWebhook(row)
+	}
+	return webhooks, nil
+}
+
+func (s *DBWebhookStore) GetWebhook(ctx context.Context, webhookID int) (Webhook, error) {
+	w, err := s.queries.GetWebhookByID(ctx, int32(webhookID))
+	if err != nil {
+		return Webhook{}, fmt.Errorf("error getting webhook %d: %w", webhookID, err)
+	}
+	return newWebhook(w), nil
+}
+
+// DeleteWebhook removes a webhook and its delivery log. It returns
+// pgx.ErrNoRows when userID has no webhook with that id.
+func (s *DBWebhookStore) DeleteWebhook(ctx context.Context, webhookID int, userID string) error {
+	n, err := s.queries.DeleteWebhook(ctx, db.DeleteWebhookParams{ID: int32(webhookID), UserID: userID})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("webhook %d: %w", webhookID, pgx.ErrNoRows)
+	}
+	return nil
+}
+
+// EnqueueWebhookEvent adds a pending delivery of payload to every webhook of
+// userID subscribed to event and returns how many were added.
+func (s *DBWebhookStore) EnqueueWebhookEvent(ctx context.Context, userID, event string, payload []byte) (int, error) {
+	n, err := s.queries.EnqueueWebhookDeliveries(ctx, db.EnqueueWebhookDeliveriesParams{Event: event, Payload: payload, UserID: userID})
+	return int(n), err
+}
+
+// ClaimWebhookDeliveries returns up to limit deliveries that are due and
+// hides them from other callers for lease, so a crashed sender only delays them.
+func (s *DBWebhookStore) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]PendingWebhookDelivery, error) {
+	rows, err := s.queries.ClaimWebhookDeliveries(ctx, db.ClaimWebhookDeliveriesParams{
+		LeaseSeconds: int32(lease.Seconds()),
+		BatchSize:    int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]PendingWebhookDelivery, len(rows))
+	for i, row := range rows {
+		deliveries[i] = PendingWebhookDelivery{
+			ID:        int(row.ID),
+			WebhookID: int(row.WebhookID),
+			Event:     row.Event,
+			Payload:   row.Payload,
+			Attempts:  int(row.Attempts),
+			URL:       row.Url,
+			Secret:    row.Secret,
+		}
+	}
+	return deliveries, nil
+}
+
+func (s *DBWebhookStore) MarkWebhookDelivered(ctx context.Context, deliveryID, code int) error {
+	return s.queries.MarkWebhookDelivered(ctx, db.MarkWebhookDeliveredParams{ID: int32(deliveryID), ResponseStatus: responseStatus(code)})
+}
+
+func (s *DBWebhookStore) RetryWebhookDelivery(ctx context.Context, deliveryID, code int, lastError string, delay time.Duration) error {
+	return s.queries.RetryWebhookDelivery(ctx, db.RetryWebhookDeliveryParams{
+		ID:             int32(deliveryID),
+		ResponseStatus: responseStatus(code),
+		LastError:      pgtype.Text{String: lastError, Valid: lastError != ""},
+		DelayMs:        int32(delay.Milliseconds()),
+	})
+}
+
+func (s *DBWebhookStore) FailWebhookDelivery(ctx context.Context, deliveryID, code int, lastError string) error {
+	return s.queries.FailWebhookDelivery(ctx, db.FailWebhookDeliveryParams{
+		ID:             int32(deliveryID),
+		ResponseStatus: responseStatus(code),
+		LastError:      pgtype.Text{String: lastError, Valid: lastError != ""},
+	})
+}
+
+func (s *DBWebhookStore) ListWebhookDeliveries(ctx context.Context, webhookID, limit, offset int) ([]WebhookDelivery, error) {
+	rows, err := s.queries.ListWebhookDeliveries(ctx, db.ListWebhookDeliveriesParams{
+		WebhookID: int32(webhookID),
+		Limit:     int32(limit),
+		Offset:    int32(offset),
+	})
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]WebhookDelivery, len(rows))
+	for i, row := range rows {
+		delivery := WebhookDelivery{
+			ID:          int(row.ID),
+			Event:       row.Event,
+			Payload:     row.Payload,
+			Status:      row.Status,
+			Attempts:    int(row.Attempts),
+			LastError:   row.LastError.String,
+			CreatedAt:   row.CreatedAt.Time,
+			DeliveredAt: timePtr(row.DeliveredAt),
+		}
+		if row.ResponseStatus.Valid {
+			code := int(row.ResponseStatus.Int32)
+			delivery.ResponseStatus = &code
+		}
+		if row.Status == WebhookPending {
+			delivery.NextAttemptAt = timePtr(row.NextAttemptAt)
+		}
+		deliveries[i] = delivery
+	}
+	return deliveries, nil
+}
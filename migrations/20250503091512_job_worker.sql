@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE jobs ADD COLUMN language VARCHAR(64) NOT NULL DEFAULT 'English';
+ALTER TABLE jobs ADD COLUMN locked_at TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE jobs DROP COLUMN locked_at;
+ALTER TABLE jobs DROP COLUMN language;
+-- +goose StatementEnd
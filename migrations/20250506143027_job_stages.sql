@@ -0,0 +1,56 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE jobs ADD COLUMN stage VARCHAR(32) NOT NULL DEFAULT 'queued';
+ALTER TABLE jobs ADD COLUMN error_reason TEXT;
+ALTER TABLE jobs ADD COLUMN attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE jobs ADD COLUMN started_at TIMESTAMP;
+ALTER TABLE jobs ADD COLUMN finished_at TIMESTAMP;
+ALTER TABLE jobs ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- job_status: 0 article generated, 1 quiz generated, 2 error, 3 pending, 4 running
+UPDATE jobs j SET
+    stage = CASE
+        WHEN EXISTS (SELECT 1 FROM quizzes q WHERE q.pod_id = j.pod_id) THEN 'done'
+        WHEN j.job_status IN (3, 4) THEN 'queued'
+        ELSE 'failed'
+    END,
+    error_reason = CASE
+        WHEN EXISTS (SELECT 1 FROM quizzes q WHERE q.pod_id = j.pod_id) THEN NULL
+        WHEN j.job_status IN (3, 4) THEN NULL
+        ELSE 'generation did not complete'
+    END,
+    started_at = j.locked_at,
+    created_at = COALESCE((SELECT p.created_at FROM pods p WHERE p.id = j.pod_id), j.created_at),
+    -- a done job finished when its quiz was stored; when a failed job ended was never recorded
+    finished_at = (SELECT MAX(q.created_at) FROM quizzes q WHERE q.pod_id = j.pod_id);
+UPDATE jobs SET updated_at = COALESCE(finished_at, started_at, created_at);
+
+CREATE INDEX IF NOT EXISTS jobs_stage_idx ON jobs (stage, id);
+
+ALTER TABLE jobs DROP COLUMN job_status;
+ALTER TABLE jobs DROP COLUMN locked_at;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE jobs ADD COLUMN job_status INT NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN locked_at TIMESTAMP;
+UPDATE jobs SET
+    job_status = CASE stage
+        WHEN 'done' THEN 1
+        WHEN 'failed' THEN 2
+        WHEN 'queued' THEN 3
+        ELSE 4
+    END,
+    locked_at = started_at;
+
+DROP INDEX IF EXISTS jobs_stage_idx;
+ALTER TABLE jobs DROP COLUMN updated_at;
+ALTER TABLE jobs DROP COLUMN finished_at;
+ALTER TABLE jobs DROP COLUMN started_at;
+ALTER TABLE jobs DROP COLUMN created_at;
+ALTER TABLE jobs DROP COLUMN attempts;
+ALTER TABLE jobs DROP COLUMN error_reason;
+ALTER TABLE jobs DROP COLUMN stage;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE jobs ADD COLUMN last_error TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE jobs SET stage = 'failed' WHERE stage = 'dead_letter';
+ALTER TABLE jobs DROP COLUMN last_error;
+-- +goose StatementEnd
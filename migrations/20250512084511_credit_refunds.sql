@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE jobs ADD COLUMN charged_credits INT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS credit_refunds (
+    id SERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    pod_id INT NOT NULL REFERENCES pods(id),
+    job_id INT NOT NULL UNIQUE REFERENCES jobs(id),
+    amount INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS credit_refunds;
+ALTER TABLE jobs DROP COLUMN charged_credits;
+-- +goose StatementEnd
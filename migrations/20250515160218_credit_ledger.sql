@@ -0,0 +1,59 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS credit_ledger (
+    id SERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    amount INT NOT NULL,
+    reason VARCHAR(32) NOT NULL,
+    pod_id INT REFERENCES pods(id),
+    job_id INT REFERENCES jobs(id),
+    note TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS credit_ledger_user_idx ON credit_ledger (user_id, id);
+CREATE UNIQUE INDEX IF NOT EXISTS credit_ledger_refund_job_idx ON credit_ledger (job_id) WHERE reason = 'refund';
+
+-- Rebuild history from what is known: charged jobs and refunds. Whatever the
+-- current balance does not explain becomes the opening grant.
+INSERT INTO credit_ledger (user_id, amount, reason, note, created_at)
+SELECT u.user_id,
+       u.credits
+         + COALESCE((SELECT SUM(j.charged_credits) FROM jobs j INNER JOIN pods p ON p.id = j.pod_id WHERE p.created_by = u.user_id), 0)
+         - COALESCE((SELECT SUM(r.amount) FROM credit_refunds r WHERE r.user_id = u.user_id), 0),
+       'initial_grant',
+       'opening balance',
+       COALESCE((SELECT MIN(p.created_at) FROM pods p WHERE p.created_by = u.user_id), CURRENT_TIMESTAMP)
+FROM usage u;
+
+INSERT INTO credit_ledger (user_id, amount, reason, pod_id, job_id, created_at)
+SELECT p.created_by, -j.charged_credits, 'pod_debit', j.pod_id, j.id, j.created_at
+FROM jobs j
+INNER JOIN pods p ON p.id = j.pod_id
+WHERE j.charged_credits > 0
+ORDER BY j.id;
+
+INSERT INTO credit_ledger (user_id, amount, reason, pod_id, job_id, created_at)
+SELECT user_id, amount, 'refund', pod_id, job_id, created_at
+FROM credit_refunds
+ORDER BY id;
+
+DROP TABLE IF EXISTS credit_refunds;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS credit_refunds (
+    id SERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    pod_id INT NOT NULL REFERENCES pods(id),
+    job_id INT NOT NULL UNIQUE REFERENCES jobs(id),
+    amount INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO credit_refunds (user_id, pod_id, job_id, amount, created_at)
+SELECT user_id, pod_id, job_id, amount, created_at
+FROM credit_ledger
+WHERE reason = 'refund';
+
+DROP TABLE IF EXISTS credit_ledger;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE pods ADD COLUMN language VARCHAR(64) NOT NULL DEFAULT 'English';
+ALTER TABLE pods ADD COLUMN source_pod_id INT REFERENCES pods(id);
+
+UPDATE pods p SET language = j.language
+FROM jobs j
+WHERE j.pod_id = p.id;
+
+CREATE INDEX IF NOT EXISTS pods_link_language_idx ON pods (link, language);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS pods_link_language_idx;
+ALTER TABLE pods DROP COLUMN source_pod_id;
+ALTER TABLE pods DROP COLUMN language;
+-- +goose StatementEnd
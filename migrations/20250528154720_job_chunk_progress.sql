@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE jobs ADD COLUMN chunks_done INT NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN chunks_total INT NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE jobs DROP COLUMN chunks_total;
+ALTER TABLE jobs DROP COLUMN chunks_done;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS llm_calls (
+    id SERIAL PRIMARY KEY,
+    pod_id INT REFERENCES pods(id) ON DELETE SET NULL,
+    job_id INT REFERENCES jobs(id) ON DELETE SET NULL,
+    user_id VARCHAR(128) NOT NULL,
+    model VARCHAR(128) NOT NULL,
+    purpose VARCHAR(64) NOT NULL,
+    input_tokens INT NOT NULL DEFAULT 0,
+    output_tokens INT NOT NULL DEFAULT 0,
+    total_tokens INT NOT NULL DEFAULT 0,
+    latency_ms INT NOT NULL,
+    error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS llm_calls_pod_id_idx ON llm_calls (pod_id);
+CREATE INDEX IF NOT EXISTS llm_calls_user_id_created_at_idx ON llm_calls (user_id, created_at);
+CREATE INDEX IF NOT EXISTS llm_calls_created_at_idx ON llm_calls (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS llm_calls;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS prompt_templates (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(32) NOT NULL,
+    version VARCHAR(64) NOT NULL,
+    body TEXT NOT NULL DEFAULT '',
+    weight INT NOT NULL DEFAULT 0 CHECK (weight >= 0),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (kind, version)
+);
+
+ALTER TABLE articles ADD COLUMN prompt_version VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE quizzes ADD COLUMN prompt_version VARCHAR(64) NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE quizzes DROP COLUMN prompt_version;
+ALTER TABLE articles DROP COLUMN prompt_version;
+DROP TABLE IF EXISTS prompt_templates;
+-- +goose StatementEnd
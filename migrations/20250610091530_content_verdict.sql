@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE pods ADD COLUMN youtube_category VARCHAR(16) NOT NULL DEFAULT '';
+ALTER TABLE pods ADD COLUMN verdict_educational BOOLEAN;
+ALTER TABLE pods ADD COLUMN verdict_category VARCHAR(64);
+ALTER TABLE pods ADD COLUMN verdict_confidence REAL;
+ALTER TABLE pods ADD COLUMN verdict_reason TEXT;
+ALTER TABLE pods ADD COLUMN verdict_source VARCHAR(32);
+ALTER TABLE pods ADD COLUMN classified_at TIMESTAMP;
+
+-- cost is what a job is priced at; charged_credits only becomes cost once the
+-- content passed classification and the user was actually debited.
+ALTER TABLE jobs ADD COLUMN cost INT NOT NULL DEFAULT 0;
+UPDATE jobs SET cost = charged_credits;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE jobs DROP COLUMN cost;
+ALTER TABLE pods DROP COLUMN classified_at;
+ALTER TABLE pods DROP COLUMN verdict_source;
+ALTER TABLE pods DROP COLUMN verdict_reason;
+ALTER TABLE pods DROP COLUMN verdict_confidence;
+ALTER TABLE pods DROP COLUMN verdict_category;
+ALTER TABLE pods DROP COLUMN verdict_educational;
+ALTER TABLE pods DROP COLUMN youtube_category;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A NULL question_count asks for the default of 7-10 questions.
+ALTER TABLE pods ADD COLUMN question_count INT;
+ALTER TABLE pods ADD COLUMN quiz_difficulty VARCHAR(16) NOT NULL DEFAULT 'mixed';
+ALTER TABLE quizzes ADD COLUMN question_count INT;
+ALTER TABLE quizzes ADD COLUMN difficulty VARCHAR(16) NOT NULL DEFAULT 'mixed';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE quizzes DROP COLUMN difficulty;
+ALTER TABLE quizzes DROP COLUMN question_count;
+ALTER TABLE pods DROP COLUMN quiz_difficulty;
+ALTER TABLE pods DROP COLUMN question_count;
+-- +goose StatementEnd
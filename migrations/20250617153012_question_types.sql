@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- answer holds the type specific answer, e.g. {"index": 2} for single_choice
+-- or {"indices": [0, 3]} for multiple_select. correct_option is only kept for
+-- single_choice questions.
+ALTER TABLE questions ADD COLUMN question_type VARCHAR(32) NOT NULL DEFAULT 'single_choice';
+ALTER TABLE questions ADD COLUMN answer JSONB;
+UPDATE questions SET answer = jsonb_build_object('index', correct_option);
+ALTER TABLE questions ALTER COLUMN answer SET NOT NULL;
+ALTER TABLE questions ALTER COLUMN correct_option DROP NOT NULL;
+ALTER TABLE questions ALTER COLUMN options SET DEFAULT '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM questions WHERE question_type <> 'single_choice';
+ALTER TABLE questions ALTER COLUMN options DROP DEFAULT;
+ALTER TABLE questions ALTER COLUMN correct_option SET NOT NULL;
+ALTER TABLE questions DROP COLUMN answer;
+ALTER TABLE questions DROP COLUMN question_type;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- segments is the ordered array of {"text", "start_ms", "duration_ms"} the
+-- provider returned. Providers without timing leave both at 0.
+CREATE TABLE IF NOT EXISTS transcripts (
+    id SERIAL PRIMARY KEY,
+    pod_id INT NOT NULL REFERENCES pods(id),
+    provider VARCHAR(32) NOT NULL,
+    language VARCHAR(16) NOT NULL DEFAULT '',
+    segments JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS transcripts_pod_id_idx ON transcripts (pod_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS transcripts;
+-- +goose StatementEnd
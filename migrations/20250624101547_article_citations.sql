@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- citations are the video moments the article sections were written from,
+-- as an array of {"heading", "section", "start_ms", "end_ms"}.
+ALTER TABLE articles ADD COLUMN citations JSONB NOT NULL DEFAULT '[]';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE articles DROP COLUMN citations;
+-- +goose StatementEnd
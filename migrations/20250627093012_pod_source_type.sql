@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- source_type is what the pod was generated from: a youtube video, an
+-- uploaded media file, pasted text, a PDF or a web page.
+ALTER TABLE pods ADD COLUMN source_type VARCHAR(16) NOT NULL DEFAULT 'youtube'
+    CHECK (source_type IN ('youtube', 'media', 'text', 'pdf', 'url'));
+UPDATE pods SET source_type = 'media' WHERE link LIKE 'media:%';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE pods DROP COLUMN source_type;
+-- +goose StatementEnd
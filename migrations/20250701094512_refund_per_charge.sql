@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A requeued job is charged again, so it may need refunding more than once.
+-- Refunds zero jobs.charged_credits instead of being unique per job.
+DROP INDEX IF EXISTS credit_ledger_refund_job_idx;
+UPDATE jobs j
+SET charged_credits = 0
+WHERE EXISTS (SELECT 1 FROM credit_ledger l WHERE l.job_id = j.id AND l.reason = 'refund');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE jobs j
+SET charged_credits = l.amount
+FROM credit_ledger l
+WHERE l.job_id = j.id AND l.reason = 'refund' AND j.charged_credits = 0;
+CREATE UNIQUE INDEX IF NOT EXISTS credit_ledger_refund_job_idx ON credit_ledger (job_id) WHERE reason = 'refund';
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- attempts counts stage retries; claims counts how often a worker picked the
+-- job up, which is what decides when a stale job is given up on.
+ALTER TABLE jobs ADD COLUMN claims INT NOT NULL DEFAULT 0;
+UPDATE jobs SET claims = 1 WHERE started_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE jobs DROP COLUMN claims;
+-- +goose StatementEnd
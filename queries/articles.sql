@@ -1,9 +1,20 @@
--- name: InsertArticle :exec
-INSERT INTO articles (pod_id, article_text)
-VALUES ($1, $2);
+-- name: InsertArticle :one
+INSERT INTO articles (pod_id, article_text, prompt_version, notes_prompt_version, citations)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id;
 
 -- name: GetArticleByPodId :one
-SELECT article_text FROM articles WHERE pod_id = $1 LIMIT 1;
+-- Retries and reuse can store more than one article for a pod; the newest wins.
+SELECT article_text, citations FROM articles WHERE pod_id = $1 ORDER BY id DESC LIMIT 1;
 
 -- name: GetArticlePodInfo :one
-SELECT p.created_by,p.is_public FROM articles a INNER JOIN pods p ON a.pod_id = p.id WHERE a.pod_id = $1 LIMIT 1;
\ No newline at end of file
+SELECT p.created_by,p.is_public FROM articles a INNER JOIN pods p ON a.pod_id = p.id WHERE a.pod_id = $1 LIMIT 1;
+
+-- name: CloneArticle :one
+INSERT INTO articles (pod_id, article_text, prompt_version, notes_prompt_version, citations)
+SELECT sqlc.arg(pod_id), a.article_text, a.prompt_version, a.notes_prompt_version, a.citations
+FROM articles a
+WHERE a.pod_id = sqlc.arg(source_pod_id)
+ORDER BY a.id DESC
+LIMIT 1
+RETURNING id;
@@ -1,19 +1,124 @@
 -- name: InsertJob :one
-INSERT INTO jobs(pod_id)
-VALUES($1)
+INSERT INTO jobs(pod_id, language, cost)
+VALUES($1, $2, $3)
 RETURNING id;
 
--- name: GetJobStatusByPodID :one
-SELECT job_status 
-FROM jobs 
-WHERE pod_id = $1;
+-- name: GetJobByID :one
+SELECT *
+FROM jobs
+WHERE id = $1;
+
+-- name: GetLatestJobByPodID :one
+SELECT *
+FROM jobs
+WHERE pod_id = $1
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: UpdateJobStage :exec
+UPDATE jobs
+SET stage = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: UpdateJobChunkProgress :exec
+UPDATE jobs
+SET chunks_done = $2, chunks_total = $3, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: FinishJob :exec
+UPDATE jobs
+SET stage = $2,
+    error_reason = $3,
+    last_error = COALESCE(sqlc.narg(last_error), last_error),
+    finished_at = CURRENT_TIMESTAMP,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: ClaimJob :one
+UPDATE jobs j
+SET stage = sqlc.arg(new_stage),
+    claims = j.claims + 1,
+    started_at = CURRENT_TIMESTAMP,
+    updated_at = CURRENT_TIMESTAMP
+FROM pods p
+WHERE p.id = j.pod_id AND j.id = (
+    SELECT q.id FROM jobs q
+    WHERE q.stage = sqlc.arg(stage)
+    ORDER BY q.id
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING j.id, j.pod_id, j.language, j.cost, j.charged_credits, p.link, p.created_by, p.question_count, p.quiz_difficulty;
 
--- name: GetJobStatusByID :one
-SELECT job_status 
-FROM jobs 
+-- name: RecordJobRetry :exec
+UPDATE jobs
+SET attempts = attempts + 1, last_error = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: ListJobsByStage :many
+SELECT j.id, j.pod_id, j.language, j.stage, j.error_reason, j.last_error, j.attempts, j.claims,
+       j.created_at, j.started_at, j.finished_at, p.link, p.created_by
+FROM jobs j
+INNER JOIN pods p ON p.id = j.pod_id
+WHERE j.stage = $1
+ORDER BY j.id DESC
+LIMIT $2 OFFSET $3;
+
+-- name: LockJobPod :one
+SELECT p.id FROM pods p INNER JOIN jobs j ON j.pod_id = p.id WHERE j.id = $1 FOR UPDATE OF p;
+
+-- name: HasNewerPodJob :one
+SELECT EXISTS (
+    SELECT 1 FROM jobs j INNER JOIN jobs n ON n.pod_id = j.pod_id AND n.id > j.id WHERE j.id = $1
+);
+
+-- name: RequeueJob :one
+-- charged_credits is kept: failed jobs were refunded, which zeroed it, so
+-- the rerun is charged again unless the refund never happened. Only the
+-- latest job of a pod is requeued, as a newer one already reruns the pod.
+UPDATE jobs
+SET stage = sqlc.arg(queued_stage),
+    error_reason = NULL,
+    last_error = NULL,
+    attempts = 0,
+    claims = 0,
+    started_at = NULL,
+    finished_at = NULL,
+    updated_at = CURRENT_TIMESTAMP
+WHERE jobs.id = sqlc.arg(id) AND jobs.stage = ANY(sqlc.arg(from_stages)::text[])
+  AND NOT EXISTS (SELECT 1 FROM jobs n WHERE n.pod_id = jobs.pod_id AND n.id > jobs.id)
+RETURNING id;
+
+-- name: ReleaseJob :exec
+UPDATE jobs
+SET stage = $2,
+    claims = GREATEST(claims - 1, 0),
+    started_at = NULL,
+    last_error = $3,
+    updated_at = CURRENT_TIMESTAMP
 WHERE id = $1;
 
--- name: UpdateJobStatusByID :exec
+-- name: RequeueStaleJobs :many
 UPDATE jobs
-SET job_status = $2
-WHERE id = $1;
\ No newline at end of file
+SET stage = sqlc.arg(queued_stage),
+    started_at = NULL,
+    last_error = sqlc.arg(last_error),
+    updated_at = CURRENT_TIMESTAMP
+WHERE stage = ANY(sqlc.arg(stages)::text[])
+  AND updated_at < CURRENT_TIMESTAMP - (sqlc.arg(stale_seconds)::int * INTERVAL '1 second')
+  AND claims < sqlc.arg(max_claims)
+RETURNING id;
+
+-- name: FailStaleJobs :many
+UPDATE jobs j
+SET stage = sqlc.arg(failed_stage),
+    error_reason = sqlc.arg(error_reason),
+    last_error = sqlc.arg(last_error),
+    finished_at = CURRENT_TIMESTAMP,
+    updated_at = CURRENT_TIMESTAMP
+FROM pods p
+WHERE p.id = j.pod_id
+  AND j.stage = ANY(sqlc.arg(stages)::text[])
+  AND j.updated_at < CURRENT_TIMESTAMP - (sqlc.arg(stale_seconds)::int * INTERVAL '1 second')
+  AND j.claims >= sqlc.arg(max_claims)
+RETURNING j.id, j.pod_id, j.language, j.cost, j.charged_credits, p.link, p.created_by, p.question_count, p.quiz_difficulty;
@@ -0,0 +1,48 @@
+-- name: InsertLLMCall :exec
+INSERT INTO llm_calls(pod_id, job_id, user_id, model, purpose, input_tokens, output_tokens, total_tokens, latency_ms, error)
+VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);
+
+-- name: LLMUsageByPod :many
+SELECT l.pod_id,
+       COUNT(*)::int AS calls,
+       SUM(l.input_tokens)::bigint AS input_tokens,
+       SUM(l.output_tokens)::bigint AS output_tokens,
+       SUM(l.total_tokens)::bigint AS total_tokens,
+       AVG(l.latency_ms)::int AS avg_latency_ms,
+       (SELECT COALESCE(SUM(j.charged_credits), 0) FROM jobs j WHERE j.pod_id = l.pod_id)::bigint AS charged_credits
+FROM llm_calls l
+WHERE l.pod_id IS NOT NULL
+  AND (sqlc.narg(user_id)::text IS NULL OR l.user_id = sqlc.narg(user_id))
+  AND (sqlc.narg(pod_id)::int IS NULL OR l.pod_id = sqlc.narg(pod_id))
+  AND l.created_at >= sqlc.arg(from_time) AND l.created_at < sqlc.arg(to_time)
+GROUP BY l.pod_id
+ORDER BY l.pod_id DESC
+LIMIT sqlc.arg(row_limit) OFFSET sqlc.arg(row_offset);
+
+-- name: LLMUsageByUser :many
+SELECT l.user_id,
+       COUNT(*)::int AS calls,
+       SUM(l.input_tokens)::bigint AS input_tokens,
+       SUM(l.output_tokens)::bigint AS output_tokens,
+       SUM(l.total_tokens)::bigint AS total_tokens,
+       AVG(l.latency_ms)::int AS avg_latency_ms
+FROM llm_calls l
+WHERE (sqlc.narg(user_id)::text IS NULL OR l.user_id = sqlc.narg(user_id))
+  AND l.created_at >= sqlc.arg(from_time) AND l.created_at < sqlc.arg(to_time)
+GROUP BY l.user_id
+ORDER BY SUM(l.total_tokens) DESC
+LIMIT sqlc.arg(row_limit) OFFSET sqlc.arg(row_offset);
+
+-- name: LLMUsageByDay :many
+SELECT date_trunc('day', l.created_at)::date AS day,
+       COUNT(*)::int AS calls,
+       SUM(l.input_tokens)::bigint AS input_tokens,
+       SUM(l.output_tokens)::bigint AS output_tokens,
+       SUM(l.total_tokens)::bigint AS total_tokens,
+       AVG(l.latency_ms)::int AS avg_latency_ms
+FROM llm_calls l
+WHERE (sqlc.narg(user_id)::text IS NULL OR l.user_id = sqlc.narg(user_id))
+  AND l.created_at >= sqlc.arg(from_time) AND l.created_at < sqlc.arg(to_time)
+GROUP BY day
+ORDER BY day DESC
+LIMIT sqlc.arg(row_limit) OFFSET sqlc.arg(row_offset);
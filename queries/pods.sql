@@ -2,8 +2,8 @@
 select * from pods where link = $1;
 
 -- name: InsertPod :one
-INSERT INTO pods (link,title,created_by)
-VALUES ($1,$2,$3)
+INSERT INTO pods (link,title,created_by,language,source_pod_id,youtube_category,question_count,quiz_difficulty,source_type)
+VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
 RETURNING id;
 
 
@@ -13,5 +13,51 @@ SELECT * FROM pods WHERE created_by = $1;
 -- name: UpdatePodIsPublic :exec
 UPDATE pods SET is_public = $1 WHERE id = $2;
 
+-- name: GetPodByID :one
+SELECT * FROM pods WHERE id = $1;
+
+-- name: LockPod :exec
+SELECT id FROM pods WHERE id = $1 FOR UPDATE;
+
 -- name: GetPodOwner :one
 SELECT created_by,is_public FROM pods WHERE id = $1;
+
+-- name: GetPodArtifactIDs :one
+SELECT
+    COALESCE((SELECT a.id FROM articles a WHERE a.pod_id = sqlc.arg(pod_id) ORDER BY a.id DESC LIMIT 1), 0)::int AS article_id,
+    COALESCE((SELECT q.id FROM quizzes q WHERE q.pod_id = sqlc.arg(pod_id) ORDER BY q.id DESC LIMIT 1), 0)::int AS quiz_id;
+
+-- name: GetReusablePod :one
+SELECT p.id
+FROM pods p
+INNER JOIN jobs j ON j.pod_id = p.id
+WHERE p.link = $1
+  AND p.language = $2
+  AND p.question_count IS NOT DISTINCT FROM sqlc.narg(question_count)
+  AND p.quiz_difficulty = sqlc.arg(quiz_difficulty)
+  AND j.stage = sqlc.arg(done_stage)
+  AND EXISTS (SELECT 1 FROM articles a WHERE a.pod_id = p.id)
+  AND EXISTS (SELECT 1 FROM quizzes q WHERE q.pod_id = p.id)
+ORDER BY p.id DESC
+LIMIT 1;
+
+-- name: SetPodVerdict :exec
+UPDATE pods
+SET verdict_educational = $2,
+    verdict_category = $3,
+    verdict_confidence = $4,
+    verdict_reason = $5,
+    verdict_source = $6,
+    classified_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: CopyPodVerdict :exec
+UPDATE pods p
+SET verdict_educational = s.verdict_educational,
+    verdict_category = s.verdict_category,
+    verdict_confidence = s.verdict_confidence,
+    verdict_reason = s.verdict_reason,
+    verdict_source = s.verdict_source,
+    classified_at = s.classified_at
+FROM pods s
+WHERE s.id = sqlc.arg(source_pod_id) AND p.id = sqlc.arg(pod_id);
@@ -0,0 +1,15 @@
+-- name: ListPromptTemplates :many
+SELECT *
+FROM prompt_templates
+ORDER BY kind, id;
+
+-- name: InsertPromptTemplate :one
+INSERT INTO prompt_templates(kind, version, body, weight)
+VALUES($1, $2, $3, $4)
+RETURNING *;
+
+-- name: UpdatePromptTemplateWeight :one
+UPDATE prompt_templates
+SET weight = $2
+WHERE id = $1
+RETURNING *;
@@ -1,7 +1,9 @@
--- name: InsertQuestion :one
-INSERT INTO questions (quizzes_id, question_text, options, correct_option)
-VALUES ($1, $2, $3, $4)
-RETURNING id;
-
 -- name: GetQuestionByQuizId :many
-SELECT id,question_text,options,correct_option FROM questions WHERE quizzes_id = $1;
\ No newline at end of file
+SELECT id,question_type,question_text,options,correct_option,answer,explanation FROM questions WHERE quizzes_id = $1;
+
+-- name: CloneQuestions :exec
+INSERT INTO questions (quizzes_id, question_type, question_text, options, correct_option, answer, explanation)
+SELECT sqlc.arg(quiz_id), q.question_type, q.question_text, q.options, q.correct_option, q.answer, q.explanation
+FROM questions q
+WHERE q.quizzes_id = sqlc.arg(source_quiz_id)
+ORDER BY q.id;
@@ -1,10 +1,32 @@
 -- name: InsertQuiz :one
-INSERT INTO quizzes (pod_id)
-VALUES ($1)
+INSERT INTO quizzes (pod_id, prompt_version, question_count, difficulty)
+VALUES ($1, $2, $3, $4)
 RETURNING id;
 
+-- name: InsertQuizWithQuestions :one
+-- questions is a JSON array of store.Question; the quiz only exists once all
+-- of its questions are stored.
+WITH quiz AS (
+    INSERT INTO quizzes (pod_id, prompt_version, question_count, difficulty)
+    VALUES (sqlc.arg(pod_id), sqlc.arg(prompt_version), sqlc.arg(question_count), sqlc.arg(difficulty))
+    RETURNING id
+), inserted AS (
+    INSERT INTO questions (quizzes_id, question_type, question_text, options, correct_option, answer, explanation)
+    SELECT quiz.id,
+           q.question->>'type',
+           q.question->>'question',
+           ARRAY(SELECT jsonb_array_elements_text(q.question->'options')),
+           (q.question->>'correct_answer_index')::int,
+           NULLIF(q.question->'answer', 'null'::jsonb),
+           q.question->>'explanation'
+    FROM quiz, jsonb_array_elements(sqlc.arg(questions)::jsonb) WITH ORDINALITY AS q(question, position)
+    ORDER BY q.position
+)
+SELECT id FROM quiz;
+
 -- name: GetQuizByPodId :one
-SELECT id,pod_id FROM quizzes WHERE pod_id = $1 LIMIT 1;
+-- Retries and reuse can store more than one quiz for a pod; the newest wins.
+SELECT id,pod_id,prompt_version,question_count,difficulty FROM quizzes WHERE pod_id = $1 ORDER BY id DESC LIMIT 1;
 
 -- name: GetQuizPodInfo :one
 SELECT p.created_by,p.is_public FROM quizzes q INNER JOIN pods p ON q.pod_id = p.id WHERE q.pod_id = $1 LIMIT 1;
\ No newline at end of file
@@ -0,0 +1,14 @@
+-- name: InsertTranscript :exec
+INSERT INTO transcripts (pod_id, provider, language, segments)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (pod_id) DO NOTHING;
+
+-- name: GetTranscriptByPodId :one
+SELECT provider, language, segments, created_at FROM transcripts WHERE pod_id = $1;
+
+-- name: CloneTranscript :exec
+INSERT INTO transcripts (pod_id, provider, language, segments)
+SELECT sqlc.arg(pod_id), t.provider, t.language, t.segments
+FROM transcripts t
+WHERE t.pod_id = sqlc.arg(source_pod_id)
+ON CONFLICT (pod_id) DO NOTHING;
@@ -4,14 +4,92 @@ SELECT credits from usage WHERE user_id = $1;
 -- name: IsCreditExist :one
 SELECT EXISTS(SELECT 1 FROM usage WHERE user_id = $1);
 
--- name: InsertCredit :exec
-INSERT INTO usage (user_id, credits) VALUES ($1, $2);
+-- name: GrantInitialCredit :exec
+WITH entry AS (
+    INSERT INTO credit_ledger (user_id, amount, reason)
+    VALUES ($1, $2, $3)
+    RETURNING user_id, amount
+)
+INSERT INTO usage (user_id, credits)
+SELECT user_id, amount FROM entry;
 
--- name: UpdateCredit :one
-UPDATE usage SET credits = $2 WHERE user_id = $1 RETURNING credits;
+-- name: AddCreditTransaction :one
+WITH entry AS (
+    INSERT INTO credit_ledger (user_id, amount, reason, pod_id, job_id, note)
+    VALUES ($1, $2, $3, $4, $5, $6)
+    RETURNING user_id, amount
+)
+UPDATE usage u
+SET credits = u.credits + entry.amount
+FROM entry
+WHERE u.user_id = entry.user_id
+RETURNING u.credits;
 
--- name: DeleteCredit :exec
-DELETE FROM usage WHERE user_id = $1;
+-- name: ChargeJob :one
+-- The balance is only debited when it covers the cost; the job is locked so
+-- concurrent charges of the same job wait for each other.
+WITH job AS (
+    SELECT j.id, j.pod_id, j.cost
+    FROM jobs j
+    WHERE j.id = sqlc.arg(job_id) AND j.charged_credits = 0 AND j.cost > 0
+    FOR UPDATE
+), debit AS (
+    UPDATE usage u
+    SET credits = u.credits - job.cost
+    FROM job
+    WHERE u.user_id = sqlc.arg(user_id) AND u.credits >= job.cost
+    RETURNING u.credits, job.id, job.pod_id, job.cost
+), charged AS (
+    UPDATE jobs j
+    SET charged_credits = d.cost, updated_at = CURRENT_TIMESTAMP
+    FROM debit d
+    WHERE j.id = d.id
+), entry AS (
+    INSERT INTO credit_ledger (user_id, amount, reason, pod_id, job_id)
+    SELECT sqlc.arg(user_id), -d.cost, 'pod_debit', d.pod_id, d.id
+    FROM debit d
+)
+SELECT credits FROM debit;
 
--- name: DecrementCredit :one
-UPDATE usage SET credits = credits - $1 WHERE user_id = $2 RETURNING credits;
\ No newline at end of file
+-- name: GetJobCharge :one
+SELECT cost, charged_credits FROM jobs WHERE id = $1;
+
+-- name: RefundJob :one
+-- Refunding zeroes the job's charged_credits, so a job is refunded at most
+-- once per charge and is charged again if it is requeued.
+WITH job AS (
+    SELECT j.id, j.pod_id, j.charged_credits
+    FROM jobs j
+    WHERE j.id = sqlc.arg(job_id) AND j.charged_credits > 0
+    FOR UPDATE
+), refunded AS (
+    UPDATE jobs j
+    SET charged_credits = 0, updated_at = CURRENT_TIMESTAMP
+    FROM job
+    WHERE j.id = job.id
+), entry AS (
+    INSERT INTO credit_ledger (user_id, amount, reason, pod_id, job_id)
+    SELECT sqlc.arg(user_id), job.charged_credits, 'refund', job.pod_id, job.id
+    FROM job
+    RETURNING user_id, amount
+)
+UPDATE usage u
+SET credits = u.credits + entry.amount
+FROM entry
+WHERE u.user_id = entry.user_id
+RETURNING entry.amount;
+
+-- name: GetPodKeptCredits :one
+SELECT (-COALESCE(SUM(amount), 0))::int AS kept
+FROM credit_ledger
+WHERE pod_id = $1 AND reason IN ('pod_debit', 'refund');
+
+-- name: GetCreditHistory :many
+SELECT id, amount, reason, pod_id, job_id, note, created_at
+FROM credit_ledger
+WHERE user_id = $1
+ORDER BY id DESC
+LIMIT $2 OFFSET $3;
+
+-- name: CountCreditHistory :one
+SELECT COUNT(*) FROM credit_ledger WHERE user_id = $1;
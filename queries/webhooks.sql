@@ -0,0 +1,71 @@
+-- name: InsertWebhook :one
+INSERT INTO webhooks(user_id, url, secret, events)
+VALUES($1, $2, $3, $4)
+RETURNING *;
+
+-- name: ListWebhooksByUser :many
+SELECT *
+FROM webhooks
+WHERE user_id = $1
+ORDER BY id;
+
+-- name: GetWebhookByID :one
+SELECT *
+FROM webhooks
+WHERE id = $1;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE id = $1 AND user_id = $2;
+
+-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries(webhook_id, event, payload)
+SELECT w.id, sqlc.arg(event), sqlc.arg(payload)
+FROM webhooks w
+WHERE w.user_id = sqlc.arg(user_id) AND sqlc.arg(event)::text = ANY(w.events);
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries d
+SET next_attempt_at = CURRENT_TIMESTAMP + (sqlc.arg(lease_seconds)::int * INTERVAL '1 second')
+FROM webhooks w
+WHERE w.id = d.webhook_id AND d.id IN (
+    SELECT q.id FROM webhook_deliveries q
+    WHERE q.status = 'pending' AND q.next_attempt_at <= CURRENT_TIMESTAMP
+    ORDER BY q.next_attempt_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING d.id, d.webhook_id, d.event, d.payload, d.attempts, w.url, w.secret;
+
+-- name: MarkWebhookDelivered :exec
+UPDATE webhook_deliveries
+SET status = 'delivered',
+    attempts = attempts + 1,
+    response_status = $2,
+    last_error = NULL,
+    delivered_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: RetryWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET attempts = attempts + 1,
+    response_status = sqlc.narg(response_status),
+    last_error = sqlc.arg(last_error),
+    next_attempt_at = CURRENT_TIMESTAMP + (sqlc.arg(delay_ms)::int * INTERVAL '1 millisecond')
+WHERE id = sqlc.arg(id);
+
+-- name: FailWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = 'failed',
+    attempts = attempts + 1,
+    response_status = sqlc.narg(response_status),
+    last_error = sqlc.arg(last_error)
+WHERE id = sqlc.arg(id);
+
+-- name: ListWebhookDeliveries :many
+SELECT id, webhook_id, event, payload, status, attempts, response_status, last_error,
+       next_attempt_at, created_at, delivered_at
+FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY id DESC
+LIMIT $2 OFFSET $3;